@@ -0,0 +1,408 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"wb-tech-l0/internal/application/ports"
+	"wb-tech-l0/internal/application/usecase"
+	"wb-tech-l0/internal/auth"
+	"wb-tech-l0/internal/config"
+	"wb-tech-l0/internal/delivery/kafka"
+	"wb-tech-l0/internal/delivery/ndjson"
+	"wb-tech-l0/internal/export"
+	"wb-tech-l0/internal/logging"
+	"wb-tech-l0/internal/models"
+	"wb-tech-l0/internal/projection"
+	"wb-tech-l0/internal/repository/cache"
+	"wb-tech-l0/internal/repository/database"
+	"wb-tech-l0/internal/validator"
+)
+
+const commandsUsage = `usage: main [command] [flags]
+
+Without a command the service is started.
+
+Commands:
+  rotate-keys     re-encrypt customer data under the primary encryption key
+  erase-customer  anonymise the personal data of a customer's orders
+  replay          re-ingest messages of a Kafka topic from an offset or time
+  ingest          ingest orders from an NDJSON file or stdin
+  export          export orders as NDJSON, CSV or Parquet
+  rebuild-rollups recompute the daily analytics rollups from the order tables
+`
+
+// runCommand runs a maintenance command and returns the exit code.
+func runCommand(ctx context.Context, cfg *config.Config, logger *slog.Logger, args []string) int {
+	var err error
+	switch args[0] {
+	case "rotate-keys":
+		err = rotateKeys(ctx, cfg, logger, args[1:])
+	case "erase-customer":
+		err = eraseCustomer(ctx, cfg, logger, args[1:])
+	case "replay":
+		err = replay(ctx, cfg, logger, args[1:])
+	case "ingest":
+		err = ingest(ctx, cfg, logger, args[1:])
+	case "export":
+		err = exportOrders(ctx, cfg, logger, args[1:])
+	case "rebuild-rollups":
+		err = rebuildRollups(ctx, cfg, logger, args[1:])
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(os.Stdout, commandsUsage)
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", args[0], commandsUsage)
+		return 2
+	}
+
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		logger.Error("command failed", "command", args[0], logging.Err(err))
+		return 1
+	}
+	return 0
+}
+
+// rotateKeys re-encrypts delivery rows and event payloads that are stored
+// in clear text or under a non-primary key. Cached orders are not touched: they expire
+// after cache_ttl, so keep the old key in the keyring at least that long.
+func rotateKeys(ctx context.Context, cfg *config.Config, logger *slog.Logger, args []string) error {
+	flags := flag.NewFlagSet("rotate-keys", flag.ContinueOnError)
+	batchSize := flags.Int("batch-size", 500, "rows re-encrypted per transaction")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	keys := newKeyring(cfg, logger)
+	if keys == nil {
+		return errors.New("set PII_ENCRYPTION_KEYFILE or PII_ENCRYPTION_KEYS")
+	}
+
+	db := newDatabase(cfg.PostgresDSN, nil, keys, logger)
+	defer closeDatabase(db, logger)
+
+	result, err := db.RotateEncryptionKeys(ctx, *batchSize)
+	logger.Info("key rotation finished",
+		"primary_key", keys.Primary(),
+		"scanned", result.Scanned,
+		"updated", result.Updated,
+	)
+	return err
+}
+
+// eraseCustomer runs a customer data erasure and prints the report as JSON.
+func eraseCustomer(ctx context.Context, cfg *config.Config, logger *slog.Logger, args []string) error {
+	flags := flag.NewFlagSet("erase-customer", flag.ContinueOnError)
+	customerID := flags.String("customer-id", "", "customer whose personal data is erased (required)")
+	dryRun := flags.Bool("dry-run", false, "only report what would change")
+	requestedBy := flags.String("requested-by", "", "who asked for the erasure, recorded in the audit log (required)")
+	reason := flags.String("reason", "", "reference of the request, e.g. a ticket number")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *customerID == "" || *requestedBy == "" {
+		flags.Usage()
+		return errors.New("-customer-id and -requested-by are required")
+	}
+
+	redisClient := newRedisClient(cfg.RedisAddr, logger)
+	defer redisClient.Close()
+
+	keys := newKeyring(cfg, logger)
+	db := newDatabase(cfg.PostgresDSN, cache.NewOrderCache(redisClient, cfg.CacheTTL, keys, logger), keys, logger)
+	defer closeDatabase(db, logger)
+
+	report, err := usecase.NewErasureService(db).EraseCustomer(ctx, ports.ErasureRequest{
+		CustomerID:  *customerID,
+		RequestedBy: "cli:" + *requestedBy,
+		Reason:      *reason,
+		DryRun:      *dryRun,
+	})
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+// replay feeds past messages of a topic through the normal processing
+// and prints the report as JSON. It can run next to the service: no
+// consumer group offsets are touched, and saves lock the stored order and
+// are retried after a conflict with a concurrent save, so replayed and
+// live versions of an order are applied one after the other.
+func replay(ctx context.Context, cfg *config.Config, logger *slog.Logger, args []string) error {
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	topic := flags.String("topic", cfg.KafkaTopic, "topic to replay, e.g. a dead-letter topic")
+	partition := flags.Int("partition", 0, "partition to replay")
+	fromOffset := flags.Int64("from-offset", -1, "first offset to replay")
+	from := flags.String("from", "", "replay messages produced at or after this RFC 3339 time")
+	to := flags.String("to", "", "stop before messages produced at or after this RFC 3339 time")
+	dryRun := flags.Bool("dry-run", false, "only decode and validate, and report")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *fromOffset >= 0 && *from != "" {
+		flags.Usage()
+		return errors.New("-from-offset and -from are mutually exclusive")
+	}
+
+	opts := kafka.ReplayOptions{Topic: *topic, Partition: int32(*partition), FromOffset: *fromOffset, DryRun: *dryRun}
+	var err error
+	if opts.From, err = parseTimeFlag("from", *from); err != nil {
+		return err
+	}
+	if opts.To, err = parseTimeFlag("to", *to); err != nil {
+		return err
+	}
+
+	// A dry run only reads the topic and needs no use case; Replay refuses
+	// to save without one.
+	var orderUC ports.OrderUseCase
+	if !*dryRun {
+		redisClient := newRedisClient(cfg.RedisAddr, logger)
+		defer redisClient.Close()
+
+		keys := newKeyring(cfg, logger)
+		db := newDatabase(cfg.PostgresDSN, cache.NewOrderCache(redisClient, cfg.CacheTTL, keys, logger), keys, logger)
+		defer closeDatabase(db, logger)
+		orderUC = usecase.NewOrderService(db)
+	}
+
+	consumer, err := kafka.NewReplayConsumer(cfg.KafkaBrokers, orderUC, newDecoder(cfg, logger), logger)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := consumer.Close(); err != nil {
+			logger.Error("failed to close Kafka consumer", logging.Err(err))
+		}
+	}()
+
+	report, err := consumer.Replay(ctx, opts)
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if encErr := enc.Encode(report); encErr != nil && err == nil {
+		err = encErr
+	}
+	return err
+}
+
+// ingest saves the orders of an NDJSON file, or of stdin, through the
+// same validation and use case as the Kafka consumer, and prints the
+// final progress as JSON.
+func ingest(ctx context.Context, cfg *config.Config, logger *slog.Logger, args []string) error {
+	flags := flag.NewFlagSet("ingest", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: main ingest [flags] [file]\n\nReads stdin when file is omitted or \"-\".")
+		flags.PrintDefaults()
+	}
+	fromLine := flags.Int("from-line", 1, "first line to ingest, to resume an interrupted run")
+	errorsPath := flags.String("errors", "", "append rejected lines with their errors to this NDJSON file")
+	progress := flags.Duration("progress", 10*time.Second, "how often to log progress")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return errors.New("at most one file can be ingested")
+	}
+
+	name := flags.Arg(0)
+	input := io.Reader(os.Stdin)
+	if name != "" && name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		input = f
+	} else {
+		name = "stdin"
+	}
+
+	sourceCfg := ndjson.Config{FromLine: *fromLine, ProgressInterval: *progress}
+	if *errorsPath != "" {
+		f, err := os.OpenFile(*errorsPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		sourceCfg.Errors = f
+	}
+
+	redisClient := newRedisClient(cfg.RedisAddr, logger)
+	defer redisClient.Close()
+
+	keys := newKeyring(cfg, logger)
+	db := newDatabase(cfg.PostgresDSN, cache.NewOrderCache(redisClient, cfg.CacheTTL, keys, logger), keys, logger)
+	defer closeDatabase(db, logger)
+
+	source := ndjson.NewSource(input, name, usecase.NewOrderService(db), validator.NewValidator(), sourceCfg, logger)
+	err := source.Run(ctx)
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if encErr := enc.Encode(source.Progress()); encErr != nil && err == nil {
+		err = encErr
+	}
+	if err != nil {
+		return fmt.Errorf("%w; resume with -from-line %d", err, source.Progress().Line+1)
+	}
+	return nil
+}
+
+// exportOrders writes the orders matching the filters to a file, or to
+// stdout, streaming them from the database. Personal data is masked unless
+// -include-pii is given. A failed export removes its incomplete file.
+func exportOrders(ctx context.Context, cfg *config.Config, logger *slog.Logger, args []string) (err error) {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	formatName := flags.String("format", "ndjson", "output format: ndjson, csv or parquet")
+	output := flags.String("o", "", "output file; stdout when empty or \"-\"")
+	includePII := flags.Bool("include-pii", false, "write personal data in clear text")
+	deliveryService := flags.String("delivery-service", "", "only orders of this delivery service")
+	entry := flags.String("entry", "", "only orders with this entry")
+	customerID := flags.String("customer-id", "", "only orders of this customer")
+	from := flags.String("from", "", "only orders created at or after this RFC 3339 time")
+	to := flags.String("to", "", "only orders created before this RFC 3339 time")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	format, err := export.ParseFormat(*formatName)
+	if err != nil {
+		return err
+	}
+	filter := ports.OrderFilter{DeliveryService: *deliveryService, Entry: *entry, CustomerID: *customerID}
+	if filter.CreatedFrom, err = parseTimeFlag("from", *from); err != nil {
+		return err
+	}
+	if filter.CreatedTo, err = parseTimeFlag("to", *to); err != nil {
+		return err
+	}
+
+	out := io.Writer(os.Stdout)
+	if *output != "" && *output != "-" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if closeErr := f.Close(); closeErr != nil && err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				_ = os.Remove(*output)
+			}
+		}()
+		out = f
+	} else {
+		// The orders go to stdout, so the logs must not.
+		if logger, err = logging.New(os.Stderr, logging.Config{Level: cfg.LogLevel, Format: cfg.LogFormat}); err != nil {
+			return err
+		}
+	}
+
+	if *includePII {
+		ctx = auth.WithPrincipal(ctx, &auth.Principal{Subject: "cli", Scopes: []string{auth.ScopePIIRead}})
+	}
+
+	keys := newKeyring(cfg, logger)
+	db := newDatabase(cfg.PostgresDSN, nil, keys, logger)
+	defer closeDatabase(db, logger)
+
+	w, err := export.NewWriter(out, format)
+	if err != nil {
+		return err
+	}
+	exported := 0
+	err = usecase.NewOrderService(db).ExportOrders(ctx, filter, func(o *models.Order) error {
+		exported++
+		return w.Write(projection.Order(ctx, o))
+	})
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		return fmt.Errorf("export stopped after %d orders: %w", exported, err)
+	}
+
+	logger.Info("export finished", "format", format, "orders", exported, "include_pii", *includePII)
+	return nil
+}
+
+// rebuildRollups recomputes the daily rollup tables and prints, as JSON,
+// the rows that differed from the order tables. With -check the tables are
+// only compared and any difference fails the command.
+func rebuildRollups(ctx context.Context, cfg *config.Config, logger *slog.Logger, args []string) error {
+	flags := flag.NewFlagSet("rebuild-rollups", flag.ContinueOnError)
+	checkOnly := flags.Bool("check", false, "only compare the rollups with the order tables, failing on differences")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	db := newDatabase(cfg.PostgresDSN, nil, nil, logger)
+	defer closeDatabase(db, logger)
+
+	run := db.RebuildRollups
+	if *checkOnly {
+		run = db.CheckRollups
+	}
+	check, err := run(ctx)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(check); err != nil {
+		return err
+	}
+	if *checkOnly {
+		if len(check.Mismatches) > 0 {
+			return fmt.Errorf("%d rollup rows differ from the order tables", len(check.Mismatches))
+		}
+		return nil
+	}
+
+	logger.Info("rollups rebuilt",
+		"order_rows", check.OrderRows,
+		"brand_rows", check.BrandRows,
+		"fixed", len(check.Mismatches),
+	)
+	return nil
+}
+
+func parseTimeFlag(name, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("-%s: %w", name, err)
+	}
+	return t, nil
+}
+
+func closeDatabase(db *database.DB, logger *slog.Logger) {
+	sqlDB, err := db.Conn.DB()
+	if err != nil {
+		logger.Error("failed to get sql.DB from GORM", logging.Err(err))
+		return
+	}
+	if err := sqlDB.Close(); err != nil {
+		logger.Error("failed to close DB connection", logging.Err(err))
+	}
+}
@@ -2,7 +2,9 @@ package main
 
 import (
 	"context"
-	"log"
+	"log/slog"
+	"net/http"
+	"os"
 	"os/signal"
 	"sync"
 	"syscall"
@@ -10,86 +12,200 @@ import (
 
 	"wb-tech-l0/cmd/server"
 	"wb-tech-l0/internal/application/usecase"
+	"wb-tech-l0/internal/auth"
+	"wb-tech-l0/internal/delivery/grpcapi"
 	"wb-tech-l0/internal/delivery/kafka"
+	"wb-tech-l0/internal/delivery/webhook"
+	"wb-tech-l0/internal/feed"
+	"wb-tech-l0/internal/fieldcrypt"
+	"wb-tech-l0/internal/logging"
+	"wb-tech-l0/internal/models"
+	"wb-tech-l0/internal/ratelimit"
 	"wb-tech-l0/internal/repository/cache"
 	"wb-tech-l0/internal/repository/database"
+	"wb-tech-l0/internal/schemaregistry"
+	"wb-tech-l0/internal/telemetry"
 
 	"github.com/redis/go-redis/v9"
+	"google.golang.org/grpc"
 )
 
 func main() {
 	cfg := config.Load()
 
+	logger, err := logging.New(os.Stdout, logging.Config{Level: cfg.LogLevel, Format: cfg.LogFormat})
+	if err != nil {
+		slog.Error("invalid logging configuration", logging.Err(err))
+		os.Exit(1)
+	}
+	slog.SetDefault(logger)
+
 	// Root context with OS signal cancellation.
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
+	// Maintenance commands run instead of the service.
+	if len(os.Args) > 1 {
+		code := runCommand(ctx, cfg, logger, os.Args[1:])
+		stop()
+		os.Exit(code)
+	}
+
 	// --- Infrastructure setup ---
 
-	redisClient := newRedisClient(cfg.RedisAddr)
+	shutdownTracing, err := telemetry.Setup(ctx, telemetry.Config{
+		Exporter:     cfg.TracingExporter,
+		OTLPEndpoint: cfg.TracingOTLPEndpoint,
+		OTLPInsecure: cfg.TracingOTLPInsecure,
+		SampleRatio:  cfg.TracingSampleRatio,
+	})
+	if err != nil {
+		fatal(logger, "failed to set up tracing", err)
+	}
+
+	redisClient := newRedisClient(cfg.RedisAddr, logger)
 	defer func() {
 		if err := redisClient.Close(); err != nil {
-			log.Printf("Failed to close Redis client: %v", err)
+			logger.Error("failed to close Redis client", logging.Err(err))
 		}
 	}()
 
-	orderCache := cache.NewOrderCache(redisClient, cfg.CacheTTL)
+	keys := newKeyring(cfg, logger)
+	orderCache := cache.NewOrderCache(redisClient, cfg.CacheTTL, keys, logger)
 
-	db := newDatabase(cfg.PostgresDSN, orderCache)
-	defer func() {
-		sqlDB, err := db.Conn.DB()
-		if err != nil {
-			log.Printf("Failed to get sql.DB from GORM: %v", err)
-			return
-		}
-		if err := sqlDB.Close(); err != nil {
-			log.Printf("Failed to close DB connection: %v", err)
-		}
-	}()
+	db := newDatabase(cfg.PostgresDSN, orderCache, keys, logger)
+	defer closeDatabase(db, logger)
 
 	// --- Application layer ---
 
 	orderRepo := db
-	orderUC := usecase.NewOrderService(orderRepo)
+	orderFeed := feed.NewHub(cfg.StreamBufferSize)
+	orderUC := usecase.NewOrderService(orderRepo, orderFeed)
+	webhookUC := usecase.NewWebhookService(db)
+	erasureUC := usecase.NewErasureService(db, orderFeed)
+	analyticsUC := usecase.NewAnalyticsService(db)
 
 	// --- Delivery / adapters ---
 
-	httpServer := server.NewServer(orderUC)
+	httpOpts := []server.Option{
+		server.WithWebhookUseCase(webhookUC),
+		server.WithErasureUseCase(erasureUC),
+		server.WithAnalyticsUseCase(analyticsUC),
+		server.WithOrderFeed(orderFeed, cfg.StreamHeartbeat),
+		server.WithLogger(logger),
+		server.WithRequestTimeout(cfg.HTTPRequestTimeout),
+		server.WithMaxBodyBytes(cfg.HTTPMaxBodyBytes),
+	}
+	var grpcOpts []grpc.ServerOption
+
+	if cfg.AuthEnabled {
+		authenticator := newAuthenticator(cfg, db, logger)
+		httpOpts = append(httpOpts, server.WithAuth(authenticator, cfg.AuthProtectWeb))
+		grpcOpts = append(grpcOpts, grpcapi.AuthInterceptors(authenticator)...)
+	} else {
+		logger.Warn("authentication is disabled, the API is open to everyone")
+	}
+
+	if cfg.RateLimitEnabled {
+		httpOpts = append(httpOpts, server.WithRateLimiter(newRateLimiter(cfg, redisClient, logger), cfg.RateLimitTrustProxy))
+	}
+
+	httpServer := server.NewServer(orderUC, httpOpts...)
+
+	grpcServer := grpcapi.NewServer(orderUC, orderFeed, grpcOpts...)
 
 	kafkaConsumer, err := kafka.NewConsumer(
 		cfg.KafkaBrokers,
+		kafka.ConsumerConfig{
+			Group:        cfg.KafkaConsumerGroup,
+			Concurrency:  cfg.KafkaConsumerConcurrency,
+			QueueDepth:   cfg.KafkaConsumerQueueDepth,
+			BatchSize:    cfg.KafkaConsumerBatchSize,
+			BatchTimeout: cfg.KafkaConsumerBatchTimeout,
+
+			DeadLetterTopic: cfg.KafkaDeadLetterTopic,
+		},
 		orderUC,
+		newDecoder(cfg, logger),
+		logger,
 	)
 	if err != nil {
-		log.Fatalf("Failed to create Kafka consumer: %v", err)
+		fatal(logger, "failed to create Kafka consumer", err)
 	}
 	defer func() {
 		if err := kafkaConsumer.Close(); err != nil {
-			log.Printf("Failed to close Kafka consumer: %v", err)
+			logger.Error("failed to close Kafka consumer", logging.Err(err))
 		}
 	}()
 
+	outboxProducer, err := kafka.NewOutboxProducer(cfg.KafkaBrokers)
+	if err != nil {
+		fatal(logger, "failed to create outbox producer", err)
+	}
+	outboxRelay := kafka.NewOutboxRelay(db, outboxProducer, cfg.OutboxTopic, cfg.OutboxBatchSize, cfg.OutboxPollInterval, logger)
+	defer func() {
+		if err := outboxRelay.Close(); err != nil {
+			logger.Error("failed to close outbox producer", logging.Err(err))
+		}
+	}()
+
+	webhookDispatcher := webhook.NewDispatcher(db, &http.Client{Timeout: cfg.WebhookTimeout}, webhook.Config{
+		BatchSize:    cfg.WebhookBatchSize,
+		PollInterval: cfg.WebhookPollInterval,
+		MaxAttempts:  cfg.WebhookMaxAttempts,
+		BackoffBase:  cfg.WebhookBackoffBase,
+		BackoffMax:   cfg.WebhookBackoffMax,
+		ClaimTTL:     cfg.WebhookClaimTTL,
+	}, logger)
+
 	// --- Run servers ---
 
 	var wg sync.WaitGroup
-	wg.Add(3)
+	wg.Add(6)
 
 	// HTTP server lifecycle
 	go func() {
 		defer wg.Done()
-		log.Printf("Starting HTTP server on %s", cfg.HTTPAddr)
+		logger.Info("starting HTTP server", "addr", cfg.HTTPAddr)
 		if err := httpServer.Start(cfg.HTTPAddr); err != nil {
 			// http.ErrServerClosed is expected on graceful shutdown
-			log.Printf("HTTP server stopped: %v", err)
+			logger.Info("HTTP server stopped", logging.Err(err))
+		}
+	}()
+
+	// gRPC server lifecycle
+	go func() {
+		defer wg.Done()
+		logger.Info("starting gRPC server", "addr", cfg.GRPCAddr)
+		if err := grpcServer.Start(cfg.GRPCAddr); err != nil {
+			logger.Info("gRPC server stopped", logging.Err(err))
 		}
 	}()
 
 	// Kafka consumer lifecycle
 	go func() {
 		defer wg.Done()
-		log.Printf("Starting Kafka consumer on %s, topic %s", cfg.KafkaBrokers, cfg.KafkaTopic)
-		if err := kafkaConsumer.Start(ctx, cfg.KafkaTopic); err != nil && ctx.Err() == nil {
-			log.Printf("Kafka consumer error: %v", err)
+		logger.Info("starting Kafka consumer", "brokers", cfg.KafkaBrokers, logging.KeyTopic, cfg.KafkaTopic)
+		if err := kafkaConsumer.Source(cfg.KafkaTopic).Run(ctx); err != nil && ctx.Err() == nil {
+			logger.Error("Kafka consumer stopped with error", logging.Err(err))
+		}
+	}()
+
+	// Outbox relay lifecycle
+	go func() {
+		defer wg.Done()
+		logger.Info("starting outbox relay", logging.KeyTopic, cfg.OutboxTopic)
+		if err := outboxRelay.Start(ctx); err != nil {
+			logger.Error("outbox relay stopped with error", logging.Err(err))
+		}
+	}()
+
+	// Webhook dispatcher lifecycle
+	go func() {
+		defer wg.Done()
+		logger.Info("starting webhook dispatcher")
+		if err := webhookDispatcher.Start(ctx); err != nil {
+			logger.Error("webhook dispatcher stopped with error", logging.Err(err))
 		}
 	}()
 
@@ -97,54 +213,167 @@ func main() {
 
 	go func() {
 		defer wg.Done()
-		log.Printf("Filling cache with orders from DB...")
+		logger.Info("filling cache with orders from DB", "count", cfg.CachePreloadCount)
 		if err := orderUC.LoadOrdersToCache(cfg.CachePreloadCount); err != nil {
-			log.Printf("Failed to load orders to cache: %v", err)
+			logger.Error("failed to load orders to cache", logging.Err(err))
 		}
 	}()
 
 	// Graceful shutdown implementation
 
 	<-ctx.Done()
-	log.Println("Shutdown signal received, shutting down...")
+	logger.Info("shutdown signal received, shutting down")
 
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
 	defer cancel()
 
 	if err := httpServer.Shutdown(shutdownCtx); err != nil {
-		log.Printf("HTTP server shutdown error: %v", err)
+		logger.Error("HTTP server shutdown error", logging.Err(err))
+	}
+	if err := grpcServer.Shutdown(shutdownCtx); err != nil {
+		logger.Error("gRPC server shutdown error", logging.Err(err))
 	}
 
 	wg.Wait()
 
-	log.Println("Shutdown complete")
+	// Flush spans of the last processed messages and requests.
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		logger.Error("tracing shutdown error", logging.Err(err))
+	}
+
+	logger.Info("shutdown complete")
+}
+
+// fatal logs err and terminates the process; used for startup failures.
+func fatal(logger *slog.Logger, msg string, err error, args ...any) {
+	logger.Error(msg, append(args, logging.Err(err))...)
+	os.Exit(1)
 }
 
-func newRedisClient(addr string) *redis.Client {
+// newRateLimiter builds the limiter of the public order endpoints: per
+// instance in memory, or shared by all replicas in Redis.
+func newRateLimiter(cfg *config.Config, client *redis.Client, logger *slog.Logger) ratelimit.Limiter {
+	limits := ratelimit.Limits{Rate: cfg.RateLimitRate, Burst: cfg.RateLimitBurst}
+	logger.Info("rate limiting enabled",
+		"backend", cfg.RateLimitBackend, "rate", limits.Rate, "burst", limits.Burst)
+
+	if cfg.RateLimitBackend == "redis" {
+		return ratelimit.NewRedis(client, limits)
+	}
+	return ratelimit.NewMemory(limits)
+}
+
+// newDecoder builds the decoder of consumed messages, with a schema
+// registry client when a registry is configured.
+func newDecoder(cfg *config.Config, logger *slog.Logger) kafka.Decoder {
+	var registry *schemaregistry.Client
+	if cfg.SchemaRegistryURL != "" {
+		registry = schemaregistry.NewClient(&http.Client{Timeout: cfg.SchemaRegistryTimeout}, schemaregistry.Config{
+			URL:      cfg.SchemaRegistryURL,
+			Username: cfg.SchemaRegistryUsername,
+			Password: cfg.SchemaRegistryPassword,
+		})
+	}
+
+	decoder, err := kafka.NewDecoder(cfg.KafkaMessageFormat, registry)
+	if err != nil {
+		fatal(logger, "failed to create message decoder", err)
+	}
+	logger.Info("message decoder configured",
+		"format", cfg.KafkaMessageFormat, "schema_registry", cfg.SchemaRegistryURL)
+	return decoder
+}
+
+func newRedisClient(addr string, logger *slog.Logger) *redis.Client {
 	client := redis.NewClient(&redis.Options{
 		Addr: addr,
 	})
 
 	if err := client.Ping(context.Background()).Err(); err != nil {
-		log.Fatalf("Failed to connect to Redis at %s: %v", addr, err)
+		fatal(logger, "failed to connect to Redis", err, "addr", addr)
 	}
 
-	log.Printf("Connected to Redis at %s", addr)
+	logger.Info("connected to Redis", "addr", addr)
 
 	return client
 }
 
-func newDatabase(dsn string, orderCache *cache.OrderCache) *database.DB {
-	db, err := database.NewDB(dsn, orderCache)
+// newAuthenticator checks API keys from the config first, then the ones
+// stored in the database, and verifies JWTs when a secret or JWKS is set.
+func newAuthenticator(cfg *config.Config, db *database.DB, logger *slog.Logger) *auth.Authenticator {
+	staticKeys := make([]models.APIKey, 0, len(cfg.AuthAPIKeys))
+	for _, k := range cfg.AuthAPIKeys {
+		staticKeys = append(staticKeys, models.APIKey{Name: k.Name, KeyHash: k.SHA256, Scopes: k.Scopes})
+	}
+
+	var tokens *auth.TokenVerifier
+	if cfg.AuthJWTHMACSecret != "" || cfg.AuthJWTJWKSFile != "" {
+		tokenCfg := auth.TokenConfig{
+			Issuer:   cfg.AuthJWTIssuer,
+			Audience: cfg.AuthJWTAudience,
+			Leeway:   cfg.AuthJWTLeeway,
+		}
+		if cfg.AuthJWTHMACSecret != "" {
+			tokenCfg.HMACSecret = []byte(cfg.AuthJWTHMACSecret)
+		}
+		if cfg.AuthJWTJWKSFile != "" {
+			keySet, err := auth.LoadKeySet(cfg.AuthJWTJWKSFile)
+			if err != nil {
+				fatal(logger, "failed to load JWKS", err, "path", cfg.AuthJWTJWKSFile)
+			}
+			tokenCfg.JWKS = keySet
+		}
+
+		var err error
+		tokens, err = auth.NewTokenVerifier(tokenCfg)
+		if err != nil {
+			fatal(logger, "failed to configure JWT verification", err)
+		}
+	}
+
+	logger.Info("authentication enabled",
+		"static_api_keys", len(staticKeys),
+		"jwt", tokens != nil,
+		"protect_web", cfg.AuthProtectWeb,
+	)
+	return auth.NewAuthenticator(tokens, auth.NewStaticKeys(staticKeys), db)
+}
+
+// newKeyring loads the customer data encryption keys from the keyfile or,
+// failing that, from the environment. It returns nil when neither is set.
+func newKeyring(cfg *config.Config, logger *slog.Logger) *fieldcrypt.Keyring {
+	var (
+		keys *fieldcrypt.Keyring
+		err  error
+	)
+	switch {
+	case cfg.PIIEncryptionKeyfile != "":
+		keys, err = fieldcrypt.LoadKeyfile(cfg.PIIEncryptionKeyfile)
+	case cfg.PIIEncryptionKeys != "":
+		keys, err = fieldcrypt.ParseKeys(cfg.PIIEncryptionKeys)
+	default:
+		logger.Warn("customer data encryption is disabled, personal data is stored in clear text")
+		return nil
+	}
+	if err != nil {
+		fatal(logger, "failed to load encryption keys", err)
+	}
+
+	logger.Info("customer data encryption enabled", "primary_key", keys.Primary())
+	return keys
+}
+
+func newDatabase(dsn string, orderCache *cache.OrderCache, keys *fieldcrypt.Keyring, logger *slog.Logger) *database.DB {
+	db, err := database.NewDB(dsn, orderCache, keys, logger)
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		fatal(logger, "failed to connect to database", err)
 	}
 
 	if err := db.Migrate(); err != nil {
-		log.Fatalf("Failed to migrate database: %v", err)
+		fatal(logger, "failed to migrate database", err)
 	}
 
-	log.Println("Connected to database")
+	logger.Info("connected to database")
 
 	return db
 }
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"wb-tech-l0/internal/application/ports"
+)
+
+// parseAnalyticsRange reads the from and to query parameters.
+func parseAnalyticsRange(query url.Values) (ports.AnalyticsRange, error) {
+	from, err := ports.ParseAnalyticsTime(query.Get("from"))
+	if err != nil {
+		return ports.AnalyticsRange{}, fmt.Errorf("%w: from: %v", ports.ErrInvalidAnalyticsQuery, err)
+	}
+	to, err := ports.ParseAnalyticsTime(query.Get("to"))
+	if err != nil {
+		return ports.AnalyticsRange{}, fmt.Errorf("%w: to: %v", ports.ErrInvalidAnalyticsQuery, err)
+	}
+	return ports.AnalyticsRange{From: from, To: to}, nil
+}
+
+// RevenueHandler serves order counts and revenue per day or week.
+func (s *Server) RevenueHandler(w http.ResponseWriter, r *http.Request) {
+	rng, err := parseAnalyticsRange(r.URL.Query())
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	report, err := s.analyticsUseCase.Revenue(r.Context(), ports.RevenueQuery{
+		AnalyticsRange: rng,
+		Interval:       r.URL.Query().Get("interval"),
+	})
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// BreakdownHandler serves order counts and revenue per value of an order
+// attribute.
+func (s *Server) BreakdownHandler(w http.ResponseWriter, r *http.Request) {
+	rng, err := parseAnalyticsRange(r.URL.Query())
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	report, err := s.analyticsUseCase.Breakdown(r.Context(), ports.BreakdownQuery{
+		AnalyticsRange: rng,
+		By:             r.URL.Query().Get("by"),
+	})
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// BasketHandler serves the average basket size and value per currency.
+func (s *Server) BasketHandler(w http.ResponseWriter, r *http.Request) {
+	rng, err := parseAnalyticsRange(r.URL.Query())
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	report, err := s.analyticsUseCase.Basket(r.Context(), rng)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// TopProductsHandler serves the best selling brands or nm_ids.
+func (s *Server) TopProductsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	rng, err := parseAnalyticsRange(query)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	var limit int
+	if v := query.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 {
+			s.writeError(w, r, fmt.Errorf("%w: limit must be a positive integer", ports.ErrInvalidAnalyticsQuery))
+			return
+		}
+	}
+
+	report, err := s.analyticsUseCase.TopProducts(r.Context(), ports.TopProductsQuery{
+		AnalyticsRange: rng,
+		By:             query.Get("by"),
+		Metric:         query.Get("metric"),
+		Limit:          limit,
+	})
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
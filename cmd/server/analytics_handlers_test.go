@@ -0,0 +1,80 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"wb-tech-l0/internal/application/ports"
+	"wb-tech-l0/internal/application/usecase"
+	imocks "wb-tech-l0/internal/mocks"
+	"wb-tech-l0/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_RevenuePassesRange(t *testing.T) {
+	repo := new(imocks.AnalyticsRepositoryMock)
+	s, _, keys := newAuthTestServer(t, false, WithAnalyticsUseCase(usecase.NewAnalyticsService(repo)))
+
+	want := ports.RevenueQuery{
+		AnalyticsRange: ports.AnalyticsRange{
+			From: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+			To:   time.Date(2025, 3, 8, 12, 0, 0, 0, time.UTC),
+		},
+		Interval: ports.IntervalWeek,
+	}
+	repo.On("Revenue", mock.Anything, want).Return([]models.PeriodRevenue{
+		{Period: time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC), Currency: "USD", Orders: 2, Revenue: 150},
+	}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/analytics/revenue?from=2025-03-01&to=2025-03-08T15:00:00%2B03:00&interval=week", nil)
+	req.Header.Set(HeaderAPIKey, keys.reader)
+	rec := httptest.NewRecorder()
+	s.httpServer.Handler.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var report models.RevenueReport
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	assert.Equal(t, ports.IntervalWeek, report.Interval)
+	assert.True(t, want.To.Equal(report.To))
+	require.Len(t, report.Points, 1)
+	assert.Equal(t, int64(150), report.Points[0].Revenue)
+}
+
+func TestServer_AnalyticsRejectsBadQuery(t *testing.T) {
+	repo := new(imocks.AnalyticsRepositoryMock)
+	s, _, keys := newAuthTestServer(t, false, WithAnalyticsUseCase(usecase.NewAnalyticsService(repo)))
+
+	for _, target := range []string{
+		"/api/v1/analytics/revenue?from=yesterday",
+		"/api/v1/analytics/revenue?from=2025-03-08&to=2025-03-01",
+		"/api/v1/analytics/breakdown?by=city",
+		"/api/v1/analytics/top-products?limit=0",
+		"/api/v1/analytics/top-products?metric=margin",
+	} {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set(HeaderAPIKey, keys.reader)
+		rec := httptest.NewRecorder()
+		s.httpServer.Handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code, target)
+	}
+	assert.Empty(t, repo.Calls)
+}
+
+func TestServer_AnalyticsRequiresCredentials(t *testing.T) {
+	repo := new(imocks.AnalyticsRepositoryMock)
+	s, _, _ := newAuthTestServer(t, false, WithAnalyticsUseCase(usecase.NewAnalyticsService(repo)))
+
+	for _, target := range []string{"/api/v1/analytics/basket", "/analytics"} {
+		rec := httptest.NewRecorder()
+		s.httpServer.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		assert.Equal(t, http.StatusUnauthorized, rec.Code, target)
+	}
+	assert.Empty(t, repo.Calls)
+}
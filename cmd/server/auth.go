@@ -0,0 +1,80 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"wb-tech-l0/internal/auth"
+	"wb-tech-l0/internal/logging"
+)
+
+// HeaderAPIKey carries a static API key.
+const HeaderAPIKey = "X-API-Key"
+
+// authChallenge is sent with 401 responses. The Basic challenge lets
+// browsers prompt for an API key (as the password) on protected pages.
+const authChallenge = `Bearer realm="wb-orders", Basic realm="wb-orders"`
+
+// WithAuth enables authentication of the API routes. When protectWeb is
+// set, the HTML pages and the order stream they use require
+// orders:read as well; otherwise they stay public.
+func WithAuth(a *auth.Authenticator, protectWeb bool) Option {
+	return func(s *Server) {
+		s.authenticator = a
+		s.protectWeb = protectWeb
+	}
+}
+
+// requireScope authenticates the caller and lets the request through only
+// if it was granted scope. Without an authenticator every request passes.
+// With a rate limiter, invalid credentials count against the caller's IP
+// address and an exhausted address gets 429 before they are checked.
+func (s *Server) requireScope(scope string) Middleware {
+	return func(next http.Handler) http.Handler {
+		if s.authenticator == nil {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !s.checkAuthAttempts(w, r) {
+				return
+			}
+
+			creds := auth.ParseCredentials(r.Header.Get("Authorization"), r.Header.Get(HeaderAPIKey))
+			principal, err := s.authenticator.Authenticate(r.Context(), creds)
+			if errors.Is(err, auth.ErrInvalidCredentials) {
+				s.chargeFailedAuth(r)
+			}
+			switch {
+			case errors.Is(err, auth.ErrNoCredentials):
+				w.Header().Set("WWW-Authenticate", authChallenge)
+				writeProblem(w, r, http.StatusUnauthorized, CodeUnauthorized, "authentication required")
+				return
+			case errors.Is(err, auth.ErrInvalidCredentials):
+				w.Header().Set("WWW-Authenticate", authChallenge)
+				writeProblem(w, r, http.StatusUnauthorized, CodeUnauthorized, "invalid credentials")
+				return
+			case err != nil:
+				s.logger.ErrorContext(r.Context(), "authentication failed", logging.Err(err))
+				writeProblem(w, r, http.StatusServiceUnavailable, CodeUnavailable, "authentication is temporarily unavailable")
+				return
+			}
+
+			if !principal.HasScope(scope) {
+				writeProblem(w, r, http.StatusForbidden, CodeForbidden, "missing scope "+scope)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+		})
+	}
+}
+
+// webScope guards the HTML pages: orders:read when they are protected,
+// nothing otherwise.
+func (s *Server) webScope() Middleware {
+	if !s.protectWeb {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	return s.requireScope(auth.ScopeOrdersRead)
+}
@@ -0,0 +1,148 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"wb-tech-l0/internal/application/usecase"
+	"wb-tech-l0/internal/auth"
+	"wb-tech-l0/internal/logging"
+	imocks "wb-tech-l0/internal/mocks"
+	"wb-tech-l0/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type authTestKeys struct {
+	reader, piiReader, admin string
+}
+
+func newAuthTestServer(t *testing.T, protectWeb bool, opts ...Option) (*Server, *imocks.OrderUseCaseMock, authTestKeys) {
+	t.Helper()
+
+	readerKey, readerHash, err := auth.GenerateAPIKey()
+	require.NoError(t, err)
+	piiKey, piiHash, err := auth.GenerateAPIKey()
+	require.NoError(t, err)
+	adminKey, adminHash, err := auth.GenerateAPIKey()
+	require.NoError(t, err)
+
+	keys := auth.NewStaticKeys([]models.APIKey{
+		{Name: "reader", KeyHash: readerHash, Scopes: []string{auth.ScopeOrdersRead}},
+		{Name: "pii", KeyHash: piiHash, Scopes: []string{auth.ScopeOrdersRead, auth.ScopePIIRead}},
+		{Name: "admin", KeyHash: adminHash, Scopes: []string{auth.ScopeAdmin}},
+	})
+
+	uc := new(imocks.OrderUseCaseMock)
+	s := NewServer(uc, append([]Option{
+		WithLogger(logging.Discard()),
+		WithWebhookUseCase(usecase.NewWebhookService(new(imocks.WebhookRepositoryMock))),
+		WithAuth(auth.NewAuthenticator(nil, keys), protectWeb),
+	}, opts...)...)
+	return s, uc, authTestKeys{reader: readerKey, piiReader: piiKey, admin: adminKey}
+}
+
+func TestServer_RequiresCredentials(t *testing.T) {
+	s, uc, _ := newAuthTestServer(t, false)
+
+	rec := httptest.NewRecorder()
+	s.httpServer.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/order/uid-1", nil))
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Contains(t, rec.Header().Get("WWW-Authenticate"), "Bearer")
+	uc.AssertNotCalled(t, "GetOrder", mock.Anything, mock.Anything)
+
+	req := httptest.NewRequest(http.MethodGet, "/order/uid-1", nil)
+	req.Header.Set(HeaderAPIKey, "wbk_unknown")
+	rec = httptest.NewRecorder()
+	s.httpServer.Handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestServer_ChecksScopes(t *testing.T) {
+	s, uc, keys := newAuthTestServer(t, false)
+	uc.On("GetOrder", mock.Anything, "uid-1").Return(&models.Order{OrderUID: "uid-1"}, nil)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		key    string
+		want   int
+	}{
+		{"reader reads orders", http.MethodGet, "/order/uid-1", keys.reader, http.StatusOK},
+		{"admin reads orders", http.MethodGet, "/order/uid-1", keys.admin, http.StatusOK},
+		{"reader cannot manage webhooks", http.MethodGet, "/api/v1/admin/webhooks", keys.reader, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set(HeaderAPIKey, tt.key)
+			rec := httptest.NewRecorder()
+			s.httpServer.Handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.want, rec.Code)
+		})
+	}
+
+	t.Run("basic auth password", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/order/uid-1", nil)
+		req.SetBasicAuth("support", keys.reader)
+		rec := httptest.NewRecorder()
+		s.httpServer.Handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+}
+
+func TestServer_PublicRoutes(t *testing.T) {
+	s, _, _ := newAuthTestServer(t, false)
+
+	rec := httptest.NewRecorder()
+	s.httpServer.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	protected, _, _ := newAuthTestServer(t, true)
+	rec = httptest.NewRecorder()
+	protected.httpServer.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/order", nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestServer_MasksPersonalData(t *testing.T) {
+	s, uc, keys := newAuthTestServer(t, false)
+	uc.On("GetOrder", mock.Anything, "uid-1").Return(&models.Order{
+		OrderUID: "uid-1",
+		Delivery: models.Delivery{Name: "John Smith", Phone: "+79161234567", Email: "john@example.com", Address: "Tverskaya 1", City: "Moscow"},
+		Payment:  models.Payment{Transaction: "b563feb7b2b84b6test"},
+	}, nil)
+
+	get := func(key string) models.Order {
+		req := httptest.NewRequest(http.MethodGet, "/order/uid-1", nil)
+		req.Header.Set(HeaderAPIKey, key)
+		rec := httptest.NewRecorder()
+		s.httpServer.Handler.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var o models.Order
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &o))
+		return o
+	}
+
+	masked := get(keys.reader)
+	assert.Equal(t, "J*** S***", masked.Delivery.Name)
+	assert.Equal(t, "+7******4567", masked.Delivery.Phone)
+	assert.Equal(t, "j***@example.com", masked.Delivery.Email)
+	assert.Equal(t, "***", masked.Delivery.Address)
+	assert.Equal(t, "Moscow", masked.Delivery.City)
+	assert.Equal(t, "***************test", masked.Payment.Transaction)
+
+	for _, key := range []string{keys.piiReader, keys.admin} {
+		full := get(key)
+		assert.Equal(t, "+79161234567", full.Delivery.Phone)
+		assert.Equal(t, "b563feb7b2b84b6test", full.Payment.Transaction)
+	}
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"wb-tech-l0/internal/application/ports"
+	"wb-tech-l0/internal/auth"
+)
+
+type eraseCustomerRequest struct {
+	DryRun bool   `json:"dry_run"`
+	Reason string `json:"reason"`
+}
+
+// EraseCustomerHandler anonymises the personal data of a customer's
+// orders. The caller is recorded in the audit log as the requester.
+func (s *Server) EraseCustomerHandler(w http.ResponseWriter, r *http.Request) {
+	var req eraseCustomerRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeBodyError(w, r, err)
+			return
+		}
+	}
+
+	requestedBy := "anonymous"
+	if p := auth.PrincipalFromContext(r.Context()); p != nil {
+		requestedBy = p.Subject
+	}
+
+	report, err := s.erasureUseCase.EraseCustomer(r.Context(), ports.ErasureRequest{
+		CustomerID:  r.PathValue("customer_id"),
+		RequestedBy: requestedBy,
+		Reason:      req.Reason,
+		DryRun:      req.DryRun,
+	})
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	if !report.DryRun {
+		s.logger.InfoContext(r.Context(), "customer data erased",
+			"audit_id", report.AuditID, "orders", len(report.OrderUIDs), "requested_by", requestedBy)
+	}
+	writeJSON(w, http.StatusOK, report)
+}
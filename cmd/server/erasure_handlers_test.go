@@ -0,0 +1,67 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"wb-tech-l0/internal/application/ports"
+	"wb-tech-l0/internal/application/usecase"
+	"wb-tech-l0/internal/logging"
+	imocks "wb-tech-l0/internal/mocks"
+	"wb-tech-l0/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_EraseCustomer(t *testing.T) {
+	repo := new(imocks.ErasureRepositoryMock)
+	repo.On("EraseCustomerData", mock.Anything, ports.ErasureRequest{
+		CustomerID:  "cust-1",
+		RequestedBy: "anonymous",
+		Reason:      "ticket 42",
+		DryRun:      true,
+	}).Return(models.ErasureReport{CustomerID: "cust-1", DryRun: true, OrderUIDs: []string{"uid-1"}}, nil)
+
+	s := NewServer(new(imocks.OrderUseCaseMock),
+		WithLogger(logging.Discard()),
+		WithErasureUseCase(usecase.NewErasureService(repo)),
+	)
+
+	body := strings.NewReader(`{"dry_run":true,"reason":"ticket 42"}`)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/customers/cust-1/erasure", body)
+	rec := httptest.NewRecorder()
+	s.httpServer.Handler.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	var report models.ErasureReport
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	assert.True(t, report.DryRun)
+	assert.Equal(t, []string{"uid-1"}, report.OrderUIDs)
+	repo.AssertExpectations(t)
+}
+
+func TestServer_EraseCustomerRequiresAdmin(t *testing.T) {
+	repo := new(imocks.ErasureRepositoryMock)
+	repo.On("EraseCustomerData", mock.Anything, mock.MatchedBy(func(req ports.ErasureRequest) bool {
+		return req.RequestedBy == "apikey:admin"
+	})).Return(models.ErasureReport{CustomerID: "cust-1"}, nil)
+
+	s, _, keys := newAuthTestServer(t, false, WithErasureUseCase(usecase.NewErasureService(repo)))
+
+	erase := func(key string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/customers/cust-1/erasure", nil)
+		req.Header.Set(HeaderAPIKey, key)
+		rec := httptest.NewRecorder()
+		s.httpServer.Handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusForbidden, erase(keys.reader))
+	assert.Equal(t, http.StatusOK, erase(keys.admin))
+	repo.AssertNumberOfCalls(t, "EraseCustomerData", 1)
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"wb-tech-l0/internal/application/ports"
+	"wb-tech-l0/internal/export"
+	"wb-tech-l0/internal/logging"
+	"wb-tech-l0/internal/models"
+	"wb-tech-l0/internal/projection"
+)
+
+// ExportOrdersHandler streams every order matching the filters in the
+// requested format. Orders are read from the database in batches and
+// written as they arrive, so the response has no length and no deadline.
+// Personal data is masked unless the caller may read it.
+func (s *Server) ExportOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	format, err := export.ParseFormat(query.Get("format"))
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
+	}
+	filter, err := parseOrderFilter(query)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="orders.%s"`, format.Extension()))
+
+	out := &startedWriter{w: w}
+	ew, err := export.NewWriter(out, format)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	exported := 0
+	err = s.orderUseCase.ExportOrders(r.Context(), filter, func(o *models.Order) error {
+		exported++
+		return ew.Write(projection.Order(r.Context(), o))
+	})
+	if err == nil {
+		err = ew.Close()
+	}
+	if err == nil {
+		return
+	}
+
+	if !out.started {
+		w.Header().Del("Content-Disposition")
+		s.writeError(w, r, err)
+		return
+	}
+	// The status line is gone: cut the response short so the client
+	// does not take a truncated file for a complete one.
+	s.logger.WarnContext(r.Context(), "order export aborted", "exported", exported, logging.Err(err))
+	panic(http.ErrAbortHandler)
+}
+
+// parseOrderFilter reads the listing filters from query parameters;
+// created_from and created_to are RFC 3339 times.
+func parseOrderFilter(query url.Values) (ports.OrderFilter, error) {
+	filter := ports.OrderFilter{
+		DeliveryService: query.Get("delivery_service"),
+		Entry:           query.Get("entry"),
+		CustomerID:      query.Get("customer_id"),
+	}
+	for name, t := range map[string]*time.Time{"created_from": &filter.CreatedFrom, "created_to": &filter.CreatedTo} {
+		v := query.Get(name)
+		if v == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return ports.OrderFilter{}, fmt.Errorf("%s must be an RFC 3339 time", name)
+		}
+		*t = parsed
+	}
+	return filter, nil
+}
+
+// startedWriter records whether the response body has been started, after
+// which errors can no longer be reported with a status code.
+type startedWriter struct {
+	w       http.ResponseWriter
+	started bool
+}
+
+func (sw *startedWriter) Write(p []byte) (int, error) {
+	sw.started = true
+	return sw.w.Write(p)
+}
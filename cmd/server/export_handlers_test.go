@@ -0,0 +1,109 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"wb-tech-l0/internal/application/ports"
+	"wb-tech-l0/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func exportOrders(orders ...*models.Order) func(mock.Arguments) {
+	return func(args mock.Arguments) {
+		fn := args.Get(2).(func(*models.Order) error)
+		for _, o := range orders {
+			if fn(o) != nil {
+				return
+			}
+		}
+	}
+}
+
+func TestServer_ExportOrders(t *testing.T) {
+	s, uc, keys := newAuthTestServer(t, false)
+	order := &models.Order{OrderUID: "uid-1", Delivery: models.Delivery{Phone: "+79001234567"}}
+	want := ports.OrderFilter{
+		DeliveryService: "dhl",
+		CreatedFrom:     time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	uc.On("ExportOrders", mock.Anything, want, mock.Anything).Run(exportOrders(order, order)).Return(nil)
+
+	for _, tt := range []struct {
+		key   string
+		phone string
+	}{
+		{keys.reader, "+7******4567"},
+		{keys.piiReader, "+79001234567"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/orders/export?delivery_service=dhl&created_from=2025-01-01T00:00:00Z", nil)
+		req.Header.Set(HeaderAPIKey, tt.key)
+		rec := httptest.NewRecorder()
+		s.httpServer.Handler.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, "application/x-ndjson", rec.Header().Get("Content-Type"))
+		assert.Equal(t, `attachment; filename="orders.ndjson"`, rec.Header().Get("Content-Disposition"))
+
+		lines := 0
+		sc := bufio.NewScanner(rec.Body)
+		for sc.Scan() {
+			var got models.Order
+			require.NoError(t, json.Unmarshal(sc.Bytes(), &got))
+			assert.Equal(t, tt.phone, got.Delivery.Phone)
+			lines++
+		}
+		assert.Equal(t, 2, lines)
+	}
+}
+
+func TestServer_ExportOrdersRejectsBadFilter(t *testing.T) {
+	s, uc, keys := newAuthTestServer(t, false)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/orders/export?created_to=yesterday", nil)
+	req.Header.Set(HeaderAPIKey, keys.reader)
+	rec := httptest.NewRecorder()
+	s.httpServer.Handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "created_to")
+	uc.AssertNotCalled(t, "ExportOrders", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestServer_ExportOrdersReportsEarlyFailure(t *testing.T) {
+	s, uc, keys := newAuthTestServer(t, false)
+	uc.On("ExportOrders", mock.Anything, mock.Anything, mock.Anything).Return(ports.ErrUnavailable)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/orders/export?format=csv", nil)
+	req.Header.Set(HeaderAPIKey, keys.reader)
+	rec := httptest.NewRecorder()
+	s.httpServer.Handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "application/problem+json", rec.Header().Get("Content-Type"))
+	assert.Empty(t, rec.Header().Get("Content-Disposition"))
+}
+
+func TestServer_ExportOrdersAbortsStartedResponse(t *testing.T) {
+	s, uc, keys := newAuthTestServer(t, false)
+	// Enough orders to spill out of the writer's buffer.
+	orders := make([]*models.Order, 200)
+	for i := range orders {
+		orders[i] = &models.Order{OrderUID: "uid-1"}
+	}
+	uc.On("ExportOrders", mock.Anything, mock.Anything, mock.Anything).Run(exportOrders(orders...)).Return(ports.ErrUnavailable)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/orders/export", nil)
+	req.Header.Set(HeaderAPIKey, keys.reader)
+	rec := httptest.NewRecorder()
+
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() { s.httpServer.Handler.ServeHTTP(rec, req) })
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
@@ -0,0 +1,26 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"wb-tech-l0/internal/metrics"
+)
+
+// instrumentHTTP records request count and latency per route. The route
+// label is the ServeMux pattern that matched the request, so path
+// parameters such as order UIDs never end up in label values.
+func instrumentHTTP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := newResponseRecorder(w)
+
+		next.ServeHTTP(rec, r)
+
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		metrics.ObserveHTTP(route, r.Method, rec.status, time.Since(start))
+	})
+}
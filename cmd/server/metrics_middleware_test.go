@@ -0,0 +1,41 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"wb-tech-l0/internal/metrics"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstrumentHTTP_LabelsByRoutePattern(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("id") == "missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	})
+	h := instrumentHTTP(mux)
+
+	okBefore := testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues("GET /items/{id}", "GET", "200"))
+	notFoundBefore := testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues("GET /items/{id}", "GET", "404"))
+
+	for _, path := range []string{"/items/1", "/items/2", "/items/missing"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	assert.Equal(t, okBefore+2, testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues("GET /items/{id}", "GET", "200")))
+	assert.Equal(t, notFoundBefore+1, testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues("GET /items/{id}", "GET", "404")))
+}
+
+func TestResponseRecorder_KeepsFlusher(t *testing.T) {
+	rec := newResponseRecorder(httptest.NewRecorder())
+	var w http.ResponseWriter = rec
+	_, ok := w.(http.Flusher)
+	assert.True(t, ok)
+}
@@ -0,0 +1,176 @@
+package server
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"wb-tech-l0/internal/logging"
+
+	"github.com/google/uuid"
+)
+
+// Middleware wraps an http.Handler with cross-cutting behaviour.
+//
+// Middlewares running outside the mux must pass the request they receive
+// down unchanged (mutating it is fine, replacing it via WithContext is
+// not) when they read r.Pattern after serving: the mux records the
+// matched route on the request it was given.
+type Middleware func(http.Handler) http.Handler
+
+// Chain composes middlewares so that the first one is the outermost:
+// Chain(a, b)(h) serves a request through a, then b, then h.
+func Chain(mws ...Middleware) Middleware {
+	return func(h http.Handler) http.Handler {
+		for i := len(mws) - 1; i >= 0; i-- {
+			h = mws[i](h)
+		}
+		return h
+	}
+}
+
+// HeaderRequestID carries the request ID in requests and responses.
+const HeaderRequestID = "X-Request-ID"
+
+// maxRequestIDLength bounds client supplied request IDs.
+const maxRequestIDLength = 128
+
+// RequestID propagates a valid incoming X-Request-ID or generates a new
+// one, echoes it in the response and stores it in the request context
+// for logging.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(HeaderRequestID)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+
+		w.Header().Set(HeaderRequestID, id)
+		next.ServeHTTP(w, r.WithContext(logging.WithRequestID(r.Context(), id)))
+	})
+}
+
+// validRequestID accepts non-empty printable ASCII IDs of bounded length,
+// so clients cannot inject line breaks or huge values into logs.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// AccessLog writes one structured record per request. Server errors are
+// logged at error level and client errors at warn level. The query string
+// is left out since it may carry user input.
+func AccessLog(logger *slog.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := newResponseRecorder(w)
+
+			next.ServeHTTP(rec, r)
+
+			level := slog.LevelInfo
+			switch {
+			case rec.status >= 500:
+				level = slog.LevelError
+			case rec.status >= 400:
+				level = slog.LevelWarn
+			}
+
+			logger.LogAttrs(r.Context(), level, "http request",
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.String("route", r.Pattern),
+				slog.Int("status", rec.status),
+				slog.Int("bytes", rec.bytes),
+				slog.Duration("duration", time.Since(start)),
+				slog.String("remote_addr", r.RemoteAddr),
+				slog.String("user_agent", r.UserAgent()),
+			)
+		})
+	}
+}
+
+// Recoverer turns a panicking handler into a 500 response, logging the
+// panic with its stack trace. http.ErrAbortHandler is re-raised so the
+// server aborts the connection as intended.
+func Recoverer(logger *slog.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rec := newResponseRecorder(w)
+			defer func() {
+				p := recover()
+				if p == nil {
+					return
+				}
+				if p == http.ErrAbortHandler {
+					panic(p)
+				}
+
+				logger.ErrorContext(r.Context(), "panic while serving request",
+					"panic", p,
+					"method", r.Method,
+					"path", r.URL.Path,
+					"stack", string(debug.Stack()),
+				)
+				if !rec.wroteHeader {
+					writeProblem(rec, r, http.StatusInternalServerError, CodeInternal, "")
+				}
+			}()
+
+			next.ServeHTTP(rec, r)
+		})
+	}
+}
+
+// Timeout answers 503 with a timeout problem when the handler does not
+// finish within d and cancels the request context. The response is
+// buffered until the handler returns, so it must not be applied to
+// streaming routes.
+func Timeout(d time.Duration) Middleware {
+	return func(next http.Handler) http.Handler {
+		if d <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// A TimeoutHandler per request, so that the body carries the
+			// request ID.
+			body, _ := json.Marshal(newProblem(r, http.StatusServiceUnavailable, CodeTimeout, "request timed out"))
+			http.TimeoutHandler(next, d, string(body)).ServeHTTP(timeoutWriter{w}, r)
+		})
+	}
+}
+
+// timeoutWriter sets the content type of the body http.TimeoutHandler
+// writes on timeout, which comes without one.
+type timeoutWriter struct {
+	http.ResponseWriter
+}
+
+func (w timeoutWriter) WriteHeader(status int) {
+	if status == http.StatusServiceUnavailable && w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", ContentTypeProblem)
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// MaxBody limits the size of request bodies to n bytes. Reading past the
+// limit fails with *http.MaxBytesError; see writeBodyError.
+func MaxBody(n int64) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if n > 0 && r.Body != nil {
+				r.Body = http.MaxBytesReader(w, r.Body, n)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"wb-tech-l0/internal/logging"
+	imocks "wb-tech-l0/internal/mocks"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChain_FirstMiddlewareIsOutermost(t *testing.T) {
+	var order []string
+	mw := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	h := Chain(mw("a"), mw("b"), mw("c"))(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		order = append(order, "handler")
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, []string{"a", "b", "c", "handler"}, order)
+}
+
+func TestRequestID(t *testing.T) {
+	var seen string
+	h := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = logging.RequestID(r.Context())
+	}))
+
+	t.Run("propagates incoming id", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(HeaderRequestID, "abc-123")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, "abc-123", seen)
+		assert.Equal(t, "abc-123", rec.Header().Get(HeaderRequestID))
+	})
+
+	t.Run("generates missing id", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		assert.NotEmpty(t, seen)
+		assert.Equal(t, seen, rec.Header().Get(HeaderRequestID))
+	})
+
+	t.Run("replaces invalid id", func(t *testing.T) {
+		for _, bad := range []string{"with space", strings.Repeat("x", maxRequestIDLength+1), "line\nbreak"} {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(HeaderRequestID, bad)
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			assert.NotEqual(t, bad, seen)
+			assert.NotEmpty(t, seen)
+		}
+	})
+}
+
+func TestAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := logging.New(&buf, logging.Config{Level: "info", Format: logging.FormatJSON})
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte("missing"))
+	})
+	h := Chain(RequestID, AccessLog(logger))(mux)
+
+	req := httptest.NewRequest(http.MethodGet, "/items/7?secret=1", nil)
+	req.Header.Set(HeaderRequestID, "req-1")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "WARN", entry["level"])
+	assert.Equal(t, "req-1", entry["request_id"])
+	assert.Equal(t, "GET", entry["method"])
+	assert.Equal(t, "/items/7", entry["path"])
+	assert.Equal(t, "GET /items/{id}", entry["route"])
+	assert.EqualValues(t, http.StatusNotFound, entry["status"])
+	assert.EqualValues(t, len("missing"), entry["bytes"])
+	assert.NotContains(t, buf.String(), "secret")
+}
+
+func TestRecoverer(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := logging.New(&buf, logging.Config{Level: "info", Format: logging.FormatJSON})
+	require.NoError(t, err)
+
+	h := Recoverer(logger)(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("boom")
+	}))
+
+	rec := httptest.NewRecorder()
+	require.NotPanics(t, func() {
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	})
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Contains(t, buf.String(), "panic while serving request")
+	assert.Contains(t, buf.String(), "boom")
+}
+
+func TestRecoverer_ReraisesAbortHandler(t *testing.T) {
+	h := Recoverer(logging.Discard())(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	})
+}
+
+func TestTimeout(t *testing.T) {
+	h := Timeout(10 * time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, CodeTimeout, decodeProblem(t, rec).Code)
+}
+
+func TestTimeout_KeepsHandlerContentType(t *testing.T) {
+	h := Timeout(time.Second)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+}
+
+func TestServer_RejectsOversizedBody(t *testing.T) {
+	uc := new(imocks.OrderUseCaseMock)
+	s := NewServer(uc, WithLogger(logging.Discard()), WithMaxBodyBytes(16))
+
+	body := `{"order_uids":["` + strings.Repeat("a", 64) + `"]}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/orders:batchGet", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	s.httpServer.Handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	assert.Equal(t, CodeBodyTooLarge, decodeProblem(t, rec).Code)
+	assert.NotEmpty(t, rec.Header().Get(HeaderRequestID))
+	uc.AssertNotCalled(t, "GetOrders", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,37 @@
+package server
+
+import (
+	_ "embed"
+	"net/http"
+
+	"wb-tech-l0/internal/orderschema"
+)
+
+// openAPISpec describes every route registered in NewServer. Keep it in
+// sync with the handlers: TestOpenAPI_* fail when routes or response
+// bodies drift from it.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// docsPage is a Swagger UI page rendering openAPISpec. The UI assets are
+// loaded from a CDN by the browser.
+//
+//go:embed docs.html
+var docsPage []byte
+
+func (s *Server) OpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(openAPISpec)
+}
+
+func (s *Server) DocsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write(docsPage)
+}
+
+// OrderSchemaHandler serves the JSON Schema of the Kafka order message.
+func (s *Server) OrderSchemaHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/schema+json")
+	_, _ = w.Write(orderschema.Order)
+}
@@ -0,0 +1,297 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"wb-tech-l0/internal/application/ports"
+	"wb-tech-l0/internal/application/usecase"
+	"wb-tech-l0/internal/feed"
+	imocks "wb-tech-l0/internal/mocks"
+	"wb-tech-l0/internal/models"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// openAPI is the served OpenAPI document with a compiler resolving the
+// schemas it references.
+type openAPI struct {
+	doc      map[string]any
+	compiler *jsonschema.Compiler
+}
+
+const openAPIURL = "openapi.json"
+
+func loadOpenAPI(t *testing.T, s *Server) *openAPI {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	s.httpServer.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(rec.Body.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, "3.1.0", doc.(map[string]any)["openapi"])
+
+	c := jsonschema.NewCompiler()
+	c.DefaultDraft(jsonschema.Draft2020)
+	c.AssertFormat()
+	require.NoError(t, c.AddResource(openAPIURL, doc))
+	return &openAPI{doc: doc.(map[string]any), compiler: c}
+}
+
+// resolve follows a local $ref, if obj is one.
+func (o *openAPI) resolve(t *testing.T, obj map[string]any) map[string]any {
+	t.Helper()
+
+	ref, ok := obj["$ref"].(string)
+	if !ok {
+		return obj
+	}
+	var node any = o.doc
+	for _, token := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		node = node.(map[string]any)[token]
+		require.NotNil(t, node, "unresolved reference %s", ref)
+	}
+	return node.(map[string]any)
+}
+
+func (o *openAPI) operation(path, method string) map[string]any {
+	item, _ := o.doc["paths"].(map[string]any)[path].(map[string]any)
+	op, _ := item[strings.ToLower(method)].(map[string]any)
+	return op
+}
+
+// checkResponse asserts that the status, content type and body of rec are
+// documented for the operation.
+func (o *openAPI) checkResponse(t *testing.T, path, method string, rec *httptest.ResponseRecorder) {
+	t.Helper()
+
+	op := o.operation(path, method)
+	require.NotNil(t, op, "%s %s is not documented", method, path)
+
+	raw, ok := op["responses"].(map[string]any)[strconv.Itoa(rec.Code)].(map[string]any)
+	require.True(t, ok, "status %d of %s %s is not documented", rec.Code, method, path)
+	resp := o.resolve(t, raw)
+
+	content, ok := resp["content"].(map[string]any)
+	if !ok {
+		return
+	}
+	mediaType, _, err := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+	require.NoError(t, err)
+	media, ok := content[mediaType].(map[string]any)
+	require.True(t, ok, "content type %s of %s %s %d is not documented", mediaType, method, path, rec.Code)
+
+	schema, _ := media["schema"].(map[string]any)
+	ref, ok := schema["$ref"].(string)
+	if !ok || !strings.Contains(mediaType, "json") {
+		return
+	}
+	compiled, err := o.compiler.Compile(openAPIURL + ref)
+	require.NoError(t, err)
+
+	body, err := jsonschema.UnmarshalJSON(bytes.NewReader(rec.Body.Bytes()))
+	require.NoError(t, err)
+	assert.NoError(t, compiled.Validate(body), "%s %s %d: %s", method, path, rec.Code, rec.Body.String())
+}
+
+// specPath converts a ServeMux pattern to its OpenAPI method and path.
+// Subtree patterns have their remainder documented as a path parameter.
+func specPath(pattern string) (method, path string) {
+	method, path, found := strings.Cut(pattern, " ")
+	if !found {
+		method, path = http.MethodGet, pattern
+	}
+	subtrees := map[string]string{
+		"/order/":  "/order/{uid}",
+		"/static/": "/static/{path}",
+	}
+	if p, ok := subtrees[path]; ok {
+		path = p
+	}
+	return method, path
+}
+
+func newOpenAPITestServer(t *testing.T) (*Server, authTestKeys) {
+	t.Helper()
+
+	webhooks := new(imocks.WebhookRepositoryMock)
+	webhooks.On("CreateSubscription", mock.Anything).Run(func(args mock.Arguments) {
+		sub := args.Get(0).(*models.WebhookSubscription)
+		sub.ID, sub.CreatedAt = 1, time.Now()
+	}).Return(nil)
+	webhooks.On("ListSubscriptions").Return([]models.WebhookSubscription{
+		{ID: 1, URL: "https://example.com/hook", Secret: "s", Active: true, CreatedAt: time.Now()},
+		{ID: 2, URL: "https://example.com/all", EventTypes: []string{models.EventOrderStored}, Active: true, CreatedAt: time.Now()},
+	}, nil)
+	webhooks.On("GetSubscription", uint(1)).Return(&models.WebhookSubscription{ID: 1}, nil)
+	webhooks.On("GetSubscription", uint(99)).Return(nil, ports.ErrSubscriptionNotFound)
+	webhooks.On("ListDeadLetters", uint(1)).Return([]models.WebhookDelivery{{
+		ID: 7, SubscriptionID: 1, EventID: "evt-1", EventType: models.EventOrderStored, OrderUID: "uid-1",
+		Status: models.WebhookDeliveryDead, Attempts: 8, NextAttemptAt: time.Now(), LastError: "status 500", CreatedAt: time.Now(),
+	}}, nil)
+	webhooks.On("ReplayDeliveries", uint(1), mock.Anything).Return(int64(1), nil)
+	webhooks.On("DeleteSubscription", uint(1)).Return(nil)
+
+	erasure := new(imocks.ErasureRepositoryMock)
+	erasure.On("EraseCustomerData", mock.Anything, mock.Anything).Return(models.ErasureReport{
+		CustomerID: "cust-1", DryRun: true, OrderUIDs: []string{"uid-1"}, ErasedFields: models.ErasedFields, RequestedBy: "apikey:admin",
+	}, nil)
+
+	analytics := new(imocks.AnalyticsRepositoryMock)
+	analytics.On("Revenue", mock.Anything, mock.Anything).Return([]models.PeriodRevenue{
+		{Period: time.Date(2021, 11, 26, 0, 0, 0, 0, time.UTC), Currency: "USD", Orders: 1, Revenue: 1817},
+	}, nil)
+	analytics.On("Breakdown", mock.Anything, mock.Anything).Return([]models.BreakdownRow{
+		{Value: "meest", Currency: "USD", Orders: 1, Revenue: 1817},
+	}, nil)
+	analytics.On("Basket", mock.Anything, mock.Anything).Return([]models.BasketStats{
+		{Currency: "USD", Orders: 1, Items: 1, GoodsTotal: 317},
+	}, nil)
+	analytics.On("TopProducts", mock.Anything, mock.Anything).Return([]models.ProductSales{
+		{Brand: "Vivienne Sabo", Currency: "USD", Units: 1, Revenue: 317},
+	}, nil)
+
+	s, uc, keys := newAuthTestServer(t, false,
+		WithWebhookUseCase(usecase.NewWebhookService(webhooks)),
+		WithErasureUseCase(usecase.NewErasureService(erasure)),
+		WithAnalyticsUseCase(usecase.NewAnalyticsService(analytics)),
+		WithOrderFeed(feed.NewHub(10), time.Hour),
+	)
+
+	order := &models.Order{
+		OrderUID:        "uid-1",
+		TrackNumber:     "WBILMTESTTRACK",
+		Entry:           "WBIL",
+		Delivery:        models.Delivery{Name: "Test Testov", Phone: "+9720000000", Zip: "2639809", City: "Kiryat Mozkin", Address: "Ploshad Mira 15", Region: "Kraiot", Email: "test@gmail.com"},
+		Payment:         models.Payment{Transaction: "b563feb7b2b84b6test", Currency: "USD", Provider: "wbpay", Amount: 1817, PaymentDt: 1637907727, Bank: "alpha", DeliveryCost: 1500, GoodsTotal: 317},
+		Items:           []models.Item{{ChrtID: 9934930, TrackNumber: "WBILMTESTTRACK", Price: 453, RID: "ab4219087a764ae0btest", Name: "Mascaras", Sale: 30, Size: "0", TotalPrice: 317, NmID: 2389212, Brand: "Vivienne Sabo", Status: 202}},
+		Locale:          "en",
+		CustomerID:      "test",
+		DeliveryService: "meest",
+		Shardkey:        "9",
+		SmID:            99,
+		DateCreated:     time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+		OofShard:        "1",
+	}
+	uc.On("GetOrder", mock.Anything, "uid-1").Return(order, nil)
+	uc.On("GetOrder", mock.Anything, "missing").Return(nil, ports.ErrOrderNotFound)
+	uc.On("GetOrders", mock.Anything, []string{"uid-1", "missing"}).Return(ports.BatchOrders{
+		Orders: []*models.Order{order}, Missing: []string{"missing"},
+	}, nil)
+	uc.On("Stats").Return(ports.OrderStats{CacheSize: 1, DBCount: 1}, nil)
+	uc.On("ExportOrders", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		_ = args.Get(2).(func(*models.Order) error)(order)
+	}).Return(nil)
+
+	return s, keys
+}
+
+func TestOpenAPI_DocumentsEveryRoute(t *testing.T) {
+	s, _ := newOpenAPITestServer(t)
+	spec := loadOpenAPI(t, s)
+
+	registered := make(map[string]bool)
+	for _, pattern := range s.routes {
+		method, path := specPath(pattern)
+		registered[method+" "+path] = true
+		assert.NotNil(t, spec.operation(path, method), "route %q is not documented", pattern)
+	}
+
+	for path, item := range spec.doc["paths"].(map[string]any) {
+		for method := range item.(map[string]any) {
+			key := strings.ToUpper(method) + " " + path
+			assert.True(t, registered[key], "%s is documented but not registered", key)
+		}
+	}
+}
+
+func TestOpenAPI_ResponsesConformToSpec(t *testing.T) {
+	// The HTML pages read their templates relative to the repository root.
+	t.Chdir("../..")
+
+	s, keys := newOpenAPITestServer(t)
+	spec := loadOpenAPI(t, s)
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		path   string
+		key    string
+		body   string
+		want   int
+	}{
+		{"order", http.MethodGet, "/order/uid-1", "/order/{uid}", keys.admin, "", http.StatusOK},
+		{"masked order", http.MethodGet, "/order/uid-1", "/order/{uid}", keys.reader, "", http.StatusOK},
+		{"unknown order", http.MethodGet, "/order/missing", "/order/{uid}", keys.reader, "", http.StatusNotFound},
+		{"no credentials", http.MethodGet, "/order/uid-1", "/order/{uid}", "", "", http.StatusUnauthorized},
+		{"batch", http.MethodPost, "/api/v1/orders:batchGet", "/api/v1/orders:batchGet", keys.reader, `{"order_uids":["uid-1","missing"]}`, http.StatusOK},
+		{"empty batch", http.MethodPost, "/api/v1/orders:batchGet", "/api/v1/orders:batchGet", keys.reader, `{}`, http.StatusBadRequest},
+		{"bad stream cursor", http.MethodGet, "/api/v1/orders/stream?last_event_id=x", "/api/v1/orders/stream", "", "", http.StatusBadRequest},
+		{"export", http.MethodGet, "/api/v1/orders/export?format=csv&delivery_service=meest", "/api/v1/orders/export", keys.reader, "", http.StatusOK},
+		{"bad export format", http.MethodGet, "/api/v1/orders/export?format=xlsx", "/api/v1/orders/export", keys.reader, "", http.StatusBadRequest},
+		{"revenue", http.MethodGet, "/api/v1/analytics/revenue?from=2021-11-01&interval=week", "/api/v1/analytics/revenue", keys.reader, "", http.StatusOK},
+		{"bad revenue interval", http.MethodGet, "/api/v1/analytics/revenue?interval=month", "/api/v1/analytics/revenue", keys.reader, "", http.StatusBadRequest},
+		{"breakdown", http.MethodGet, "/api/v1/analytics/breakdown?by=delivery_service", "/api/v1/analytics/breakdown", keys.reader, "", http.StatusOK},
+		{"basket", http.MethodGet, "/api/v1/analytics/basket", "/api/v1/analytics/basket", keys.reader, "", http.StatusOK},
+		{"top products", http.MethodGet, "/api/v1/analytics/top-products?by=brand&metric=units&limit=5", "/api/v1/analytics/top-products", keys.reader, "", http.StatusOK},
+		{"stats", http.MethodGet, "/stats", "/stats", keys.reader, "", http.StatusOK},
+		{"create webhook", http.MethodPost, "/api/v1/admin/webhooks", "/api/v1/admin/webhooks", keys.admin, `{"url":"https://example.com/hook","event_types":["OrderStored"]}`, http.StatusCreated},
+		{"invalid webhook", http.MethodPost, "/api/v1/admin/webhooks", "/api/v1/admin/webhooks", keys.admin, `{"url":"ftp://example.com"}`, http.StatusBadRequest},
+		{"webhooks need admin", http.MethodPost, "/api/v1/admin/webhooks", "/api/v1/admin/webhooks", keys.reader, `{}`, http.StatusForbidden},
+		{"list webhooks", http.MethodGet, "/api/v1/admin/webhooks", "/api/v1/admin/webhooks", keys.admin, "", http.StatusOK},
+		{"delete webhook", http.MethodDelete, "/api/v1/admin/webhooks/1", "/api/v1/admin/webhooks/{id}", keys.admin, "", http.StatusNoContent},
+		{"invalid webhook id", http.MethodDelete, "/api/v1/admin/webhooks/abc", "/api/v1/admin/webhooks/{id}", keys.admin, "", http.StatusBadRequest},
+		{"dead letters", http.MethodGet, "/api/v1/admin/webhooks/1/dead-letters", "/api/v1/admin/webhooks/{id}/dead-letters", keys.admin, "", http.StatusOK},
+		{"unknown webhook", http.MethodGet, "/api/v1/admin/webhooks/99/dead-letters", "/api/v1/admin/webhooks/{id}/dead-letters", keys.admin, "", http.StatusNotFound},
+		{"replay", http.MethodPost, "/api/v1/admin/webhooks/1/replay", "/api/v1/admin/webhooks/{id}/replay", keys.admin, `{"delivery_ids":[7]}`, http.StatusOK},
+		{"erasure", http.MethodPost, "/api/v1/admin/customers/cust-1/erasure", "/api/v1/admin/customers/{customer_id}/erasure", keys.admin, `{"dry_run":true}`, http.StatusOK},
+		{"index page", http.MethodGet, "/", "/", "", "", http.StatusOK},
+		{"order page", http.MethodGet, "/order?uid=uid-1", "/order", "", "", http.StatusOK},
+		{"missing order page", http.MethodGet, "/order?uid=missing", "/order", "", "", http.StatusNotFound},
+		{"analytics page", http.MethodGet, "/analytics?from=2021-11-01&to=2021-11-30", "/analytics", keys.reader, "", http.StatusOK},
+		{"analytics page needs credentials", http.MethodGet, "/analytics", "/analytics", "", "", http.StatusUnauthorized},
+		{"static file", http.MethodGet, "/static/style.css", "/static/{path}", "", "", http.StatusOK},
+		{"metrics", http.MethodGet, "/metrics", "/metrics", "", "", http.StatusOK},
+		{"openapi", http.MethodGet, "/openapi.json", "/openapi.json", "", "", http.StatusOK},
+		{"docs", http.MethodGet, "/docs", "/docs", "", "", http.StatusOK},
+		{"order schema", http.MethodGet, "/schema/order.json", "/schema/order.json", "", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			if tt.key != "" {
+				req.Header.Set(HeaderAPIKey, tt.key)
+			}
+			rec := httptest.NewRecorder()
+			s.httpServer.Handler.ServeHTTP(rec, req)
+
+			require.Equal(t, tt.want, rec.Code, rec.Body.String())
+			spec.checkResponse(t, tt.path, tt.method, rec)
+		})
+	}
+}
+
+func TestOpenAPI_DetectsDrift(t *testing.T) {
+	s, _ := newOpenAPITestServer(t)
+	spec := loadOpenAPI(t, s)
+
+	compiled, err := spec.compiler.Compile(openAPIURL + "#/components/schemas/Order")
+	require.NoError(t, err)
+
+	var order map[string]any
+	require.NoError(t, json.Unmarshal([]byte(`{"order_uid":"uid-1","unexpected":true}`), &order))
+	assert.Error(t, compiled.Validate(order))
+}
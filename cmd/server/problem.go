@@ -0,0 +1,109 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"wb-tech-l0/internal/application/ports"
+	"wb-tech-l0/internal/logging"
+)
+
+// ContentTypeProblem is the media type of error responses (RFC 7807).
+const ContentTypeProblem = "application/problem+json"
+
+// Machine readable error codes, sent in the code member of a Problem.
+// Clients should branch on them rather than on the status or the title.
+const (
+	CodeInvalidRequest  = "invalid_request"
+	CodeInvalidOrder    = "invalid_order"
+	CodeBatchTooLarge   = "batch_too_large"
+	CodeBodyTooLarge    = "body_too_large"
+	CodeUnauthorized    = "unauthorized"
+	CodeForbidden       = "forbidden"
+	CodeOrderNotFound   = "order_not_found"
+	CodeWebhookNotFound = "webhook_not_found"
+	CodeConflict        = "conflict"
+	CodeRateLimited     = "rate_limited"
+	CodeInternal        = "internal_error"
+	CodeUnavailable     = "unavailable"
+	CodeTimeout         = "timeout"
+)
+
+// Problem is the body of every API error response: an RFC 7807 problem
+// details object extended with an error code and the request ID.
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	Code      string `json:"code"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+func newProblem(r *http.Request, status int, code, detail string) Problem {
+	return Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		Code:      code,
+		RequestID: logging.RequestID(r.Context()),
+	}
+}
+
+// writeProblem sends an error response.
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, code, detail string) {
+	w.Header().Set("Content-Type", ContentTypeProblem)
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(newProblem(r, status, code, detail))
+}
+
+// writeError sends the error response matching a domain error. Errors
+// without a domain meaning are logged and reported as internal errors,
+// without their message: it may describe the internals.
+func (s *Server) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, ports.ErrOrderNotFound):
+		writeProblem(w, r, http.StatusNotFound, CodeOrderNotFound, err.Error())
+	case errors.Is(err, ports.ErrSubscriptionNotFound):
+		writeProblem(w, r, http.StatusNotFound, CodeWebhookNotFound, err.Error())
+	case errors.Is(err, ports.ErrInvalidOrder):
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidOrder, err.Error())
+	case errors.Is(err, ports.ErrBatchTooLarge):
+		writeProblem(w, r, http.StatusBadRequest, CodeBatchTooLarge, err.Error())
+	case errors.Is(err, ports.ErrInvalidSubscription),
+		errors.Is(err, ports.ErrInvalidCursor),
+		errors.Is(err, ports.ErrInvalidAnalyticsQuery),
+		errors.Is(err, ports.ErrCustomerIDRequired):
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+	case errors.Is(err, ports.ErrConflict):
+		writeProblem(w, r, http.StatusConflict, CodeConflict, "the resource was changed concurrently, retry the request")
+	case errors.Is(err, ports.ErrUnavailable), errors.Is(err, context.DeadlineExceeded):
+		s.logger.WarnContext(r.Context(), "backing service unavailable", logging.Err(err))
+		w.Header().Set("Retry-After", "1")
+		writeProblem(w, r, http.StatusServiceUnavailable, CodeUnavailable, "the service is temporarily unavailable, retry later")
+	default:
+		s.logger.ErrorContext(r.Context(), "request failed", logging.Err(err))
+		writeProblem(w, r, http.StatusInternalServerError, CodeInternal, "")
+	}
+}
+
+// writeBodyError reports a request body that could not be decoded.
+func writeBodyError(w http.ResponseWriter, r *http.Request, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeProblem(w, r, http.StatusRequestEntityTooLarge, CodeBodyTooLarge, "request body too large")
+		return
+	}
+	writeProblem(w, r, http.StatusBadRequest, CodeInvalidRequest, "invalid request body")
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
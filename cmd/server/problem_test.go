@@ -0,0 +1,79 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"wb-tech-l0/internal/application/ports"
+	"wb-tech-l0/internal/logging"
+	imocks "wb-tech-l0/internal/mocks"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func decodeProblem(t *testing.T, rec *httptest.ResponseRecorder) Problem {
+	t.Helper()
+	assert.Equal(t, ContentTypeProblem, rec.Header().Get("Content-Type"))
+	var p Problem
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &p))
+	return p
+}
+
+func TestServer_MapsDomainErrors(t *testing.T) {
+	uc := new(imocks.OrderUseCaseMock)
+	uc.On("GetOrder", mock.Anything, "missing").Return(nil, fmt.Errorf("%w: missing", ports.ErrOrderNotFound))
+	uc.On("GetOrder", mock.Anything, "").Return(nil, ports.ErrInvalidOrder)
+	uc.On("GetOrder", mock.Anything, "busy").Return(nil, ports.ErrConflict)
+	uc.On("GetOrder", mock.Anything, "db-down").Return(nil, fmt.Errorf("%w: dial tcp: connection refused", ports.ErrUnavailable))
+	uc.On("GetOrder", mock.Anything, "broken").Return(nil, fmt.Errorf("pq: column secret does not exist"))
+	s := NewServer(uc, WithLogger(logging.Discard()))
+
+	tests := []struct {
+		uid    string
+		status int
+		code   string
+	}{
+		{"missing", http.StatusNotFound, CodeOrderNotFound},
+		{"", http.StatusBadRequest, CodeInvalidOrder},
+		{"busy", http.StatusConflict, CodeConflict},
+		{"db-down", http.StatusServiceUnavailable, CodeUnavailable},
+		{"broken", http.StatusInternalServerError, CodeInternal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/order/"+tt.uid, nil)
+			req.Header.Set(HeaderRequestID, "req-"+tt.code)
+			rec := httptest.NewRecorder()
+			s.httpServer.Handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.status, rec.Code)
+			p := decodeProblem(t, rec)
+			assert.Equal(t, tt.status, p.Status)
+			assert.Equal(t, http.StatusText(tt.status), p.Title)
+			assert.Equal(t, tt.code, p.Code)
+			assert.Equal(t, "req-"+tt.code, p.RequestID)
+			assert.Equal(t, "/order/"+tt.uid, p.Instance)
+			// Internal details never reach the client.
+			assert.NotContains(t, rec.Body.String(), "connection refused")
+			assert.NotContains(t, rec.Body.String(), "column secret")
+		})
+	}
+}
+
+func TestServer_StatsErrorIsProblem(t *testing.T) {
+	uc := new(imocks.OrderUseCaseMock)
+	uc.On("Stats").Return(ports.OrderStats{}, ports.ErrUnavailable)
+	s := NewServer(uc, WithLogger(logging.Discard()))
+
+	rec := httptest.NewRecorder()
+	s.httpServer.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stats", nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.NotEmpty(t, rec.Header().Get("Retry-After"))
+	assert.Equal(t, CodeUnavailable, decodeProblem(t, rec).Code)
+}
@@ -0,0 +1,140 @@
+package server
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"wb-tech-l0/internal/auth"
+	"wb-tech-l0/internal/logging"
+	"wb-tech-l0/internal/metrics"
+	"wb-tech-l0/internal/ratelimit"
+)
+
+// Rate limit response headers.
+const (
+	HeaderRateLimitLimit     = "X-RateLimit-Limit"
+	HeaderRateLimitRemaining = "X-RateLimit-Remaining"
+	HeaderRateLimitReset     = "X-RateLimit-Reset"
+)
+
+// WithRateLimiter throttles the public order endpoints per client. When
+// trustProxy is set the client IP is taken from the last X-Forwarded-For
+// entry, the one added by the reverse proxy in front of the service.
+func WithRateLimiter(l ratelimit.Limiter, trustProxy bool) Option {
+	return func(s *Server) {
+		s.rateLimiter = l
+		s.trustProxy = trustProxy
+	}
+}
+
+// rateLimit rejects requests of clients that ran out of tokens with 429.
+// It must run after authentication: authenticated callers are limited per
+// principal, anonymous ones per IP address. Failed authentications are
+// limited separately, see checkAuthAttempts. If the limiter fails, the
+// request is let through rather than taking the endpoint down with it.
+func (s *Server) rateLimit() Middleware {
+	return func(next http.Handler) http.Handler {
+		if s.rateLimiter == nil {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			res, err := s.rateLimiter.Allow(r.Context(), s.clientKey(r))
+			if err != nil {
+				metrics.HTTPRateLimiterErrors.Inc()
+				s.logger.WarnContext(r.Context(), "rate limiter failed, request not limited", logging.Err(err))
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Set(HeaderRateLimitLimit, strconv.Itoa(res.Limit))
+			h.Set(HeaderRateLimitRemaining, strconv.Itoa(res.Remaining))
+			h.Set(HeaderRateLimitReset, ceilSeconds(res.Reset))
+
+			if !res.Allowed {
+				metrics.HTTPRateLimited.WithLabelValues(r.Pattern).Inc()
+				h.Set("Retry-After", ceilSeconds(res.RetryAfter))
+				writeProblem(w, r, http.StatusTooManyRequests, CodeRateLimited, "too many requests, retry later")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// checkAuthAttempts rejects callers whose IP address ran out of failed
+// authentications with 429, before their credentials are checked, so a
+// correct guess is not confirmed either. Only a read: the bucket is
+// charged by chargeFailedAuth.
+func (s *Server) checkAuthAttempts(w http.ResponseWriter, r *http.Request) bool {
+	if s.rateLimiter == nil {
+		return true
+	}
+
+	res, err := s.rateLimiter.Peek(r.Context(), authAttemptsKey+s.clientIP(r))
+	if err != nil {
+		metrics.HTTPRateLimiterErrors.Inc()
+		s.logger.WarnContext(r.Context(), "rate limiter failed, authentication not limited", logging.Err(err))
+		return true
+	}
+	if !res.Allowed {
+		metrics.HTTPRateLimited.WithLabelValues(r.Pattern).Inc()
+		w.Header().Set("Retry-After", ceilSeconds(res.RetryAfter))
+		writeProblem(w, r, http.StatusTooManyRequests, CodeRateLimited, "too many failed authentication attempts, retry later")
+		return false
+	}
+	return true
+}
+
+// chargeFailedAuth takes a token from the caller IP's bucket of failed
+// authentications.
+func (s *Server) chargeFailedAuth(r *http.Request) {
+	if s.rateLimiter == nil {
+		return
+	}
+	if _, err := s.rateLimiter.Allow(r.Context(), authAttemptsKey+s.clientIP(r)); err != nil {
+		metrics.HTTPRateLimiterErrors.Inc()
+		s.logger.WarnContext(r.Context(), "failed to count failed authentication", logging.Err(err))
+	}
+}
+
+// authAttemptsKey prefixes the per-IP buckets of failed authentications.
+const authAttemptsKey = "auth:ip:"
+
+// clientKey identifies the caller for rate limiting.
+func (s *Server) clientKey(r *http.Request) string {
+	if p := auth.PrincipalFromContext(r.Context()); p != nil {
+		return "principal:" + p.Subject
+	}
+	return "ip:" + s.clientIP(r)
+}
+
+func (s *Server) clientIP(r *http.Request) string {
+	if s.trustProxy {
+		if xff := r.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+			last := xff[len(xff)-1]
+			if i := strings.LastIndexByte(last, ','); i >= 0 {
+				last = last[i+1:]
+			}
+			if ip := net.ParseIP(strings.TrimSpace(last)); ip != nil {
+				return ip.String()
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// ceilSeconds formats d as whole seconds, rounded up, for headers.
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"wb-tech-l0/internal/application/ports"
+	"wb-tech-l0/internal/logging"
+	imocks "wb-tech-l0/internal/mocks"
+	"wb-tech-l0/internal/models"
+	"wb-tech-l0/internal/ratelimit"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestServer_RateLimitsPerAPIKey(t *testing.T) {
+	s, uc, keys := newAuthTestServer(t, false, WithRateLimiter(ratelimit.NewMemory(ratelimit.Limits{Rate: 1, Burst: 2}), false))
+	uc.On("GetOrder", mock.Anything, "uid-1").Return(&models.Order{OrderUID: "uid-1"}, nil)
+
+	get := func(key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/order/uid-1", nil)
+		req.Header.Set(HeaderAPIKey, key)
+		rec := httptest.NewRecorder()
+		s.httpServer.Handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get(keys.reader)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "2", rec.Header().Get(HeaderRateLimitLimit))
+	assert.Equal(t, "1", rec.Header().Get(HeaderRateLimitRemaining))
+	assert.Equal(t, http.StatusOK, get(keys.reader).Code)
+
+	rec = get(keys.reader)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+	assert.Equal(t, "0", rec.Header().Get(HeaderRateLimitRemaining))
+	assert.Equal(t, "2", rec.Header().Get(HeaderRateLimitReset))
+	uc.AssertNumberOfCalls(t, "GetOrder", 2)
+
+	// Another key has its own budget.
+	assert.Equal(t, http.StatusOK, get(keys.admin).Code)
+}
+
+func TestServer_RateLimitsFailedAuthenticationPerIP(t *testing.T) {
+	s, uc, keys := newAuthTestServer(t, false, WithRateLimiter(ratelimit.NewMemory(ratelimit.Limits{Rate: 1, Burst: 2}), false))
+	uc.On("Stats").Return(ports.OrderStats{}, nil)
+
+	get := func(remoteAddr, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/stats", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set(HeaderAPIKey, key)
+		rec := httptest.NewRecorder()
+		s.httpServer.Handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Successful authentications do not use up the budget, even on routes
+	// without a rate limit.
+	for range 3 {
+		assert.Equal(t, http.StatusOK, get("10.0.0.1:1", keys.reader).Code)
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, get("10.0.0.1:1", "guess-1").Code)
+	assert.Equal(t, http.StatusUnauthorized, get("10.0.0.1:2", "guess-2").Code)
+	rec := get("10.0.0.1:3", "guess-3")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+	// The address is blocked before its credentials are checked, so a
+	// correct guess is not revealed either.
+	assert.Equal(t, http.StatusTooManyRequests, get("10.0.0.1:4", keys.reader).Code)
+
+	assert.Equal(t, http.StatusOK, get("10.0.0.2:1", keys.reader).Code)
+}
+
+func TestServer_RateLimitsWebPagePerIP(t *testing.T) {
+	uc := new(imocks.OrderUseCaseMock)
+	uc.On("GetOrder", mock.Anything, mock.Anything).Return(nil, ports.ErrOrderNotFound)
+	limiter := ratelimit.NewMemory(ratelimit.Limits{Rate: 1, Burst: 1})
+
+	get := func(s *Server, remoteAddr, forwardedFor string) int {
+		req := httptest.NewRequest(http.MethodGet, "/order?uid=x", nil)
+		req.RemoteAddr = remoteAddr
+		if forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		rec := httptest.NewRecorder()
+		s.httpServer.Handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	direct := NewServer(uc, WithLogger(logging.Discard()), WithRateLimiter(limiter, false))
+	assert.NotEqual(t, http.StatusTooManyRequests, get(direct, "10.0.0.1:1234", ""))
+	// Another port of the same host shares the bucket; spoofed headers are ignored.
+	assert.Equal(t, http.StatusTooManyRequests, get(direct, "10.0.0.1:5678", "192.0.2.1"))
+	assert.NotEqual(t, http.StatusTooManyRequests, get(direct, "10.0.0.2:1234", ""))
+
+	proxied := NewServer(uc, WithLogger(logging.Discard()), WithRateLimiter(limiter, true))
+	assert.NotEqual(t, http.StatusTooManyRequests, get(proxied, "10.0.0.9:1", "203.0.113.5, 198.51.100.7"))
+	// Only the entry added by the proxy counts, not the client supplied ones.
+	assert.Equal(t, http.StatusTooManyRequests, get(proxied, "10.0.0.9:2", "203.0.113.6, 198.51.100.7"))
+}
+
+type failingLimiter struct{}
+
+func (failingLimiter) Allow(context.Context, string) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("redis is down")
+}
+
+func (failingLimiter) Peek(context.Context, string) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("redis is down")
+}
+
+func TestServer_RateLimiterFailsOpen(t *testing.T) {
+	uc := new(imocks.OrderUseCaseMock)
+	uc.On("GetOrder", mock.Anything, "uid-1").Return(&models.Order{OrderUID: "uid-1"}, nil)
+	s := NewServer(uc, WithLogger(logging.Discard()), WithRateLimiter(failingLimiter{}, false))
+
+	rec := httptest.NewRecorder()
+	s.httpServer.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/order/uid-1", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get(HeaderRateLimitLimit))
+}
@@ -3,9 +3,16 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"log/slog"
 	"net/http"
+	"time"
 
 	"wb-tech-l0/internal/application/ports"
+	"wb-tech-l0/internal/auth"
+	"wb-tech-l0/internal/feed"
+	"wb-tech-l0/internal/metrics"
+	"wb-tech-l0/internal/projection"
+	"wb-tech-l0/internal/ratelimit"
 	"wb-tech-l0/internal/web"
 )
 
@@ -13,66 +20,233 @@ import (
 // It depends only on the use case interfaces and wraps http.Server
 // to allow graceful shutdown.
 type Server struct {
-	orderUseCase ports.OrderUseCase
-	webHandler   *web.WebHandler
-	httpServer   *http.Server
+	orderUseCase     ports.OrderUseCase
+	webhookUseCase   ports.WebhookUseCase
+	erasureUseCase   ports.ErasureUseCase
+	analyticsUseCase ports.AnalyticsUseCase
+	orderFeed        *feed.Hub
+	streamHeartbeat  time.Duration
+	webHandler       *web.WebHandler
+	analyticsPage    *web.AnalyticsHandler
+	httpServer       *http.Server
+	shutdown         chan struct{}
+
+	logger         *slog.Logger
+	requestTimeout time.Duration
+	maxBodyBytes   int64
+
+	authenticator *auth.Authenticator
+	protectWeb    bool
+
+	rateLimiter ratelimit.Limiter
+	trustProxy  bool
+
+	// routes lists the registered patterns, in registration order.
+	routes []string
+}
+
+// Defaults for the request limits, overridable with options.
+const (
+	DefaultRequestTimeout = 10 * time.Second
+	DefaultMaxBodyBytes   = 1 << 20
+)
+
+// Option configures optional parts of the server.
+type Option func(*Server)
+
+// WithWebhookUseCase enables the webhook admin API.
+func WithWebhookUseCase(uc ports.WebhookUseCase) Option {
+	return func(s *Server) {
+		s.webhookUseCase = uc
+	}
+}
+
+// WithErasureUseCase enables the customer data erasure admin API.
+func WithErasureUseCase(uc ports.ErasureUseCase) Option {
+	return func(s *Server) {
+		s.erasureUseCase = uc
+	}
 }
 
-func NewServer(orderUseCase ports.OrderUseCase) *Server {
+// WithAnalyticsUseCase enables the analytics API and dashboard.
+func WithAnalyticsUseCase(uc ports.AnalyticsUseCase) Option {
+	return func(s *Server) {
+		s.analyticsUseCase = uc
+		s.analyticsPage = web.NewAnalyticsHandler(uc)
+	}
+}
+
+// WithOrderFeed enables the live order stream, sending a heartbeat comment
+// every heartbeat interval to keep idle connections open.
+func WithOrderFeed(hub *feed.Hub, heartbeat time.Duration) Option {
+	return func(s *Server) {
+		s.orderFeed = hub
+		s.streamHeartbeat = heartbeat
+	}
+}
+
+// WithLogger sets the logger used for access logs and recovered panics.
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *Server) {
+		s.logger = logger
+	}
+}
+
+// WithRequestTimeout limits how long a non-streaming handler may run;
+// zero disables the limit.
+func WithRequestTimeout(d time.Duration) Option {
+	return func(s *Server) {
+		s.requestTimeout = d
+	}
+}
+
+// WithMaxBodyBytes limits the size of request bodies; zero disables the
+// limit.
+func WithMaxBodyBytes(n int64) Option {
+	return func(s *Server) {
+		s.maxBodyBytes = n
+	}
+}
+
+func NewServer(orderUseCase ports.OrderUseCase, opts ...Option) *Server {
 	webHandler := web.NewWebHandler(orderUseCase)
 
 	mux := http.NewServeMux()
 
 	s := &Server{
-		orderUseCase: orderUseCase,
-		webHandler:   webHandler,
-		httpServer: &http.Server{
-			Handler: mux,
-		},
+		orderUseCase:   orderUseCase,
+		webHandler:     webHandler,
+		httpServer:     &http.Server{},
+		shutdown:       make(chan struct{}),
+		logger:         slog.Default(),
+		requestTimeout: DefaultRequestTimeout,
+		maxBodyBytes:   DefaultMaxBodyBytes,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.logger = s.logger.With("component", "http_server")
+
+	// Middlewares shared by every route. Everything after RequestID reads
+	// the matched route from the request, see Middleware.
+	s.httpServer.Handler = Chain(
+		RequestID,
+		traceHTTP,
+		instrumentHTTP,
+		AccessLog(s.logger),
+		Recoverer(s.logger),
+		MaxBody(s.maxBodyBytes),
+	)(mux)
+
+	// handle registers a route wrapped in its own middlewares.
+	handle := func(pattern string, h http.Handler, mws ...Middleware) {
+		mux.Handle(pattern, Chain(mws...)(h))
+		s.routes = append(s.routes, pattern)
+	}
+	timeout := Timeout(s.requestTimeout)
+	read := s.requireScope(auth.ScopeOrdersRead)
+	admin := s.requireScope(auth.ScopeAdmin)
+	web := s.webScope()
+	limit := s.rateLimit()
+
+	// Long-lived streams never become idle, so end them when shutdown starts.
+	s.httpServer.RegisterOnShutdown(func() {
+		close(s.shutdown)
+	})
 
 	// API routes
-	mux.HandleFunc("/order/", s.GetOrderHandler)
-	mux.HandleFunc("/stats", s.StatsHandler)
+	handle("/order/", http.HandlerFunc(s.GetOrderHandler), read, limit, timeout)
+	handle("/stats", http.HandlerFunc(s.StatsHandler), read, timeout)
+	handle("GET /metrics", metrics.Handler(), timeout)
+	handle("POST /api/v1/orders:batchGet", http.HandlerFunc(s.BatchGetOrdersHandler), read, limit, timeout)
+	// Exports stream for as long as the result takes: no timeout.
+	handle("GET /api/v1/orders/export", http.HandlerFunc(s.ExportOrdersHandler), read, limit)
+	handle("GET /openapi.json", http.HandlerFunc(s.OpenAPIHandler), timeout)
+	handle("GET /docs", http.HandlerFunc(s.DocsHandler), timeout)
+	handle("GET /schema/order.json", http.HandlerFunc(s.OrderSchemaHandler), timeout)
+
+	if s.orderFeed != nil {
+		// Streams stay open for the life of the connection: no timeout.
+		// EventSource cannot send custom headers, so the stream follows
+		// the access rules of the web pages that consume it.
+		handle("GET /api/v1/orders/stream", http.HandlerFunc(s.OrderStreamHandler), web)
+	}
+
+	if s.analyticsUseCase != nil {
+		handle("GET /api/v1/analytics/revenue", http.HandlerFunc(s.RevenueHandler), read, limit, timeout)
+		handle("GET /api/v1/analytics/breakdown", http.HandlerFunc(s.BreakdownHandler), read, limit, timeout)
+		handle("GET /api/v1/analytics/basket", http.HandlerFunc(s.BasketHandler), read, limit, timeout)
+		handle("GET /api/v1/analytics/top-products", http.HandlerFunc(s.TopProductsHandler), read, limit, timeout)
+		// Revenue figures are not public even when the other pages are.
+		handle("GET /analytics", http.HandlerFunc(s.analyticsPage.DashboardHandler), read, limit, timeout)
+	}
+
+	// Admin routes
+	if s.webhookUseCase != nil {
+		handle("POST /api/v1/admin/webhooks", http.HandlerFunc(s.CreateWebhookHandler), admin, timeout)
+		handle("GET /api/v1/admin/webhooks", http.HandlerFunc(s.ListWebhooksHandler), admin, timeout)
+		handle("DELETE /api/v1/admin/webhooks/{id}", http.HandlerFunc(s.DeleteWebhookHandler), admin, timeout)
+		handle("GET /api/v1/admin/webhooks/{id}/dead-letters", http.HandlerFunc(s.WebhookDeadLettersHandler), admin, timeout)
+		handle("POST /api/v1/admin/webhooks/{id}/replay", http.HandlerFunc(s.ReplayWebhookHandler), admin, timeout)
+	}
+	if s.erasureUseCase != nil {
+		handle("POST /api/v1/admin/customers/{customer_id}/erasure", http.HandlerFunc(s.EraseCustomerHandler), admin, timeout)
+	}
 
 	// Web routes
-	mux.HandleFunc("/", s.webHandler.IndexHandler)
-	mux.HandleFunc("/order", s.webHandler.OrderPageHandler)
+	handle("/", http.HandlerFunc(s.webHandler.IndexHandler), web, timeout)
+	handle("/order", http.HandlerFunc(s.webHandler.OrderPageHandler), web, limit, timeout)
 
 	// Static files
-	mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("static"))))
+	handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("static"))), timeout)
 
 	return s
 }
 
 func (s *Server) GetOrderHandler(w http.ResponseWriter, r *http.Request) {
 	orderUID := r.URL.Path[len("/order/"):]
-	if orderUID == "" {
-		http.Error(w, "Order UID is required", http.StatusBadRequest)
+	order, err := s.orderUseCase.GetOrder(r.Context(), orderUID)
+	if err != nil {
+		s.writeError(w, r, err)
 		return
 	}
 
-	order, err := s.orderUseCase.GetOrder(orderUID)
-	if err != nil {
-		http.Error(w, "Order not found", http.StatusNotFound)
+	writeJSON(w, http.StatusOK, projection.Order(r.Context(), order))
+}
+
+type batchGetOrdersRequest struct {
+	OrderUIDs []string `json:"order_uids"`
+}
+
+func (s *Server) BatchGetOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	var req batchGetOrdersRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, r, err)
+		return
+	}
+	if len(req.OrderUIDs) == 0 {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidRequest, "order_uids is required")
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(order); err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	result, err := s.orderUseCase.GetOrders(r.Context(), req.OrderUIDs)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
 	}
+
+	result.Orders = projection.Orders(r.Context(), result.Orders)
+	writeJSON(w, http.StatusOK, result)
 }
 
 func (s *Server) StatsHandler(w http.ResponseWriter, r *http.Request) {
 	stats, err := s.orderUseCase.Stats()
 	if err != nil {
-		http.Error(w, "Failed to get stats", http.StatusInternalServerError)
+		s.writeError(w, r, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(stats)
+	writeJSON(w, http.StatusOK, stats)
 }
 
 func (s *Server) Start(addr string) error {
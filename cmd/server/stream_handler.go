@@ -0,0 +1,95 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"wb-tech-l0/internal/feed"
+)
+
+// OrderStreamHandler serves newly saved orders as Server-Sent Events.
+// Clients may filter by delivery_service and entry, and resume after a
+// reconnect through the Last-Event-ID header (or last_event_id parameter).
+func (s *Server) OrderStreamHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeProblem(w, r, http.StatusInternalServerError, CodeInternal, "streaming is not supported")
+		return
+	}
+
+	filter := feed.Filter{
+		DeliveryService: r.URL.Query().Get("delivery_service"),
+		Entry:           r.URL.Query().Get("entry"),
+	}
+
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("last_event_id")
+	}
+	var lastEventID uint64
+	if lastID != "" {
+		id, err := strconv.ParseUint(lastID, 10, 64)
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, CodeInvalidRequest, "invalid Last-Event-ID")
+			return
+		}
+		lastEventID = id
+	}
+
+	sub, missed := s.orderFeed.Subscribe(filter, lastEventID)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	_, _ = fmt.Fprintf(w, "retry: %d\n\n", streamRetry.Milliseconds())
+	for _, e := range missed {
+		if err := writeOrderEvent(w, e); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(s.streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.shutdown:
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case e, ok := <-sub.Events():
+			if !ok {
+				// Dropped as a slow consumer; the client reconnects and resumes.
+				return
+			}
+			if err := writeOrderEvent(w, e); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+const streamRetry = 3 * time.Second
+
+func writeOrderEvent(w http.ResponseWriter, e feed.Event) error {
+	data, err := json.Marshal(e.Summary)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: order\ndata: %s\n\n", e.ID, data)
+	return err
+}
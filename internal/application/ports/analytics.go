@@ -0,0 +1,91 @@
+package ports
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"wb-tech-l0/internal/models"
+)
+
+var ErrInvalidAnalyticsQuery = errors.New("invalid analytics query")
+
+// Intervals of a revenue report. Weeks start on Monday; both are in UTC.
+const (
+	IntervalDay  = "day"
+	IntervalWeek = "week"
+)
+
+// BreakdownDimensions are the order attributes a breakdown can group by.
+var BreakdownDimensions = []string{"delivery_service", "provider", "bank", "locale", "entry"}
+
+// Product rankings group items by brand or nm_id and rank them by units
+// sold or by revenue.
+var (
+	ProductKeys    = []string{"brand", "nm_id"}
+	ProductMetrics = []string{"units", "revenue"}
+)
+
+// AnalyticsRange selects orders created in [From, To). Zero bounds are
+// filled in by the use case.
+type AnalyticsRange struct {
+	From time.Time
+	To   time.Time
+}
+
+type RevenueQuery struct {
+	AnalyticsRange
+	Interval string
+}
+
+type BreakdownQuery struct {
+	AnalyticsRange
+	// By is one of BreakdownDimensions.
+	By string
+}
+
+type TopProductsQuery struct {
+	AnalyticsRange
+	// By is one of ProductKeys, Metric one of ProductMetrics.
+	By     string
+	Metric string
+	Limit  int
+}
+
+// ParseAnalyticsTime reads a range bound given as a date (2006-01-02,
+// midnight UTC) or an RFC 3339 time; empty leaves it unset.
+func ParseAnalyticsTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, errors.New("want a date (YYYY-MM-DD) or an RFC 3339 time")
+	}
+	return t, nil
+}
+
+// AnalyticsRepository aggregates stored orders. Amounts are summed per
+// currency and never converted.
+type AnalyticsRepository interface {
+	// Revenue counts orders and sums payment.amount per period and
+	// currency, oldest period first.
+	Revenue(ctx context.Context, q RevenueQuery) ([]models.PeriodRevenue, error)
+	// Breakdown counts orders and sums payment.amount per value of the
+	// dimension and currency, most orders first.
+	Breakdown(ctx context.Context, q BreakdownQuery) ([]models.BreakdownRow, error)
+	// Basket sums orders, items and goods totals per currency.
+	Basket(ctx context.Context, r AnalyticsRange) ([]models.BasketStats, error)
+	// TopProducts ranks brands or nm_ids per currency by the metric.
+	TopProducts(ctx context.Context, q TopProductsQuery) ([]models.ProductSales, error)
+}
+
+type AnalyticsUseCase interface {
+	Revenue(ctx context.Context, q RevenueQuery) (models.RevenueReport, error)
+	Breakdown(ctx context.Context, q BreakdownQuery) (models.BreakdownReport, error)
+	Basket(ctx context.Context, r AnalyticsRange) (models.BasketReport, error)
+	TopProducts(ctx context.Context, q TopProductsQuery) (models.TopProductsReport, error)
+}
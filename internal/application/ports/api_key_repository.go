@@ -0,0 +1,15 @@
+package ports
+
+import (
+	"context"
+	"errors"
+	"wb-tech-l0/internal/models"
+)
+
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+type APIKeyRepository interface {
+	// FindAPIKeyByHash returns the active key with the given hex SHA-256
+	// hash, or ErrAPIKeyNotFound.
+	FindAPIKeyByHash(ctx context.Context, keyHash string) (*models.APIKey, error)
+}
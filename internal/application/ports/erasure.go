@@ -0,0 +1,37 @@
+package ports
+
+import (
+	"context"
+	"errors"
+	"wb-tech-l0/internal/models"
+)
+
+var ErrCustomerIDRequired = errors.New("customer_id is required")
+
+// ErasureRequest asks to erase the personal data of one customer.
+type ErasureRequest struct {
+	CustomerID string
+	// RequestedBy identifies who asked for the erasure, for the audit log.
+	RequestedBy string
+	Reason      string
+	// DryRun only reports what would change.
+	DryRun bool
+}
+
+type ErasureRepository interface {
+	// EraseCustomerData anonymises the delivery data and payment
+	// transaction of every order of the customer, removes pending copies
+	// of those orders, evicts them from the cache and records an audit
+	// entry, all in one transaction. With DryRun nothing is written.
+	EraseCustomerData(ctx context.Context, req ErasureRequest) (models.ErasureReport, error)
+}
+
+type ErasureUseCase interface {
+	EraseCustomer(ctx context.Context, req ErasureRequest) (models.ErasureReport, error)
+}
+
+// ErasureObserver is notified after a customer's data has been erased, so
+// that copies of the orders held outside the database can be dropped.
+type ErasureObserver interface {
+	CustomerErased(report models.ErasureReport)
+}
@@ -0,0 +1,24 @@
+package ports
+
+import "errors"
+
+// Domain errors shared by the repositories and use cases. Adapters map
+// them to status codes of their transport; any other error is an internal
+// failure. Implementations wrap them with details, so compare with
+// errors.Is.
+var (
+	// ErrOrderNotFound means no order has the requested UID.
+	ErrOrderNotFound = errors.New("order not found")
+	// ErrInvalidOrder means an order, or the order UID of a request, is
+	// not valid.
+	ErrInvalidOrder = errors.New("invalid order")
+	// ErrConflict means the change clashed with a concurrent one; it may
+	// succeed when retried.
+	ErrConflict = errors.New("conflict with a concurrent change")
+	// ErrUnavailable means a backing service could not be reached; it may
+	// succeed later.
+	ErrUnavailable = errors.New("service unavailable")
+	// ErrBatchTooLarge means a batch request names more orders than one
+	// call may return.
+	ErrBatchTooLarge = errors.New("too many order uids in batch")
+)
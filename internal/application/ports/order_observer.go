@@ -0,0 +1,9 @@
+package ports
+
+import "wb-tech-l0/internal/models"
+
+// OrderObserver is notified after a save has stored a new or changed
+// order. Resaving an unchanged order notifies nobody.
+type OrderObserver interface {
+	OrderSaved(order *models.Order)
+}
@@ -0,0 +1,31 @@
+package ports
+
+import (
+	"errors"
+	"time"
+	"wb-tech-l0/internal/models"
+)
+
+var ErrInvalidCursor = errors.New("invalid page cursor")
+
+// OrderFilter narrows down listed orders; zero fields are ignored.
+type OrderFilter struct {
+	DeliveryService string
+	Entry           string
+	CustomerID      string
+	CreatedFrom     time.Time
+	CreatedTo       time.Time
+}
+
+// ListOrdersQuery requests a page of orders, newest first. Cursor is the
+// NextCursor of the previous page, empty for the first one.
+type ListOrdersQuery struct {
+	Filter OrderFilter
+	Limit  int
+	Cursor string
+}
+
+type OrderPage struct {
+	Orders     []*models.Order
+	NextCursor string
+}
@@ -1,10 +1,27 @@
 package ports
 
-import "wb-tech-l0/internal/models"
+import (
+	"context"
+	"wb-tech-l0/internal/models"
+)
 
 type OrderRepository interface {
-	SaveOrder(order *models.Order) error
-	GetOrder(orderUID string) (*models.Order, error)
+	// SaveOrder stores or replaces the order. changed is false when the
+	// stored order was the same and nothing was written.
+	SaveOrder(ctx context.Context, order *models.Order) (changed bool, err error)
+	// SaveOrders saves the orders in one transaction, as if by SaveOrder
+	// in order; either all of them are stored or none is. changed[i]
+	// reports whether orders[i] was written.
+	SaveOrders(ctx context.Context, orders []*models.Order) (changed []bool, err error)
+	GetOrder(ctx context.Context, orderUID string) (*models.Order, error)
+	// GetOrders returns the stored orders among orderUIDs keyed by UID;
+	// unknown UIDs are simply absent from the result.
+	GetOrders(ctx context.Context, orderUIDs []string) (map[string]*models.Order, error)
+	ListOrders(ctx context.Context, query ListOrdersQuery) (OrderPage, error)
+	// ExportOrders calls fn for every order matching filter, oldest first,
+	// without holding them all in memory. An error from fn stops the
+	// export and is returned as is.
+	ExportOrders(ctx context.Context, filter OrderFilter, fn func(*models.Order) error) error
 	GetOrderCount() (int64, error)
 	LoadOrdersToCache(maxOrdersCount int) error
 	CacheSize() int
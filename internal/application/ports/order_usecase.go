@@ -1,14 +1,30 @@
 package ports
 
-import "wb-tech-l0/internal/models"
+import (
+	"context"
+	"wb-tech-l0/internal/models"
+)
 
 type OrderUseCase interface {
-	SaveOrder(order *models.Order) error
-	GetOrder(uid string) (*models.Order, error)
+	SaveOrder(ctx context.Context, order *models.Order) error
+	// SaveOrders saves the orders all together or not at all.
+	SaveOrders(ctx context.Context, orders []*models.Order) error
+	GetOrder(ctx context.Context, uid string) (*models.Order, error)
+	GetOrders(ctx context.Context, uids []string) (BatchOrders, error)
+	ListOrders(ctx context.Context, query ListOrdersQuery) (OrderPage, error)
+	// ExportOrders streams the orders matching filter to fn, oldest first.
+	ExportOrders(ctx context.Context, filter OrderFilter, fn func(*models.Order) error) error
 	Stats() (OrderStats, error)
 	LoadOrdersToCache(maxOrdersCount int) error
 }
 
+// BatchOrders is the result of a batch lookup: the orders found, in request
+// order, and the requested UIDs that do not exist.
+type BatchOrders struct {
+	Orders  []*models.Order `json:"orders"`
+	Missing []string        `json:"missing"`
+}
+
 type OrderStats struct {
 	CacheSize int   `json:"cache_size"`
 	DBCount   int64 `json:"db_count"`
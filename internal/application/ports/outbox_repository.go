@@ -0,0 +1,26 @@
+package ports
+
+import (
+	"context"
+	"time"
+)
+
+// OutboxEvent is a pending event read from the transactional outbox.
+type OutboxEvent struct {
+	ID        uint64
+	EventID   string
+	EventType string
+	OrderUID  string
+	Payload   []byte
+	CreatedAt time.Time
+}
+
+type OutboxRepository interface {
+	// RelayOutboxEvents locks up to limit pending events in insertion
+	// order, passes them to publish and removes the ones publish returns
+	// as published, in one transaction. Relays running concurrently wait
+	// for each other, so an event is not published by two of them and
+	// the events of an order stay in order. It returns the number of
+	// removed events.
+	RelayOutboxEvents(ctx context.Context, limit int, publish func([]OutboxEvent) []uint64) (int, error)
+}
@@ -0,0 +1,40 @@
+package ports
+
+import (
+	"errors"
+	"time"
+	"wb-tech-l0/internal/models"
+)
+
+var (
+	ErrSubscriptionNotFound = errors.New("webhook subscription not found")
+	ErrInvalidSubscription  = errors.New("invalid webhook subscription")
+)
+
+type WebhookRepository interface {
+	CreateSubscription(sub *models.WebhookSubscription) error
+	GetSubscription(id uint) (*models.WebhookSubscription, error)
+	ListSubscriptions() ([]models.WebhookSubscription, error)
+	// DeleteSubscription removes the subscription together with its deliveries.
+	DeleteSubscription(id uint) error
+
+	// ClaimDueDeliveries returns pending deliveries scheduled at or before
+	// now, in id order, and claims them until now+ttl so that no other
+	// caller gets them meanwhile. A delivery is held back while an earlier
+	// pending delivery of its subscription is not due yet or claimed by
+	// another caller, so each subscriber receives its events in order.
+	ClaimDueDeliveries(now time.Time, limit int, ttl time.Duration) ([]models.WebhookDelivery, error)
+	// ReleaseDeliveries gives up the claim on deliveries that were not sent.
+	ReleaseDeliveries(ids []uint) error
+	// MarkDelivered removes a delivered delivery, payload included.
+	MarkDelivered(id uint) error
+	// MarkFailed records a failed attempt and either reschedules the delivery
+	// at nextAttemptAt or, if dead is set, moves it to the dead-letter list.
+	// The claim on it is released.
+	MarkFailed(id uint, attempts int, nextAttemptAt time.Time, dead bool, lastErr string) error
+
+	ListDeadLetters(subscriptionID uint) ([]models.WebhookDelivery, error)
+	// ReplayDeliveries puts dead deliveries of the subscription back into the
+	// queue. An empty deliveryIDs list replays the whole dead-letter list.
+	ReplayDeliveries(subscriptionID uint, deliveryIDs []uint) (int64, error)
+}
@@ -0,0 +1,11 @@
+package ports
+
+import "wb-tech-l0/internal/models"
+
+type WebhookUseCase interface {
+	CreateSubscription(url, secret string, eventTypes []string) (*models.WebhookSubscription, error)
+	ListSubscriptions() ([]models.WebhookSubscription, error)
+	DeleteSubscription(id uint) error
+	DeadLetters(subscriptionID uint) ([]models.WebhookDelivery, error)
+	Replay(subscriptionID uint, deliveryIDs []uint) (int64, error)
+}
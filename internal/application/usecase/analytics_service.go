@@ -0,0 +1,131 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"wb-tech-l0/internal/application/ports"
+	"wb-tech-l0/internal/models"
+)
+
+// Analytics defaults: the last DefaultAnalyticsDays days including today,
+// and the length of product rankings.
+const (
+	DefaultAnalyticsDays = 30
+	DefaultTopProducts   = 10
+	MaxTopProducts       = 100
+)
+
+type AnalyticsService struct {
+	repo ports.AnalyticsRepository
+	now  func() time.Time
+}
+
+func NewAnalyticsService(repo ports.AnalyticsRepository) *AnalyticsService {
+	return &AnalyticsService{repo: repo, now: time.Now}
+}
+
+func (s *AnalyticsService) Revenue(ctx context.Context, q ports.RevenueQuery) (models.RevenueReport, error) {
+	if q.Interval == "" {
+		q.Interval = ports.IntervalDay
+	}
+	if q.Interval != ports.IntervalDay && q.Interval != ports.IntervalWeek {
+		return models.RevenueReport{}, fmt.Errorf("%w: interval must be day or week", ports.ErrInvalidAnalyticsQuery)
+	}
+	r, err := s.normalize(q.AnalyticsRange)
+	if err != nil {
+		return models.RevenueReport{}, err
+	}
+	q.AnalyticsRange = r
+
+	points, err := s.repo.Revenue(ctx, q)
+	if err != nil {
+		return models.RevenueReport{}, err
+	}
+	return models.RevenueReport{From: r.From, To: r.To, Interval: q.Interval, Points: points}, nil
+}
+
+func (s *AnalyticsService) Breakdown(ctx context.Context, q ports.BreakdownQuery) (models.BreakdownReport, error) {
+	if !slices.Contains(ports.BreakdownDimensions, q.By) {
+		return models.BreakdownReport{}, fmt.Errorf("%w: by must be one of %v", ports.ErrInvalidAnalyticsQuery, ports.BreakdownDimensions)
+	}
+	r, err := s.normalize(q.AnalyticsRange)
+	if err != nil {
+		return models.BreakdownReport{}, err
+	}
+	q.AnalyticsRange = r
+
+	rows, err := s.repo.Breakdown(ctx, q)
+	if err != nil {
+		return models.BreakdownReport{}, err
+	}
+	return models.BreakdownReport{From: r.From, To: r.To, By: q.By, Rows: rows}, nil
+}
+
+func (s *AnalyticsService) Basket(ctx context.Context, r ports.AnalyticsRange) (models.BasketReport, error) {
+	r, err := s.normalize(r)
+	if err != nil {
+		return models.BasketReport{}, err
+	}
+
+	stats, err := s.repo.Basket(ctx, r)
+	if err != nil {
+		return models.BasketReport{}, err
+	}
+	for i := range stats {
+		if st := &stats[i]; st.Orders > 0 {
+			st.AvgItems = float64(st.Items) / float64(st.Orders)
+			st.AvgValue = float64(st.GoodsTotal) / float64(st.Orders)
+		}
+	}
+	return models.BasketReport{From: r.From, To: r.To, Currencies: stats}, nil
+}
+
+func (s *AnalyticsService) TopProducts(ctx context.Context, q ports.TopProductsQuery) (models.TopProductsReport, error) {
+	if q.By == "" {
+		q.By = "brand"
+	}
+	if q.Metric == "" {
+		q.Metric = "revenue"
+	}
+	if !slices.Contains(ports.ProductKeys, q.By) {
+		return models.TopProductsReport{}, fmt.Errorf("%w: by must be one of %v", ports.ErrInvalidAnalyticsQuery, ports.ProductKeys)
+	}
+	if !slices.Contains(ports.ProductMetrics, q.Metric) {
+		return models.TopProductsReport{}, fmt.Errorf("%w: metric must be one of %v", ports.ErrInvalidAnalyticsQuery, ports.ProductMetrics)
+	}
+	if q.Limit <= 0 {
+		q.Limit = DefaultTopProducts
+	}
+	q.Limit = min(q.Limit, MaxTopProducts)
+
+	r, err := s.normalize(q.AnalyticsRange)
+	if err != nil {
+		return models.TopProductsReport{}, err
+	}
+	q.AnalyticsRange = r
+
+	products, err := s.repo.TopProducts(ctx, q)
+	if err != nil {
+		return models.TopProductsReport{}, err
+	}
+	return models.TopProductsReport{From: r.From, To: r.To, By: q.By, Metric: q.Metric, Products: products}, nil
+}
+
+// normalize fills in missing bounds: To defaults to the end of today and
+// From to DefaultAnalyticsDays days before To, both in UTC.
+func (s *AnalyticsService) normalize(r ports.AnalyticsRange) (ports.AnalyticsRange, error) {
+	if r.To.IsZero() {
+		r.To = s.now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 1)
+	}
+	if r.From.IsZero() {
+		r.From = r.To.AddDate(0, 0, -DefaultAnalyticsDays)
+	}
+	if !r.From.Before(r.To) {
+		return r, fmt.Errorf("%w: from must be before to", ports.ErrInvalidAnalyticsQuery)
+	}
+	r.From, r.To = r.From.UTC(), r.To.UTC()
+	return r, nil
+}
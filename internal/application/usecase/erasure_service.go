@@ -0,0 +1,39 @@
+package usecase
+
+import (
+	"context"
+	"strings"
+
+	"wb-tech-l0/internal/application/ports"
+	"wb-tech-l0/internal/models"
+)
+
+type ErasureService struct {
+	repo      ports.ErasureRepository
+	observers []ports.ErasureObserver
+}
+
+func NewErasureService(repo ports.ErasureRepository, observers ...ports.ErasureObserver) *ErasureService {
+	return &ErasureService{repo: repo, observers: observers}
+}
+
+// EraseCustomer anonymises the personal data of the customer's orders.
+// Erasing a customer twice is harmless: the second run finds only
+// already anonymised orders.
+func (s *ErasureService) EraseCustomer(ctx context.Context, req ports.ErasureRequest) (models.ErasureReport, error) {
+	req.CustomerID = strings.TrimSpace(req.CustomerID)
+	if req.CustomerID == "" {
+		return models.ErasureReport{}, ports.ErrCustomerIDRequired
+	}
+	if req.RequestedBy == "" {
+		req.RequestedBy = "unknown"
+	}
+	report, err := s.repo.EraseCustomerData(ctx, req)
+	if err != nil || req.DryRun {
+		return report, err
+	}
+	for _, o := range s.observers {
+		o.CustomerErased(report)
+	}
+	return report, nil
+}
@@ -1,24 +1,120 @@
 package usecase
 
 import (
+	"context"
+	"fmt"
+
 	"wb-tech-l0/internal/application/ports"
 	"wb-tech-l0/internal/models"
 )
 
 type OrderService struct {
-	repo ports.OrderRepository
+	repo      ports.OrderRepository
+	observers []ports.OrderObserver
+}
+
+func NewOrderService(repo ports.OrderRepository, observers ...ports.OrderObserver) *OrderService {
+	return &OrderService{repo: repo, observers: observers}
+}
+
+func (s *OrderService) GetOrder(ctx context.Context, uid string) (*models.Order, error) {
+	if uid == "" {
+		return nil, fmt.Errorf("%w: order uid is required", ports.ErrInvalidOrder)
+	}
+	return s.repo.GetOrder(ctx, uid)
+}
+
+// MaxBatchSize limits the number of UIDs accepted by GetOrders.
+const MaxBatchSize = 500
+
+func (s *OrderService) GetOrders(ctx context.Context, uids []string) (ports.BatchOrders, error) {
+	unique := make([]string, 0, len(uids))
+	seen := make(map[string]bool, len(uids))
+	for _, uid := range uids {
+		if uid == "" || seen[uid] {
+			continue
+		}
+		seen[uid] = true
+		unique = append(unique, uid)
+	}
+	if len(unique) > MaxBatchSize {
+		return ports.BatchOrders{}, ports.ErrBatchTooLarge
+	}
+
+	found, err := s.repo.GetOrders(ctx, unique)
+	if err != nil {
+		return ports.BatchOrders{}, err
+	}
+
+	result := ports.BatchOrders{
+		Orders:  make([]*models.Order, 0, len(found)),
+		Missing: []string{},
+	}
+	for _, uid := range unique {
+		if order, ok := found[uid]; ok {
+			result.Orders = append(result.Orders, order)
+		} else {
+			result.Missing = append(result.Missing, uid)
+		}
+	}
+	return result, nil
+}
+
+// Page size limits applied to ListOrders.
+const (
+	DefaultListLimit = 50
+	MaxListLimit     = 500
+)
+
+func (s *OrderService) ListOrders(ctx context.Context, query ports.ListOrdersQuery) (ports.OrderPage, error) {
+	if query.Limit <= 0 {
+		query.Limit = DefaultListLimit
+	}
+	if query.Limit > MaxListLimit {
+		query.Limit = MaxListLimit
+	}
+	return s.repo.ListOrders(ctx, query)
 }
 
-func NewOrderService(repo ports.OrderRepository) *OrderService {
-	return &OrderService{repo: repo}
+func (s *OrderService) ExportOrders(ctx context.Context, filter ports.OrderFilter, fn func(*models.Order) error) error {
+	return s.repo.ExportOrders(ctx, filter, fn)
 }
 
-func (s *OrderService) GetOrder(uid string) (*models.Order, error) {
-	return s.repo.GetOrder(uid)
+func (s *OrderService) SaveOrder(ctx context.Context, order *models.Order) error {
+	if order == nil || order.OrderUID == "" {
+		return fmt.Errorf("%w: order uid is required", ports.ErrInvalidOrder)
+	}
+	changed, err := s.repo.SaveOrder(ctx, order)
+	if err != nil || !changed {
+		return err
+	}
+
+	for _, o := range s.observers {
+		o.OrderSaved(order)
+	}
+	return nil
 }
 
-func (s *OrderService) SaveOrder(order *models.Order) error {
-	return s.repo.SaveOrder(order)
+func (s *OrderService) SaveOrders(ctx context.Context, orders []*models.Order) error {
+	for _, order := range orders {
+		if order == nil || order.OrderUID == "" {
+			return fmt.Errorf("%w: order uid is required", ports.ErrInvalidOrder)
+		}
+	}
+	changed, err := s.repo.SaveOrders(ctx, orders)
+	if err != nil {
+		return err
+	}
+
+	for i, order := range orders {
+		if !changed[i] {
+			continue
+		}
+		for _, o := range s.observers {
+			o.OrderSaved(order)
+		}
+	}
+	return nil
 }
 
 func (s *OrderService) Stats() (ports.OrderStats, error) {
@@ -0,0 +1,84 @@
+package usecase
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"slices"
+
+	"wb-tech-l0/internal/application/ports"
+	"wb-tech-l0/internal/models"
+)
+
+type WebhookService struct {
+	repo ports.WebhookRepository
+}
+
+func NewWebhookService(repo ports.WebhookRepository) *WebhookService {
+	return &WebhookService{repo: repo}
+}
+
+// CreateSubscription registers a subscriber. When secret is empty a random
+// one is generated; it is returned only in the response of this call.
+func (s *WebhookService) CreateSubscription(rawURL, secret string, eventTypes []string) (*models.WebhookSubscription, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("%w: invalid url %q", ports.ErrInvalidSubscription, rawURL)
+	}
+
+	for _, t := range eventTypes {
+		if !slices.Contains(models.EventTypes, t) {
+			return nil, fmt.Errorf("%w: unknown event type %q", ports.ErrInvalidSubscription, t)
+		}
+	}
+
+	if secret == "" {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+		secret = hex.EncodeToString(buf)
+	}
+
+	sub := &models.WebhookSubscription{
+		URL:        u.String(),
+		Secret:     secret,
+		EventTypes: eventTypes,
+		Active:     true,
+	}
+	if err := s.repo.CreateSubscription(sub); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// ListSubscriptions returns all subscriptions with their secrets stripped.
+func (s *WebhookService) ListSubscriptions() ([]models.WebhookSubscription, error) {
+	subs, err := s.repo.ListSubscriptions()
+	if err != nil {
+		return nil, err
+	}
+	for i := range subs {
+		subs[i].Secret = ""
+	}
+	return subs, nil
+}
+
+func (s *WebhookService) DeleteSubscription(id uint) error {
+	return s.repo.DeleteSubscription(id)
+}
+
+func (s *WebhookService) DeadLetters(subscriptionID uint) ([]models.WebhookDelivery, error) {
+	if _, err := s.repo.GetSubscription(subscriptionID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListDeadLetters(subscriptionID)
+}
+
+func (s *WebhookService) Replay(subscriptionID uint, deliveryIDs []uint) (int64, error) {
+	if _, err := s.repo.GetSubscription(subscriptionID); err != nil {
+		return 0, err
+	}
+	return s.repo.ReplayDeliveries(subscriptionID, deliveryIDs)
+}
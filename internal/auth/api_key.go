@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+
+	"wb-tech-l0/internal/application/ports"
+	"wb-tech-l0/internal/models"
+)
+
+// APIKeyPrefix marks generated keys so they are easy to recognise, for
+// example by secret scanners.
+const APIKeyPrefix = "wbk_"
+
+// HashAPIKey returns the hex SHA-256 hash under which a key is stored.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateAPIKey returns a new random key and its hash.
+func GenerateAPIKey() (key, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	key = APIKeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+	return key, HashAPIKey(key), nil
+}
+
+// StaticKeys is an in-memory key store for keys defined in the config.
+type StaticKeys map[string]models.APIKey
+
+var _ ports.APIKeyRepository = StaticKeys(nil)
+
+func NewStaticKeys(keys []models.APIKey) StaticKeys {
+	s := make(StaticKeys, len(keys))
+	for _, k := range keys {
+		s[strings.ToLower(k.KeyHash)] = k
+	}
+	return s
+}
+
+func (s StaticKeys) FindAPIKeyByHash(_ context.Context, keyHash string) (*models.APIKey, error) {
+	k, ok := s[keyHash]
+	if !ok {
+		return nil, ports.ErrAPIKeyNotFound
+	}
+	return &k, nil
+}
@@ -0,0 +1,212 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"wb-tech-l0/internal/models"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCredentials(t *testing.T) {
+	basic := func(user, pass string) string {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+pass))
+	}
+
+	tests := []struct {
+		name          string
+		authorization string
+		apiKey        string
+		want          Credentials
+	}{
+		{"api key header", "", "wbk_key", Credentials{APIKey: "wbk_key"}},
+		{"api key wins", "Bearer a.b.c", "wbk_key", Credentials{APIKey: "wbk_key"}},
+		{"bearer", "Bearer a.b.c", "", Credentials{BearerToken: "a.b.c"}},
+		{"bearer lower case", "bearer a.b.c", "", Credentials{BearerToken: "a.b.c"}},
+		{"basic with key", basic("support", "wbk_key"), "", Credentials{APIKey: "wbk_key"}},
+		{"basic with token", basic("", "a.b.c"), "", Credentials{BearerToken: "a.b.c"}},
+		{"broken basic", "Basic %%%", "", Credentials{}},
+		{"unknown scheme", "Digest xyz", "", Credentials{}},
+		{"nothing", "", "", Credentials{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ParseCredentials(tt.authorization, tt.apiKey))
+		})
+	}
+}
+
+func TestPrincipal_HasScope(t *testing.T) {
+	reader := &Principal{Scopes: []string{ScopeOrdersRead}}
+	assert.True(t, reader.HasScope(ScopeOrdersRead))
+	assert.False(t, reader.HasScope(ScopeOrdersWrite))
+	assert.False(t, reader.HasScope(ScopeAdmin))
+
+	admin := &Principal{Scopes: []string{ScopeAdmin}}
+	assert.True(t, admin.HasScope(ScopeOrdersRead))
+	assert.True(t, admin.HasScope(ScopeOrdersWrite))
+}
+
+type failingStore struct{}
+
+func (failingStore) FindAPIKeyByHash(context.Context, string) (*models.APIKey, error) {
+	return nil, errors.New("db is down")
+}
+
+func TestAuthenticator_APIKeys(t *testing.T) {
+	key, hash, err := GenerateAPIKey()
+	require.NoError(t, err)
+	assert.Equal(t, HashAPIKey(key), hash)
+
+	static := NewStaticKeys([]models.APIKey{{Name: "support", KeyHash: hash, Scopes: []string{ScopeOrdersRead}}})
+	a := NewAuthenticator(nil, static)
+	ctx := context.Background()
+
+	p, err := a.Authenticate(ctx, Credentials{APIKey: key})
+	require.NoError(t, err)
+	assert.Equal(t, "apikey:support", p.Subject)
+	assert.Equal(t, MethodAPIKey, p.Method)
+	assert.Equal(t, []string{ScopeOrdersRead}, p.Scopes)
+
+	_, err = a.Authenticate(ctx, Credentials{APIKey: "wbk_unknown"})
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	_, err = a.Authenticate(ctx, Credentials{})
+	assert.ErrorIs(t, err, ErrNoCredentials)
+
+	// Tokens are rejected when JWT is not configured.
+	_, err = a.Authenticate(ctx, Credentials{BearerToken: "a.b.c"})
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	// Store failures are not reported as bad credentials.
+	_, err = NewAuthenticator(nil, failingStore{}).Authenticate(ctx, Credentials{APIKey: key})
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, ErrInvalidCredentials)
+}
+
+func TestTokenVerifier_HMAC(t *testing.T) {
+	secret := []byte("test-secret")
+	v, err := NewTokenVerifier(TokenConfig{HMACSecret: secret, Issuer: "https://issuer", Audience: "wb-orders"})
+	require.NoError(t, err)
+
+	sign := func(claims jwt.MapClaims) string {
+		s, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret)
+		require.NoError(t, err)
+		return s
+	}
+	valid := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"sub":   "agent-7",
+			"iss":   "https://issuer",
+			"aud":   "wb-orders",
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"scope": "orders:read orders:write",
+		}
+	}
+
+	p, err := v.Verify(sign(valid()))
+	require.NoError(t, err)
+	assert.Equal(t, "agent-7", p.Subject)
+	assert.Equal(t, MethodJWT, p.Method)
+	assert.Equal(t, []string{ScopeOrdersRead, ScopeOrdersWrite}, p.Scopes)
+
+	scp := valid()
+	delete(scp, "scope")
+	scp["scp"] = []string{ScopeAdmin}
+	p, err = v.Verify(sign(scp))
+	require.NoError(t, err)
+	assert.Equal(t, []string{ScopeAdmin}, p.Scopes)
+
+	rejected := map[string]func(jwt.MapClaims){
+		"expired":      func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() },
+		"no exp":       func(c jwt.MapClaims) { delete(c, "exp") },
+		"wrong issuer": func(c jwt.MapClaims) { c["iss"] = "https://evil" },
+		"wrong aud":    func(c jwt.MapClaims) { c["aud"] = "other" },
+	}
+	for name, mutate := range rejected {
+		t.Run(name, func(t *testing.T) {
+			c := valid()
+			mutate(c)
+			_, err := v.Verify(sign(c))
+			assert.ErrorIs(t, err, ErrInvalidCredentials)
+		})
+	}
+
+	t.Run("wrong secret", func(t *testing.T) {
+		s, err := jwt.NewWithClaims(jwt.SigningMethodHS256, valid()).SignedString([]byte("other"))
+		require.NoError(t, err)
+		_, err = v.Verify(s)
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+	})
+
+	t.Run("none algorithm", func(t *testing.T) {
+		s, err := jwt.NewWithClaims(jwt.SigningMethodNone, valid()).SignedString(jwt.UnsafeAllowNoneSignatureType)
+		require.NoError(t, err)
+		_, err = v.Verify(s)
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+	})
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func TestTokenVerifier_JWKS(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	doc, err := json.Marshal(map[string]any{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rsa-1", "use": "sig", "n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+		{"kty": "EC", "kid": "ec-1", "crv": "P-256", "x": b64(ecKey.X.Bytes()), "y": b64(ecKey.Y.Bytes())},
+		{"kty": "RSA", "kid": "enc-1", "use": "enc", "n": "AQAB", "e": "AQAB"},
+	}})
+	require.NoError(t, err)
+
+	set, err := ParseKeySet(doc)
+	require.NoError(t, err)
+	v, err := NewTokenVerifier(TokenConfig{JWKS: set})
+	require.NoError(t, err)
+
+	claims := jwt.MapClaims{"sub": "svc", "exp": time.Now().Add(time.Hour).Unix(), "scope": ScopeOrdersRead}
+	sign := func(method jwt.SigningMethod, kid string, key any) string {
+		tok := jwt.NewWithClaims(method, claims)
+		tok.Header["kid"] = kid
+		s, err := tok.SignedString(key)
+		require.NoError(t, err)
+		return s
+	}
+
+	p, err := v.Verify(sign(jwt.SigningMethodRS256, "rsa-1", rsaKey))
+	require.NoError(t, err)
+	assert.Equal(t, "svc", p.Subject)
+
+	_, err = v.Verify(sign(jwt.SigningMethodES256, "ec-1", ecKey))
+	require.NoError(t, err)
+
+	_, err = v.Verify(sign(jwt.SigningMethodRS256, "unknown", rsaKey))
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	// HMAC tokens are not accepted without a configured secret, which
+	// prevents using the public key as an HMAC secret.
+	_, err = v.Verify(sign(jwt.SigningMethodHS256, "rsa-1", []byte("x")))
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+}
+
+func TestNewTokenVerifier_RequiresKeys(t *testing.T) {
+	_, err := NewTokenVerifier(TokenConfig{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type TokenConfig struct {
+	// HMACSecret enables HS256/HS384/HS512 tokens.
+	HMACSecret []byte
+	// JWKS enables asymmetric tokens signed by one of its keys.
+	JWKS *KeySet
+	// Issuer and Audience are checked when set.
+	Issuer   string
+	Audience string
+	// Leeway tolerates clock skew in exp/nbf/iat checks.
+	Leeway time.Duration
+}
+
+// TokenVerifier validates JWT bearer tokens. Tokens must carry exp; the
+// caller's scopes are read from the space-separated "scope" claim or the
+// "scp" array claim.
+type TokenVerifier struct {
+	cfg    TokenConfig
+	parser *jwt.Parser
+}
+
+type tokenClaims struct {
+	jwt.RegisteredClaims
+	Scope string   `json:"scope,omitempty"`
+	Scp   []string `json:"scp,omitempty"`
+}
+
+func NewTokenVerifier(cfg TokenConfig) (*TokenVerifier, error) {
+	var methods []string
+	if len(cfg.HMACSecret) > 0 {
+		methods = append(methods, "HS256", "HS384", "HS512")
+	}
+	if cfg.JWKS != nil {
+		methods = append(methods, "RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA")
+	}
+	if len(methods) == 0 {
+		return nil, errors.New("token verifier needs an HMAC secret or a JWKS")
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(methods),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(cfg.Leeway),
+	}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+
+	return &TokenVerifier{cfg: cfg, parser: jwt.NewParser(opts...)}, nil
+}
+
+// Verify checks the token signature and claims. Every failure is reported
+// as ErrInvalidCredentials wrapping the reason.
+func (v *TokenVerifier) Verify(token string) (*Principal, error) {
+	var claims tokenClaims
+	if _, err := v.parser.ParseWithClaims(token, &claims, v.key); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+
+	scopes := claims.Scp
+	if claims.Scope != "" {
+		scopes = strings.Fields(claims.Scope)
+	}
+	return &Principal{
+		Subject: claims.Subject,
+		Method:  MethodJWT,
+		Scopes:  scopes,
+	}, nil
+}
+
+func (v *TokenVerifier) key(t *jwt.Token) (interface{}, error) {
+	if _, ok := t.Method.(*jwt.SigningMethodHMAC); ok {
+		return v.cfg.HMACSecret, nil
+	}
+
+	kid, _ := t.Header["kid"].(string)
+	key, ok := v.cfg.JWKS.Lookup(kid)
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key, nil
+}
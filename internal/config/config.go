@@ -1,6 +1,7 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
 	"strings"
 	"time"
@@ -10,18 +11,96 @@ import (
 
 type Config struct {
 	HTTPAddr string
+	GRPCAddr string
+
+	HTTPRequestTimeout time.Duration
+	HTTPMaxBodyBytes   int64
 
 	PostgresDSN  string
 	RedisAddr    string
 	KafkaBrokers []string
 	KafkaTopic   string
 
+	// Format of consumed messages: auto, json, avro or protobuf. Avro and
+	// Protobuf schemas are looked up in the schema registry.
+	KafkaMessageFormat     string
+	SchemaRegistryURL      string
+	SchemaRegistryUsername string
+	SchemaRegistryPassword string
+	SchemaRegistryTimeout  time.Duration
+
+	// Offsets are committed under KafkaConsumerGroup. Up to
+	// KafkaConsumerConcurrency messages with different keys are processed
+	// at once, with KafkaConsumerQueueDepth messages buffered per worker.
+	KafkaConsumerGroup       string
+	KafkaConsumerConcurrency int
+	KafkaConsumerQueueDepth  int
+	// Each worker saves up to KafkaConsumerBatchSize orders in one
+	// transaction, waiting at most KafkaConsumerBatchTimeout to fill it.
+	KafkaConsumerBatchSize    int
+	KafkaConsumerBatchTimeout time.Duration
+	// Messages whose order fails to save permanently are copied to
+	// KafkaDeadLetterTopic and committed.
+	KafkaDeadLetterTopic string
+
+	OutboxTopic        string
+	OutboxBatchSize    int
+	OutboxPollInterval time.Duration
+
+	WebhookBatchSize    int
+	WebhookPollInterval time.Duration
+	WebhookTimeout      time.Duration
+	WebhookMaxAttempts  int
+	WebhookBackoffBase  time.Duration
+	WebhookBackoffMax   time.Duration
+	WebhookClaimTTL     time.Duration
+
+	StreamBufferSize int
+	StreamHeartbeat  time.Duration
+
+	AuthEnabled       bool
+	AuthProtectWeb    bool
+	AuthAPIKeys       []APIKeyConfig
+	AuthJWTHMACSecret string
+	AuthJWTJWKSFile   string
+	AuthJWTIssuer     string
+	AuthJWTAudience   string
+	AuthJWTLeeway     time.Duration
+
+	// Per-client token bucket limit of the public order endpoints.
+	RateLimitEnabled    bool
+	RateLimitBackend    string
+	RateLimitRate       float64
+	RateLimitBurst      int
+	RateLimitTrustProxy bool
+
+	// Customer data encryption keys: a JSON keyfile, or "id:base64,..."
+	// with the primary key first. Encryption is off when both are empty.
+	PIIEncryptionKeyfile string
+	PIIEncryptionKeys    string
+
+	LogLevel  string
+	LogFormat string
+
+	TracingExporter     string
+	TracingOTLPEndpoint string
+	TracingOTLPInsecure bool
+	TracingSampleRatio  float64
+
 	CachePreloadCount int
 
 	CacheTTL        time.Duration
 	ShutdownTimeout time.Duration
 }
 
+// APIKeyConfig is a static API key. Only the hex SHA-256 of the key is
+// configured, never the key itself.
+type APIKeyConfig struct {
+	Name   string   `mapstructure:"name" json:"name"`
+	SHA256 string   `mapstructure:"sha256" json:"sha256"`
+	Scopes []string `mapstructure:"scopes" json:"scopes"`
+}
+
 func Load() *Config {
 	v := viper.New()
 	v.AutomaticEnv() // read from ENV
@@ -45,6 +124,10 @@ func Load() *Config {
 	if httpAddr == "" {
 		httpAddr = ":8080"
 	}
+	grpcAddr := v.GetString("GRPC_ADDR")
+	if grpcAddr == "" {
+		grpcAddr = ":9090"
+	}
 
 	// ----------- Backing services ---------------------------------------
 	postgresDSN := required("POSTGRES_DSN")
@@ -67,6 +150,48 @@ func Load() *Config {
 		kafkaTopic = "orders"
 	}
 
+	kafkaMessageFormat := strings.ToLower(v.GetString("KAFKA_MESSAGE_FORMAT"))
+	schemaRegistryURL := v.GetString("SCHEMA_REGISTRY_URL")
+	switch kafkaMessageFormat {
+	case "":
+		kafkaMessageFormat = "auto"
+	case "auto", "json":
+	case "avro", "protobuf":
+		if schemaRegistryURL == "" {
+			panic(fmt.Sprintf("KAFKA_MESSAGE_FORMAT %s requires SCHEMA_REGISTRY_URL", kafkaMessageFormat))
+		}
+	default:
+		panic(fmt.Sprintf("KAFKA_MESSAGE_FORMAT must be auto, json, avro or protobuf, got %q", kafkaMessageFormat))
+	}
+
+	kafkaConsumerGroup := v.GetString("KAFKA_CONSUMER_GROUP")
+	if kafkaConsumerGroup == "" {
+		kafkaConsumerGroup = "wb-orders"
+	}
+	kafkaConsumerConcurrency := v.GetInt("KAFKA_CONSUMER_CONCURRENCY")
+	if kafkaConsumerConcurrency <= 0 {
+		kafkaConsumerConcurrency = 8
+	}
+	kafkaConsumerQueueDepth := v.GetInt("KAFKA_CONSUMER_QUEUE_DEPTH")
+	if kafkaConsumerQueueDepth <= 0 {
+		kafkaConsumerQueueDepth = 100
+	}
+
+	kafkaConsumerBatchSize := v.GetInt("KAFKA_CONSUMER_BATCH_SIZE")
+	if kafkaConsumerBatchSize <= 0 {
+		kafkaConsumerBatchSize = 100
+	}
+
+	kafkaDeadLetterTopic := v.GetString("KAFKA_DEAD_LETTER_TOPIC")
+	if kafkaDeadLetterTopic == "" {
+		kafkaDeadLetterTopic = kafkaTopic + "-dlq"
+	}
+
+	outboxTopic := v.GetString("OUTBOX_TOPIC")
+	if outboxTopic == "" {
+		outboxTopic = "order-events"
+	}
+
 	// ----------- Application behaviour ----------------------------------
 	parseDur := func(key string, def time.Duration) time.Duration {
 		s := v.GetString(key)
@@ -83,14 +208,182 @@ func Load() *Config {
 	cachePreloadCount := v.GetInt("CACHE_PRELOAD_COUNT")
 	cacheTTL := parseDur("CACHE_TTL", 10*time.Minute)
 	shutdownTimeout := parseDur("SHUTDOWN_TIMEOUT", 10*time.Second)
+	schemaRegistryTimeout := parseDur("SCHEMA_REGISTRY_TIMEOUT", 5*time.Second)
+	kafkaConsumerBatchTimeout := parseDur("KAFKA_CONSUMER_BATCH_TIMEOUT", 50*time.Millisecond)
+
+	outboxBatchSize := v.GetInt("OUTBOX_BATCH_SIZE")
+	if outboxBatchSize <= 0 {
+		outboxBatchSize = 100
+	}
+	outboxPollInterval := parseDur("OUTBOX_POLL_INTERVAL", time.Second)
+
+	webhookBatchSize := v.GetInt("WEBHOOK_BATCH_SIZE")
+	if webhookBatchSize <= 0 {
+		webhookBatchSize = 100
+	}
+	webhookMaxAttempts := v.GetInt("WEBHOOK_MAX_ATTEMPTS")
+	if webhookMaxAttempts <= 0 {
+		webhookMaxAttempts = 8
+	}
+	webhookPollInterval := parseDur("WEBHOOK_POLL_INTERVAL", time.Second)
+	webhookTimeout := parseDur("WEBHOOK_TIMEOUT", 5*time.Second)
+	webhookBackoffBase := parseDur("WEBHOOK_BACKOFF_BASE", time.Second)
+	webhookBackoffMax := parseDur("WEBHOOK_BACKOFF_MAX", 10*time.Minute)
+	webhookClaimTTL := parseDur("WEBHOOK_CLAIM_TTL", 5*time.Minute)
+
+	httpRequestTimeout := parseDur("HTTP_REQUEST_TIMEOUT", 10*time.Second)
+	httpMaxBodyBytes := v.GetInt64("HTTP_MAX_BODY_BYTES")
+	if httpMaxBodyBytes <= 0 {
+		httpMaxBodyBytes = 1 << 20
+	}
+
+	streamBufferSize := v.GetInt("STREAM_BUFFER_SIZE")
+	if streamBufferSize <= 0 {
+		streamBufferSize = 1000
+	}
+	streamHeartbeat := parseDur("STREAM_HEARTBEAT", 15*time.Second)
+
+	// ----------- Authentication -----------------------------------------
+	v.SetDefault("AUTH_ENABLED", true)
+	authEnabled := v.GetBool("AUTH_ENABLED")
+	authProtectWeb := v.GetBool("AUTH_PROTECT_WEB")
+
+	// A YAML list in config.yaml, or a JSON array in the env var.
+	var authAPIKeys []APIKeyConfig
+	switch raw := v.Get("AUTH_API_KEYS").(type) {
+	case nil:
+	case string:
+		if raw != "" {
+			if err := json.Unmarshal([]byte(raw), &authAPIKeys); err != nil {
+				panic(fmt.Sprintf("invalid AUTH_API_KEYS: %v", err))
+			}
+		}
+	default:
+		if err := v.UnmarshalKey("AUTH_API_KEYS", &authAPIKeys); err != nil {
+			panic(fmt.Sprintf("invalid auth_api_keys: %v", err))
+		}
+	}
+	for _, k := range authAPIKeys {
+		if k.Name == "" || len(k.SHA256) != 64 {
+			panic(fmt.Sprintf("invalid API key %q: name and 64 hex digit sha256 are required", k.Name))
+		}
+	}
+
+	authJWTLeeway := parseDur("AUTH_JWT_LEEWAY", 30*time.Second)
+
+	// ----------- Rate limiting ------------------------------------------
+	v.SetDefault("RATE_LIMIT_ENABLED", true)
+	rateLimitBackend := strings.ToLower(v.GetString("RATE_LIMIT_BACKEND"))
+	switch rateLimitBackend {
+	case "":
+		rateLimitBackend = "memory"
+	case "memory", "redis":
+	default:
+		panic(fmt.Sprintf("RATE_LIMIT_BACKEND must be memory or redis, got %q", rateLimitBackend))
+	}
+	v.SetDefault("RATE_LIMIT_RATE", 10.0)
+	rateLimitRate := v.GetFloat64("RATE_LIMIT_RATE")
+	if rateLimitRate <= 0 {
+		panic(fmt.Sprintf("RATE_LIMIT_RATE must be positive, got %v", rateLimitRate))
+	}
+	v.SetDefault("RATE_LIMIT_BURST", 20)
+	rateLimitBurst := v.GetInt("RATE_LIMIT_BURST")
+	if rateLimitBurst < 1 {
+		panic(fmt.Sprintf("RATE_LIMIT_BURST must be at least 1, got %d", rateLimitBurst))
+	}
+
+	// ----------- Observability ------------------------------------------
+	logLevel := v.GetString("LOG_LEVEL")
+	if logLevel == "" {
+		logLevel = "info"
+	}
+	logFormat := v.GetString("LOG_FORMAT")
+	if logFormat == "" {
+		logFormat = "json"
+	}
+
+	tracingExporter := v.GetString("TRACING_EXPORTER")
+	if tracingExporter == "" {
+		tracingExporter = "none"
+	}
+	tracingOTLPEndpoint := v.GetString("TRACING_OTLP_ENDPOINT")
+	if tracingOTLPEndpoint == "" {
+		tracingOTLPEndpoint = "localhost:4317"
+	}
+	v.SetDefault("TRACING_OTLP_INSECURE", true)
+	tracingOTLPInsecure := v.GetBool("TRACING_OTLP_INSECURE")
+	v.SetDefault("TRACING_SAMPLE_RATIO", 1.0)
+	tracingSampleRatio := v.GetFloat64("TRACING_SAMPLE_RATIO")
+	if tracingSampleRatio < 0 || tracingSampleRatio > 1 {
+		panic(fmt.Sprintf("TRACING_SAMPLE_RATIO must be within [0, 1], got %v", tracingSampleRatio))
+	}
 
 	// --------------------------------------------------------------------
 	return &Config{
-		HTTPAddr:          httpAddr,
-		PostgresDSN:       postgresDSN,
-		RedisAddr:         redisAddr,
-		KafkaBrokers:      kafkaBrokers,
-		KafkaTopic:        kafkaTopic,
+		HTTPAddr:           httpAddr,
+		GRPCAddr:           grpcAddr,
+		HTTPRequestTimeout: httpRequestTimeout,
+		HTTPMaxBodyBytes:   httpMaxBodyBytes,
+		PostgresDSN:        postgresDSN,
+		RedisAddr:          redisAddr,
+		KafkaBrokers:       kafkaBrokers,
+		KafkaTopic:         kafkaTopic,
+
+		KafkaMessageFormat:     kafkaMessageFormat,
+		SchemaRegistryURL:      schemaRegistryURL,
+		SchemaRegistryUsername: v.GetString("SCHEMA_REGISTRY_USERNAME"),
+		SchemaRegistryPassword: v.GetString("SCHEMA_REGISTRY_PASSWORD"),
+		SchemaRegistryTimeout:  schemaRegistryTimeout,
+
+		KafkaConsumerGroup:       kafkaConsumerGroup,
+		KafkaConsumerConcurrency: kafkaConsumerConcurrency,
+		KafkaConsumerQueueDepth:  kafkaConsumerQueueDepth,
+
+		KafkaConsumerBatchSize:    kafkaConsumerBatchSize,
+		KafkaConsumerBatchTimeout: kafkaConsumerBatchTimeout,
+		KafkaDeadLetterTopic:      kafkaDeadLetterTopic,
+
+		OutboxTopic:        outboxTopic,
+		OutboxBatchSize:    outboxBatchSize,
+		OutboxPollInterval: outboxPollInterval,
+
+		WebhookBatchSize:    webhookBatchSize,
+		WebhookPollInterval: webhookPollInterval,
+		WebhookTimeout:      webhookTimeout,
+		WebhookMaxAttempts:  webhookMaxAttempts,
+		WebhookBackoffBase:  webhookBackoffBase,
+		WebhookBackoffMax:   webhookBackoffMax,
+		WebhookClaimTTL:     webhookClaimTTL,
+
+		StreamBufferSize: streamBufferSize,
+		StreamHeartbeat:  streamHeartbeat,
+
+		AuthEnabled:       authEnabled,
+		AuthProtectWeb:    authProtectWeb,
+		AuthAPIKeys:       authAPIKeys,
+		AuthJWTHMACSecret: v.GetString("AUTH_JWT_HMAC_SECRET"),
+		AuthJWTJWKSFile:   v.GetString("AUTH_JWT_JWKS_FILE"),
+		AuthJWTIssuer:     v.GetString("AUTH_JWT_ISSUER"),
+		AuthJWTAudience:   v.GetString("AUTH_JWT_AUDIENCE"),
+		AuthJWTLeeway:     authJWTLeeway,
+
+		RateLimitEnabled:    v.GetBool("RATE_LIMIT_ENABLED"),
+		RateLimitBackend:    rateLimitBackend,
+		RateLimitRate:       rateLimitRate,
+		RateLimitBurst:      rateLimitBurst,
+		RateLimitTrustProxy: v.GetBool("RATE_LIMIT_TRUST_PROXY"),
+
+		PIIEncryptionKeyfile: v.GetString("PII_ENCRYPTION_KEYFILE"),
+		PIIEncryptionKeys:    v.GetString("PII_ENCRYPTION_KEYS"),
+
+		LogLevel:  logLevel,
+		LogFormat: logFormat,
+
+		TracingExporter:     tracingExporter,
+		TracingOTLPEndpoint: tracingOTLPEndpoint,
+		TracingOTLPInsecure: tracingOTLPInsecure,
+		TracingSampleRatio:  tracingSampleRatio,
+
 		CachePreloadCount: cachePreloadCount,
 		CacheTTL:          cacheTTL,
 		ShutdownTimeout:   shutdownTimeout,
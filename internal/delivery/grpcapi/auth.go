@@ -0,0 +1,100 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"wb-tech-l0/internal/auth"
+	orderv1 "wb-tech-l0/pkg/api/order/v1"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// methodScopes lists the scope required by every OrderService method.
+// Methods missing from the table are denied.
+var methodScopes = map[string]string{
+	orderv1.OrderService_GetOrder_FullMethodName:       auth.ScopeOrdersRead,
+	orderv1.OrderService_BatchGetOrders_FullMethodName: auth.ScopeOrdersRead,
+	orderv1.OrderService_ListOrders_FullMethodName:     auth.ScopeOrdersRead,
+	orderv1.OrderService_WatchOrders_FullMethodName:    auth.ScopeOrdersRead,
+}
+
+// publicServices are reachable without credentials.
+var publicServices = []string{
+	"/grpc.health.v1.Health/",
+	"/grpc.reflection.",
+}
+
+// AuthInterceptors returns server options that authenticate calls with
+// the same credentials as the HTTP API, taken from the "authorization"
+// ("Bearer <jwt>") or "x-api-key" metadata.
+func AuthInterceptors(a *auth.Authenticator) []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			ctx, err := authorize(ctx, a, info.FullMethod)
+			if err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.ChainStreamInterceptor(func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			ctx, err := authorize(ss.Context(), a, info.FullMethod)
+			if err != nil {
+				return err
+			}
+			return handler(srv, &authorizedStream{ServerStream: ss, ctx: ctx})
+		}),
+	}
+}
+
+func authorize(ctx context.Context, a *auth.Authenticator, fullMethod string) (context.Context, error) {
+	for _, prefix := range publicServices {
+		if strings.HasPrefix(fullMethod, prefix) {
+			return ctx, nil
+		}
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	creds := auth.ParseCredentials(first(md.Get("authorization")), first(md.Get("x-api-key")))
+
+	principal, err := a.Authenticate(ctx, creds)
+	switch {
+	case errors.Is(err, auth.ErrNoCredentials):
+		return nil, status.Error(codes.Unauthenticated, "authentication required")
+	case errors.Is(err, auth.ErrInvalidCredentials):
+		return nil, status.Error(codes.Unauthenticated, "invalid credentials")
+	case err != nil:
+		return nil, status.Error(codes.Unavailable, "authentication is temporarily unavailable")
+	}
+
+	scope, ok := methodScopes[fullMethod]
+	if !ok {
+		return nil, status.Error(codes.PermissionDenied, "method is not allowed")
+	}
+	if !principal.HasScope(scope) {
+		return nil, status.Errorf(codes.PermissionDenied, "missing scope %s", scope)
+	}
+	return auth.WithPrincipal(ctx, principal), nil
+}
+
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// authorizedStream exposes the context carrying the principal to stream
+// handlers.
+type authorizedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authorizedStream) Context() context.Context {
+	return s.ctx
+}
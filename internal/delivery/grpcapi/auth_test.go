@@ -0,0 +1,58 @@
+package grpcapi
+
+import (
+	"context"
+	"testing"
+
+	"wb-tech-l0/internal/auth"
+	imocks "wb-tech-l0/internal/mocks"
+	"wb-tech-l0/internal/models"
+	orderv1 "wb-tech-l0/pkg/api/order/v1"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestAuthInterceptors(t *testing.T) {
+	readerKey, readerHash, err := auth.GenerateAPIKey()
+	require.NoError(t, err)
+	noScopeKey, noScopeHash, err := auth.GenerateAPIKey()
+	require.NoError(t, err)
+	piiKey, piiHash, err := auth.GenerateAPIKey()
+	require.NoError(t, err)
+
+	keys := auth.NewStaticKeys([]models.APIKey{
+		{Name: "reader", KeyHash: readerHash, Scopes: []string{auth.ScopeOrdersRead}},
+		{Name: "nobody", KeyHash: noScopeHash},
+		{Name: "pii", KeyHash: piiHash, Scopes: []string{auth.ScopeOrdersRead, auth.ScopePIIRead}},
+	})
+
+	uc := new(imocks.OrderUseCaseMock)
+	uc.On("GetOrder", mock.Anything, "uid-1").Return(newTestOrder("uid-1"), nil)
+
+	client := startTestServer(t, uc, nil, AuthInterceptors(auth.NewAuthenticator(nil, keys))...)
+	get := func(md ...string) (*orderv1.GetOrderResponse, error) {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), md...)
+		return client.GetOrder(ctx, &orderv1.GetOrderRequest{OrderUid: "uid-1"})
+	}
+	call := func(md ...string) error {
+		_, err := get(md...)
+		return err
+	}
+
+	assert.Equal(t, codes.Unauthenticated, status.Code(call()))
+	assert.Equal(t, codes.Unauthenticated, status.Code(call("x-api-key", "wbk_wrong")))
+	assert.Equal(t, codes.PermissionDenied, status.Code(call("x-api-key", noScopeKey)))
+
+	resp, err := get("x-api-key", readerKey)
+	require.NoError(t, err)
+	assert.Equal(t, "j***@example.com", resp.GetOrder().GetDelivery().GetEmail())
+
+	resp, err = get("x-api-key", piiKey)
+	require.NoError(t, err)
+	assert.Equal(t, "john@example.com", resp.GetOrder().GetDelivery().GetEmail())
+}
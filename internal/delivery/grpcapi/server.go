@@ -0,0 +1,204 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"wb-tech-l0/internal/application/ports"
+	"wb-tech-l0/internal/feed"
+	"wb-tech-l0/internal/projection"
+	orderv1 "wb-tech-l0/pkg/api/order/v1"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+// Server is a gRPC adapter exposing the order use case. Like the HTTP
+// server it depends only on the use case interfaces.
+type Server struct {
+	orderv1.UnimplementedOrderServiceServer
+
+	orderUseCase ports.OrderUseCase
+	orderFeed    *feed.Hub
+	grpcServer   *grpc.Server
+	health       *health.Server
+	shutdown     chan struct{}
+}
+
+func NewServer(orderUseCase ports.OrderUseCase, orderFeed *feed.Hub, opts ...grpc.ServerOption) *Server {
+	s := &Server{
+		orderUseCase: orderUseCase,
+		orderFeed:    orderFeed,
+		grpcServer:   grpc.NewServer(opts...),
+		health:       health.NewServer(),
+		shutdown:     make(chan struct{}),
+	}
+
+	orderv1.RegisterOrderServiceServer(s.grpcServer, s)
+	healthpb.RegisterHealthServer(s.grpcServer, s.health)
+	reflection.Register(s.grpcServer)
+
+	return s
+}
+
+func (s *Server) Start(addr string) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(lis)
+}
+
+func (s *Server) Serve(lis net.Listener) error {
+	return s.grpcServer.Serve(lis)
+}
+
+// Shutdown ends open watch streams and waits for in-flight calls to finish.
+// If the context expires first, remaining connections are closed forcibly.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.health.Shutdown()
+	close(s.shutdown)
+
+	done := make(chan struct{})
+	go func() {
+		s.grpcServer.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.grpcServer.Stop()
+		return ctx.Err()
+	}
+}
+
+func (s *Server) GetOrder(ctx context.Context, req *orderv1.GetOrderRequest) (*orderv1.GetOrderResponse, error) {
+	if req.GetOrderUid() == "" {
+		return nil, status.Error(codes.InvalidArgument, "order_uid is required")
+	}
+
+	order, err := s.orderUseCase.GetOrder(ctx, req.GetOrderUid())
+	if err != nil {
+		return nil, toStatus(err, "failed to get order")
+	}
+
+	return &orderv1.GetOrderResponse{Order: orderv1.FromModel(projection.Order(ctx, order))}, nil
+}
+
+func (s *Server) BatchGetOrders(ctx context.Context, req *orderv1.BatchGetOrdersRequest) (*orderv1.BatchGetOrdersResponse, error) {
+	result, err := s.orderUseCase.GetOrders(ctx, req.GetOrderUids())
+	if err != nil {
+		return nil, toStatus(err, "failed to get orders")
+	}
+
+	resp := &orderv1.BatchGetOrdersResponse{
+		Orders:           make([]*orderv1.Order, len(result.Orders)),
+		MissingOrderUids: result.Missing,
+	}
+	for i, o := range projection.Orders(ctx, result.Orders) {
+		resp.Orders[i] = orderv1.FromModel(o)
+	}
+	return resp, nil
+}
+
+func (s *Server) ListOrders(ctx context.Context, req *orderv1.ListOrdersRequest) (*orderv1.ListOrdersResponse, error) {
+	query := ports.ListOrdersQuery{
+		Filter: ports.OrderFilter{
+			DeliveryService: req.GetDeliveryService(),
+			Entry:           req.GetEntry(),
+			CustomerID:      req.GetCustomerId(),
+		},
+		Limit:  int(req.GetPageSize()),
+		Cursor: req.GetPageToken(),
+	}
+	if req.GetCreatedFrom() != nil {
+		query.Filter.CreatedFrom = req.GetCreatedFrom().AsTime()
+	}
+	if req.GetCreatedTo() != nil {
+		query.Filter.CreatedTo = req.GetCreatedTo().AsTime()
+	}
+
+	page, err := s.orderUseCase.ListOrders(ctx, query)
+	if err != nil {
+		if errors.Is(err, ports.ErrInvalidCursor) {
+			return nil, status.Error(codes.InvalidArgument, "invalid page_token")
+		}
+		return nil, toStatus(err, "failed to list orders")
+	}
+
+	resp := &orderv1.ListOrdersResponse{
+		Orders:        make([]*orderv1.Order, len(page.Orders)),
+		NextPageToken: page.NextCursor,
+	}
+	for i, o := range projection.Orders(ctx, page.Orders) {
+		resp.Orders[i] = orderv1.FromModel(o)
+	}
+	return resp, nil
+}
+
+func (s *Server) WatchOrders(req *orderv1.WatchOrdersRequest, stream grpc.ServerStreamingServer[orderv1.WatchOrdersResponse]) error {
+	if s.orderFeed == nil {
+		return status.Error(codes.Unimplemented, "order feed is disabled")
+	}
+
+	filter := feed.Filter{
+		DeliveryService: req.GetDeliveryService(),
+		Entry:           req.GetEntry(),
+	}
+	sub, missed := s.orderFeed.Subscribe(filter, req.GetLastEventId())
+	defer sub.Close()
+
+	for _, e := range missed {
+		if err := sendWatchEvent(stream, e); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-s.shutdown:
+			return status.Error(codes.Unavailable, "server is shutting down")
+		case e, ok := <-sub.Events():
+			if !ok {
+				return status.Error(codes.ResourceExhausted, "watcher is too slow, resume with last_event_id")
+			}
+			if err := sendWatchEvent(stream, e); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func sendWatchEvent(stream grpc.ServerStreamingServer[orderv1.WatchOrdersResponse], e feed.Event) error {
+	return stream.Send(&orderv1.WatchOrdersResponse{
+		EventId: e.ID,
+		Order:   orderv1.FromModel(projection.Order(stream.Context(), e.Order)),
+	})
+}
+
+// toStatus maps a use case error to a gRPC status. Errors without a domain
+// meaning become Internal with the generic message msg, so that their text
+// does not reach clients.
+func toStatus(err error, msg string) error {
+	switch {
+	case errors.Is(err, ports.ErrOrderNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, ports.ErrInvalidOrder), errors.Is(err, ports.ErrBatchTooLarge):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, ports.ErrConflict):
+		return status.Error(codes.Aborted, err.Error())
+	case errors.Is(err, ports.ErrUnavailable), errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.Unavailable, "service temporarily unavailable")
+	default:
+		return status.Error(codes.Internal, msg)
+	}
+}
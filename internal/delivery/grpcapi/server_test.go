@@ -0,0 +1,156 @@
+package grpcapi
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"wb-tech-l0/internal/application/ports"
+	"wb-tech-l0/internal/feed"
+	imocks "wb-tech-l0/internal/mocks"
+	"wb-tech-l0/internal/models"
+	"wb-tech-l0/internal/pii"
+	orderv1 "wb-tech-l0/pkg/api/order/v1"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newTestOrder(uid string) *models.Order {
+	return &models.Order{
+		OrderUID:        uid,
+		TrackNumber:     "ABCDEFGHJK",
+		Entry:           "WBIL",
+		Locale:          "en",
+		CustomerID:      "11111111-1111-1111-1111-111111111111",
+		DeliveryService: "meest",
+		Shardkey:        "1",
+		SmID:            1,
+		DateCreated:     time.Unix(1700000000, 0).UTC(),
+		OofShard:        "1",
+		Delivery:        models.Delivery{Name: "John", Phone: "+123", Zip: "12345", City: "City", Address: "Street 1", Region: "Reg", Email: "john@example.com"},
+		Payment:         models.Payment{Transaction: "tx1", Currency: "USD", Provider: "wbpay", Amount: 100, PaymentDt: 1700000000, Bank: "bank", DeliveryCost: 10, GoodsTotal: 90},
+		Items:           []models.Item{{ChrtID: 1, TrackNumber: "ABCDEFGHJK", Price: 100, RID: "rid", Name: "Item", Size: "M", TotalPrice: 100, NmID: 1, Brand: "brand", Status: 200}},
+	}
+}
+
+func startTestServer(t *testing.T, uc ports.OrderUseCase, hub *feed.Hub, opts ...grpc.ServerOption) orderv1.OrderServiceClient {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	srv := NewServer(uc, hub, opts...)
+	go func() { _ = srv.Serve(lis) }()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		conn.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = srv.Shutdown(ctx)
+	})
+
+	return orderv1.NewOrderServiceClient(conn)
+}
+
+func TestServer_GetOrder(t *testing.T) {
+	uc := new(imocks.OrderUseCaseMock)
+	order := newTestOrder("uid-1")
+	uc.On("GetOrder", mock.Anything, "uid-1").Return(order, nil)
+	uc.On("GetOrder", mock.Anything, "missing").Return(nil, ports.ErrOrderNotFound)
+	uc.On("GetOrder", mock.Anything, "db-down").Return(nil, fmt.Errorf("%w: connection refused", ports.ErrUnavailable))
+	uc.On("GetOrder", mock.Anything, "broken").Return(nil, assert.AnError)
+
+	client := startTestServer(t, uc, nil)
+
+	// Callers without the pii:read scope get personal data masked.
+	resp, err := client.GetOrder(context.Background(), &orderv1.GetOrderRequest{OrderUid: "uid-1"})
+	require.NoError(t, err)
+	masked := pii.Mask(*order)
+	assert.Equal(t, &masked, orderv1.ToModel(resp.GetOrder()))
+
+	_, err = client.GetOrder(context.Background(), &orderv1.GetOrderRequest{OrderUid: "missing"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = client.GetOrder(context.Background(), &orderv1.GetOrderRequest{OrderUid: "db-down"})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.NotContains(t, status.Convert(err).Message(), "connection refused")
+
+	_, err = client.GetOrder(context.Background(), &orderv1.GetOrderRequest{OrderUid: "broken"})
+	assert.Equal(t, codes.Internal, status.Code(err))
+
+	_, err = client.GetOrder(context.Background(), &orderv1.GetOrderRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestServer_BatchGetOrders(t *testing.T) {
+	uc := new(imocks.OrderUseCaseMock)
+	uc.On("GetOrders", mock.Anything, []string{"uid-1", "uid-2"}).Return(ports.BatchOrders{
+		Orders:  []*models.Order{newTestOrder("uid-1")},
+		Missing: []string{"uid-2"},
+	}, nil)
+
+	client := startTestServer(t, uc, nil)
+
+	resp, err := client.BatchGetOrders(context.Background(), &orderv1.BatchGetOrdersRequest{OrderUids: []string{"uid-1", "uid-2"}})
+	require.NoError(t, err)
+	require.Len(t, resp.GetOrders(), 1)
+	assert.Equal(t, "uid-1", resp.GetOrders()[0].GetOrderUid())
+	assert.Equal(t, []string{"uid-2"}, resp.GetMissingOrderUids())
+}
+
+func TestServer_ListOrders(t *testing.T) {
+	uc := new(imocks.OrderUseCaseMock)
+	uc.On("ListOrders", mock.Anything, ports.ListOrdersQuery{
+		Filter: ports.OrderFilter{DeliveryService: "meest"},
+		Limit:  1,
+	}).Return(ports.OrderPage{Orders: []*models.Order{newTestOrder("uid-2")}, NextCursor: "2"}, nil)
+	uc.On("ListOrders", mock.Anything, ports.ListOrdersQuery{Limit: 1, Cursor: "bad"}).Return(ports.OrderPage{}, ports.ErrInvalidCursor)
+
+	client := startTestServer(t, uc, nil)
+
+	resp, err := client.ListOrders(context.Background(), &orderv1.ListOrdersRequest{PageSize: 1, DeliveryService: "meest"})
+	require.NoError(t, err)
+	require.Len(t, resp.GetOrders(), 1)
+	assert.Equal(t, "2", resp.GetNextPageToken())
+
+	_, err = client.ListOrders(context.Background(), &orderv1.ListOrdersRequest{PageSize: 1, PageToken: "bad"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestServer_WatchOrders(t *testing.T) {
+	hub := feed.NewHub(10)
+	first := hub.Publish(newTestOrder("uid-old"))
+
+	client := startTestServer(t, new(imocks.OrderUseCaseMock), hub)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := client.WatchOrders(ctx, &orderv1.WatchOrdersRequest{LastEventId: first.ID - 1})
+	require.NoError(t, err)
+
+	// Buffered event is replayed first.
+	resp, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, resp.GetEventId())
+	assert.Equal(t, "uid-old", resp.GetOrder().GetOrderUid())
+
+	// The subscription is registered before the replay, so live events follow.
+	live := hub.Publish(newTestOrder("uid-new"))
+	resp, err = stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, live.ID, resp.GetEventId())
+	assert.Equal(t, "uid-new", resp.GetOrder().GetOrderUid())
+}
@@ -0,0 +1,78 @@
+package kafka
+
+import (
+	_ "embed"
+	"fmt"
+	"sync"
+
+	"wb-tech-l0/internal/models"
+	"wb-tech-l0/internal/schemaregistry"
+
+	"github.com/hamba/avro/v2"
+)
+
+// orderAvroSchema is the reader schema Avro messages are decoded with.
+//
+//go:embed order.avsc
+var orderAvroSchema string
+
+// avroAPI decodes records into models by their JSON field names, which
+// the reader schema uses.
+var avroAPI = avro.Config{TagKey: "json"}.Freeze()
+
+// avroDecoder decodes Avro payloads written with a registered schema. The
+// writer schema is resolved against orderAvroSchema once per schema ID.
+type avroDecoder struct {
+	registry *schemaregistry.Client
+	reader   avro.Schema
+
+	mu       sync.Mutex
+	resolved map[int]avro.Schema
+}
+
+func newAvroDecoder(registry *schemaregistry.Client) *avroDecoder {
+	return &avroDecoder{
+		registry: registry,
+		reader:   avro.MustParse(orderAvroSchema),
+		resolved: make(map[int]avro.Schema),
+	}
+}
+
+func (d *avroDecoder) decode(schema schemaregistry.Schema, payload []byte) (*models.Order, error) {
+	resolved, err := d.schema(schema)
+	if err != nil {
+		return nil, err
+	}
+
+	var order models.Order
+	if err := avroAPI.Unmarshal(resolved, payload, &order); err != nil {
+		return nil, fmt.Errorf("%w: avro: %w", ErrMalformedMessage, err)
+	}
+	return &order, nil
+}
+
+// schema returns the writer schema resolved against the reader schema.
+// Writer schemas the reader cannot read, e.g. without a required field,
+// make their messages malformed.
+func (d *avroDecoder) schema(schema schemaregistry.Schema) (avro.Schema, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if s, ok := d.resolved[schema.ID]; ok {
+		return s, nil
+	}
+
+	// A cache per schema: writer schemas of different IDs may define the
+	// same names differently.
+	writer, err := avro.ParseWithCache(schema.Schema, "", &avro.SchemaCache{})
+	if err != nil {
+		return nil, fmt.Errorf("%w: parse avro schema %d: %w", ErrMalformedMessage, schema.ID, err)
+	}
+	resolved, err := avro.NewSchemaCompatibility().Resolve(d.reader, writer)
+	if err != nil {
+		return nil, fmt.Errorf("%w: avro schema %d is not compatible with the order schema: %w", ErrMalformedMessage, schema.ID, err)
+	}
+
+	d.resolved[schema.ID] = resolved
+	return resolved, nil
+}
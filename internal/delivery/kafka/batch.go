@@ -0,0 +1,181 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"wb-tech-l0/internal/application/ports"
+	"wb-tech-l0/internal/logging"
+	"wb-tech-l0/internal/metrics"
+	"wb-tech-l0/internal/models"
+	"wb-tech-l0/internal/telemetry"
+
+	"github.com/IBM/sarama"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// pendingOrder is a decoded and validated message waiting for its batch
+// to be saved.
+type pendingOrder struct {
+	msg    *sarama.ConsumerMessage
+	order  *models.Order
+	logger *slog.Logger
+	span   trace.SpanContext
+	start  time.Time
+}
+
+// runBatchWorker handles the messages of one queue, saving their orders
+// in batches of up to cfg.BatchSize. A batch is saved when it is full,
+// cfg.BatchTimeout after its first order, or when the queue is closed.
+func (c *Consumer) runBatchWorker(ctx context.Context, fail context.CancelCauseFunc, topic string, tracker *offsetTracker, queue <-chan *sarama.ConsumerMessage) {
+	inFlight := metrics.KafkaMessagesInFlight.WithLabelValues(topic)
+	timer := time.NewTimer(c.cfg.BatchTimeout)
+	timer.Stop()
+
+	var batch []*pendingOrder
+	flush := func() {
+		timer.Stop()
+		if len(batch) == 0 {
+			return
+		}
+		done, err := c.saveBatch(context.WithoutCancel(ctx), topic, batch)
+		for _, p := range done {
+			tracker.complete(p.msg.Offset)
+		}
+		if err != nil {
+			fail(err)
+		}
+		for _, p := range batch {
+			metrics.KafkaProcessingSeconds.WithLabelValues(topic).Observe(time.Since(p.start).Seconds())
+			inFlight.Dec()
+		}
+		batch = nil
+	}
+
+	for {
+		select {
+		case msg, ok := <-queue:
+			if !ok {
+				flush()
+				return
+			}
+			if ctx.Err() != nil {
+				inFlight.Dec()
+				continue
+			}
+
+			p, err := c.prepareMessage(context.WithoutCancel(ctx), topic, msg)
+			if err != nil || p == nil {
+				if err != nil {
+					fail(err)
+				} else {
+					tracker.complete(msg.Offset)
+				}
+				inFlight.Dec()
+				continue
+			}
+
+			batch = append(batch, p)
+			if len(batch) == 1 {
+				timer.Reset(c.cfg.BatchTimeout)
+			}
+			if len(batch) >= c.cfg.BatchSize {
+				flush()
+			}
+
+		case <-timer.C:
+			flush()
+		}
+	}
+}
+
+// prepareMessage decodes and validates a message within its own span,
+// like handleMessage, but leaves saving to the batch. It returns nil for
+// skipped messages.
+func (c *Consumer) prepareMessage(ctx context.Context, topic string, msg *sarama.ConsumerMessage) (p *pendingOrder, err error) {
+	start := time.Now()
+	ctx, span, logger := c.startProcessing(ctx, topic, msg)
+	defer func() { telemetry.End(span, err) }()
+
+	order, err := c.decodeMessage(ctx, span, logger, topic, msg)
+	if err != nil || order == nil {
+		metrics.KafkaProcessingSeconds.WithLabelValues(topic).Observe(time.Since(start).Seconds())
+		return nil, err
+	}
+	return &pendingOrder{
+		msg:    msg,
+		order:  order,
+		logger: logger.With(logging.KeyOrderUID, order.OrderUID),
+		span:   span.SpanContext(),
+		start:  start,
+	}, nil
+}
+
+// saveBatch saves the orders of a batch in one transaction. If that fails
+// for any reason but an unavailable database, the orders are saved one by
+// one so a single bad order does not hold back the rest. An order that
+// fails permanently is handled as in handleMessage: its message is
+// dead-lettered and done. Once an order fails otherwise, later versions of
+// it in the batch are not saved, to keep its updates in order. It returns
+// the orders whose messages are done and the first error that stops the
+// consumer.
+func (c *Consumer) saveBatch(ctx context.Context, topic string, batch []*pendingOrder) (done []*pendingOrder, err error) {
+	links := make([]trace.Link, len(batch))
+	orders := make([]*models.Order, len(batch))
+	for i, p := range batch {
+		links[i] = trace.Link{SpanContext: p.span}
+		orders[i] = p.order
+	}
+	ctx, span := tracer.Start(ctx, topic+" save",
+		trace.WithLinks(links...),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "kafka"),
+			attribute.String("messaging.destination.name", topic),
+			attribute.Int("messaging.batch.message_count", len(batch)),
+		),
+	)
+	defer func() { telemetry.End(span, err) }()
+
+	metrics.KafkaBatchSize.WithLabelValues(topic).Observe(float64(len(batch)))
+	batchErr := c.orderUseCase.SaveOrders(ctx, orders)
+	if batchErr == nil {
+		for _, p := range batch {
+			p.logger.InfoContext(ctx, "order processed")
+		}
+		return batch, nil
+	}
+	if errors.Is(batchErr, ports.ErrUnavailable) {
+		metrics.KafkaMessagesFailed.WithLabelValues(topic, metrics.ReasonSave).Add(float64(len(batch)))
+		c.logger.ErrorContext(ctx, "failed to save batch", logging.KeyTopic, topic, "orders", len(batch), logging.Err(batchErr))
+		return nil, batchErr
+	}
+
+	metrics.KafkaBatchFallbacks.WithLabelValues(topic).Inc()
+	telemetry.RecordError(span, batchErr)
+	c.logger.WarnContext(ctx, "failed to save batch, saving orders one by one",
+		logging.KeyTopic, topic, "orders", len(batch), logging.Err(batchErr))
+
+	var firstErr error
+	failed := make(map[string]bool)
+	for _, p := range batch {
+		if failed[p.order.OrderUID] {
+			continue
+		}
+		err := c.saveOrder(ctx, p.logger, topic, p.order)
+		if err != nil {
+			err = c.deadLetter(ctx, p.logger, topic, p.msg, err)
+		}
+		if err != nil {
+			failed[p.order.OrderUID] = true
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		done = append(done, p)
+	}
+	return done, firstErr
+}
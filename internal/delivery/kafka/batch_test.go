@@ -0,0 +1,201 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"wb-tech-l0/internal/application/ports"
+	imocks "wb-tech-l0/internal/mocks"
+	"wb-tech-l0/internal/models"
+
+	"github.com/IBM/sarama"
+	smocks "github.com/IBM/sarama/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newBatchTestConsumer(t *testing.T, uc *imocks.OrderUseCaseMock, batchSize int, timeout time.Duration) (*Consumer, *smocks.PartitionConsumer, *fakePartitionOffsetManager) {
+	t.Helper()
+	saramaC := smocks.NewConsumer(t, nil)
+	t.Cleanup(func() { _ = saramaC.Close() })
+	pc := saramaC.ExpectConsumePartition("orders", 0, 10)
+
+	v := new(imocks.ValidatorMock)
+	v.On("Validate", mock.Anything).Return(nil)
+
+	pom := &fakePartitionOffsetManager{marked: 10}
+	cons := newTestConsumer(saramaC, uc, v)
+	cons.offsets = &fakeOffsetManager{pom: pom}
+	cons.cfg = ConsumerConfig{Concurrency: 1, QueueDepth: 10, BatchSize: batchSize, BatchTimeout: timeout}
+	return cons, pc, pom
+}
+
+func orderUIDs(orders []*models.Order) []string {
+	uids := make([]string, len(orders))
+	for i, o := range orders {
+		uids[i] = o.OrderUID
+	}
+	return uids
+}
+
+func TestConsumer_SavesOrdersInBatches(t *testing.T) {
+	batches := make(chan []string, 10)
+	uc := new(imocks.OrderUseCaseMock)
+	uc.On("SaveOrders", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		batches <- orderUIDs(args.Get(1).([]*models.Order))
+	}).Return(nil)
+
+	cons, pc, pom := newBatchTestConsumer(t, uc, 3, 20*time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- cons.Start(ctx, "orders") }()
+
+	for _, uid := range []string{"a", "b", "c", "d", "e"} {
+		pc.YieldMessage(orderMessage(t, uid, "1"))
+	}
+	// A full batch is saved at once, the rest when the timeout passes.
+	assert.Equal(t, []string{"a", "b", "c"}, <-batches)
+	assert.Equal(t, []string{"d", "e"}, <-batches)
+
+	cancel()
+	require.NoError(t, <-done)
+	assert.EqualValues(t, 15, pom.committed)
+	uc.AssertNotCalled(t, "SaveOrder", mock.Anything, mock.Anything)
+}
+
+// withDeadLetters gives the consumer a dead-letter topic, expecting one
+// message to be published to it per UID in uids.
+func withDeadLetters(t *testing.T, cons *Consumer, uids ...string) {
+	t.Helper()
+	producer := smocks.NewSyncProducer(t, nil)
+	t.Cleanup(func() { _ = producer.Close() })
+	for _, uid := range uids {
+		producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+			key, _ := msg.Key.Encode()
+			if msg.Topic != "orders-dlq" || string(key) != uid {
+				return fmt.Errorf("unexpected dead letter %s/%s", msg.Topic, key)
+			}
+			headers := make(map[string]string, len(msg.Headers))
+			for _, h := range msg.Headers {
+				headers[string(h.Key)] = string(h.Value)
+			}
+			if headers[HeaderDeadLetterTopic] != "orders" || headers[HeaderDeadLetterError] == "" {
+				return fmt.Errorf("unexpected dead-letter headers %v", headers)
+			}
+			return nil
+		})
+	}
+	cons.deadLetters = producer
+	cons.cfg.DeadLetterTopic = "orders-dlq"
+}
+
+func TestConsumer_DeadLettersOrdersThatCannotBeSaved(t *testing.T) {
+	// A batch falls back to single saves; with a batch size of 1 the
+	// orders are saved one by one from the start. Both end the same way.
+	for _, batchSize := range []int{4, 1} {
+		t.Run(fmt.Sprintf("batch size %d", batchSize), func(t *testing.T) {
+			errBad := errors.New("bad order")
+			saved := make(chan string, 10)
+			record := func(args mock.Arguments) { saved <- args.Get(1).(*models.Order).OrderUID }
+			uc := new(imocks.OrderUseCaseMock)
+			uc.On("SaveOrders", mock.Anything, mock.Anything).Return(assert.AnError)
+			uc.On("SaveOrder", mock.Anything, mock.MatchedBy(func(o *models.Order) bool { return o.OrderUID == "bad" })).Run(record).Return(errBad)
+			uc.On("SaveOrder", mock.Anything, mock.Anything).Run(record).Return(nil)
+
+			cons, pc, pom := newBatchTestConsumer(t, uc, batchSize, time.Hour)
+			withDeadLetters(t, cons, "bad", "bad")
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error, 1)
+			go func() { done <- cons.Start(ctx, "orders") }()
+
+			pc.YieldMessage(orderMessage(t, "ok-1", "1")) // 10
+			pc.YieldMessage(orderMessage(t, "bad", "1"))  // 11
+			pc.YieldMessage(orderMessage(t, "ok-2", "1")) // 12
+			pc.YieldMessage(orderMessage(t, "bad", "2"))  // 13
+			// Consumption goes on past the order that cannot be saved.
+			pc.YieldMessage(orderMessage(t, "ok-3", "1")) // 14
+			pc.YieldMessage(orderMessage(t, "ok-4", "1")) // 15
+			pc.YieldMessage(orderMessage(t, "ok-5", "1")) // 16
+			pc.YieldMessage(orderMessage(t, "ok-6", "1")) // 17
+
+			var uids []string
+			for range 8 {
+				uids = append(uids, <-saved)
+			}
+			// Later versions of a dead-lettered order are still tried.
+			assert.Equal(t, []string{"ok-1", "bad", "ok-2", "bad", "ok-3", "ok-4", "ok-5", "ok-6"}, uids)
+
+			cancel()
+			require.NoError(t, <-done)
+			assert.EqualValues(t, 18, pom.committed)
+		})
+	}
+}
+
+func TestConsumer_StopsOnPermanentSaveErrorWithoutDeadLetterTopic(t *testing.T) {
+	for _, batchSize := range []int{4, 1} {
+		t.Run(fmt.Sprintf("batch size %d", batchSize), func(t *testing.T) {
+			errBad := errors.New("bad order")
+			uc := new(imocks.OrderUseCaseMock)
+			uc.On("SaveOrders", mock.Anything, mock.Anything).Return(assert.AnError)
+			uc.On("SaveOrder", mock.Anything, mock.MatchedBy(func(o *models.Order) bool { return o.OrderUID == "bad" })).Return(errBad)
+			uc.On("SaveOrder", mock.Anything, mock.Anything).Return(nil)
+
+			cons, pc, pom := newBatchTestConsumer(t, uc, batchSize, time.Hour)
+			done := make(chan error, 1)
+			go func() { done <- cons.Start(context.Background(), "orders") }()
+
+			pc.YieldMessage(orderMessage(t, "ok-1", "1")) // 10
+			pc.YieldMessage(orderMessage(t, "bad", "1"))  // 11
+			pc.YieldMessage(orderMessage(t, "ok-2", "1")) // 12
+			pc.YieldMessage(orderMessage(t, "ok-3", "1")) // 13
+
+			require.ErrorIs(t, <-done, errBad)
+			assert.EqualValues(t, 11, pom.committed, "the failed message is consumed again on restart")
+		})
+	}
+}
+
+func TestConsumer_StopsOnRetryableSaveError(t *testing.T) {
+	uc := new(imocks.OrderUseCaseMock)
+	uc.On("SaveOrders", mock.Anything, mock.Anything).Return(assert.AnError)
+	uc.On("SaveOrder", mock.Anything, mock.MatchedBy(func(o *models.Order) bool { return o.OrderUID == "busy" })).Return(ports.ErrConflict)
+	uc.On("SaveOrder", mock.Anything, mock.Anything).Return(nil)
+
+	cons, pc, pom := newBatchTestConsumer(t, uc, 4, time.Hour)
+	done := make(chan error, 1)
+	go func() { done <- cons.Start(context.Background(), "orders") }()
+
+	pc.YieldMessage(orderMessage(t, "ok-1", "1")) // 10
+	pc.YieldMessage(orderMessage(t, "busy", "1")) // 11
+	pc.YieldMessage(orderMessage(t, "ok-2", "1")) // 12
+	pc.YieldMessage(orderMessage(t, "busy", "2")) // 13
+
+	require.ErrorIs(t, <-done, ports.ErrConflict)
+
+	// The rest of the batch is saved, but not the later version of the
+	// failed order, and the failed message is consumed again on restart.
+	uc.AssertNumberOfCalls(t, "SaveOrder", 3)
+	uc.AssertCalled(t, "SaveOrder", mock.Anything, mock.MatchedBy(func(o *models.Order) bool { return o.OrderUID == "ok-2" }))
+	assert.EqualValues(t, 11, pom.committed)
+}
+
+func TestConsumer_StopsWhenBatchStoreUnavailable(t *testing.T) {
+	uc := new(imocks.OrderUseCaseMock)
+	uc.On("SaveOrders", mock.Anything, mock.Anything).Return(ports.ErrUnavailable)
+
+	cons, pc, pom := newBatchTestConsumer(t, uc, 2, time.Hour)
+	done := make(chan error, 1)
+	go func() { done <- cons.Start(context.Background(), "orders") }()
+
+	pc.YieldMessage(orderMessage(t, "a", "1"))
+	pc.YieldMessage(orderMessage(t, "b", "1"))
+
+	require.ErrorIs(t, <-done, ports.ErrUnavailable)
+	uc.AssertNotCalled(t, "SaveOrder", mock.Anything, mock.Anything)
+	assert.EqualValues(t, 10, pom.committed)
+}
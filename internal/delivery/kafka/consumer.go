@@ -2,94 +2,382 @@ package kafka
 
 import (
 	"context"
-	"encoding/json"
-	"log"
+	"errors"
+	"hash/fnv"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
 	"wb-tech-l0/internal/validator"
 
 	"wb-tech-l0/internal/application/ports"
+	"wb-tech-l0/internal/delivery"
+	"wb-tech-l0/internal/logging"
+	"wb-tech-l0/internal/metrics"
 	"wb-tech-l0/internal/models"
+	"wb-tech-l0/internal/telemetry"
 
 	"github.com/IBM/sarama"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = telemetry.Tracer("wb-tech-l0/internal/delivery/kafka")
+
+// ConsumerConfig tunes the worker pool of the consumer.
+type ConsumerConfig struct {
+	// Group is the consumer group offsets are committed under.
+	Group string
+	// Concurrency is the number of messages processed at once. Messages
+	// with the same key always go to the same worker, so the updates of an
+	// order are applied in the order they were produced.
+	Concurrency int
+	// QueueDepth is the number of messages buffered per worker before the
+	// consumer stops reading from the partition.
+	QueueDepth int
+	// BatchSize is the number of orders a worker saves in one transaction.
+	// A smaller batch is saved once BatchTimeout has passed since its first
+	// order. With a BatchSize of 1 every order is saved on its own.
+	BatchSize    int
+	BatchTimeout time.Duration
+	// DeadLetterTopic receives the messages whose order fails to save
+	// with a non-retryable error; their offsets are then committed. With
+	// no dead-letter topic such a message stops the consumer.
+	DeadLetterTopic string
+}
+
 // Consumer is a Kafka adapter that depends on the OrderUseCase, not on DB.
 type Consumer struct {
+	client       sarama.Client
+	lookup       offsetLookup // finds offsets by time for Replay
 	consumer     sarama.Consumer
+	offsets      sarama.OffsetManager // nil: start at the newest offset and commit nothing
+	deadLetters  sarama.SyncProducer  // nil when cfg.DeadLetterTopic is empty
+	cfg          ConsumerConfig
 	orderUseCase ports.OrderUseCase
 	validator    validator.Validator
+	decoder      Decoder
+	logger       *slog.Logger
+	replayIdle   time.Duration // see replayIdleTimeout
 }
 
-func NewConsumer(brokers []string, uc ports.OrderUseCase) (*Consumer, error) {
-	cfg := sarama.NewConfig()
-	consumer, err := sarama.NewConsumer(brokers, cfg)
+// NewConsumer connects to the brokers. Messages are decoded by decoder,
+// see NewDecoder. Consumption resumes from the offset committed for
+// cfg.Group, or from the newest message when there is none.
+func NewConsumer(brokers []string, cfg ConsumerConfig, uc ports.OrderUseCase, decoder Decoder, logger *slog.Logger) (*Consumer, error) {
+	saramaCfg := sarama.NewConfig()
+	saramaCfg.Consumer.Offsets.Initial = sarama.OffsetNewest
+	saramaCfg.Producer.RequiredAcks = sarama.WaitForAll
+	saramaCfg.Producer.Return.Successes = true
+	client, err := sarama.NewClient(brokers, saramaCfg)
+	if err != nil {
+		return nil, err
+	}
+	consumer, err := sarama.NewConsumerFromClient(client)
+	if err != nil {
+		_ = client.Close()
+		return nil, err
+	}
+	offsets, err := sarama.NewOffsetManagerFromClient(cfg.Group, client)
 	if err != nil {
+		_ = consumer.Close()
+		_ = client.Close()
 		return nil, err
 	}
+	var deadLetters sarama.SyncProducer
+	if cfg.DeadLetterTopic != "" {
+		if deadLetters, err = sarama.NewSyncProducerFromClient(client); err != nil {
+			_ = offsets.Close()
+			_ = consumer.Close()
+			_ = client.Close()
+			return nil, err
+		}
+	}
 
 	return &Consumer{
+		client:       client,
+		lookup:       client,
 		consumer:     consumer,
+		offsets:      offsets,
+		deadLetters:  deadLetters,
+		cfg:          cfg,
 		orderUseCase: uc,
 		validator:    validator.NewValidator(),
+		decoder:      decoder,
+		logger:       logger.With("component", "kafka_consumer"),
 	}, nil
 }
 
 // NewConsumerWith allows injecting a custom sarama.Consumer and validator, making it test-friendly.
-func NewConsumerWith(consumer sarama.Consumer, uc ports.OrderUseCase, v validator.Validator) *Consumer {
+// Messages are decoded as JSON one at a time, and offsets are not committed.
+func NewConsumerWith(consumer sarama.Consumer, uc ports.OrderUseCase, v validator.Validator, logger *slog.Logger) *Consumer {
 	return &Consumer{
 		consumer:     consumer,
+		cfg:          ConsumerConfig{Concurrency: 1, QueueDepth: 1, BatchSize: 1},
 		orderUseCase: uc,
 		validator:    v,
+		decoder:      JSONDecoder{},
+		logger:       logger.With("component", "kafka_consumer"),
 	}
 }
 
-// Start consumes messages from the given topic until the context is cancelled.
+// Start consumes messages from the given topic until the context is
+// cancelled or a message fails.
+//
+// Messages are handed to cfg.Concurrency workers by key, and the offset
+// of a message is committed only once it and all earlier messages are
+// processed. On shutdown, messages being processed are finished and
+// queued ones are left for the next run; after a failure the failed
+// message and everything after it are consumed again on restart.
 func (c *Consumer) Start(ctx context.Context, topic string) error {
-	partitionConsumer, err := c.consumer.ConsumePartition(topic, 0, sarama.OffsetNewest)
+	const partition = 0
+
+	offset := sarama.OffsetNewest
+	var store OffsetStore
+	if c.offsets != nil {
+		pom, err := c.offsets.ManagePartition(topic, partition)
+		if err != nil {
+			return err
+		}
+		defer pom.Close()
+		// Flush the last marked offset rather than wait for the interval.
+		defer c.offsets.Commit()
+		store = pom
+		offset, _ = pom.NextOffset()
+	}
+
+	partitionConsumer, err := c.consumer.ConsumePartition(topic, partition, offset)
 	if err != nil {
 		return err
 	}
 	defer partitionConsumer.Close()
 
-	log.Println("Kafka consumer started. Waiting for messages...")
+	c.logger.InfoContext(ctx, "consumer started",
+		logging.KeyTopic, topic, logging.KeyOffset, offset, "concurrency", c.cfg.Concurrency)
+
+	partitionLabel := strconv.Itoa(partition)
+	tracker := newOffsetTracker(func(next int64) {
+		if store != nil {
+			store.MarkOffset(next, "")
+		}
+		metrics.KafkaCommittedOffset.WithLabelValues(topic, partitionLabel).Set(float64(next))
+	})
+
+	runCtx, fail := context.WithCancelCause(ctx)
+	defer fail(nil)
+	queues, wait := c.startWorkers(runCtx, fail, topic, tracker)
+	defer func() {
+		for _, q := range queues {
+			close(q)
+		}
+		wait()
+	}()
 
 	for {
 		select {
-		case <-ctx.Done():
-			log.Println("Kafka consumer: context cancelled, stopping")
-			return nil
+		case <-runCtx.Done():
+			if ctx.Err() != nil {
+				c.logger.InfoContext(ctx, "context cancelled, stopping consumer", logging.KeyTopic, topic)
+				return nil
+			}
+			return context.Cause(runCtx)
 
 		case msg, ok := <-partitionConsumer.Messages():
 			if !ok {
-				log.Println("Kafka consumer: partition consumer channel closed")
+				c.logger.WarnContext(ctx, "partition consumer channel closed", logging.KeyTopic, topic)
 				return nil
 			}
 
-			log.Printf("Received message: %s\n", string(msg.Value))
-
-			// Парсинг JSON
-			var order models.Order
-			if err := json.Unmarshal(msg.Value, &order); err != nil {
-				log.Printf("Error parsing JSON: %v\n", err)
-				continue
+			partition := strconv.Itoa(int(msg.Partition))
+			metrics.KafkaMessagesConsumed.WithLabelValues(topic, partition).Inc()
+			if hwm := partitionConsumer.HighWaterMarkOffset(); hwm > 0 {
+				metrics.KafkaConsumerLag.WithLabelValues(topic, partition).Set(float64(hwm - msg.Offset - 1))
 			}
 
-			// Валидация модели
-
-			if err := c.validator.Validate(order); err != nil {
-				log.Printf("❌ Invalid order data for orderUID %s: %v", order.OrderUID, err)
-				return err
+			tracker.add(msg.Offset)
+			metrics.KafkaMessagesInFlight.WithLabelValues(topic).Inc()
+			select {
+			case queues[c.worker(runCtx, msg, len(queues))] <- msg:
+			case <-runCtx.Done():
+				metrics.KafkaMessagesInFlight.WithLabelValues(topic).Dec()
 			}
+		}
+	}
+}
 
-			if err := c.orderUseCase.SaveOrder(&order); err != nil {
-				log.Printf("Failed to process order %s: %v\n", order.OrderUID, err)
-				return err
-			} else {
-				log.Printf("Order %s processed successfully\n", order.OrderUID)
+// startWorkers starts one goroutine per queue. A failed message cancels
+// ctx with its error; the remaining queued messages are then dropped
+// without being committed. wait returns once the queues are closed and
+// drained.
+func (c *Consumer) startWorkers(ctx context.Context, fail context.CancelCauseFunc, topic string, tracker *offsetTracker) (queues []chan *sarama.ConsumerMessage, wait func()) {
+	queues = make([]chan *sarama.ConsumerMessage, max(c.cfg.Concurrency, 1))
+	var wg sync.WaitGroup
+	for i := range queues {
+		queues[i] = make(chan *sarama.ConsumerMessage, max(c.cfg.QueueDepth, 0))
+		wg.Add(1)
+		go func(queue <-chan *sarama.ConsumerMessage) {
+			defer wg.Done()
+			if c.cfg.BatchSize > 1 {
+				c.runBatchWorker(ctx, fail, topic, tracker, queue)
+				return
+			}
+			for msg := range queue {
+				if ctx.Err() == nil {
+					// A message already taken is finished even if the
+					// consumer is stopping meanwhile.
+					if err := c.handleMessage(context.WithoutCancel(ctx), topic, msg); err != nil {
+						fail(err)
+					} else {
+						tracker.complete(msg.Offset)
+					}
+				}
+				metrics.KafkaMessagesInFlight.WithLabelValues(topic).Dec()
 			}
+		}(queues[i])
+	}
+	return queues, wg.Wait
+}
+
+// worker picks the queue of a message by its key, the order UID as set by
+// producers. Keyless messages are decoded to find the order UID.
+func (c *Consumer) worker(ctx context.Context, msg *sarama.ConsumerMessage, workers int) int {
+	if workers == 1 {
+		return 0
+	}
+	key := msg.Key
+	if len(key) == 0 {
+		if order, err := c.decoder.Decode(ctx, msg); err == nil {
+			key = []byte(order.OrderUID)
 		}
 	}
+	h := fnv.New32a()
+	_, _ = h.Write(key)
+	return int(h.Sum32() % uint32(workers))
 }
 
+// handleMessage decodes, validates and saves a single message. Malformed
+// messages are skipped and orders that fail to save permanently are
+// dead-lettered, see deadLetter; validation, schema registry and other
+// storage errors stop the consumer.
+//
+// The message span continues the trace found in the message headers, if
+// any, so producer, consumer and storage spans end up in one trace.
+func (c *Consumer) handleMessage(ctx context.Context, topic string, msg *sarama.ConsumerMessage) (err error) {
+	start := time.Now()
+	defer func() {
+		metrics.KafkaProcessingSeconds.WithLabelValues(topic).Observe(time.Since(start).Seconds())
+	}()
+
+	ctx, span, logger := c.startProcessing(ctx, topic, msg)
+	defer func() { telemetry.End(span, err) }()
+
+	order, err := c.decodeMessage(ctx, span, logger, topic, msg)
+	if err != nil || order == nil {
+		return err
+	}
+	logger = logger.With(logging.KeyOrderUID, order.OrderUID)
+	if err := c.saveOrder(ctx, logger, topic, order); err != nil {
+		return c.deadLetter(ctx, logger, topic, msg, err)
+	}
+	return nil
+}
+
+// startProcessing starts the span of a message, continuing the trace of
+// its producer, and returns a logger describing the message.
+func (c *Consumer) startProcessing(ctx context.Context, topic string, msg *sarama.ConsumerMessage) (context.Context, trace.Span, *slog.Logger) {
+	ctx = otel.GetTextMapPropagator().Extract(ctx, consumerHeaderCarrier(msg.Headers))
+	ctx, span := tracer.Start(ctx, topic+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "kafka"),
+			attribute.String("messaging.operation.type", "process"),
+			attribute.String("messaging.destination.name", topic),
+			attribute.Int("messaging.destination.partition.id", int(msg.Partition)),
+			attribute.Int64("messaging.kafka.offset", msg.Offset),
+			attribute.Int("messaging.message.body.size", len(msg.Value)),
+		),
+	)
+
+	logger := c.logger.With(
+		logging.KeyTopic, topic,
+		logging.KeyPartition, msg.Partition,
+		logging.KeyOffset, msg.Offset,
+	)
+	logger.DebugContext(ctx, "message received", "size", len(msg.Value))
+	return ctx, span, logger
+}
+
+// decodeMessage decodes and validates a message. It returns a nil order
+// for malformed messages, which are skipped, and the order together with
+// the error when it is invalid.
+func (c *Consumer) decodeMessage(ctx context.Context, span trace.Span, logger *slog.Logger, topic string, msg *sarama.ConsumerMessage) (*models.Order, error) {
+	// Decode the message
+	order, err := c.decoder.Decode(ctx, msg)
+	if errors.Is(err, ErrMalformedMessage) {
+		metrics.KafkaMessagesFailed.WithLabelValues(topic, metrics.ReasonDecode).Inc()
+		telemetry.RecordError(span, err)
+		logger.WarnContext(ctx, "skipping malformed message", logging.Err(err))
+		return nil, nil
+	}
+	if err != nil {
+		metrics.KafkaMessagesFailed.WithLabelValues(topic, metrics.ReasonDecode).Inc()
+		logger.ErrorContext(ctx, "failed to decode message", logging.Err(err))
+		return nil, err
+	}
+	span.SetAttributes(attribute.String("order.uid", order.OrderUID))
+	logger = logger.With(logging.KeyOrderUID, order.OrderUID)
+
+	// Validate the order
+	if err := c.validate(ctx, *order); err != nil {
+		metrics.KafkaMessagesFailed.WithLabelValues(topic, metrics.ReasonValidation).Inc()
+		logger.ErrorContext(ctx, "invalid order", logging.Err(err))
+		return order, err
+	}
+	return order, nil
+}
+
+func (c *Consumer) saveOrder(ctx context.Context, logger *slog.Logger, topic string, order *models.Order) error {
+	if err := c.orderUseCase.SaveOrder(ctx, order); err != nil {
+		metrics.KafkaMessagesFailed.WithLabelValues(topic, metrics.ReasonSave).Inc()
+		logger.ErrorContext(ctx, "failed to save order", logging.Err(err))
+		return err
+	}
+
+	logger.InfoContext(ctx, "order processed")
+	return nil
+}
+
+func (c *Consumer) validate(ctx context.Context, order models.Order) error {
+	return delivery.Validate(ctx, c.validator, order)
+}
+
+// Source returns the consumer as an ingestion source of topic.
+func (c *Consumer) Source(topic string) delivery.Source {
+	return topicSource{consumer: c, topic: topic}
+}
+
+type topicSource struct {
+	consumer *Consumer
+	topic    string
+}
+
+func (s topicSource) Run(ctx context.Context) error {
+	return s.consumer.Start(ctx, s.topic)
+}
+
+// Close stops committing offsets and disconnects from the brokers.
 func (c *Consumer) Close() error {
-	return c.consumer.Close()
+	var errs []error
+	if c.offsets != nil {
+		errs = append(errs, c.offsets.Close())
+	}
+	if c.deadLetters != nil {
+		errs = append(errs, c.deadLetters.Close())
+	}
+	errs = append(errs, c.consumer.Close())
+	if c.client != nil {
+		errs = append(errs, c.client.Close())
+	}
+	return errors.Join(errs...)
 }
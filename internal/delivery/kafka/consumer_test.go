@@ -1,12 +1,14 @@
 package kafka
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"testing"
 	"time"
 
 	"wb-tech-l0/internal/application/ports"
+	"wb-tech-l0/internal/logging"
 	imocks "wb-tech-l0/internal/mocks"
 	"wb-tech-l0/internal/models"
 	"wb-tech-l0/internal/validator"
@@ -15,11 +17,12 @@ import (
 	smocks "github.com/IBM/sarama/mocks"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 // helper to start consumer with injected deps
 func newTestConsumer(saramaConsumer sarama.Consumer, uc ports.OrderUseCase, v validator.Validator) *Consumer {
-	return NewConsumerWith(saramaConsumer, uc, v)
+	return NewConsumerWith(saramaConsumer, uc, v, logging.Discard())
 }
 
 func TestConsumer_SuccessfulProcessing(t *testing.T) {
@@ -53,7 +56,7 @@ func TestConsumer_SuccessfulProcessing(t *testing.T) {
 	data, _ := json.Marshal(order)
 
 	v.On("Validate", mock.Anything).Return(nil)
-	uc.On("SaveOrder", mock.MatchedBy(func(o *models.Order) bool { return o != nil && o.OrderUID == order.OrderUID })).Return(nil)
+	uc.On("SaveOrder", mock.Anything, mock.MatchedBy(func(o *models.Order) bool { return o != nil && o.OrderUID == order.OrderUID })).Return(nil)
 
 	cons := newTestConsumer(saramaC, uc, v)
 
@@ -68,7 +71,7 @@ func TestConsumer_SuccessfulProcessing(t *testing.T) {
 
 	err := <-doneCh
 	assert.NoError(t, err)
-	uc.AssertCalled(t, "SaveOrder", mock.MatchedBy(func(o *models.Order) bool { return o != nil && o.OrderUID == order.OrderUID }))
+	uc.AssertCalled(t, "SaveOrder", mock.Anything, mock.MatchedBy(func(o *models.Order) bool { return o != nil && o.OrderUID == order.OrderUID }))
 }
 
 func TestConsumer_InvalidJSON(t *testing.T) {
@@ -93,7 +96,7 @@ func TestConsumer_InvalidJSON(t *testing.T) {
 
 	// Ensure validator and usecase were not called
 	v.AssertNotCalled(t, "Validate", mock.Anything)
-	uc.AssertNotCalled(t, "SaveOrder", mock.Anything)
+	uc.AssertNotCalled(t, "SaveOrder", mock.Anything, mock.Anything)
 }
 
 func TestConsumer_ValidatorError(t *testing.T) {
@@ -119,7 +122,7 @@ func TestConsumer_ValidatorError(t *testing.T) {
 	pc.YieldMessage(&sarama.ConsumerMessage{Value: data})
 	err := <-errCh
 	assert.Error(t, err)
-	uc.AssertNotCalled(t, "SaveOrder", mock.Anything)
+	uc.AssertNotCalled(t, "SaveOrder", mock.Anything, mock.Anything)
 }
 
 func TestConsumer_ContextCancel(t *testing.T) {
@@ -141,3 +144,33 @@ func TestConsumer_ContextCancel(t *testing.T) {
 	err := <-done
 	assert.NoError(t, err)
 }
+
+func TestConsumer_DoesNotLogMessageBody(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := logging.New(&buf, logging.Config{Level: "debug", Format: logging.FormatJSON})
+	require.NoError(t, err)
+
+	v := new(imocks.ValidatorMock)
+	v.On("Validate", mock.Anything).Return(nil)
+	uc := new(imocks.OrderUseCaseMock)
+	uc.On("SaveOrder", mock.Anything, mock.Anything).Return(nil)
+
+	order := models.Order{
+		OrderUID: "uid-private",
+		Delivery: models.Delivery{Name: "John Smith", Phone: "+79161234567", Address: "Ploshad Mira 15", Email: "john@example.com"},
+		Payment:  models.Payment{Transaction: "b563feb7b2b84b6test"},
+	}
+	data, err := json.Marshal(order)
+	require.NoError(t, err)
+
+	c := NewConsumerWith(nil, uc, v, logger)
+	require.NoError(t, c.handleMessage(context.Background(), "orders", &sarama.ConsumerMessage{Value: data, Partition: 3, Offset: 42}))
+
+	out := buf.String()
+	assert.Contains(t, out, `"order_uid":"uid-private"`)
+	assert.Contains(t, out, `"partition":3`)
+	assert.Contains(t, out, `"offset":42`)
+	for _, secret := range []string{"John Smith", "+79161234567", "Ploshad Mira", "john@example.com", "b563feb7b2b84b6test"} {
+		assert.NotContains(t, out, secret)
+	}
+}
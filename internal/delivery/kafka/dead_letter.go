@@ -0,0 +1,72 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"wb-tech-l0/internal/application/ports"
+	"wb-tech-l0/internal/logging"
+	"wb-tech-l0/internal/metrics"
+
+	"github.com/IBM/sarama"
+)
+
+// Headers added to a dead-lettered message, next to its own, telling
+// where it came from and why it could not be saved.
+const (
+	HeaderDeadLetterTopic     = "x-dead-letter-topic"
+	HeaderDeadLetterPartition = "x-dead-letter-partition"
+	HeaderDeadLetterOffset    = "x-dead-letter-offset"
+	HeaderDeadLetterError     = "x-dead-letter-error"
+)
+
+// deadLetter decides what happens to a message whose order failed to
+// save. A retryable error, or any error when no dead-letter topic is
+// configured, is returned and stops the consumer, so the message is
+// consumed again after a restart. Otherwise the message is copied to the
+// dead-letter topic, from where it can be replayed once the cause is
+// fixed, and nil is returned: the message is done. An error publishing the
+// copy is returned as well.
+func (c *Consumer) deadLetter(ctx context.Context, logger *slog.Logger, topic string, msg *sarama.ConsumerMessage, err error) error {
+	if retryable(err) || c.deadLetters == nil {
+		return err
+	}
+
+	headers := make([]sarama.RecordHeader, 0, len(msg.Headers)+4)
+	for _, h := range msg.Headers {
+		if h != nil {
+			headers = append(headers, *h)
+		}
+	}
+	headers = append(headers,
+		sarama.RecordHeader{Key: []byte(HeaderDeadLetterTopic), Value: []byte(topic)},
+		sarama.RecordHeader{Key: []byte(HeaderDeadLetterPartition), Value: []byte(strconv.Itoa(int(msg.Partition)))},
+		sarama.RecordHeader{Key: []byte(HeaderDeadLetterOffset), Value: []byte(strconv.FormatInt(msg.Offset, 10))},
+		sarama.RecordHeader{Key: []byte(HeaderDeadLetterError), Value: []byte(err.Error())},
+	)
+	_, _, pubErr := c.deadLetters.SendMessage(&sarama.ProducerMessage{
+		Topic:   c.cfg.DeadLetterTopic,
+		Key:     sarama.ByteEncoder(msg.Key),
+		Value:   sarama.ByteEncoder(msg.Value),
+		Headers: headers,
+	})
+	if pubErr != nil {
+		logger.ErrorContext(ctx, "failed to dead-letter message", logging.Err(pubErr))
+		return fmt.Errorf("dead-letter message: %w", errors.Join(err, pubErr))
+	}
+
+	metrics.KafkaMessagesDeadLettered.WithLabelValues(topic).Inc()
+	logger.WarnContext(ctx, "order cannot be saved, message dead-lettered",
+		"dead_letter_topic", c.cfg.DeadLetterTopic, logging.Err(err))
+	return nil
+}
+
+// retryable reports whether saving an order may succeed when its message
+// is consumed again: the database was unreachable or the save clashed
+// with a concurrent one.
+func retryable(err error) bool {
+	return errors.Is(err, ports.ErrUnavailable) || errors.Is(err, ports.ErrConflict)
+}
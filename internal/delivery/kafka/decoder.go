@@ -0,0 +1,169 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"strings"
+
+	"wb-tech-l0/internal/models"
+	"wb-tech-l0/internal/schemaregistry"
+
+	"github.com/IBM/sarama"
+)
+
+// Message formats accepted by NewDecoder.
+const (
+	// FormatAuto picks the decoder per message, see NewDecoder.
+	FormatAuto     = "auto"
+	FormatJSON     = "json"
+	FormatAvro     = "avro"
+	FormatProtobuf = "protobuf"
+)
+
+// ErrMalformedMessage marks messages that can never be decoded. The
+// consumer skips them; other decode errors, such as an unreachable schema
+// registry, stop it like storage errors do.
+var ErrMalformedMessage = errors.New("malformed message")
+
+// Decoder turns a Kafka message into an order.
+type Decoder interface {
+	Decode(ctx context.Context, msg *sarama.ConsumerMessage) (*models.Order, error)
+}
+
+// NewDecoder returns the decoder of a message format. Avro and Protobuf
+// messages must be in the Confluent wire format; their schema is fetched
+// from the registry by the ID in the message. registry may be nil for the
+// JSON format, and for the auto format if no framed messages are expected.
+//
+// The auto format decodes by the content-type header when there is one
+// (application/json, application/avro, application/x-protobuf), otherwise
+// by the magic byte: framed messages are decoded by the type of their
+// registered schema and everything else as JSON.
+func NewDecoder(format string, registry *schemaregistry.Client) (Decoder, error) {
+	switch format {
+	case FormatJSON:
+		return JSONDecoder{}, nil
+	case FormatAvro:
+		if registry == nil {
+			return nil, errors.New("the avro format requires a schema registry")
+		}
+		return &registryDecoder{registry: registry, format: FormatAvro, avro: newAvroDecoder(registry)}, nil
+	case FormatProtobuf:
+		if registry == nil {
+			return nil, errors.New("the protobuf format requires a schema registry")
+		}
+		return &registryDecoder{registry: registry, format: FormatProtobuf, protobuf: newProtobufDecoder(registry)}, nil
+	case FormatAuto:
+		d := &autoDecoder{}
+		if registry != nil {
+			d.registry = &registryDecoder{
+				registry: registry,
+				avro:     newAvroDecoder(registry),
+				protobuf: newProtobufDecoder(registry),
+			}
+		}
+		return d, nil
+	}
+	return nil, fmt.Errorf("unknown message format %q", format)
+}
+
+// JSONDecoder decodes plain JSON messages, the format of models.Order.
+type JSONDecoder struct{}
+
+func (JSONDecoder) Decode(_ context.Context, msg *sarama.ConsumerMessage) (*models.Order, error) {
+	return decodeJSON(msg.Value)
+}
+
+func decodeJSON(data []byte) (*models.Order, error) {
+	var order models.Order
+	if err := json.Unmarshal(data, &order); err != nil {
+		return nil, fmt.Errorf("%w: json: %w", ErrMalformedMessage, err)
+	}
+	return &order, nil
+}
+
+// registryDecoder decodes framed messages by their registered schema. With
+// a format set, schemas of other types are rejected.
+type registryDecoder struct {
+	registry *schemaregistry.Client
+	format   string
+	avro     *avroDecoder
+	protobuf *protobufDecoder
+}
+
+func (d *registryDecoder) Decode(ctx context.Context, msg *sarama.ConsumerMessage) (*models.Order, error) {
+	id, payload, err := schemaregistry.ParseWireFormat(msg.Value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMalformedMessage, err)
+	}
+
+	schema, err := d.registry.SchemaByID(ctx, id)
+	if errors.Is(err, schemaregistry.ErrSchemaNotFound) {
+		return nil, fmt.Errorf("%w: %w", ErrMalformedMessage, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case schema.Type == schemaregistry.TypeAvro && d.avro != nil:
+		return d.avro.decode(schema, payload)
+	case schema.Type == schemaregistry.TypeProtobuf && d.protobuf != nil:
+		return d.protobuf.decode(ctx, schema, payload)
+	case schema.Type == schemaregistry.TypeJSON && d.format == "":
+		// JSON Schema framed messages carry plain JSON after the header.
+		return decodeJSON(payload)
+	}
+	return nil, fmt.Errorf("%w: schema %d is of type %s", ErrMalformedMessage, id, schema.Type)
+}
+
+type autoDecoder struct {
+	registry *registryDecoder
+}
+
+func (d *autoDecoder) Decode(ctx context.Context, msg *sarama.ConsumerMessage) (*models.Order, error) {
+	format := formatFromContentType(msg.Headers)
+	if format == FormatJSON || (format == "" && !schemaregistry.IsWireFormat(msg.Value)) {
+		return decodeJSON(msg.Value)
+	}
+	if d.registry == nil {
+		return nil, fmt.Errorf("%w: framed message but no schema registry is configured", ErrMalformedMessage)
+	}
+
+	registry := *d.registry
+	registry.format = format
+	if format == FormatAvro {
+		registry.protobuf = nil
+	}
+	if format == FormatProtobuf {
+		registry.avro = nil
+	}
+	return registry.Decode(ctx, msg)
+}
+
+// formatFromContentType maps the content-type header of a message to a
+// format, or returns "" if there is no header or it is not recognised.
+func formatFromContentType(headers []*sarama.RecordHeader) string {
+	for _, h := range headers {
+		if h == nil || !strings.EqualFold(string(h.Key), "content-type") {
+			continue
+		}
+		mediaType, _, err := mime.ParseMediaType(string(h.Value))
+		if err != nil {
+			return ""
+		}
+		switch mediaType {
+		case "application/json":
+			return FormatJSON
+		case "application/avro", "avro/binary", "application/vnd.apache.avro+binary":
+			return FormatAvro
+		case "application/x-protobuf", "application/protobuf", "application/vnd.google.protobuf":
+			return FormatProtobuf
+		}
+		return ""
+	}
+	return ""
+}
@@ -0,0 +1,399 @@
+package kafka
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"wb-tech-l0/internal/application/ports"
+	imocks "wb-tech-l0/internal/mocks"
+	"wb-tech-l0/internal/models"
+	"wb-tech-l0/internal/schemaregistry"
+	orderv1 "wb-tech-l0/pkg/api/order/v1"
+
+	"github.com/IBM/sarama"
+	"github.com/bufbuild/protocompile"
+	"github.com/hamba/avro/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Producer schemas registered in the fake registry.
+const (
+	avroSchemaID     = 1
+	protobufSchemaID = 2
+	jsonSchemaID     = 3
+	commonProtoID    = 4
+)
+
+// producerAvroSchema lacks the optional fields of the reader schema and
+// adds one the reader does not know.
+const producerAvroSchema = `{
+  "type": "record", "name": "Order", "namespace": "producer",
+  "fields": [
+    {"name": "order_uid", "type": "string"},
+    {"name": "track_number", "type": "string"},
+    {"name": "entry", "type": "string"},
+    {"name": "delivery", "type": {"type": "record", "name": "Delivery", "fields": [
+      {"name": "name", "type": "string"}, {"name": "phone", "type": "string"},
+      {"name": "zip", "type": "string"}, {"name": "city", "type": "string"},
+      {"name": "address", "type": "string"}, {"name": "region", "type": "string"},
+      {"name": "email", "type": "string"}]}},
+    {"name": "payment", "type": {"type": "record", "name": "Payment", "fields": [
+      {"name": "transaction", "type": "string"}, {"name": "currency", "type": "string"},
+      {"name": "provider", "type": "string"}, {"name": "amount", "type": "int"},
+      {"name": "payment_dt", "type": "long"}, {"name": "bank", "type": "string"},
+      {"name": "delivery_cost", "type": "int"}, {"name": "goods_total", "type": "int"}]}},
+    {"name": "items", "type": {"type": "array", "items": {"type": "record", "name": "Item", "fields": [
+      {"name": "chrt_id", "type": "long"}, {"name": "track_number", "type": "string"},
+      {"name": "price", "type": "int"}, {"name": "rid", "type": "string"},
+      {"name": "name", "type": "string"}, {"name": "sale", "type": "int"},
+      {"name": "size", "type": "string"}, {"name": "total_price", "type": "int"},
+      {"name": "nm_id", "type": "long"}, {"name": "brand", "type": "string"},
+      {"name": "status", "type": "int"}]}}},
+    {"name": "locale", "type": "string"},
+    {"name": "customer_id", "type": "string"},
+    {"name": "delivery_service", "type": "string"},
+    {"name": "shardkey", "type": "string"},
+    {"name": "sm_id", "type": "int"},
+    {"name": "date_created", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+    {"name": "oof_shard", "type": "string"},
+    {"name": "producer", "type": "string", "default": ""}
+  ]
+}`
+
+// producerProtoSchema numbers its fields differently from order.v1, puts
+// the order second in the file and imports a referenced schema.
+const producerProtoSchema = `syntax = "proto3";
+package producer.v1;
+
+import "google/protobuf/timestamp.proto";
+import "common.proto";
+
+message Envelope { string id = 1; }
+
+message Order {
+  string order_uid = 10;
+  string track_number = 11;
+  string entry = 12;
+  common.v1.Delivery delivery = 13;
+  Payment payment = 14;
+  repeated Item items = 15;
+  string locale = 16;
+  string internal_signature = 17;
+  string customer_id = 18;
+  string delivery_service = 19;
+  string shardkey = 20;
+  int64 sm_id = 21;
+  google.protobuf.Timestamp date_created = 22;
+  string oof_shard = 23;
+
+  message Payment {
+    string transaction = 1;
+    string request_id = 2;
+    string currency = 3;
+    string provider = 4;
+    int64 amount = 5;
+    int64 payment_dt = 6;
+    string bank = 7;
+    int64 delivery_cost = 8;
+    int64 goods_total = 9;
+    int64 custom_fee = 10;
+  }
+  message Item {
+    int64 chrt_id = 1;
+    string track_number = 2;
+    int64 price = 3;
+    string rid = 4;
+    string name = 5;
+    int64 sale = 6;
+    string size = 7;
+    int64 total_price = 8;
+    int64 nm_id = 9;
+    string brand = 10;
+    int64 status = 11;
+  }
+}
+`
+
+const commonProtoSchema = `syntax = "proto3";
+package common.v1;
+
+message Delivery {
+  string name = 1;
+  string phone = 2;
+  string zip = 3;
+  string city = 4;
+  string address = 5;
+  string region = 6;
+  string email = 7;
+}
+`
+
+// fakeRegistry serves the producer schemas and counts requests.
+type fakeRegistry struct {
+	srv      *httptest.Server
+	requests atomic.Int32
+}
+
+func newFakeRegistry(t *testing.T) *fakeRegistry {
+	t.Helper()
+
+	schemas := map[string]map[string]any{
+		"/schemas/ids/" + strconv.Itoa(avroSchemaID): {"schema": producerAvroSchema},
+		"/schemas/ids/" + strconv.Itoa(protobufSchemaID): {
+			"schemaType": "PROTOBUF",
+			"schema":     producerProtoSchema,
+			"references": []map[string]any{{"name": "common.proto", "subject": "common-value", "version": 1}},
+		},
+		"/schemas/ids/" + strconv.Itoa(jsonSchemaID): {"schemaType": "JSON", "schema": `{"type":"object"}`},
+		"/subjects/common-value/versions/1": {
+			"id": commonProtoID, "subject": "common-value", "version": 1,
+			"schemaType": "PROTOBUF", "schema": commonProtoSchema,
+		},
+	}
+
+	r := &fakeRegistry{}
+	r.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.requests.Add(1)
+		w.Header().Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+		body, ok := schemas[req.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error_code":40403,"message":"Schema not found"}`))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(body)
+	}))
+	t.Cleanup(r.srv.Close)
+	return r
+}
+
+func (r *fakeRegistry) client() *schemaregistry.Client {
+	return schemaregistry.NewClient(r.srv.Client(), schemaregistry.Config{URL: r.srv.URL})
+}
+
+func decoderFixture() models.Order {
+	return models.Order{
+		OrderUID:    "b563feb7-b2b8-4b6a-9f3e-1c2d3e4f5a6b",
+		TrackNumber: "WBILMTESTTRACK",
+		Entry:       "WBIL",
+		Delivery: models.Delivery{
+			Name: "Test Testov", Phone: "+9720000000", Zip: "2639809", City: "Kiryat Mozkin",
+			Address: "Ploshad Mira 15", Region: "Kraiot", Email: "test@gmail.com",
+		},
+		Payment: models.Payment{
+			Transaction: "5c1d4e7a-8f2b-4c3d-9e0f-a1b2c3d4e5f6", Currency: "USD", Provider: "wbpay",
+			Amount: 1817, PaymentDt: 1637907727, Bank: "alpha", DeliveryCost: 1500, GoodsTotal: 317,
+		},
+		Items: []models.Item{{
+			ChrtID: 9934930, TrackNumber: "WBILMTESTTRACK", Price: 453, RID: "ab421908-7a76-4ae0-b000-000000000001",
+			Name: "Mascaras", Sale: 30, Size: "0", TotalPrice: 317, NmID: 2389212, Brand: "Vivienne Sabo", Status: 202,
+		}},
+		Locale:          "en",
+		CustomerID:      "0f8e7d6c-5b4a-4392-8170-6f5e4d3c2b1a",
+		DeliveryService: "meest",
+		Shardkey:        "9",
+		SmID:            99,
+		DateCreated:     time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+		OofShard:        "1",
+	}
+}
+
+func avroMessage(t *testing.T, order models.Order) []byte {
+	t.Helper()
+
+	schema, err := avro.ParseWithCache(producerAvroSchema, "", &avro.SchemaCache{})
+	require.NoError(t, err)
+	payload, err := avroAPI.Marshal(schema, order)
+	require.NoError(t, err)
+	return schemaregistry.AppendWireFormat(nil, avroSchemaID, payload)
+}
+
+func protobufMessage(t *testing.T, order models.Order) []byte {
+	t.Helper()
+
+	compiler := protocompile.Compiler{
+		Resolver: protocompile.WithStandardImports(&protocompile.SourceResolver{
+			Accessor: protocompile.SourceAccessorFromMap(map[string]string{
+				"producer.proto": producerProtoSchema,
+				"common.proto":   commonProtoSchema,
+			}),
+		}),
+	}
+	files, err := compiler.Compile(context.Background(), "producer.proto")
+	require.NoError(t, err)
+
+	data, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(orderv1.FromModel(&order))
+	require.NoError(t, err)
+	msg := dynamicpb.NewMessage(files[0].Messages().ByName("Order"))
+	require.NoError(t, protojson.Unmarshal(data, msg))
+	payload, err := proto.Marshal(msg)
+	require.NoError(t, err)
+
+	// Message indexes: one index, 1, the second message of the file.
+	indexes := binary.AppendVarint(binary.AppendVarint(nil, 1), 1)
+	return schemaregistry.AppendWireFormat(nil, protobufSchemaID, append(indexes, payload...))
+}
+
+func TestDecoder_Formats(t *testing.T) {
+	registry := newFakeRegistry(t)
+	order := decoderFixture()
+	jsonData, err := json.Marshal(order)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		format      string
+		value       []byte
+		contentType string
+	}{
+		{"json", FormatJSON, jsonData, ""},
+		{"avro", FormatAvro, avroMessage(t, order), ""},
+		{"protobuf", FormatProtobuf, protobufMessage(t, order), ""},
+		{"auto json", FormatAuto, jsonData, ""},
+		{"auto avro by schema type", FormatAuto, avroMessage(t, order), ""},
+		{"auto protobuf by schema type", FormatAuto, protobufMessage(t, order), ""},
+		{"auto avro by content type", FormatAuto, avroMessage(t, order), "application/avro"},
+		{"auto protobuf by content type", FormatAuto, protobufMessage(t, order), "application/x-protobuf"},
+		{"auto json schema framing", FormatAuto, schemaregistry.AppendWireFormat(nil, jsonSchemaID, jsonData), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := NewDecoder(tt.format, registry.client())
+			require.NoError(t, err)
+
+			msg := &sarama.ConsumerMessage{Value: tt.value}
+			if tt.contentType != "" {
+				msg.Headers = []*sarama.RecordHeader{{Key: []byte("content-type"), Value: []byte(tt.contentType)}}
+			}
+			got, err := d.Decode(context.Background(), msg)
+			require.NoError(t, err)
+
+			assert.Equal(t, order.DateCreated.UnixMilli(), got.DateCreated.UnixMilli())
+			got.DateCreated = order.DateCreated
+			assert.Equal(t, order, *got)
+		})
+	}
+}
+
+func TestDecoder_CachesSchemas(t *testing.T) {
+	registry := newFakeRegistry(t)
+	d, err := NewDecoder(FormatAuto, registry.client())
+	require.NoError(t, err)
+
+	for range 3 {
+		_, err := d.Decode(context.Background(), &sarama.ConsumerMessage{Value: avroMessage(t, decoderFixture())})
+		require.NoError(t, err)
+		_, err = d.Decode(context.Background(), &sarama.ConsumerMessage{Value: protobufMessage(t, decoderFixture())})
+		require.NoError(t, err)
+	}
+	// The Avro schema, the Protobuf schema and its reference, once each.
+	assert.EqualValues(t, 3, registry.requests.Load())
+}
+
+func TestDecoder_MalformedMessages(t *testing.T) {
+	registry := newFakeRegistry(t)
+	order := decoderFixture()
+
+	truncated := avroMessage(t, order)
+	truncated = truncated[:len(truncated)/2]
+
+	tests := []struct {
+		name        string
+		format      string
+		value       []byte
+		contentType string
+		noRegistry  bool
+	}{
+		{"invalid json", FormatJSON, []byte("{not json"), "", false},
+		{"unknown schema id", FormatAvro, schemaregistry.AppendWireFormat(nil, 99, []byte{1}), "", false},
+		{"not framed", FormatAvro, []byte(`{"order_uid":"x"}`), "", false},
+		{"truncated avro", FormatAvro, truncated, "", false},
+		{"protobuf schema for avro format", FormatAvro, protobufMessage(t, order), "", false},
+		{"avro schema for protobuf content type", FormatAuto, avroMessage(t, order), "application/x-protobuf", false},
+		{"framed without registry", FormatAuto, avroMessage(t, order), "", true},
+		{"bad message indexes", FormatProtobuf, schemaregistry.AppendWireFormat(nil, protobufSchemaID, []byte{0x04, 0x10}), "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := registry.client()
+			if tt.noRegistry {
+				client = nil
+			}
+			d, err := NewDecoder(tt.format, client)
+			require.NoError(t, err)
+
+			msg := &sarama.ConsumerMessage{Value: tt.value}
+			if tt.contentType != "" {
+				msg.Headers = []*sarama.RecordHeader{{Key: []byte("Content-Type"), Value: []byte(tt.contentType)}}
+			}
+			_, err = d.Decode(context.Background(), msg)
+			assert.ErrorIs(t, err, ErrMalformedMessage)
+		})
+	}
+}
+
+func TestDecoder_RegistryUnavailable(t *testing.T) {
+	registry := newFakeRegistry(t)
+	client := registry.client()
+	registry.srv.Close()
+
+	d, err := NewDecoder(FormatAvro, client)
+	require.NoError(t, err)
+
+	_, err = d.Decode(context.Background(), &sarama.ConsumerMessage{Value: avroMessage(t, decoderFixture())})
+	assert.ErrorIs(t, err, ports.ErrUnavailable)
+	assert.NotErrorIs(t, err, ErrMalformedMessage)
+}
+
+func TestNewDecoder_Errors(t *testing.T) {
+	_, err := NewDecoder(FormatAvro, nil)
+	assert.Error(t, err)
+	_, err = NewDecoder(FormatProtobuf, nil)
+	assert.Error(t, err)
+	_, err = NewDecoder("xml", nil)
+	assert.True(t, err != nil && strings.Contains(err.Error(), "xml"))
+}
+
+func TestConsumer_DecodesWithRegistry(t *testing.T) {
+	registry := newFakeRegistry(t)
+	uc := new(imocks.OrderUseCaseMock)
+	v := new(imocks.ValidatorMock)
+	v.On("Validate", mock.Anything).Return(nil)
+	uc.On("SaveOrder", mock.Anything, mock.MatchedBy(func(o *models.Order) bool {
+		return o.OrderUID == decoderFixture().OrderUID
+	})).Return(nil)
+
+	c := newTestConsumer(nil, uc, v)
+	c.decoder, _ = NewDecoder(FormatAuto, registry.client())
+
+	// Malformed messages are skipped.
+	err := c.handleMessage(context.Background(), "orders", &sarama.ConsumerMessage{
+		Value: schemaregistry.AppendWireFormat(nil, 99, []byte{1}),
+	})
+	require.NoError(t, err)
+
+	err = c.handleMessage(context.Background(), "orders", &sarama.ConsumerMessage{Value: protobufMessage(t, decoderFixture())})
+	require.NoError(t, err)
+	uc.AssertNumberOfCalls(t, "SaveOrder", 1)
+
+	// Without the registry the message cannot be read yet: stop instead
+	// of skipping it.
+	registry.srv.Close()
+	c.decoder, _ = NewDecoder(FormatAuto, registry.client())
+	err = c.handleMessage(context.Background(), "orders", &sarama.ConsumerMessage{Value: avroMessage(t, decoderFixture())})
+	assert.ErrorIs(t, err, ports.ErrUnavailable)
+	uc.AssertNumberOfCalls(t, "SaveOrder", 1)
+}
@@ -0,0 +1,57 @@
+package kafka
+
+import "sync"
+
+// OffsetStore keeps the consumer's position in a partition.
+// sarama.PartitionOffsetManager implements it.
+type OffsetStore interface {
+	// NextOffset returns the offset to resume from.
+	NextOffset() (int64, string)
+	// MarkOffset records offset as the next one to consume; it is
+	// committed to the broker in the background.
+	MarkOffset(offset int64, metadata string)
+}
+
+// offsetTracker turns out-of-order completion into a safe commit
+// position. Messages are added in offset order as they are dispatched and
+// completed in any order; the committed offset only moves past a message
+// once it and every message before it are done, so a restart never skips
+// an unprocessed message.
+type offsetTracker struct {
+	mu      sync.Mutex
+	pending []int64 // dispatched offsets, ascending
+	done    map[int64]bool
+	commit  func(next int64)
+}
+
+func newOffsetTracker(commit func(next int64)) *offsetTracker {
+	return &offsetTracker{done: make(map[int64]bool), commit: commit}
+}
+
+// add registers a dispatched message. Offsets must be added in order.
+func (t *offsetTracker) add(offset int64) {
+	t.mu.Lock()
+	t.pending = append(t.pending, offset)
+	t.mu.Unlock()
+}
+
+// complete marks a message processed and commits the offset after the
+// longest processed prefix, if it moved. commit is called under the lock,
+// so commits are never reordered.
+func (t *offsetTracker) complete(offset int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.done[offset] = true
+	advanced := false
+	var next int64
+	for len(t.pending) > 0 && t.done[t.pending[0]] {
+		delete(t.done, t.pending[0])
+		next = t.pending[0] + 1
+		t.pending = t.pending[1:]
+		advanced = true
+	}
+	if advanced {
+		t.commit(next)
+	}
+}
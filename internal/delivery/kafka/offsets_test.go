@@ -0,0 +1,164 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"testing"
+	"time"
+
+	imocks "wb-tech-l0/internal/mocks"
+	"wb-tech-l0/internal/models"
+
+	"github.com/IBM/sarama"
+	smocks "github.com/IBM/sarama/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOffsetTracker_CommitsContiguousPrefix(t *testing.T) {
+	var commits []int64
+	tr := newOffsetTracker(func(next int64) { commits = append(commits, next) })
+	for _, o := range []int64{5, 6, 7, 9} {
+		tr.add(o)
+	}
+
+	tr.complete(7)
+	tr.complete(6)
+	assert.Empty(t, commits, "5 is still being processed")
+
+	tr.complete(5)
+	assert.Equal(t, []int64{8}, commits)
+
+	tr.complete(9)
+	assert.Equal(t, []int64{8, 10}, commits)
+}
+
+// fakeOffsetManager keeps offsets in memory in place of the broker.
+type fakeOffsetManager struct {
+	sarama.OffsetManager
+	pom *fakePartitionOffsetManager
+}
+
+func (m *fakeOffsetManager) ManagePartition(string, int32) (sarama.PartitionOffsetManager, error) {
+	return m.pom, nil
+}
+
+func (m *fakeOffsetManager) Commit() {
+	m.pom.mu.Lock()
+	m.pom.committed = m.pom.marked
+	m.pom.mu.Unlock()
+}
+
+type fakePartitionOffsetManager struct {
+	sarama.PartitionOffsetManager
+
+	mu        sync.Mutex
+	marked    int64
+	committed int64
+}
+
+func (p *fakePartitionOffsetManager) NextOffset() (int64, string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.marked, ""
+}
+
+func (p *fakePartitionOffsetManager) MarkOffset(offset int64, _ string) {
+	p.mu.Lock()
+	p.marked = offset
+	p.mu.Unlock()
+}
+
+func (p *fakePartitionOffsetManager) Close() error { return nil }
+
+func orderMessage(t *testing.T, uid, track string) *sarama.ConsumerMessage {
+	t.Helper()
+	data, err := json.Marshal(models.Order{OrderUID: uid, TrackNumber: track})
+	require.NoError(t, err)
+	return &sarama.ConsumerMessage{Key: []byte(uid), Value: data}
+}
+
+func TestConsumer_KeepsOrderPerKey(t *testing.T) {
+	topic := "orders"
+	saramaC := smocks.NewConsumer(t, nil)
+	defer saramaC.Close()
+	pc := saramaC.ExpectConsumePartition(topic, 0, sarama.OffsetNewest)
+
+	var (
+		mu   sync.Mutex
+		seen = make(map[string][]string)
+		wg   sync.WaitGroup
+	)
+	v := new(imocks.ValidatorMock)
+	v.On("Validate", mock.Anything).Return(nil)
+	uc := new(imocks.OrderUseCaseMock)
+	uc.On("SaveOrder", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		defer wg.Done()
+		time.Sleep(time.Duration(rand.IntN(3)) * time.Millisecond)
+		o := args.Get(1).(*models.Order)
+		mu.Lock()
+		seen[o.OrderUID] = append(seen[o.OrderUID], o.TrackNumber)
+		mu.Unlock()
+	}).Return(nil)
+
+	cons := newTestConsumer(saramaC, uc, v)
+	cons.cfg = ConsumerConfig{Concurrency: 4, QueueDepth: 2}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- cons.Start(ctx, topic) }()
+
+	const perKey = 20
+	keys := []string{"a", "b", "c", "d", "e"}
+	wg.Add(perKey * len(keys))
+	for i := range perKey {
+		for _, k := range keys {
+			pc.YieldMessage(orderMessage(t, k, fmt.Sprintf("%02d", i)))
+		}
+	}
+	wg.Wait()
+	cancel()
+	require.NoError(t, <-done)
+
+	for _, k := range keys {
+		require.Len(t, seen[k], perKey)
+		assert.IsIncreasing(t, seen[k], "updates of %s reordered", k)
+	}
+}
+
+func TestConsumer_CommitsOnlyProcessedOffsets(t *testing.T) {
+	topic := "orders"
+	saramaC := smocks.NewConsumer(t, nil)
+	defer saramaC.Close()
+	// Consumption resumes from the committed offset.
+	pc := saramaC.ExpectConsumePartition(topic, 0, 10)
+
+	v := new(imocks.ValidatorMock)
+	v.On("Validate", mock.MatchedBy(func(o models.Order) bool { return o.OrderUID == "bad" })).Return(assert.AnError)
+	v.On("Validate", mock.Anything).Return(nil)
+	uc := new(imocks.OrderUseCaseMock)
+	uc.On("SaveOrder", mock.Anything, mock.Anything).Return(nil)
+
+	pom := &fakePartitionOffsetManager{marked: 10}
+	cons := newTestConsumer(saramaC, uc, v)
+	cons.offsets = &fakeOffsetManager{pom: pom}
+	cons.cfg = ConsumerConfig{Concurrency: 2, QueueDepth: 4}
+
+	done := make(chan error, 1)
+	go func() { done <- cons.Start(context.Background(), topic) }()
+
+	pc.YieldMessage(orderMessage(t, "ok-1", "1")) // 10
+	assert.Eventually(t, func() bool { next, _ := pom.NextOffset(); return next == 11 }, time.Second, time.Millisecond)
+	pc.YieldMessage(orderMessage(t, "bad", "2"))  // 11
+	pc.YieldMessage(orderMessage(t, "ok-2", "3")) // 12
+
+	err := <-done
+	require.ErrorIs(t, err, assert.AnError)
+
+	// 12 may have been saved, but 11 was not, so it is consumed again.
+	assert.EqualValues(t, 11, pom.committed)
+}
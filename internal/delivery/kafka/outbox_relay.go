@@ -0,0 +1,119 @@
+package kafka
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"wb-tech-l0/internal/application/ports"
+	"wb-tech-l0/internal/logging"
+
+	"github.com/IBM/sarama"
+)
+
+// OutboxRelay publishes events from the transactional outbox to Kafka.
+//
+// Delivery is at-least-once: rows are deleted only after the broker has
+// acknowledged them. A batch stays locked in the database while it is
+// published, so relays on several replicas take turns instead of
+// publishing the same events. Events are keyed by order UID, and once an
+// event of an order fails to publish the remaining events of that order
+// are held back until the next pass, so per-order ordering is preserved.
+type OutboxRelay struct {
+	repo      ports.OutboxRepository
+	producer  sarama.SyncProducer
+	topic     string
+	batchSize int
+	interval  time.Duration
+	logger    *slog.Logger
+}
+
+// NewOutboxProducer creates a sync producer suitable for ordered publishing.
+func NewOutboxProducer(brokers []string) (sarama.SyncProducer, error) {
+	cfg := sarama.NewConfig()
+	cfg.Producer.RequiredAcks = sarama.WaitForAll
+	cfg.Producer.Return.Successes = true
+	cfg.Producer.Idempotent = true
+	cfg.Producer.Partitioner = sarama.NewHashPartitioner
+	cfg.Net.MaxOpenRequests = 1
+	return sarama.NewSyncProducer(brokers, cfg)
+}
+
+func NewOutboxRelay(repo ports.OutboxRepository, producer sarama.SyncProducer, topic string, batchSize int, interval time.Duration, logger *slog.Logger) *OutboxRelay {
+	return &OutboxRelay{
+		repo:      repo,
+		producer:  producer,
+		topic:     topic,
+		batchSize: batchSize,
+		interval:  interval,
+		logger:    logger.With("component", "outbox_relay"),
+	}
+}
+
+// Start polls the outbox until the context is cancelled.
+func (r *OutboxRelay) Start(ctx context.Context) error {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		published, err := r.RelayOnce(ctx)
+		if err != nil {
+			r.logger.ErrorContext(ctx, "outbox relay pass failed", logging.Err(err))
+		}
+
+		// A full batch means there is likely more work waiting.
+		if err == nil && published == r.batchSize {
+			if ctx.Err() != nil {
+				return nil
+			}
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// RelayOnce publishes a single batch of pending events and deletes the ones
+// acknowledged by the broker. It returns the number of published events.
+func (r *OutboxRelay) RelayOnce(ctx context.Context) (int, error) {
+	return r.repo.RelayOutboxEvents(ctx, r.batchSize, r.publish)
+}
+
+// publish sends the events in order and returns the IDs of the ones the
+// broker acknowledged.
+func (r *OutboxRelay) publish(events []ports.OutboxEvent) []uint64 {
+	blocked := make(map[string]bool)
+	published := make([]uint64, 0, len(events))
+
+	for _, e := range events {
+		if blocked[e.OrderUID] {
+			continue
+		}
+
+		msg := &sarama.ProducerMessage{
+			Topic: r.topic,
+			Key:   sarama.StringEncoder(e.OrderUID),
+			Value: sarama.ByteEncoder(e.Payload),
+			Headers: []sarama.RecordHeader{
+				{Key: []byte("event_id"), Value: []byte(e.EventID)},
+				{Key: []byte("event_type"), Value: []byte(e.EventType)},
+			},
+		}
+
+		if _, _, err := r.producer.SendMessage(msg); err != nil {
+			r.logger.Warn("failed to publish outbox event", "event_id", e.EventID, logging.KeyOrderUID, e.OrderUID, logging.Err(err))
+			blocked[e.OrderUID] = true
+			continue
+		}
+		published = append(published, e.ID)
+	}
+	return published
+}
+
+func (r *OutboxRelay) Close() error {
+	return r.producer.Close()
+}
@@ -0,0 +1,99 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"wb-tech-l0/internal/application/ports"
+	"wb-tech-l0/internal/logging"
+	imocks "wb-tech-l0/internal/mocks"
+
+	"github.com/IBM/sarama"
+	smocks "github.com/IBM/sarama/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// relayEvents makes the mocked RelayOutboxEvents publish events and record
+// the IDs the relay reports as published.
+func relayEvents(events []ports.OutboxEvent, published *[]uint64) func(mock.Arguments) {
+	return func(args mock.Arguments) {
+		*published = args.Get(2).(func([]ports.OutboxEvent) []uint64)(events)
+	}
+}
+
+func TestOutboxRelay_PublishesAndDeletesEvents(t *testing.T) {
+	producer := smocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+
+	repo := new(imocks.OutboxRepositoryMock)
+	events := []ports.OutboxEvent{
+		{ID: 1, EventID: "e1", EventType: "OrderStored", OrderUID: "uid-1", Payload: []byte(`{"n":1}`)},
+		{ID: 2, EventID: "e2", EventType: "OrderUpdated", OrderUID: "uid-1", Payload: []byte(`{"n":2}`)},
+	}
+	var published []uint64
+	repo.On("RelayOutboxEvents", mock.Anything, 10, mock.Anything).Run(relayEvents(events, &published)).Return(2, nil)
+
+	var keys []string
+	for range events {
+		producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+			key, _ := msg.Key.Encode()
+			keys = append(keys, string(key))
+			assert.Equal(t, "order-events", msg.Topic)
+			return nil
+		})
+	}
+
+	relay := NewOutboxRelay(repo, producer, "order-events", 10, time.Second, logging.Discard())
+	n, err := relay.RelayOnce(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []uint64{1, 2}, published)
+	assert.Equal(t, []string{"uid-1", "uid-1"}, keys)
+	repo.AssertExpectations(t)
+}
+
+func TestOutboxRelay_FailedEventBlocksSameOrder(t *testing.T) {
+	producer := smocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+
+	repo := new(imocks.OutboxRepositoryMock)
+	events := []ports.OutboxEvent{
+		{ID: 1, EventID: "e1", OrderUID: "uid-1", Payload: []byte("a")},
+		{ID: 2, EventID: "e2", OrderUID: "uid-2", Payload: []byte("b")},
+		{ID: 3, EventID: "e3", OrderUID: "uid-1", Payload: []byte("c")},
+	}
+	var published []uint64
+	repo.On("RelayOutboxEvents", mock.Anything, 10, mock.Anything).Run(relayEvents(events, &published)).Return(1, nil)
+
+	producer.ExpectSendMessageAndFail(errors.New("broker unavailable"))
+	producer.ExpectSendMessageAndSucceed()
+
+	relay := NewOutboxRelay(repo, producer, "order-events", 10, time.Second, logging.Discard())
+	n, err := relay.RelayOnce(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	// Only the event of the unaffected order may be removed.
+	assert.Equal(t, []uint64{2}, published)
+	repo.AssertExpectations(t)
+}
+
+func TestOutboxRelay_StartStopsOnContextCancel(t *testing.T) {
+	producer := smocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+
+	repo := new(imocks.OutboxRepositoryMock)
+	repo.On("RelayOutboxEvents", mock.Anything, 10, mock.Anything).Return(0, nil)
+
+	relay := NewOutboxRelay(repo, producer, "order-events", 10, 10*time.Millisecond, logging.Discard())
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- relay.Start(ctx) }()
+
+	time.Sleep(30 * time.Millisecond)
+	cancel()
+	assert.NoError(t, <-done)
+}
@@ -0,0 +1,160 @@
+package kafka
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"wb-tech-l0/internal/models"
+	"wb-tech-l0/internal/schemaregistry"
+	orderv1 "wb-tech-l0/pkg/api/order/v1"
+
+	"github.com/bufbuild/protocompile"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// protobufDecoder decodes Protobuf payloads written with a registered
+// schema. The schema is compiled once per ID and the message is read
+// with it, then mapped onto order.v1.Order by field name, so producers
+// may number their fields differently.
+type protobufDecoder struct {
+	registry *schemaregistry.Client
+
+	mu    sync.Mutex
+	files map[int]protoreflect.FileDescriptor
+}
+
+func newProtobufDecoder(registry *schemaregistry.Client) *protobufDecoder {
+	return &protobufDecoder{
+		registry: registry,
+		files:    make(map[int]protoreflect.FileDescriptor),
+	}
+}
+
+func (d *protobufDecoder) decode(ctx context.Context, schema schemaregistry.Schema, payload []byte) (*models.Order, error) {
+	indexes, payload, err := messageIndexes(payload)
+	if err != nil {
+		return nil, fmt.Errorf("%w: protobuf: %w", ErrMalformedMessage, err)
+	}
+
+	file, err := d.file(ctx, schema)
+	if err != nil {
+		return nil, err
+	}
+	desc, err := messageByIndexes(file, indexes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: protobuf schema %d: %w", ErrMalformedMessage, schema.ID, err)
+	}
+
+	msg := dynamicpb.NewMessage(desc)
+	if err := proto.Unmarshal(payload, msg); err != nil {
+		return nil, fmt.Errorf("%w: protobuf: %w", ErrMalformedMessage, err)
+	}
+	data, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("%w: protobuf: %w", ErrMalformedMessage, err)
+	}
+	var order orderv1.Order
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(data, &order); err != nil {
+		return nil, fmt.Errorf("%w: protobuf message %s does not match order.v1.Order: %w", ErrMalformedMessage, desc.FullName(), err)
+	}
+	return orderv1.ToModel(&order), nil
+}
+
+// file compiles a registered schema together with the schemas it
+// references. Well-known imports such as google/protobuf/timestamp.proto
+// are built in.
+func (d *protobufDecoder) file(ctx context.Context, schema schemaregistry.Schema) (protoreflect.FileDescriptor, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if fd, ok := d.files[schema.ID]; ok {
+		return fd, nil
+	}
+
+	name := "registry/" + strconv.Itoa(schema.ID) + ".proto"
+	sources := map[string]string{name: schema.Schema}
+	if err := d.addReferences(ctx, sources, schema.References); err != nil {
+		return nil, err
+	}
+
+	compiler := protocompile.Compiler{
+		Resolver: protocompile.WithStandardImports(&protocompile.SourceResolver{
+			Accessor: protocompile.SourceAccessorFromMap(sources),
+		}),
+	}
+	files, err := compiler.Compile(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: compile protobuf schema %d: %w", ErrMalformedMessage, schema.ID, err)
+	}
+
+	d.files[schema.ID] = files[0]
+	return files[0], nil
+}
+
+func (d *protobufDecoder) addReferences(ctx context.Context, sources map[string]string, refs []schemaregistry.Reference) error {
+	for _, ref := range refs {
+		if _, ok := sources[ref.Name]; ok {
+			continue
+		}
+		s, err := d.registry.SchemaByReference(ctx, ref)
+		if errors.Is(err, schemaregistry.ErrSchemaNotFound) {
+			return fmt.Errorf("%w: %w", ErrMalformedMessage, err)
+		}
+		if err != nil {
+			return err
+		}
+		sources[ref.Name] = s.Schema
+		if err := d.addReferences(ctx, sources, s.References); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// messageIndexes reads the path to the message type that prefixes
+// Protobuf payloads in the wire format: a count and that many indexes,
+// all zigzag varints. A zero count is short for the first message.
+func messageIndexes(data []byte) ([]int, []byte, error) {
+	count, n := binary.Varint(data)
+	if n <= 0 || count < 0 || count > int64(len(data)) {
+		return nil, nil, errors.New("invalid message indexes")
+	}
+	data = data[n:]
+	if count == 0 {
+		return []int{0}, data, nil
+	}
+
+	indexes := make([]int, count)
+	for i := range indexes {
+		index, n := binary.Varint(data)
+		if n <= 0 || index < 0 {
+			return nil, nil, errors.New("invalid message indexes")
+		}
+		indexes[i] = int(index)
+		data = data[n:]
+	}
+	return indexes, data, nil
+}
+
+func messageByIndexes(file protoreflect.FileDescriptor, indexes []int) (protoreflect.MessageDescriptor, error) {
+	messages := file.Messages()
+	var desc protoreflect.MessageDescriptor
+	path := make([]string, 0, len(indexes))
+	for _, i := range indexes {
+		if i >= messages.Len() {
+			return nil, fmt.Errorf("no message at index %s", strings.Join(append(path, strconv.Itoa(i)), "."))
+		}
+		desc = messages.Get(i)
+		messages = desc.Messages()
+		path = append(path, strconv.Itoa(i))
+	}
+	return desc, nil
+}
@@ -0,0 +1,248 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"wb-tech-l0/internal/application/ports"
+	"wb-tech-l0/internal/logging"
+	"wb-tech-l0/internal/telemetry"
+	"wb-tech-l0/internal/validator"
+
+	"github.com/IBM/sarama"
+)
+
+// maxReplayProblems bounds the problems listed in a ReplayReport; the
+// counters still cover every message.
+const maxReplayProblems = 100
+
+// replayIdleTimeout is how long Replay waits for another message once the
+// partition's high water mark has reached the end of the replay. The last
+// offsets may hold no message to deliver, e.g. transaction markers or
+// records removed by compaction.
+const replayIdleTimeout = 5 * time.Second
+
+// ReplayOptions selects the messages of one partition to replay. Without
+// FromOffset and From the partition is replayed from its oldest message.
+// The replay ends before To, or at the end of the partition as of the
+// start of the replay.
+type ReplayOptions struct {
+	Topic     string
+	Partition int32
+	// FromOffset is the first offset to replay; negative means unset.
+	FromOffset int64
+	// From replays messages with a timestamp at or after it.
+	From time.Time
+	// To stops before the first message with a timestamp at or after it.
+	To time.Time
+	// DryRun decodes and validates the messages without saving them.
+	DryRun bool
+}
+
+// ReplayReport sums up a replay.
+type ReplayReport struct {
+	Topic     string `json:"topic"`
+	Partition int32  `json:"partition"`
+	DryRun    bool   `json:"dry_run"`
+	// FromOffset and EndOffset bound the replayed offsets, EndOffset
+	// exclusive.
+	FromOffset int64 `json:"from_offset"`
+	EndOffset  int64 `json:"end_offset"`
+
+	Read int `json:"read"`
+	// Saved counts the orders saved, or that would be saved in a dry run.
+	Saved     int             `json:"saved"`
+	Malformed int             `json:"malformed"`
+	Invalid   int             `json:"invalid"`
+	Failed    int             `json:"failed"`
+	Problems  []ReplayProblem `json:"problems,omitempty"`
+}
+
+// ReplayProblem describes a message that was not saved.
+type ReplayProblem struct {
+	Offset   int64  `json:"offset"`
+	OrderUID string `json:"order_uid,omitempty"`
+	Error    string `json:"error"`
+}
+
+// offsetLookup finds offsets by time; sarama.Client implements it.
+type offsetLookup interface {
+	GetOffset(topic string, partition int32, time int64) (int64, error)
+}
+
+// NewReplayConsumer connects to the brokers for Replay. It belongs to no
+// consumer group, so replaying never moves the offsets of the live
+// consumer. uc may be nil when only dry runs are replayed.
+func NewReplayConsumer(brokers []string, uc ports.OrderUseCase, decoder Decoder, logger *slog.Logger) (*Consumer, error) {
+	client, err := sarama.NewClient(brokers, sarama.NewConfig())
+	if err != nil {
+		return nil, err
+	}
+	consumer, err := sarama.NewConsumerFromClient(client)
+	if err != nil {
+		_ = client.Close()
+		return nil, err
+	}
+
+	return &Consumer{
+		client:       client,
+		lookup:       client,
+		consumer:     consumer,
+		cfg:          ConsumerConfig{Concurrency: 1, QueueDepth: 1, BatchSize: 1},
+		orderUseCase: uc,
+		validator:    validator.NewValidator(),
+		decoder:      decoder,
+		logger:       logger.With("component", "kafka_replay"),
+		replayIdle:   replayIdleTimeout,
+	}, nil
+}
+
+// Replay feeds the selected messages through decoding, validation and
+// saving, one at a time. Replaying next to the live consumer is safe: a
+// save locks the stored order and is retried when it conflicts with a
+// concurrent one, so an order saved by both is stored once and its
+// rollups stay exact.
+//
+// Malformed and invalid messages and orders that fail to save are counted
+// and listed in the report, and the replay goes on. It stops with an error
+// when a backing service is unavailable or ctx is cancelled, returning
+// the report so far. The replay ends early when no message arrives for a
+// while after the partition's high water mark has reached the end offset.
+func (c *Consumer) Replay(ctx context.Context, opts ReplayOptions) (ReplayReport, error) {
+	report := ReplayReport{Topic: opts.Topic, Partition: opts.Partition, DryRun: opts.DryRun}
+	if !opts.DryRun && c.orderUseCase == nil {
+		return report, errors.New("replay without an order use case must be a dry run")
+	}
+
+	start, end, err := c.replayRange(opts)
+	if err != nil {
+		return report, err
+	}
+	report.FromOffset, report.EndOffset = start, end
+	if start >= end {
+		return report, nil
+	}
+
+	partitionConsumer, err := c.consumer.ConsumePartition(opts.Topic, opts.Partition, start)
+	if err != nil {
+		return report, err
+	}
+	defer partitionConsumer.Close()
+
+	c.logger.InfoContext(ctx, "replay started",
+		logging.KeyTopic, opts.Topic, logging.KeyPartition, opts.Partition,
+		"from_offset", start, "end_offset", end, "dry_run", opts.DryRun)
+
+	idleTimeout := c.replayIdle
+	if idleTimeout <= 0 {
+		idleTimeout = replayIdleTimeout
+	}
+	idle := time.NewTimer(idleTimeout)
+	defer idle.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return report, ctx.Err()
+
+		case <-idle.C:
+			if partitionConsumer.HighWaterMarkOffset() >= end && len(partitionConsumer.Messages()) == 0 {
+				c.logger.InfoContext(ctx, "replay found no messages up to the end offset",
+					logging.KeyTopic, opts.Topic, logging.KeyPartition, opts.Partition, "end_offset", end)
+				return report, nil
+			}
+			idle.Reset(idleTimeout)
+
+		case msg, ok := <-partitionConsumer.Messages():
+			if !ok {
+				return report, errors.New("partition consumer closed before the end of the replay")
+			}
+			if !opts.To.IsZero() && !msg.Timestamp.Before(opts.To) {
+				return report, nil
+			}
+
+			report.Read++
+			if err := c.replayMessage(ctx, opts, msg, &report); err != nil {
+				return report, err
+			}
+			if msg.Offset+1 >= end {
+				return report, nil
+			}
+			idle.Reset(idleTimeout)
+		}
+	}
+}
+
+// replayRange resolves the options to the offsets [start, end) of the
+// partition.
+func (c *Consumer) replayRange(opts ReplayOptions) (start, end int64, err error) {
+	end, err = c.lookup.GetOffset(opts.Topic, opts.Partition, sarama.OffsetNewest)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !opts.To.IsZero() {
+		to, err := c.lookup.GetOffset(opts.Topic, opts.Partition, opts.To.UnixMilli())
+		if err != nil {
+			return 0, 0, err
+		}
+		// -1: no message at or after To yet.
+		if to >= 0 {
+			end = min(end, to)
+		}
+	}
+
+	switch {
+	case opts.FromOffset >= 0:
+		start = opts.FromOffset
+	case !opts.From.IsZero():
+		start, err = c.lookup.GetOffset(opts.Topic, opts.Partition, opts.From.UnixMilli())
+		if err == nil && start < 0 {
+			start = end
+		}
+	default:
+		start, err = c.lookup.GetOffset(opts.Topic, opts.Partition, sarama.OffsetOldest)
+	}
+	return start, end, err
+}
+
+func (c *Consumer) replayMessage(ctx context.Context, opts ReplayOptions, msg *sarama.ConsumerMessage, report *ReplayReport) (err error) {
+	ctx, span, logger := c.startProcessing(ctx, opts.Topic, msg)
+	defer func() { telemetry.End(span, err) }()
+
+	problem := func(uid string, err error) {
+		if len(report.Problems) < maxReplayProblems {
+			report.Problems = append(report.Problems, ReplayProblem{Offset: msg.Offset, OrderUID: uid, Error: err.Error()})
+		}
+	}
+
+	order, err := c.decodeMessage(ctx, span, logger, opts.Topic, msg)
+	switch {
+	case errors.Is(err, ports.ErrInvalidOrder) && order != nil:
+		report.Invalid++
+		problem(order.OrderUID, err)
+		return nil
+	case err != nil:
+		return err
+	case order == nil:
+		report.Malformed++
+		problem("", ErrMalformedMessage)
+		return nil
+	case opts.DryRun:
+		report.Saved++
+		return nil
+	}
+
+	err = c.saveOrder(ctx, logger.With(logging.KeyOrderUID, order.OrderUID), opts.Topic, order)
+	switch {
+	case errors.Is(err, ports.ErrUnavailable):
+		return err
+	case err != nil:
+		report.Failed++
+		problem(order.OrderUID, err)
+	default:
+		report.Saved++
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"wb-tech-l0/internal/application/ports"
+	imocks "wb-tech-l0/internal/mocks"
+	"wb-tech-l0/internal/models"
+
+	"github.com/IBM/sarama"
+	smocks "github.com/IBM/sarama/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeLookup answers offset lookups by the requested time.
+type fakeLookup map[int64]int64
+
+func (l fakeLookup) GetOffset(_ string, _ int32, time int64) (int64, error) {
+	return l[time], nil
+}
+
+// highWaterMarkConsumer reports a fixed high water mark for its partition
+// consumers, as a broker does when the last offsets hold no data message.
+type highWaterMarkConsumer struct {
+	sarama.Consumer
+	hwm int64
+}
+
+func (c highWaterMarkConsumer) ConsumePartition(topic string, partition int32, offset int64) (sarama.PartitionConsumer, error) {
+	pc, err := c.Consumer.ConsumePartition(topic, partition, offset)
+	return highWaterMarkPartitionConsumer{pc, c.hwm}, err
+}
+
+type highWaterMarkPartitionConsumer struct {
+	sarama.PartitionConsumer
+	hwm int64
+}
+
+func (pc highWaterMarkPartitionConsumer) HighWaterMarkOffset() int64 { return pc.hwm }
+
+func newReplayTestConsumer(t *testing.T, uc *imocks.OrderUseCaseMock, lookup fakeLookup) (*Consumer, *smocks.Consumer) {
+	t.Helper()
+	saramaC := smocks.NewConsumer(t, nil)
+	t.Cleanup(func() { _ = saramaC.Close() })
+
+	v := new(imocks.ValidatorMock)
+	v.On("Validate", mock.MatchedBy(func(o models.Order) bool { return o.OrderUID == "bad" })).Return(assert.AnError)
+	v.On("Validate", mock.Anything).Return(nil)
+
+	cons := newTestConsumer(saramaC, uc, v)
+	cons.lookup = lookup
+	return cons, saramaC
+}
+
+func TestReplay_FromOffset(t *testing.T) {
+	uc := new(imocks.OrderUseCaseMock)
+	uc.On("SaveOrder", mock.Anything, mock.Anything).Return(nil)
+	cons, saramaC := newReplayTestConsumer(t, uc, fakeLookup{sarama.OffsetNewest: 8})
+
+	pc := saramaC.ExpectConsumePartition("orders", 0, 5)
+	pc.YieldMessage(orderMessage(t, "ok", "1"))                         // 5
+	pc.YieldMessage(orderMessage(t, "bad", "1"))                        // 6
+	pc.YieldMessage(&sarama.ConsumerMessage{Value: []byte("not-json")}) // 7
+
+	report, err := cons.Replay(context.Background(), ReplayOptions{Topic: "orders", FromOffset: 5})
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(5), report.FromOffset)
+	assert.Equal(t, int64(8), report.EndOffset)
+	assert.Equal(t, 3, report.Read)
+	assert.Equal(t, 1, report.Saved)
+	assert.Equal(t, 1, report.Invalid)
+	assert.Equal(t, 1, report.Malformed)
+	require.Len(t, report.Problems, 2)
+	assert.Equal(t, int64(6), report.Problems[0].Offset)
+	assert.Equal(t, "bad", report.Problems[0].OrderUID)
+	assert.Equal(t, int64(7), report.Problems[1].Offset)
+	uc.AssertNumberOfCalls(t, "SaveOrder", 1)
+}
+
+func TestReplay_DryRunTimeRange(t *testing.T) {
+	from := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(time.Hour)
+	uc := new(imocks.OrderUseCaseMock)
+	cons, saramaC := newReplayTestConsumer(t, uc, fakeLookup{
+		sarama.OffsetNewest: 10,
+		from.UnixMilli():    2,
+		to.UnixMilli():      4,
+	})
+
+	pc := saramaC.ExpectConsumePartition("orders", 0, 2)
+	pc.YieldMessage(orderMessage(t, "a", "1"))
+	pc.YieldMessage(orderMessage(t, "b", "1"))
+
+	report, err := cons.Replay(context.Background(), ReplayOptions{Topic: "orders", FromOffset: -1, From: from, To: to, DryRun: true})
+	require.NoError(t, err)
+
+	assert.True(t, report.DryRun)
+	assert.Equal(t, int64(4), report.EndOffset)
+	assert.Equal(t, 2, report.Saved)
+	uc.AssertNotCalled(t, "SaveOrder", mock.Anything, mock.Anything)
+}
+
+func TestReplay_NothingAfterFrom(t *testing.T) {
+	from := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	cons, _ := newReplayTestConsumer(t, new(imocks.OrderUseCaseMock), fakeLookup{
+		sarama.OffsetNewest: 10,
+		from.UnixMilli():    -1,
+	})
+
+	// No partition is consumed: the mock fails the test on an unexpected call.
+	report, err := cons.Replay(context.Background(), ReplayOptions{Topic: "orders", FromOffset: -1, From: from})
+	require.NoError(t, err)
+	assert.Equal(t, report.EndOffset, report.FromOffset)
+	assert.Zero(t, report.Read)
+}
+
+func TestReplay_StopsWhenStoreUnavailable(t *testing.T) {
+	uc := new(imocks.OrderUseCaseMock)
+	uc.On("SaveOrder", mock.Anything, mock.Anything).Return(ports.ErrUnavailable)
+	cons, saramaC := newReplayTestConsumer(t, uc, fakeLookup{sarama.OffsetNewest: 10, sarama.OffsetOldest: 0})
+
+	pc := saramaC.ExpectConsumePartition("orders", 0, 0)
+	pc.YieldMessage(orderMessage(t, "a", "1"))
+
+	report, err := cons.Replay(context.Background(), ReplayOptions{Topic: "orders", FromOffset: -1})
+	require.ErrorIs(t, err, ports.ErrUnavailable)
+	assert.Equal(t, 1, report.Read)
+	assert.Zero(t, report.Saved)
+}
+
+func TestReplay_EndsWhenLastOffsetsHoldNoMessage(t *testing.T) {
+	uc := new(imocks.OrderUseCaseMock)
+	uc.On("SaveOrder", mock.Anything, mock.Anything).Return(nil)
+	cons, saramaC := newReplayTestConsumer(t, uc, fakeLookup{sarama.OffsetNewest: 8})
+	cons.consumer = highWaterMarkConsumer{Consumer: saramaC, hwm: 8}
+	cons.replayIdle = 10 * time.Millisecond
+
+	// Offsets 7 and up hold e.g. a transaction marker: no message follows 6.
+	pc := saramaC.ExpectConsumePartition("orders", 0, 5)
+	pc.YieldMessage(orderMessage(t, "a", "1"))
+	pc.YieldMessage(orderMessage(t, "b", "1"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	report, err := cons.Replay(ctx, ReplayOptions{Topic: "orders", FromOffset: 5})
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Read)
+	assert.Equal(t, 2, report.Saved)
+}
+
+func TestReplay_RequiresUseCaseUnlessDryRun(t *testing.T) {
+	cons, _ := newReplayTestConsumer(t, nil, fakeLookup{sarama.OffsetNewest: 10})
+	cons.orderUseCase = nil
+
+	// No partition is consumed: the mock fails the test on an unexpected call.
+	_, err := cons.Replay(context.Background(), ReplayOptions{Topic: "orders", FromOffset: 0})
+	require.Error(t, err)
+}
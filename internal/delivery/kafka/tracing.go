@@ -0,0 +1,35 @@
+package kafka
+
+import (
+	"github.com/IBM/sarama"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// consumerHeaderCarrier adapts consumed message headers to the
+// OpenTelemetry TextMapCarrier so trace context set by producers
+// (traceparent, tracestate, baggage) can be extracted.
+type consumerHeaderCarrier []*sarama.RecordHeader
+
+var _ propagation.TextMapCarrier = consumerHeaderCarrier(nil)
+
+func (c consumerHeaderCarrier) Get(key string) string {
+	for _, h := range c {
+		if h != nil && string(h.Key) == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+// Set is a no-op: consumed messages are read-only.
+func (c consumerHeaderCarrier) Set(string, string) {}
+
+func (c consumerHeaderCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for _, h := range c {
+		if h != nil {
+			keys = append(keys, string(h.Key))
+		}
+	}
+	return keys
+}
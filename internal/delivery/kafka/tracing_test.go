@@ -0,0 +1,72 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"wb-tech-l0/internal/logging"
+	imocks "wb-tech-l0/internal/mocks"
+	"wb-tech-l0/internal/models"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestHandleMessage_ContinuesTraceFromHeaders(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	prevTP, prevProp := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevTP)
+		otel.SetTextMapPropagator(prevProp)
+	})
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	const parentSpanID = "00f067aa0ba902b7"
+
+	v := new(imocks.ValidatorMock)
+	v.On("Validate", mock.Anything).Return(nil)
+
+	var saveCtx context.Context
+	uc := new(imocks.OrderUseCaseMock)
+	uc.On("SaveOrder", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { saveCtx = args.Get(0).(context.Context) }).
+		Return(nil)
+
+	data, err := json.Marshal(models.Order{OrderUID: "uid-traced"})
+	require.NoError(t, err)
+
+	c := NewConsumerWith(nil, uc, v, logging.Discard())
+	err = c.handleMessage(context.Background(), "orders", &sarama.ConsumerMessage{
+		Value: data,
+		Headers: []*sarama.RecordHeader{
+			{Key: []byte("traceparent"), Value: []byte("00-" + traceID + "-" + parentSpanID + "-01")},
+		},
+	})
+	require.NoError(t, err)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+
+	validate, process := spans[0], spans[1]
+	assert.Equal(t, "validator.Validate", validate.Name())
+	assert.Equal(t, "orders process", process.Name())
+	assert.Equal(t, trace.SpanKindConsumer, process.SpanKind())
+	assert.Equal(t, traceID, process.SpanContext().TraceID().String())
+	assert.Equal(t, parentSpanID, process.Parent().SpanID().String())
+	assert.Equal(t, process.SpanContext().SpanID(), validate.Parent().SpanID())
+
+	// The use case runs inside the message span.
+	require.NotNil(t, saveCtx)
+	assert.Equal(t, process.SpanContext().SpanID(), trace.SpanContextFromContext(saveCtx).SpanID())
+}
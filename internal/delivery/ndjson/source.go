@@ -0,0 +1,189 @@
+// Package ndjson ingests orders from newline-delimited JSON, one order per
+// line in the format of the Kafka messages, such as a dump of the orders
+// topic. It serves backfills and running the pipeline without Kafka.
+package ndjson
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"wb-tech-l0/internal/application/ports"
+	"wb-tech-l0/internal/delivery"
+	"wb-tech-l0/internal/logging"
+	"wb-tech-l0/internal/models"
+	"wb-tech-l0/internal/telemetry"
+	"wb-tech-l0/internal/validator"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var tracer = telemetry.Tracer("wb-tech-l0/internal/delivery/ndjson")
+
+// maxLineSize bounds a single line, and so a single order.
+const maxLineSize = 16 << 20
+
+var _ delivery.Source = (*Source)(nil)
+
+type Config struct {
+	// FromLine is the first line to ingest, counting from 1. Earlier lines
+	// are skipped, to resume an interrupted run.
+	FromLine int
+	// Errors, if set, receives an ErrorRecord for every line that was not
+	// saved.
+	Errors io.Writer
+	// ProgressInterval is how often progress is logged; zero logs it only
+	// at the end.
+	ProgressInterval time.Duration
+}
+
+// Progress counts the lines handled so far.
+type Progress struct {
+	Source string `json:"source"`
+	// Line is the last line handled: a run that stopped resumes from
+	// Line+1.
+	Line      int `json:"line"`
+	Saved     int `json:"saved"`
+	Malformed int `json:"malformed"`
+	Invalid   int `json:"invalid"`
+	Failed    int `json:"failed"`
+}
+
+// ErrorRecord is written to Config.Errors for a line that was not saved,
+// as one line of JSON. Record holds the line as read, so fixed records can
+// be ingested again.
+type ErrorRecord struct {
+	Line     int    `json:"line"`
+	OrderUID string `json:"order_uid,omitempty"`
+	Error    string `json:"error"`
+	Record   string `json:"record"`
+}
+
+// Source reads orders from r, validates them and saves them through the
+// use case, one line at a time. Lines that cannot be decoded, fail
+// validation or fail to save are reported and skipped; ingestion stops
+// when a backing service is unavailable.
+type Source struct {
+	r         io.Reader
+	name      string
+	uc        ports.OrderUseCase
+	validator validator.Validator
+	cfg       Config
+	logger    *slog.Logger
+
+	progress Progress
+}
+
+// NewSource reads orders from r; name identifies it in logs, e.g. the
+// file name.
+func NewSource(r io.Reader, name string, uc ports.OrderUseCase, v validator.Validator, cfg Config, logger *slog.Logger) *Source {
+	return &Source{
+		r:         r,
+		name:      name,
+		uc:        uc,
+		validator: v,
+		cfg:       cfg,
+		logger:    logger.With("component", "ndjson_source", "source", name),
+		progress:  Progress{Source: name},
+	}
+}
+
+// Progress returns the counts so far; after Run, the final ones.
+func (s *Source) Progress() Progress {
+	return s.progress
+}
+
+// Run ingests the lines until the end of the input.
+func (s *Source) Run(ctx context.Context) error {
+	scanner := bufio.NewScanner(s.r)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxLineSize)
+
+	lastReport := time.Now()
+	line := 0
+	for scanner.Scan() {
+		line++
+		if line >= s.cfg.FromLine {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := s.ingest(ctx, line, scanner.Bytes()); err != nil {
+				return fmt.Errorf("line %d: %w", line, err)
+			}
+		}
+		s.progress.Line = line
+
+		if s.cfg.ProgressInterval > 0 && time.Since(lastReport) >= s.cfg.ProgressInterval {
+			s.logProgress(ctx, "ingestion progress")
+			lastReport = time.Now()
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("line %d: %w", line+1, err)
+	}
+
+	s.logProgress(ctx, "ingestion finished")
+	return nil
+}
+
+func (s *Source) ingest(ctx context.Context, line int, data []byte) (err error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil
+	}
+
+	ctx, span := tracer.Start(ctx, "ndjson.ingest")
+	span.SetAttributes(attribute.String("ingest.source", s.name), attribute.Int("ingest.line", line))
+	defer func() { telemetry.End(span, err) }()
+
+	var order models.Order
+	if err := json.Unmarshal(data, &order); err != nil {
+		s.progress.Malformed++
+		return s.reject(ctx, line, "", err, data)
+	}
+	span.SetAttributes(attribute.String("order.uid", order.OrderUID))
+
+	if err := delivery.Validate(ctx, s.validator, order); err != nil {
+		s.progress.Invalid++
+		return s.reject(ctx, line, order.OrderUID, err, data)
+	}
+
+	if err := s.uc.SaveOrder(ctx, &order); err != nil {
+		if errors.Is(err, ports.ErrUnavailable) || ctx.Err() != nil {
+			return err
+		}
+		s.progress.Failed++
+		return s.reject(ctx, line, order.OrderUID, err, data)
+	}
+	s.progress.Saved++
+	return nil
+}
+
+// reject reports a line that was not saved. Only a failure to write the
+// error record stops the run.
+func (s *Source) reject(ctx context.Context, line int, orderUID string, reason error, data []byte) error {
+	s.logger.WarnContext(ctx, "order rejected", "line", line, logging.KeyOrderUID, orderUID, logging.Err(reason))
+	if s.cfg.Errors == nil {
+		return nil
+	}
+
+	rec, err := json.Marshal(ErrorRecord{Line: line, OrderUID: orderUID, Error: reason.Error(), Record: string(data)})
+	if err != nil {
+		return err
+	}
+	if _, err := s.cfg.Errors.Write(append(rec, '\n')); err != nil {
+		return fmt.Errorf("write error record: %w", err)
+	}
+	return nil
+}
+
+func (s *Source) logProgress(ctx context.Context, msg string) {
+	p := s.progress
+	s.logger.InfoContext(ctx, msg,
+		"line", p.Line, "saved", p.Saved, "malformed", p.Malformed, "invalid", p.Invalid, "failed", p.Failed)
+}
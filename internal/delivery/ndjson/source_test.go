@@ -0,0 +1,106 @@
+package ndjson_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"wb-tech-l0/internal/application/ports"
+	"wb-tech-l0/internal/delivery/ndjson"
+	"wb-tech-l0/internal/logging"
+	imocks "wb-tech-l0/internal/mocks"
+	"wb-tech-l0/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newValidator() *imocks.ValidatorMock {
+	v := new(imocks.ValidatorMock)
+	v.On("Validate", mock.MatchedBy(func(o models.Order) bool { return o.OrderUID == "invalid" })).Return(errors.New("track_number is required"))
+	v.On("Validate", mock.Anything).Return(nil)
+	return v
+}
+
+func hasUID(uid string) any {
+	return mock.MatchedBy(func(o *models.Order) bool { return o.OrderUID == uid })
+}
+
+func readErrors(t *testing.T, buf *bytes.Buffer) []ndjson.ErrorRecord {
+	t.Helper()
+	var records []ndjson.ErrorRecord
+	sc := bufio.NewScanner(buf)
+	for sc.Scan() {
+		var r ndjson.ErrorRecord
+		require.NoError(t, json.Unmarshal(sc.Bytes(), &r))
+		records = append(records, r)
+	}
+	return records
+}
+
+func TestSource_ReportsRejectedLines(t *testing.T) {
+	input := strings.Join([]string{
+		`{"order_uid":"a"}`,
+		``,
+		`{"order_uid":`,
+		`{"order_uid":"invalid"}`,
+		`{"order_uid":"conflict"}`,
+		`{"order_uid":"b"}`,
+	}, "\n")
+
+	uc := new(imocks.OrderUseCaseMock)
+	uc.On("SaveOrder", mock.Anything, hasUID("conflict")).Return(ports.ErrConflict)
+	uc.On("SaveOrder", mock.Anything, mock.Anything).Return(nil)
+
+	var errs bytes.Buffer
+	src := ndjson.NewSource(strings.NewReader(input), "dump.ndjson", uc, newValidator(), ndjson.Config{Errors: &errs}, logging.Discard())
+	require.NoError(t, src.Run(context.Background()))
+
+	assert.Equal(t, ndjson.Progress{Source: "dump.ndjson", Line: 6, Saved: 2, Malformed: 1, Invalid: 1, Failed: 1}, src.Progress())
+
+	records := readErrors(t, &errs)
+	require.Len(t, records, 3)
+	assert.Equal(t, 3, records[0].Line)
+	assert.Equal(t, `{"order_uid":`, records[0].Record)
+	assert.Equal(t, 4, records[1].Line)
+	assert.Equal(t, "invalid", records[1].OrderUID)
+	assert.Contains(t, records[1].Error, "track_number is required")
+	assert.Equal(t, 5, records[2].Line)
+	assert.Equal(t, "conflict", records[2].OrderUID)
+}
+
+func TestSource_ResumesFromLine(t *testing.T) {
+	input := "{\"order_uid\":\"a\"}\n{\"order_uid\":\"b\"}\n{\"order_uid\":\"c\"}\n"
+
+	uc := new(imocks.OrderUseCaseMock)
+	uc.On("SaveOrder", mock.Anything, mock.Anything).Return(nil)
+
+	src := ndjson.NewSource(strings.NewReader(input), "-", uc, newValidator(), ndjson.Config{FromLine: 2}, logging.Discard())
+	require.NoError(t, src.Run(context.Background()))
+
+	assert.Equal(t, 3, src.Progress().Line)
+	assert.Equal(t, 2, src.Progress().Saved)
+	uc.AssertNotCalled(t, "SaveOrder", mock.Anything, hasUID("a"))
+}
+
+func TestSource_StopsWhenStoreUnavailable(t *testing.T) {
+	input := "{\"order_uid\":\"a\"}\n{\"order_uid\":\"b\"}\n{\"order_uid\":\"c\"}\n"
+
+	uc := new(imocks.OrderUseCaseMock)
+	uc.On("SaveOrder", mock.Anything, hasUID("b")).Return(ports.ErrUnavailable)
+	uc.On("SaveOrder", mock.Anything, mock.Anything).Return(nil)
+
+	src := ndjson.NewSource(strings.NewReader(input), "-", uc, newValidator(), ndjson.Config{}, logging.Discard())
+	err := src.Run(context.Background())
+	require.ErrorIs(t, err, ports.ErrUnavailable)
+	assert.ErrorContains(t, err, "line 2")
+
+	// The run resumes from the line that failed.
+	assert.Equal(t, 1, src.Progress().Line)
+	uc.AssertNotCalled(t, "SaveOrder", mock.Anything, hasUID("c"))
+}
@@ -0,0 +1,35 @@
+// Package delivery holds the adapters that bring orders into the service.
+// Each of them is a Source: it decodes orders its own way, then validates
+// them with Validate and saves them through ports.OrderUseCase, so every
+// order takes the same path whatever its origin.
+package delivery
+
+import (
+	"context"
+	"fmt"
+
+	"wb-tech-l0/internal/application/ports"
+	"wb-tech-l0/internal/models"
+	"wb-tech-l0/internal/telemetry"
+	"wb-tech-l0/internal/validator"
+)
+
+var tracer = telemetry.Tracer("wb-tech-l0/internal/delivery")
+
+// Source ingests orders until it is exhausted, ctx is cancelled or
+// ingestion fails. The Kafka consumer and the NDJSON reader are sources.
+type Source interface {
+	Run(ctx context.Context) error
+}
+
+// Validate checks a decoded order, reporting failures as
+// ports.ErrInvalidOrder.
+func Validate(ctx context.Context, v validator.Validator, order models.Order) error {
+	_, span := tracer.Start(ctx, "validator.Validate")
+	err := v.Validate(order)
+	if err != nil {
+		err = fmt.Errorf("%w: %w", ports.ErrInvalidOrder, err)
+	}
+	telemetry.End(span, err)
+	return err
+}
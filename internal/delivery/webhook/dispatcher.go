@@ -0,0 +1,211 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"wb-tech-l0/internal/application/ports"
+	"wb-tech-l0/internal/logging"
+	"wb-tech-l0/internal/models"
+)
+
+// Headers sent with every webhook request.
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// Sign returns the signature of a webhook body: hex HMAC-SHA256 over
+// "<timestamp>.<body>" keyed with the subscription secret.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+type Config struct {
+	BatchSize    int
+	PollInterval time.Duration
+	MaxAttempts  int
+	BackoffBase  time.Duration
+	BackoffMax   time.Duration
+	// ClaimTTL is how long a batch stays claimed by this dispatcher. It
+	// only matters if the dispatcher stops without settling the batch, and
+	// must be longer than sending a batch takes.
+	ClaimTTL time.Duration
+}
+
+// Dispatcher delivers queued webhook events to subscribers. Failed requests
+// are retried with exponential backoff; after MaxAttempts the delivery is
+// moved to the subscriber's dead-letter list. Deliveries are claimed before
+// they are sent, so dispatchers on several replicas do not send the same
+// ones, and each subscriber receives its events in order.
+type Dispatcher struct {
+	repo   ports.WebhookRepository
+	client *http.Client
+	cfg    Config
+	now    func() time.Time
+	logger *slog.Logger
+}
+
+func NewDispatcher(repo ports.WebhookRepository, client *http.Client, cfg Config, logger *slog.Logger) *Dispatcher {
+	return &Dispatcher{
+		repo:   repo,
+		client: client,
+		cfg:    cfg,
+		now:    time.Now,
+		logger: logger.With("component", "webhook_dispatcher"),
+	}
+}
+
+// Start polls for due deliveries until the context is cancelled.
+func (d *Dispatcher) Start(ctx context.Context) error {
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := d.DispatchOnce(ctx); err != nil {
+			d.logger.ErrorContext(ctx, "dispatch pass failed", logging.Err(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// DispatchOnce sends one batch of due deliveries. Deliveries of different
+// subscribers are sent concurrently, those of one subscriber in order. Once
+// a delivery fails, the later ones of its subscriber are released unsent:
+// the repository holds them back until the failed one is settled.
+func (d *Dispatcher) DispatchOnce(ctx context.Context) error {
+	deliveries, err := d.repo.ClaimDueDeliveries(d.now().UTC(), d.cfg.BatchSize, d.cfg.ClaimTTL)
+	if err != nil {
+		return err
+	}
+
+	bySubscription := make(map[uint][]models.WebhookDelivery)
+	for _, del := range deliveries {
+		bySubscription[del.SubscriptionID] = append(bySubscription[del.SubscriptionID], del)
+	}
+
+	var wg sync.WaitGroup
+	for subID, batch := range bySubscription {
+		sub, err := d.repo.GetSubscription(subID)
+		if err != nil {
+			d.logger.ErrorContext(ctx, "failed to load subscription", "subscription_id", subID, logging.Err(err))
+			d.release(ctx, batch)
+			continue
+		}
+		if !sub.Active {
+			d.release(ctx, batch)
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i, del := range batch {
+				if ctx.Err() != nil || !d.deliver(ctx, sub, del) {
+					d.release(ctx, batch[i+1:])
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	return nil
+}
+
+// release gives up the claim on deliveries left unsent.
+func (d *Dispatcher) release(ctx context.Context, deliveries []models.WebhookDelivery) {
+	if len(deliveries) == 0 {
+		return
+	}
+	ids := make([]uint, len(deliveries))
+	for i, del := range deliveries {
+		ids[i] = del.ID
+	}
+	if err := d.repo.ReleaseDeliveries(ids); err != nil {
+		d.logger.ErrorContext(ctx, "failed to release deliveries", "deliveries", len(ids), logging.Err(err))
+	}
+}
+
+// deliver sends one delivery and records the outcome. It reports whether
+// the subscriber accepted it.
+func (d *Dispatcher) deliver(ctx context.Context, sub *models.WebhookSubscription, del models.WebhookDelivery) bool {
+	err := d.send(ctx, sub, del)
+	if err == nil {
+		if err := d.repo.MarkDelivered(del.ID); err != nil {
+			d.logger.ErrorContext(ctx, "failed to mark delivery as delivered", "delivery_id", del.ID, logging.Err(err))
+		}
+		return true
+	}
+
+	attempts := del.Attempts + 1
+	dead := attempts >= d.cfg.MaxAttempts
+	next := d.now().UTC().Add(d.backoff(attempts))
+	if dead {
+		d.logger.WarnContext(ctx, "delivery moved to dead letters", "delivery_id", del.ID, "subscription_id", sub.ID, "url", sub.URL, "attempts", attempts, logging.Err(err))
+	}
+
+	if err := d.repo.MarkFailed(del.ID, attempts, next, dead, err.Error()); err != nil {
+		d.logger.ErrorContext(ctx, "failed to record delivery failure", "delivery_id", del.ID, logging.Err(err))
+	}
+	return false
+}
+
+func (d *Dispatcher) send(ctx context.Context, sub *models.WebhookSubscription, del models.WebhookDelivery) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(del.Payload))
+	if err != nil {
+		return err
+	}
+
+	timestamp := strconv.FormatInt(d.now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, del.EventType)
+	req.Header.Set(HeaderDelivery, strconv.FormatUint(uint64(del.ID), 10))
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Sign(sub.Secret, timestamp, del.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("subscriber responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// backoff returns the delay before the given attempt number is retried.
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.cfg.BackoffBase
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= d.cfg.BackoffMax {
+			return d.cfg.BackoffMax
+		}
+	}
+	return delay
+}
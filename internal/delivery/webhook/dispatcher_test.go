@@ -0,0 +1,145 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"wb-tech-l0/internal/logging"
+	imocks "wb-tech-l0/internal/mocks"
+	"wb-tech-l0/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func testConfig() Config {
+	return Config{
+		BatchSize:    10,
+		PollInterval: time.Second,
+		MaxAttempts:  3,
+		BackoffBase:  time.Second,
+		BackoffMax:   time.Minute,
+		ClaimTTL:     time.Minute,
+	}
+}
+
+func newTestDispatcher(repo *imocks.WebhookRepositoryMock, now time.Time) *Dispatcher {
+	d := NewDispatcher(repo, http.DefaultClient, testConfig(), logging.Discard())
+	d.now = func() time.Time { return now }
+	return d
+}
+
+func TestDispatcher_DeliversSignedPayload(t *testing.T) {
+	payload := []byte(`{"event_type":"OrderStored","order_uid":"uid-1"}`)
+
+	var gotSignature, gotTimestamp, gotEvent string
+	var gotBody []byte
+	subscriber := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotSignature = r.Header.Get(HeaderSignature)
+		gotTimestamp = r.Header.Get(HeaderTimestamp)
+		gotEvent = r.Header.Get(HeaderEvent)
+		gotBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer subscriber.Close()
+
+	now := time.Unix(1700000000, 0)
+	repo := new(imocks.WebhookRepositoryMock)
+	repo.On("ClaimDueDeliveries", now.UTC(), 10, time.Minute).Return([]models.WebhookDelivery{
+		{ID: 7, SubscriptionID: 1, EventType: models.EventOrderStored, Payload: payload},
+	}, nil)
+	repo.On("GetSubscription", uint(1)).Return(&models.WebhookSubscription{ID: 1, URL: subscriber.URL, Secret: "s3cret", Active: true}, nil)
+	repo.On("MarkDelivered", uint(7)).Return(nil)
+
+	require.NoError(t, newTestDispatcher(repo, now).DispatchOnce(context.Background()))
+
+	assert.Equal(t, payload, gotBody)
+	assert.Equal(t, models.EventOrderStored, gotEvent)
+	assert.Equal(t, "1700000000", gotTimestamp)
+	assert.Equal(t, Sign("s3cret", gotTimestamp, payload), gotSignature)
+	repo.AssertExpectations(t)
+}
+
+func TestDispatcher_RetriesWithBackoff(t *testing.T) {
+	var calls int32
+	subscriber := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer subscriber.Close()
+
+	now := time.Unix(1700000000, 0)
+	repo := new(imocks.WebhookRepositoryMock)
+	repo.On("ClaimDueDeliveries", now.UTC(), 10, time.Minute).Return([]models.WebhookDelivery{
+		{ID: 1, SubscriptionID: 1, Attempts: 1, Payload: []byte("{}")},
+	}, nil)
+	repo.On("GetSubscription", uint(1)).Return(&models.WebhookSubscription{ID: 1, URL: subscriber.URL, Active: true}, nil)
+	// Second attempt failed: retry after base * 2.
+	repo.On("MarkFailed", uint(1), 2, now.UTC().Add(2*time.Second), false, mock.Anything).Return(nil)
+
+	require.NoError(t, newTestDispatcher(repo, now).DispatchOnce(context.Background()))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	repo.AssertExpectations(t)
+}
+
+func TestDispatcher_MovesToDeadLettersAfterMaxAttempts(t *testing.T) {
+	subscriber := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer subscriber.Close()
+
+	now := time.Unix(1700000000, 0)
+	repo := new(imocks.WebhookRepositoryMock)
+	repo.On("ClaimDueDeliveries", now.UTC(), 10, time.Minute).Return([]models.WebhookDelivery{
+		{ID: 3, SubscriptionID: 1, Attempts: 2, Payload: []byte("{}")},
+	}, nil)
+	repo.On("GetSubscription", uint(1)).Return(&models.WebhookSubscription{ID: 1, URL: subscriber.URL, Active: true}, nil)
+	repo.On("MarkFailed", uint(3), 3, mock.Anything, true, "subscriber responded with status 502").Return(nil)
+
+	require.NoError(t, newTestDispatcher(repo, now).DispatchOnce(context.Background()))
+	repo.AssertExpectations(t)
+}
+
+func TestDispatcher_StopsSubscriptionAfterFailure(t *testing.T) {
+	var paths []string
+	subscriber := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.Header.Get(HeaderDelivery))
+		if r.Header.Get(HeaderDelivery) == "2" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer subscriber.Close()
+
+	now := time.Unix(1700000000, 0)
+	repo := new(imocks.WebhookRepositoryMock)
+	repo.On("ClaimDueDeliveries", now.UTC(), 10, time.Minute).Return([]models.WebhookDelivery{
+		{ID: 1, SubscriptionID: 1, Payload: []byte("{}")},
+		{ID: 2, SubscriptionID: 1, Payload: []byte("{}")},
+		{ID: 3, SubscriptionID: 1, Payload: []byte("{}")},
+		{ID: 4, SubscriptionID: 1, Payload: []byte("{}")},
+	}, nil)
+	repo.On("GetSubscription", uint(1)).Return(&models.WebhookSubscription{ID: 1, URL: subscriber.URL, Active: true}, nil)
+	repo.On("MarkDelivered", uint(1)).Return(nil)
+	repo.On("MarkFailed", uint(2), 1, now.UTC().Add(time.Second), false, mock.Anything).Return(nil)
+	// The later events wait for the failed one instead of overtaking it.
+	repo.On("ReleaseDeliveries", []uint{3, 4}).Return(nil)
+
+	require.NoError(t, newTestDispatcher(repo, now).DispatchOnce(context.Background()))
+	assert.Equal(t, []string{"1", "2"}, paths)
+	repo.AssertExpectations(t)
+}
+
+func TestDispatcher_BackoffIsCapped(t *testing.T) {
+	d := NewDispatcher(nil, http.DefaultClient, testConfig(), logging.Discard())
+	assert.Equal(t, time.Second, d.backoff(1))
+	assert.Equal(t, 4*time.Second, d.backoff(3))
+	assert.Equal(t, time.Minute, d.backoff(20))
+}
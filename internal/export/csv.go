@@ -0,0 +1,48 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+
+	"wb-tech-l0/internal/models"
+)
+
+type csvWriter struct {
+	w      *csv.Writer
+	header bool
+	rows   []Row
+}
+
+func newCSVWriter(w io.Writer) *csvWriter {
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+func (w *csvWriter) Write(order *models.Order) error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	w.rows = AppendRows(w.rows[:0], order)
+	for i := range w.rows {
+		if err := w.w.Write(w.rows[i].Record()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeHeader writes the header once, so an empty export still has one.
+func (w *csvWriter) writeHeader() error {
+	if w.header {
+		return nil
+	}
+	w.header = true
+	return w.w.Write(Columns)
+}
+
+func (w *csvWriter) Close() error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	w.w.Flush()
+	return w.w.Error()
+}
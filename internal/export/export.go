@@ -0,0 +1,77 @@
+// Package export writes orders to files for analysts and other systems:
+// NDJSON keeps the nested orders as the API returns them, CSV and Parquet
+// flatten them to one row per item. Writers take orders one at a time, so
+// an export of any size streams through a bounded buffer.
+package export
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"wb-tech-l0/internal/models"
+)
+
+type Format string
+
+const (
+	FormatNDJSON  Format = "ndjson"
+	FormatCSV     Format = "csv"
+	FormatParquet Format = "parquet"
+)
+
+// Formats lists the supported formats.
+var Formats = []Format{FormatNDJSON, FormatCSV, FormatParquet}
+
+// ParseFormat accepts a format name in any case; empty means NDJSON.
+func ParseFormat(s string) (Format, error) {
+	if s == "" {
+		return FormatNDJSON, nil
+	}
+	f := Format(strings.ToLower(s))
+	for _, known := range Formats {
+		if f == known {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("unknown export format %q: want ndjson, csv or parquet", s)
+}
+
+// ContentType is the media type of the format.
+func (f Format) ContentType() string {
+	switch f {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatParquet:
+		return "application/vnd.apache.parquet"
+	default:
+		return "application/x-ndjson"
+	}
+}
+
+// Extension is the file name extension of the format, without the dot.
+func (f Format) Extension() string {
+	return string(f)
+}
+
+// Writer encodes orders to an underlying io.Writer. Close flushes what is
+// buffered and writes the trailer of the format, if any; it does not close
+// the underlying writer.
+type Writer interface {
+	Write(order *models.Order) error
+	Close() error
+}
+
+// NewWriter returns a writer of the format to w.
+func NewWriter(w io.Writer, f Format) (Writer, error) {
+	switch f {
+	case FormatNDJSON:
+		return newNDJSONWriter(w), nil
+	case FormatCSV:
+		return newCSVWriter(w), nil
+	case FormatParquet:
+		return newParquetWriter(w), nil
+	default:
+		return nil, fmt.Errorf("unknown export format %q", f)
+	}
+}
@@ -0,0 +1,136 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"wb-tech-l0/internal/models"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testOrders() []*models.Order {
+	created := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	return []*models.Order{
+		{
+			OrderUID:        "a",
+			TrackNumber:     "WBILTRACK1",
+			DeliveryService: "dhl",
+			DateCreated:     created,
+			Delivery:        models.Delivery{Name: "Test Testov", City: "Kazan"},
+			Payment:         models.Payment{Currency: "RUB", Amount: 1817},
+			Items: []models.Item{
+				{ChrtID: 1, Name: "Mascaras", Price: 453},
+				{ChrtID: 2, Name: "Lipstick", Price: 0},
+			},
+		},
+		{OrderUID: "b", DateCreated: created},
+	}
+}
+
+func writeAll(t *testing.T, f Format, orders []*models.Order) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, f)
+	require.NoError(t, err)
+	for _, o := range orders {
+		require.NoError(t, w.Write(o))
+	}
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestParseFormat(t *testing.T) {
+	f, err := ParseFormat("")
+	require.NoError(t, err)
+	assert.Equal(t, FormatNDJSON, f)
+
+	f, err = ParseFormat("CSV")
+	require.NoError(t, err)
+	assert.Equal(t, FormatCSV, f)
+
+	_, err = ParseFormat("xlsx")
+	assert.Error(t, err)
+}
+
+func TestColumns_MatchRow(t *testing.T) {
+	typ := reflect.TypeOf(Row{})
+	require.Len(t, Columns, typ.NumField())
+	for i, name := range Columns {
+		tag := typ.Field(i).Tag.Get("parquet")
+		assert.Regexp(t, "^"+name+"(,|$)", tag)
+	}
+}
+
+func TestNDJSON_WritesNestedOrders(t *testing.T) {
+	data := writeAll(t, FormatNDJSON, testOrders())
+
+	var got []models.Order
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		var o models.Order
+		require.NoError(t, json.Unmarshal(sc.Bytes(), &o))
+		got = append(got, o)
+	}
+	require.Len(t, got, 2)
+	assert.Equal(t, *testOrders()[0], got[0])
+}
+
+func TestCSV_WritesRowPerItem(t *testing.T) {
+	records, err := csv.NewReader(bytes.NewReader(writeAll(t, FormatCSV, testOrders()))).ReadAll()
+	require.NoError(t, err)
+
+	require.Len(t, records, 4)
+	assert.Equal(t, Columns, records[0])
+
+	col := func(name string) int {
+		for i, c := range Columns {
+			if c == name {
+				return i
+			}
+		}
+		t.Fatalf("no column %s", name)
+		return -1
+	}
+	for _, rec := range records[1:3] {
+		assert.Equal(t, "a", rec[col("order_uid")])
+		assert.Equal(t, "Kazan", rec[col("delivery_city")])
+		assert.Equal(t, "2025-03-01T12:00:00Z", rec[col("date_created")])
+	}
+	assert.Equal(t, "Mascaras", records[1][col("item_name")])
+	assert.Equal(t, "0", records[2][col("item_price")])
+
+	// An order without items keeps one row with empty item columns.
+	assert.Equal(t, "b", records[3][col("order_uid")])
+	assert.Empty(t, records[3][col("item_chrt_id")])
+}
+
+func TestCSV_EmptyExportHasHeader(t *testing.T) {
+	records, err := csv.NewReader(bytes.NewReader(writeAll(t, FormatCSV, nil))).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{Columns}, records)
+}
+
+func TestParquet_RoundTrips(t *testing.T) {
+	data := writeAll(t, FormatParquet, testOrders())
+
+	rows, err := parquet.Read[Row](bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+
+	var want []Row
+	for _, o := range testOrders() {
+		want = AppendRows(want, o)
+	}
+	require.Len(t, rows, 3)
+	for i := range rows {
+		assert.Equal(t, want[i].Record(), rows[i].Record())
+	}
+	assert.Nil(t, rows[2].ItemChrtID)
+}
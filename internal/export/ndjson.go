@@ -0,0 +1,29 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+
+	"wb-tech-l0/internal/models"
+)
+
+// ndjsonWriter writes each order as one line of JSON, in the shape of the
+// Kafka messages, so an export can be ingested again.
+type ndjsonWriter struct {
+	buf *bufio.Writer
+	enc *json.Encoder
+}
+
+func newNDJSONWriter(w io.Writer) *ndjsonWriter {
+	buf := bufio.NewWriter(w)
+	return &ndjsonWriter{buf: buf, enc: json.NewEncoder(buf)}
+}
+
+func (w *ndjsonWriter) Write(order *models.Order) error {
+	return w.enc.Encode(order)
+}
+
+func (w *ndjsonWriter) Close() error {
+	return w.buf.Flush()
+}
@@ -0,0 +1,38 @@
+package export
+
+import (
+	"io"
+
+	"wb-tech-l0/internal/models"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// parquetRowGroupSize bounds the rows buffered before a row group is
+// written out; it trades memory for compression.
+const parquetRowGroupSize = 10000
+
+type parquetWriter struct {
+	w    *parquet.GenericWriter[Row]
+	rows []Row
+}
+
+func newParquetWriter(w io.Writer) *parquetWriter {
+	return &parquetWriter{
+		w: parquet.NewGenericWriter[Row](w,
+			parquet.Compression(&parquet.Snappy),
+			parquet.MaxRowsPerRowGroup(parquetRowGroupSize),
+		),
+	}
+}
+
+func (w *parquetWriter) Write(order *models.Order) error {
+	w.rows = AppendRows(w.rows[:0], order)
+	_, err := w.w.Write(w.rows)
+	return err
+}
+
+// Close writes the last row group and the file footer.
+func (w *parquetWriter) Close() error {
+	return w.w.Close()
+}
@@ -0,0 +1,159 @@
+package export
+
+import (
+	"strconv"
+	"time"
+
+	"wb-tech-l0/internal/models"
+)
+
+// Row is an order flattened for the tabular formats: one row per item with
+// the order, delivery and payment columns repeated. An order without items
+// gets one row whose item columns are empty (null in Parquet).
+type Row struct {
+	OrderUID          string    `parquet:"order_uid"`
+	TrackNumber       string    `parquet:"track_number"`
+	Entry             string    `parquet:"entry"`
+	Locale            string    `parquet:"locale"`
+	InternalSignature string    `parquet:"internal_signature"`
+	CustomerID        string    `parquet:"customer_id"`
+	DeliveryService   string    `parquet:"delivery_service"`
+	Shardkey          string    `parquet:"shardkey"`
+	SmID              int       `parquet:"sm_id"`
+	DateCreated       time.Time `parquet:"date_created,timestamp(millisecond)"`
+	OofShard          string    `parquet:"oof_shard"`
+
+	DeliveryName    string `parquet:"delivery_name"`
+	DeliveryPhone   string `parquet:"delivery_phone"`
+	DeliveryZip     string `parquet:"delivery_zip"`
+	DeliveryCity    string `parquet:"delivery_city"`
+	DeliveryAddress string `parquet:"delivery_address"`
+	DeliveryRegion  string `parquet:"delivery_region"`
+	DeliveryEmail   string `parquet:"delivery_email"`
+
+	PaymentTransaction  string `parquet:"payment_transaction"`
+	PaymentRequestID    string `parquet:"payment_request_id"`
+	PaymentCurrency     string `parquet:"payment_currency"`
+	PaymentProvider     string `parquet:"payment_provider"`
+	PaymentAmount       int    `parquet:"payment_amount"`
+	PaymentDt           int64  `parquet:"payment_dt"`
+	PaymentBank         string `parquet:"payment_bank"`
+	PaymentDeliveryCost int    `parquet:"payment_delivery_cost"`
+	PaymentGoodsTotal   int    `parquet:"payment_goods_total"`
+	PaymentCustomFee    int    `parquet:"payment_custom_fee"`
+
+	ItemChrtID      *int    `parquet:"item_chrt_id,optional"`
+	ItemTrackNumber *string `parquet:"item_track_number,optional"`
+	ItemPrice       *int    `parquet:"item_price,optional"`
+	ItemRID         *string `parquet:"item_rid,optional"`
+	ItemName        *string `parquet:"item_name,optional"`
+	ItemSale        *int    `parquet:"item_sale,optional"`
+	ItemSize        *string `parquet:"item_size,optional"`
+	ItemTotalPrice  *int    `parquet:"item_total_price,optional"`
+	ItemNmID        *int    `parquet:"item_nm_id,optional"`
+	ItemBrand       *string `parquet:"item_brand,optional"`
+	ItemStatus      *int    `parquet:"item_status,optional"`
+}
+
+// Columns are the column names of Row, in field order; they head CSV
+// exports.
+var Columns = []string{
+	"order_uid", "track_number", "entry", "locale", "internal_signature",
+	"customer_id", "delivery_service", "shardkey", "sm_id", "date_created", "oof_shard",
+	"delivery_name", "delivery_phone", "delivery_zip", "delivery_city",
+	"delivery_address", "delivery_region", "delivery_email",
+	"payment_transaction", "payment_request_id", "payment_currency", "payment_provider",
+	"payment_amount", "payment_dt", "payment_bank", "payment_delivery_cost",
+	"payment_goods_total", "payment_custom_fee",
+	"item_chrt_id", "item_track_number", "item_price", "item_rid", "item_name",
+	"item_sale", "item_size", "item_total_price", "item_nm_id", "item_brand", "item_status",
+}
+
+// AppendRows appends the rows of order to rows.
+func AppendRows(rows []Row, order *models.Order) []Row {
+	base := Row{
+		OrderUID:          order.OrderUID,
+		TrackNumber:       order.TrackNumber,
+		Entry:             order.Entry,
+		Locale:            order.Locale,
+		InternalSignature: order.InternalSignature,
+		CustomerID:        order.CustomerID,
+		DeliveryService:   order.DeliveryService,
+		Shardkey:          order.Shardkey,
+		SmID:              order.SmID,
+		DateCreated:       order.DateCreated,
+		OofShard:          order.OofShard,
+
+		DeliveryName:    order.Delivery.Name,
+		DeliveryPhone:   order.Delivery.Phone,
+		DeliveryZip:     order.Delivery.Zip,
+		DeliveryCity:    order.Delivery.City,
+		DeliveryAddress: order.Delivery.Address,
+		DeliveryRegion:  order.Delivery.Region,
+		DeliveryEmail:   order.Delivery.Email,
+
+		PaymentTransaction:  order.Payment.Transaction,
+		PaymentRequestID:    order.Payment.RequestID,
+		PaymentCurrency:     order.Payment.Currency,
+		PaymentProvider:     order.Payment.Provider,
+		PaymentAmount:       order.Payment.Amount,
+		PaymentDt:           order.Payment.PaymentDt,
+		PaymentBank:         order.Payment.Bank,
+		PaymentDeliveryCost: order.Payment.DeliveryCost,
+		PaymentGoodsTotal:   order.Payment.GoodsTotal,
+		PaymentCustomFee:    order.Payment.CustomFee,
+	}
+	if len(order.Items) == 0 {
+		return append(rows, base)
+	}
+
+	for i := range order.Items {
+		item := &order.Items[i]
+		row := base
+		row.ItemChrtID = &item.ChrtID
+		row.ItemTrackNumber = &item.TrackNumber
+		row.ItemPrice = &item.Price
+		row.ItemRID = &item.RID
+		row.ItemName = &item.Name
+		row.ItemSale = &item.Sale
+		row.ItemSize = &item.Size
+		row.ItemTotalPrice = &item.TotalPrice
+		row.ItemNmID = &item.NmID
+		row.ItemBrand = &item.Brand
+		row.ItemStatus = &item.Status
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// Record formats the row as CSV fields in the order of Columns. Times are
+// RFC 3339 in UTC and missing item values are empty.
+func (r *Row) Record() []string {
+	return []string{
+		r.OrderUID, r.TrackNumber, r.Entry, r.Locale, r.InternalSignature,
+		r.CustomerID, r.DeliveryService, r.Shardkey, strconv.Itoa(r.SmID),
+		r.DateCreated.UTC().Format(time.RFC3339), r.OofShard,
+		r.DeliveryName, r.DeliveryPhone, r.DeliveryZip, r.DeliveryCity,
+		r.DeliveryAddress, r.DeliveryRegion, r.DeliveryEmail,
+		r.PaymentTransaction, r.PaymentRequestID, r.PaymentCurrency, r.PaymentProvider,
+		strconv.Itoa(r.PaymentAmount), strconv.FormatInt(r.PaymentDt, 10), r.PaymentBank,
+		strconv.Itoa(r.PaymentDeliveryCost), strconv.Itoa(r.PaymentGoodsTotal), strconv.Itoa(r.PaymentCustomFee),
+		optInt(r.ItemChrtID), optString(r.ItemTrackNumber), optInt(r.ItemPrice), optString(r.ItemRID),
+		optString(r.ItemName), optInt(r.ItemSale), optString(r.ItemSize), optInt(r.ItemTotalPrice),
+		optInt(r.ItemNmID), optString(r.ItemBrand), optInt(r.ItemStatus),
+	}
+}
+
+func optInt(v *int) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.Itoa(*v)
+}
+
+func optString(v *string) string {
+	if v == nil {
+		return ""
+	}
+	return *v
+}
@@ -0,0 +1,201 @@
+package feed
+
+import (
+	"sync"
+	"time"
+
+	"wb-tech-l0/internal/application/ports"
+	"wb-tech-l0/internal/models"
+)
+
+// OrderSummary is the compact view of an order pushed to live subscribers.
+type OrderSummary struct {
+	OrderUID        string    `json:"order_uid"`
+	TrackNumber     string    `json:"track_number"`
+	Entry           string    `json:"entry"`
+	DeliveryService string    `json:"delivery_service"`
+	Provider        string    `json:"provider"`
+	Currency        string    `json:"currency"`
+	Amount          int       `json:"amount"`
+	ItemsCount      int       `json:"items_count"`
+	DateCreated     time.Time `json:"date_created"`
+}
+
+func NewOrderSummary(o *models.Order) OrderSummary {
+	return OrderSummary{
+		OrderUID:        o.OrderUID,
+		TrackNumber:     o.TrackNumber,
+		Entry:           o.Entry,
+		DeliveryService: o.DeliveryService,
+		Provider:        o.Payment.Provider,
+		Currency:        o.Payment.Currency,
+		Amount:          o.Payment.Amount,
+		ItemsCount:      len(o.Items),
+		DateCreated:     o.DateCreated,
+	}
+}
+
+// Event is a saved order together with its position in the feed.
+type Event struct {
+	ID      uint64
+	Summary OrderSummary
+	Order   *models.Order
+}
+
+// Filter selects events by exact field values; empty fields match anything.
+type Filter struct {
+	DeliveryService string
+	Entry           string
+}
+
+func (f Filter) Match(s OrderSummary) bool {
+	return (f.DeliveryService == "" || f.DeliveryService == s.DeliveryService) &&
+		(f.Entry == "" || f.Entry == s.Entry)
+}
+
+const subscriptionBuffer = 64
+
+// Hub fans newly saved orders out to live subscribers and keeps the most
+// recent events in a bounded ring buffer so that reconnecting clients can
+// resume from the last event they have seen.
+//
+// Event IDs start from the hub creation time in microseconds, so IDs handed
+// out by a previous process are always lower than the current ones.
+type Hub struct {
+	mu     sync.Mutex
+	ring   []Event
+	start  int // index of the oldest event in ring
+	size   int
+	nextID uint64
+	subs   map[*Subscription]struct{}
+}
+
+var (
+	_ ports.OrderObserver   = (*Hub)(nil)
+	_ ports.ErasureObserver = (*Hub)(nil)
+)
+
+func NewHub(bufferSize int) *Hub {
+	if bufferSize <= 0 {
+		bufferSize = 1
+	}
+	return &Hub{
+		ring:   make([]Event, bufferSize),
+		nextID: uint64(time.Now().UnixMicro()),
+		subs:   make(map[*Subscription]struct{}),
+	}
+}
+
+// OrderSaved publishes the order to the feed.
+func (h *Hub) OrderSaved(order *models.Order) {
+	h.Publish(order)
+}
+
+func (h *Hub) Publish(order *models.Order) Event {
+	summary := NewOrderSummary(order)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.nextID++
+	e := Event{ID: h.nextID, Summary: summary, Order: order}
+
+	if h.size < len(h.ring) {
+		h.ring[(h.start+h.size)%len(h.ring)] = e
+		h.size++
+	} else {
+		h.ring[h.start] = e
+		h.start = (h.start + 1) % len(h.ring)
+	}
+
+	for sub := range h.subs {
+		if !sub.filter.Match(summary) {
+			continue
+		}
+		select {
+		case sub.ch <- e:
+		default:
+			// The subscriber cannot keep up; drop it so that it reconnects
+			// and resumes from the buffer instead of blocking publishers.
+			h.removeLocked(sub)
+		}
+	}
+
+	return e
+}
+
+// CustomerErased anonymises the buffered copies of the erased orders, so
+// that resuming subscribers are not replayed the erased data. Events keep
+// their IDs; those already handed to subscribers are not recalled.
+func (h *Hub) CustomerErased(report models.ErasureReport) {
+	erased := make(map[string]bool, len(report.OrderUIDs))
+	for _, uid := range report.OrderUIDs {
+		erased[uid] = true
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i := 0; i < h.size; i++ {
+		e := &h.ring[(h.start+i)%len(h.ring)]
+		if !erased[e.Summary.OrderUID] {
+			continue
+		}
+		// Copied rather than changed in place: the order may still be
+		// read from a subscriber's channel.
+		order := *e.Order
+		order.ErasePersonalData()
+		e.Order = &order
+	}
+}
+
+// Subscribe registers a subscriber. If lastEventID is non-zero, buffered
+// events newer than it that match the filter are returned for replay.
+func (h *Hub) Subscribe(filter Filter, lastEventID uint64) (*Subscription, []Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var missed []Event
+	if lastEventID != 0 {
+		for i := 0; i < h.size; i++ {
+			e := h.ring[(h.start+i)%len(h.ring)]
+			if e.ID > lastEventID && filter.Match(e.Summary) {
+				missed = append(missed, e)
+			}
+		}
+	}
+
+	sub := &Subscription{
+		hub:    h,
+		filter: filter,
+		ch:     make(chan Event, subscriptionBuffer),
+	}
+	h.subs[sub] = struct{}{}
+
+	return sub, missed
+}
+
+func (h *Hub) removeLocked(sub *Subscription) {
+	if _, ok := h.subs[sub]; ok {
+		delete(h.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// Subscription receives live events until it is closed or dropped.
+type Subscription struct {
+	hub    *Hub
+	filter Filter
+	ch     chan Event
+}
+
+// Events is closed when the subscriber is dropped for being too slow.
+func (s *Subscription) Events() <-chan Event {
+	return s.ch
+}
+
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.removeLocked(s)
+}
@@ -0,0 +1,92 @@
+package feed
+
+import (
+	"testing"
+
+	"wb-tech-l0/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func order(uid, service, entry string) *models.Order {
+	return &models.Order{OrderUID: uid, DeliveryService: service, Entry: entry}
+}
+
+func TestHub_DeliversMatchingEvents(t *testing.T) {
+	h := NewHub(10)
+	sub, missed := h.Subscribe(Filter{DeliveryService: "dhl"}, 0)
+	defer sub.Close()
+	assert.Empty(t, missed)
+
+	h.Publish(order("a", "ups", "WBIL"))
+	h.Publish(order("b", "dhl", "WBIL"))
+
+	e := <-sub.Events()
+	assert.Equal(t, "b", e.Summary.OrderUID)
+	assert.Empty(t, sub.Events())
+}
+
+func TestHub_ResumeFromLastEventID(t *testing.T) {
+	h := NewHub(3)
+	first := h.Publish(order("a", "dhl", "WBIL"))
+	h.Publish(order("b", "ups", "WBIL"))
+	h.Publish(order("c", "dhl", "TEST"))
+	h.Publish(order("d", "dhl", "WBIL"))
+
+	// "a" has been evicted from the ring, the rest is replayed in order.
+	sub, missed := h.Subscribe(Filter{}, first.ID)
+	defer sub.Close()
+	require.Len(t, missed, 3)
+	assert.Equal(t, "b", missed[0].Summary.OrderUID)
+	assert.Equal(t, "d", missed[2].Summary.OrderUID)
+
+	filtered, missed := h.Subscribe(Filter{DeliveryService: "dhl", Entry: "WBIL"}, first.ID)
+	defer filtered.Close()
+	require.Len(t, missed, 1)
+	assert.Equal(t, "d", missed[0].Summary.OrderUID)
+}
+
+func TestHub_CustomerErasedAnonymisesBufferedOrders(t *testing.T) {
+	h := NewHub(10)
+	erased, kept := order("a", "dhl", "WBIL"), order("b", "dhl", "WBIL")
+	erased.Delivery.Name, kept.Delivery.Name = "Alice", "Bob"
+	first := h.Publish(order("0", "dhl", "WBIL"))
+	h.Publish(erased)
+	h.Publish(kept)
+
+	h.CustomerErased(models.ErasureReport{CustomerID: "cust-1", OrderUIDs: []string{"a"}})
+
+	sub, missed := h.Subscribe(Filter{}, first.ID)
+	defer sub.Close()
+	require.Len(t, missed, 2)
+	assert.Equal(t, models.ErasedDelivery, missed[0].Order.Delivery)
+	assert.Equal(t, models.ErasedValue, missed[0].Order.Payment.Transaction)
+	assert.Equal(t, "Bob", missed[1].Order.Delivery.Name)
+	assert.Equal(t, "Alice", erased.Delivery.Name, "the published order is not changed in place")
+}
+
+func TestHub_DropsSlowSubscriber(t *testing.T) {
+	h := NewHub(10)
+	sub, _ := h.Subscribe(Filter{}, 0)
+
+	for i := 0; i < subscriptionBuffer+1; i++ {
+		h.Publish(order("x", "dhl", "WBIL"))
+	}
+
+	received := 0
+	for range sub.Events() {
+		received++
+	}
+	assert.Equal(t, subscriptionBuffer, received)
+
+	// Closing a dropped subscription is a no-op.
+	sub.Close()
+}
+
+func TestHub_EventIDsIncrease(t *testing.T) {
+	h := NewHub(2)
+	a := h.Publish(order("a", "", ""))
+	b := h.Publish(order("b", "", ""))
+	assert.Greater(t, b.ID, a.ID)
+}
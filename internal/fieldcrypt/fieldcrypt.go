@@ -0,0 +1,324 @@
+// Package fieldcrypt encrypts individual values, such as customer personal
+// data columns, with envelope encryption.
+//
+// Every value gets its own random data key (DEK). The value is sealed with
+// the DEK using AES-256-GCM, and the DEK is sealed with a key encryption
+// key (KEK) from the Keyring. The result is a self-describing string:
+//
+//	enc:v1:<kek id>:<wrapped dek>:<nonce + ciphertext>
+//
+// Because the KEK ID travels with the value, old keys can stay in the
+// keyring for decryption while new values use the primary key, and a
+// rotation only has to re-wrap the small DEK.
+package fieldcrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+const (
+	prefix  = "enc:v1:"
+	keySize = 32
+)
+
+var (
+	// ErrUnknownKey means a value was encrypted with a KEK that is not in
+	// the keyring.
+	ErrUnknownKey = errors.New("fieldcrypt: unknown key id")
+	// ErrMalformed means a value has the encrypted prefix but cannot be
+	// decoded or authenticated.
+	ErrMalformed = errors.New("fieldcrypt: malformed ciphertext")
+	// ErrNoKeys means an encrypted value was found but no keyring is
+	// configured.
+	ErrNoKeys = errors.New("fieldcrypt: value is encrypted but no keys are configured")
+)
+
+var keyIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// Keyring holds the key encryption keys. New values are encrypted with the
+// primary key; any key in the ring can decrypt.
+//
+// A nil *Keyring is valid and disables encryption: Encrypt returns the
+// plaintext and Decrypt passes plaintext through.
+type Keyring struct {
+	primary string
+	keks    map[string]cipher.AEAD
+}
+
+// NewKeyring builds a keyring from raw 32-byte AES keys indexed by ID.
+func NewKeyring(primary string, keys map[string][]byte) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("fieldcrypt: no keys")
+	}
+	if _, ok := keys[primary]; !ok {
+		return nil, fmt.Errorf("fieldcrypt: primary key %q is not in the keyring", primary)
+	}
+
+	k := &Keyring{primary: primary, keks: make(map[string]cipher.AEAD, len(keys))}
+	for id, raw := range keys {
+		if !keyIDPattern.MatchString(id) {
+			return nil, fmt.Errorf("fieldcrypt: invalid key id %q", id)
+		}
+		if len(raw) != keySize {
+			return nil, fmt.Errorf("fieldcrypt: key %q must be %d bytes, got %d", id, keySize, len(raw))
+		}
+		aead, err := newGCM(raw)
+		if err != nil {
+			return nil, fmt.Errorf("fieldcrypt: key %q: %w", id, err)
+		}
+		k.keks[id] = aead
+	}
+	return k, nil
+}
+
+// ParseKeys reads keys in the "id:base64,id:base64" form used by
+// environment variables. The first key is the primary one.
+func ParseKeys(spec string) (*Keyring, error) {
+	keys := make(map[string][]byte)
+	var primary string
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, encoded, ok := strings.Cut(entry, ":")
+		if !ok {
+			return nil, fmt.Errorf("fieldcrypt: key entry for %q must be id:base64", id)
+		}
+		raw, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("fieldcrypt: key %q is not valid base64: %w", id, err)
+		}
+		if _, dup := keys[id]; dup {
+			return nil, fmt.Errorf("fieldcrypt: duplicate key id %q", id)
+		}
+		if primary == "" {
+			primary = id
+		}
+		keys[id] = raw
+	}
+	return NewKeyring(primary, keys)
+}
+
+// keyfile is the on-disk keyring format:
+//
+//	{"primary": "2025-10", "keys": {"2025-10": "<base64>", "2025-01": "<base64>"}}
+type keyfile struct {
+	Primary string            `json:"primary"`
+	Keys    map[string]string `json:"keys"`
+}
+
+// LoadKeyfile reads a keyring from a JSON keyfile.
+func LoadKeyfile(path string) (*Keyring, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("fieldcrypt: read keyfile: %w", err)
+	}
+
+	var f keyfile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("fieldcrypt: parse keyfile: %w", err)
+	}
+
+	keys := make(map[string][]byte, len(f.Keys))
+	for id, encoded := range f.Keys {
+		raw, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("fieldcrypt: key %q is not valid base64: %w", id, err)
+		}
+		keys[id] = raw
+	}
+	return NewKeyring(f.Primary, keys)
+}
+
+// Primary returns the ID of the key used for new values.
+func (k *Keyring) Primary() string {
+	if k == nil {
+		return ""
+	}
+	return k.primary
+}
+
+// IsEncrypted reports whether value was produced by Encrypt.
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+// Encrypt seals plaintext under a fresh data key wrapped with the primary
+// key. aad binds the ciphertext to its context (for example the column
+// name), so it cannot be moved to another field. Empty values are kept
+// empty.
+func (k *Keyring) Encrypt(plaintext, aad string) (string, error) {
+	if k == nil || plaintext == "" {
+		return plaintext, nil
+	}
+
+	dek := make([]byte, keySize)
+	if _, err := rand.Read(dek); err != nil {
+		return "", err
+	}
+	wrapped, err := k.wrap(k.primary, dek)
+	if err != nil {
+		return "", err
+	}
+
+	aead, err := newGCM(dek)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := seal(aead, []byte(plaintext), []byte(aad))
+	if err != nil {
+		return "", err
+	}
+
+	return format(k.primary, wrapped, sealed), nil
+}
+
+// Decrypt opens a value produced by Encrypt with the same aad. Values
+// without the encrypted prefix are returned unchanged, so rows written
+// before encryption was enabled stay readable until they are rotated.
+func (k *Keyring) Decrypt(value, aad string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	if k == nil {
+		return "", ErrNoKeys
+	}
+
+	kid, wrapped, sealed, err := parse(value)
+	if err != nil {
+		return "", err
+	}
+	dek, err := k.unwrap(kid, wrapped)
+	if err != nil {
+		return "", err
+	}
+
+	aead, err := newGCM(dek)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(aead, sealed, []byte(aad))
+	if err != nil {
+		return "", ErrMalformed
+	}
+	return string(plaintext), nil
+}
+
+// NeedsRotation reports whether value is stored in clear text or under a
+// key other than the primary one.
+func (k *Keyring) NeedsRotation(value string) bool {
+	if k == nil || value == "" {
+		return false
+	}
+	if !IsEncrypted(value) {
+		return true
+	}
+	kid, _, _, err := parse(value)
+	return err == nil && kid != k.primary
+}
+
+// Rotate returns value protected by the primary key. Encrypted values only
+// have their data key re-wrapped; clear text values are encrypted.
+func (k *Keyring) Rotate(value, aad string) (string, error) {
+	if !k.NeedsRotation(value) {
+		return value, nil
+	}
+	if !IsEncrypted(value) {
+		return k.Encrypt(value, aad)
+	}
+
+	kid, wrapped, sealed, err := parse(value)
+	if err != nil {
+		return "", err
+	}
+	dek, err := k.unwrap(kid, wrapped)
+	if err != nil {
+		return "", err
+	}
+	// Make sure the value opens before it is re-wrapped, so a wrong aad
+	// is reported instead of being carried over.
+	aead, err := newGCM(dek)
+	if err != nil {
+		return "", err
+	}
+	if _, err := open(aead, sealed, []byte(aad)); err != nil {
+		return "", ErrMalformed
+	}
+
+	rewrapped, err := k.wrap(k.primary, dek)
+	if err != nil {
+		return "", err
+	}
+	return format(k.primary, rewrapped, sealed), nil
+}
+
+func (k *Keyring) wrap(kid string, dek []byte) ([]byte, error) {
+	return seal(k.keks[kid], dek, []byte(kid))
+}
+
+func (k *Keyring) unwrap(kid string, wrapped []byte) ([]byte, error) {
+	kek, ok := k.keks[kid]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, kid)
+	}
+	dek, err := open(kek, wrapped, []byte(kid))
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	return dek, nil
+}
+
+func format(kid string, wrapped, sealed []byte) string {
+	return prefix + kid + ":" +
+		base64.RawStdEncoding.EncodeToString(wrapped) + ":" +
+		base64.RawStdEncoding.EncodeToString(sealed)
+}
+
+func parse(value string) (kid string, wrapped, sealed []byte, err error) {
+	parts := strings.Split(strings.TrimPrefix(value, prefix), ":")
+	if len(parts) != 3 {
+		return "", nil, nil, ErrMalformed
+	}
+	if wrapped, err = base64.RawStdEncoding.DecodeString(parts[1]); err != nil {
+		return "", nil, nil, ErrMalformed
+	}
+	if sealed, err = base64.RawStdEncoding.DecodeString(parts[2]); err != nil {
+		return "", nil, nil, ErrMalformed
+	}
+	return parts[0], wrapped, sealed, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal returns nonce || ciphertext.
+func seal(aead cipher.AEAD, plaintext, aad []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+func open(aead cipher.AEAD, sealed, aad []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrMalformed
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, aad)
+}
@@ -0,0 +1,132 @@
+package fieldcrypt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, keySize)
+}
+
+func TestKeyring_EncryptDecrypt(t *testing.T) {
+	k, err := NewKeyring("k1", map[string][]byte{"k1": testKey(1)})
+	require.NoError(t, err)
+
+	enc, err := k.Encrypt("+79161234567", "delivery.phone")
+	require.NoError(t, err)
+	assert.True(t, IsEncrypted(enc))
+	assert.True(t, strings.HasPrefix(enc, "enc:v1:k1:"))
+	assert.NotContains(t, enc, "79161234567")
+
+	again, err := k.Encrypt("+79161234567", "delivery.phone")
+	require.NoError(t, err)
+	assert.NotEqual(t, enc, again, "every value gets a fresh data key and nonce")
+
+	dec, err := k.Decrypt(enc, "delivery.phone")
+	require.NoError(t, err)
+	assert.Equal(t, "+79161234567", dec)
+
+	_, err = k.Decrypt(enc, "delivery.email")
+	assert.ErrorIs(t, err, ErrMalformed, "ciphertext is bound to its field")
+
+	empty, err := k.Encrypt("", "delivery.phone")
+	require.NoError(t, err)
+	assert.Empty(t, empty)
+
+	plain, err := k.Decrypt("legacy value", "delivery.phone")
+	require.NoError(t, err)
+	assert.Equal(t, "legacy value", plain)
+}
+
+func TestKeyring_Nil(t *testing.T) {
+	var k *Keyring
+
+	v, err := k.Encrypt("John", "delivery.name")
+	require.NoError(t, err)
+	assert.Equal(t, "John", v)
+
+	other, err := NewKeyring("k1", map[string][]byte{"k1": testKey(1)})
+	require.NoError(t, err)
+	enc, err := other.Encrypt("John", "delivery.name")
+	require.NoError(t, err)
+
+	_, err = k.Decrypt(enc, "delivery.name")
+	assert.ErrorIs(t, err, ErrNoKeys)
+}
+
+func TestKeyring_Rotate(t *testing.T) {
+	old, err := NewKeyring("old", map[string][]byte{"old": testKey(1)})
+	require.NoError(t, err)
+	enc, err := old.Encrypt("john@example.com", "delivery.email")
+	require.NoError(t, err)
+
+	k, err := NewKeyring("new", map[string][]byte{"old": testKey(1), "new": testKey(2)})
+	require.NoError(t, err)
+
+	assert.True(t, k.NeedsRotation(enc))
+	assert.True(t, k.NeedsRotation("plain"))
+	assert.False(t, k.NeedsRotation(""))
+
+	rotated, err := k.Rotate(enc, "delivery.email")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(rotated, "enc:v1:new:"))
+	assert.False(t, k.NeedsRotation(rotated))
+
+	// The old key is no longer needed after rotation.
+	onlyNew, err := NewKeyring("new", map[string][]byte{"new": testKey(2)})
+	require.NoError(t, err)
+	dec, err := onlyNew.Decrypt(rotated, "delivery.email")
+	require.NoError(t, err)
+	assert.Equal(t, "john@example.com", dec)
+
+	_, err = onlyNew.Decrypt(enc, "delivery.email")
+	assert.ErrorIs(t, err, ErrUnknownKey)
+
+	encrypted, err := k.Rotate("plain", "delivery.name")
+	require.NoError(t, err)
+	assert.True(t, IsEncrypted(encrypted))
+
+	_, err = k.Rotate(enc, "delivery.name")
+	assert.ErrorIs(t, err, ErrMalformed)
+}
+
+func TestParseKeys(t *testing.T) {
+	b64 := base64.StdEncoding.EncodeToString
+	k, err := ParseKeys("2025-10:" + b64(testKey(1)) + ", 2025-01:" + b64(testKey(2)))
+	require.NoError(t, err)
+	assert.Equal(t, "2025-10", k.Primary())
+
+	for _, bad := range []string{
+		"",
+		"nokey",
+		"k1:not-base64!",
+		"k1:" + b64([]byte("short")),
+		"bad:id:" + b64(testKey(1)),
+		"k1:" + b64(testKey(1)) + ",k1:" + b64(testKey(2)),
+	} {
+		_, err := ParseKeys(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestLoadKeyfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	content := `{"primary":"b","keys":{"a":"` + base64.StdEncoding.EncodeToString(testKey(1)) +
+		`","b":"` + base64.StdEncoding.EncodeToString(testKey(2)) + `"}}`
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	k, err := LoadKeyfile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "b", k.Primary())
+
+	_, err = LoadKeyfile(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+}
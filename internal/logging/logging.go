@@ -0,0 +1,130 @@
+// Package logging builds the structured logger shared by all components.
+//
+// Records are enriched with the request ID stored in the context and passed
+// through a redaction step that masks well-known personal data keys, as a
+// safety net for values that do not implement slog.LogValuer themselves.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"wb-tech-l0/internal/pii"
+)
+
+// Common attribute keys, so that the same thing is called the same way in
+// every component.
+const (
+	KeyOrderUID  = "order_uid"
+	KeyTopic     = "topic"
+	KeyPartition = "partition"
+	KeyOffset    = "offset"
+	KeyRequestID = "request_id"
+	KeyError     = "error"
+)
+
+// Supported output formats.
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+type Config struct {
+	// Level is one of debug, info, warn or error.
+	Level string
+	// Format is FormatJSON or FormatText.
+	Format string
+}
+
+// New creates a logger writing to w.
+func New(w io.Writer, cfg Config) (*slog.Logger, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q: %w", cfg.Level, err)
+	}
+
+	opts := &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redact,
+	}
+
+	var h slog.Handler
+	switch strings.ToLower(cfg.Format) {
+	case FormatJSON, "":
+		h = slog.NewJSONHandler(w, opts)
+	case FormatText:
+		h = slog.NewTextHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("unknown log format %q", cfg.Format)
+	}
+
+	return slog.New(contextHandler{h}), nil
+}
+
+// Discard returns a logger that drops every record; handy in tests.
+func Discard() *slog.Logger {
+	return slog.New(slog.DiscardHandler)
+}
+
+// Err is a shorthand for the error attribute.
+func Err(err error) slog.Attr {
+	return slog.Any(KeyError, err)
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying the request ID; records
+// logged with that context get a request_id attribute.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID stored in ctx, if any.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// contextHandler adds attributes found in the record's context.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String(KeyRequestID, id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// redact masks string attributes whose key names personal data.
+func redact(_ []string, a slog.Attr) slog.Attr {
+	if a.Value.Kind() != slog.KindString {
+		return a
+	}
+
+	switch strings.ToLower(a.Key) {
+	case "phone":
+		return slog.String(a.Key, pii.MaskPhone(a.Value.String()))
+	case "email":
+		return slog.String(a.Key, pii.MaskEmail(a.Value.String()))
+	case "name", "customer_name":
+		return slog.String(a.Key, pii.MaskName(a.Value.String()))
+	case "address", "zip":
+		return slog.String(a.Key, pii.MaskAll(a.Value.String()))
+	case "transaction":
+		return slog.String(a.Key, pii.MaskTail(a.Value.String(), 4))
+	}
+	return a
+}
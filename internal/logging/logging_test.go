@@ -0,0 +1,90 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"wb-tech-l0/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decodeRecord(t *testing.T, buf *bytes.Buffer) map[string]any {
+	t.Helper()
+	var rec map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &rec))
+	return rec
+}
+
+func TestNew_RejectsInvalidConfig(t *testing.T) {
+	_, err := New(&bytes.Buffer{}, Config{Level: "loud", Format: FormatJSON})
+	assert.Error(t, err)
+
+	_, err = New(&bytes.Buffer{}, Config{Level: "info", Format: "xml"})
+	assert.Error(t, err)
+}
+
+func TestNew_FiltersByLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, Config{Level: "warn", Format: FormatText})
+	require.NoError(t, err)
+
+	logger.Info("hidden")
+	assert.Empty(t, buf.String())
+
+	logger.Warn("shown")
+	assert.Contains(t, buf.String(), "msg=shown")
+}
+
+func TestContextHandler_AddsRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, Config{Level: "info", Format: FormatJSON})
+	require.NoError(t, err)
+
+	ctx := WithRequestID(context.Background(), "req-42")
+	logger.With("component", "test").InfoContext(ctx, "handled")
+
+	rec := decodeRecord(t, &buf)
+	assert.Equal(t, "req-42", rec[KeyRequestID])
+	assert.Equal(t, "test", rec["component"])
+}
+
+func TestRedaction(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, Config{Level: "info", Format: FormatJSON})
+	require.NoError(t, err)
+
+	delivery := models.Delivery{
+		Name:    "John Smith",
+		Phone:   "+79161234567",
+		Zip:     "123456",
+		City:    "Moscow",
+		Address: "Ploshad Mira 15",
+		Region:  "Moscow",
+		Email:   "john@example.com",
+	}
+	payment := models.Payment{Transaction: "b563feb7b2b84b6test", Provider: "wbpay", Currency: "RUB", Amount: 1817}
+
+	logger.Info("order",
+		"delivery", delivery,
+		"payment", payment,
+		"email", "jane@example.com",
+	)
+
+	out := buf.String()
+	for _, secret := range []string{"John Smith", "+79161234567", "Ploshad Mira", "123456", "john@example.com", "jane@example.com", "b563feb7b2b8"} {
+		assert.NotContains(t, out, secret)
+	}
+
+	rec := decodeRecord(t, &buf)
+	d := rec["delivery"].(map[string]any)
+	assert.Equal(t, "J*** S***", d["name"])
+	assert.Equal(t, "+7******4567", d["phone"])
+	assert.Equal(t, "j***@example.com", d["email"])
+	assert.Equal(t, "Moscow", d["city"])
+	assert.Equal(t, "***************test", rec["payment"].(map[string]any)["transaction"])
+	assert.Equal(t, "j***@example.com", rec["email"])
+}
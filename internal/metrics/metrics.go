@@ -0,0 +1,192 @@
+// Package metrics defines the Prometheus collectors of the service.
+//
+// Metric names and label sets are part of the alerting contract: extend
+// them with new metrics or label values, but do not rename existing ones.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "wb_orders"
+
+// Reasons used for KafkaMessagesFailed.
+const (
+	ReasonDecode     = "decode"
+	ReasonValidation = "validation"
+	ReasonSave       = "save"
+)
+
+// Kafka consumer.
+var (
+	KafkaMessagesConsumed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "messages_consumed_total",
+		Help:      "Kafka messages received by the consumer.",
+	}, []string{"topic", "partition"})
+
+	KafkaMessagesFailed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "messages_failed_total",
+		Help:      "Kafka messages that could not be processed, by reason.",
+	}, []string{"topic", "reason"})
+
+	KafkaProcessingSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "message_processing_seconds",
+		Help:      "Time spent processing a single Kafka message.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"topic"})
+
+	KafkaConsumerLag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "consumer_lag",
+		Help:      "Messages between the last consumed offset and the partition high water mark.",
+	}, []string{"topic", "partition"})
+
+	KafkaMessagesInFlight = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "messages_in_flight",
+		Help:      "Messages handed to consumer workers and not processed yet.",
+	}, []string{"topic"})
+
+	KafkaCommittedOffset = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "committed_offset",
+		Help:      "Next offset to consume, marked for commit once every earlier message is processed.",
+	}, []string{"topic", "partition"})
+
+	KafkaBatchSize = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "batch_size",
+		Help:      "Orders saved together in one batch.",
+		Buckets:   prometheus.ExponentialBuckets(1, 2, 10),
+	}, []string{"topic"})
+
+	KafkaBatchFallbacks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "batch_fallbacks_total",
+		Help:      "Batches that failed to save and were saved order by order.",
+	}, []string{"topic"})
+
+	KafkaMessagesDeadLettered = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "messages_dead_lettered_total",
+		Help:      "Messages copied to the dead-letter topic after their order failed to save with a non-retryable error.",
+	}, []string{"topic"})
+)
+
+// Order cache.
+var (
+	CacheHits = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "hits_total",
+		Help:      "Order lookups served from Redis.",
+	})
+
+	CacheMisses = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "misses_total",
+		Help:      "Order lookups not found in Redis.",
+	})
+
+	CacheErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "errors_total",
+		Help:      "Failed Redis operations of the order cache.",
+	}, []string{"operation"})
+
+	CachePayloadBytes = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "payload_bytes",
+		Help:      "Size of order payloads written to and read from Redis.",
+		Buckets:   prometheus.ExponentialBuckets(256, 2, 10),
+	}, []string{"operation"})
+)
+
+// Database repository.
+var (
+	DBQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "query_duration_seconds",
+		Help:      "Duration of repository operations.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation"})
+
+	DBTransactionFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "transaction_failures_total",
+		Help:      "Rolled back repository transactions.",
+	}, []string{"operation"})
+)
+
+// HTTP server.
+var (
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "HTTP requests handled, by route pattern and status code.",
+	}, []string{"route", "method", "status"})
+
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency, by route pattern and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	HTTPRateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "rate_limited_total",
+		Help:      "HTTP requests rejected by the rate limiter, by route pattern.",
+	}, []string{"route"})
+
+	HTTPRateLimiterErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "rate_limiter_errors_total",
+		Help:      "Rate limiter failures; the affected requests were let through.",
+	})
+)
+
+// ObserveDB records the duration of a repository operation started at start.
+// It is meant to be deferred: defer metrics.ObserveDB("get_order", time.Now()).
+func ObserveDB(operation string, start time.Time) {
+	DBQueryDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
+
+// ObserveHTTP records a finished HTTP request.
+func ObserveHTTP(route, method string, status int, elapsed time.Duration) {
+	code := strconv.Itoa(status)
+	HTTPRequests.WithLabelValues(route, method, code).Inc()
+	HTTPRequestDuration.WithLabelValues(route, method, code).Observe(elapsed.Seconds())
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,40 @@
+package mocks
+
+import (
+	"context"
+	"wb-tech-l0/internal/application/ports"
+	"wb-tech-l0/internal/models"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// AnalyticsRepositoryMock implements ports.AnalyticsRepository.
+type AnalyticsRepositoryMock struct {
+	mock.Mock
+}
+
+var _ ports.AnalyticsRepository = (*AnalyticsRepositoryMock)(nil)
+
+func (m *AnalyticsRepositoryMock) Revenue(ctx context.Context, q ports.RevenueQuery) ([]models.PeriodRevenue, error) {
+	args := m.Called(ctx, q)
+	points, _ := args.Get(0).([]models.PeriodRevenue)
+	return points, args.Error(1)
+}
+
+func (m *AnalyticsRepositoryMock) Breakdown(ctx context.Context, q ports.BreakdownQuery) ([]models.BreakdownRow, error) {
+	args := m.Called(ctx, q)
+	rows, _ := args.Get(0).([]models.BreakdownRow)
+	return rows, args.Error(1)
+}
+
+func (m *AnalyticsRepositoryMock) Basket(ctx context.Context, r ports.AnalyticsRange) ([]models.BasketStats, error) {
+	args := m.Called(ctx, r)
+	stats, _ := args.Get(0).([]models.BasketStats)
+	return stats, args.Error(1)
+}
+
+func (m *AnalyticsRepositoryMock) TopProducts(ctx context.Context, q ports.TopProductsQuery) ([]models.ProductSales, error) {
+	args := m.Called(ctx, q)
+	products, _ := args.Get(0).([]models.ProductSales)
+	return products, args.Error(1)
+}
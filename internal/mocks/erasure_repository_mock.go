@@ -0,0 +1,21 @@
+package mocks
+
+import (
+	"context"
+	"wb-tech-l0/internal/application/ports"
+	"wb-tech-l0/internal/models"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// ErasureRepositoryMock implements ports.ErasureRepository.
+type ErasureRepositoryMock struct {
+	mock.Mock
+}
+
+var _ ports.ErasureRepository = (*ErasureRepositoryMock)(nil)
+
+func (m *ErasureRepositoryMock) EraseCustomerData(ctx context.Context, req ports.ErasureRequest) (models.ErasureReport, error) {
+	args := m.Called(ctx, req)
+	return args.Get(0).(models.ErasureReport), args.Error(1)
+}
@@ -1,6 +1,7 @@
 package mocks
 
 import (
+	"context"
 	"wb-tech-l0/internal/application/ports"
 	"wb-tech-l0/internal/models"
 
@@ -14,13 +15,21 @@ type OrderRepositoryMock struct {
 
 var _ ports.OrderRepository = (*OrderRepositoryMock)(nil)
 
-func (m *OrderRepositoryMock) SaveOrder(order *models.Order) error {
-	args := m.Called(order)
-	return args.Error(0)
+func (m *OrderRepositoryMock) SaveOrder(ctx context.Context, order *models.Order) (bool, error) {
+	args := m.Called(ctx, order)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *OrderRepositoryMock) SaveOrders(ctx context.Context, orders []*models.Order) ([]bool, error) {
+	args := m.Called(ctx, orders)
+	if v := args.Get(0); v != nil {
+		return v.([]bool), args.Error(1)
+	}
+	return nil, args.Error(1)
 }
 
-func (m *OrderRepositoryMock) GetOrder(orderUID string) (*models.Order, error) {
-	args := m.Called(orderUID)
+func (m *OrderRepositoryMock) GetOrder(ctx context.Context, orderUID string) (*models.Order, error) {
+	args := m.Called(ctx, orderUID)
 	if v := args.Get(0); v != nil {
 		return v.(*models.Order), args.Error(1)
 	}
@@ -36,3 +45,30 @@ func (m *OrderRepositoryMock) CacheSize() int {
 	args := m.Called()
 	return args.Int(0)
 }
+
+func (m *OrderRepositoryMock) LoadOrdersToCache(maxOrdersCount int) error {
+	args := m.Called(maxOrdersCount)
+	return args.Error(0)
+}
+
+func (m *OrderRepositoryMock) ListOrders(ctx context.Context, query ports.ListOrdersQuery) (ports.OrderPage, error) {
+	args := m.Called(ctx, query)
+	var page ports.OrderPage
+	if v := args.Get(0); v != nil {
+		page = v.(ports.OrderPage)
+	}
+	return page, args.Error(1)
+}
+
+func (m *OrderRepositoryMock) ExportOrders(ctx context.Context, filter ports.OrderFilter, fn func(*models.Order) error) error {
+	args := m.Called(ctx, filter, fn)
+	return args.Error(0)
+}
+
+func (m *OrderRepositoryMock) GetOrders(ctx context.Context, orderUIDs []string) (map[string]*models.Order, error) {
+	args := m.Called(ctx, orderUIDs)
+	if v := args.Get(0); v != nil {
+		return v.(map[string]*models.Order), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
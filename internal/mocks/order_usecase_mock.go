@@ -1,6 +1,7 @@
 package mocks
 
 import (
+	"context"
 	"wb-tech-l0/internal/application/ports"
 	"wb-tech-l0/internal/models"
 
@@ -15,13 +16,18 @@ type OrderUseCaseMock struct {
 // Компилятор проверит, что структура реализует интерфейс.
 var _ ports.OrderUseCase = (*OrderUseCaseMock)(nil)
 
-func (m *OrderUseCaseMock) SaveOrder(order *models.Order) error {
-	args := m.Called(order)
+func (m *OrderUseCaseMock) SaveOrder(ctx context.Context, order *models.Order) error {
+	args := m.Called(ctx, order)
 	return args.Error(0)
 }
 
-func (m *OrderUseCaseMock) GetOrder(orderUID string) (*models.Order, error) {
-	args := m.Called(orderUID)
+func (m *OrderUseCaseMock) SaveOrders(ctx context.Context, orders []*models.Order) error {
+	args := m.Called(ctx, orders)
+	return args.Error(0)
+}
+
+func (m *OrderUseCaseMock) GetOrder(ctx context.Context, orderUID string) (*models.Order, error) {
+	args := m.Called(ctx, orderUID)
 	if v := args.Get(0); v != nil {
 		return v.(*models.Order), args.Error(1)
 	}
@@ -36,3 +42,31 @@ func (m *OrderUseCaseMock) Stats() (ports.OrderStats, error) {
 	}
 	return stats, args.Error(1)
 }
+
+func (m *OrderUseCaseMock) LoadOrdersToCache(maxOrdersCount int) error {
+	args := m.Called(maxOrdersCount)
+	return args.Error(0)
+}
+
+func (m *OrderUseCaseMock) ListOrders(ctx context.Context, query ports.ListOrdersQuery) (ports.OrderPage, error) {
+	args := m.Called(ctx, query)
+	var page ports.OrderPage
+	if v := args.Get(0); v != nil {
+		page = v.(ports.OrderPage)
+	}
+	return page, args.Error(1)
+}
+
+func (m *OrderUseCaseMock) ExportOrders(ctx context.Context, filter ports.OrderFilter, fn func(*models.Order) error) error {
+	args := m.Called(ctx, filter, fn)
+	return args.Error(0)
+}
+
+func (m *OrderUseCaseMock) GetOrders(ctx context.Context, uids []string) (ports.BatchOrders, error) {
+	args := m.Called(ctx, uids)
+	var result ports.BatchOrders
+	if v := args.Get(0); v != nil {
+		result = v.(ports.BatchOrders)
+	}
+	return result, args.Error(1)
+}
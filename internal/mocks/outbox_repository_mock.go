@@ -0,0 +1,21 @@
+package mocks
+
+import (
+	"context"
+
+	"wb-tech-l0/internal/application/ports"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// OutboxRepositoryMock implements ports.OutboxRepository.
+type OutboxRepositoryMock struct {
+	mock.Mock
+}
+
+var _ ports.OutboxRepository = (*OutboxRepositoryMock)(nil)
+
+func (m *OutboxRepositoryMock) RelayOutboxEvents(ctx context.Context, limit int, publish func([]ports.OutboxEvent) []uint64) (int, error) {
+	args := m.Called(ctx, limit, publish)
+	return args.Int(0), args.Error(1)
+}
@@ -0,0 +1,78 @@
+package mocks
+
+import (
+	"time"
+	"wb-tech-l0/internal/application/ports"
+	"wb-tech-l0/internal/models"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// WebhookRepositoryMock implements ports.WebhookRepository.
+type WebhookRepositoryMock struct {
+	mock.Mock
+}
+
+var _ ports.WebhookRepository = (*WebhookRepositoryMock)(nil)
+
+func (m *WebhookRepositoryMock) CreateSubscription(sub *models.WebhookSubscription) error {
+	args := m.Called(sub)
+	return args.Error(0)
+}
+
+func (m *WebhookRepositoryMock) GetSubscription(id uint) (*models.WebhookSubscription, error) {
+	args := m.Called(id)
+	if v := args.Get(0); v != nil {
+		return v.(*models.WebhookSubscription), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *WebhookRepositoryMock) ListSubscriptions() ([]models.WebhookSubscription, error) {
+	args := m.Called()
+	if v := args.Get(0); v != nil {
+		return v.([]models.WebhookSubscription), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *WebhookRepositoryMock) DeleteSubscription(id uint) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *WebhookRepositoryMock) ClaimDueDeliveries(now time.Time, limit int, ttl time.Duration) ([]models.WebhookDelivery, error) {
+	args := m.Called(now, limit, ttl)
+	if v := args.Get(0); v != nil {
+		return v.([]models.WebhookDelivery), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *WebhookRepositoryMock) ReleaseDeliveries(ids []uint) error {
+	args := m.Called(ids)
+	return args.Error(0)
+}
+
+func (m *WebhookRepositoryMock) MarkDelivered(id uint) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *WebhookRepositoryMock) MarkFailed(id uint, attempts int, nextAttemptAt time.Time, dead bool, lastErr string) error {
+	args := m.Called(id, attempts, nextAttemptAt, dead, lastErr)
+	return args.Error(0)
+}
+
+func (m *WebhookRepositoryMock) ListDeadLetters(subscriptionID uint) ([]models.WebhookDelivery, error) {
+	args := m.Called(subscriptionID)
+	if v := args.Get(0); v != nil {
+		return v.([]models.WebhookDelivery), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *WebhookRepositoryMock) ReplayDeliveries(subscriptionID uint, deliveryIDs []uint) (int64, error) {
+	args := m.Called(subscriptionID, deliveryIDs)
+	return args.Get(0).(int64), args.Error(1)
+}
@@ -0,0 +1,69 @@
+package models
+
+import "time"
+
+// Analytics reports aggregate orders created in [From, To). Revenue is
+// in the units of payment.amount and item total_price, per currency.
+
+type PeriodRevenue struct {
+	Period   time.Time `json:"period"`
+	Currency string    `json:"currency"`
+	Orders   int64     `json:"orders"`
+	Revenue  int64     `json:"revenue"`
+}
+
+type RevenueReport struct {
+	From     time.Time       `json:"from"`
+	To       time.Time       `json:"to"`
+	Interval string          `json:"interval"`
+	Points   []PeriodRevenue `json:"points"`
+}
+
+type BreakdownRow struct {
+	Value    string `json:"value"`
+	Currency string `json:"currency"`
+	Orders   int64  `json:"orders"`
+	Revenue  int64  `json:"revenue"`
+}
+
+type BreakdownReport struct {
+	From time.Time      `json:"from"`
+	To   time.Time      `json:"to"`
+	By   string         `json:"by"`
+	Rows []BreakdownRow `json:"rows"`
+}
+
+// BasketStats describes the average order of a currency: AvgItems items
+// worth AvgValue of payment.goods_total.
+type BasketStats struct {
+	Currency   string  `json:"currency"`
+	Orders     int64   `json:"orders"`
+	Items      int64   `json:"items"`
+	GoodsTotal int64   `json:"goods_total"`
+	AvgItems   float64 `json:"avg_items"`
+	AvgValue   float64 `json:"avg_value"`
+}
+
+type BasketReport struct {
+	From       time.Time     `json:"from"`
+	To         time.Time     `json:"to"`
+	Currencies []BasketStats `json:"currencies"`
+}
+
+// ProductSales sums the items of one brand or nm_id, whichever the report
+// is by; Units counts item rows and Revenue sums their total_price.
+type ProductSales struct {
+	Brand    string `json:"brand,omitempty"`
+	NmID     int    `json:"nm_id,omitempty"`
+	Currency string `json:"currency"`
+	Units    int64  `json:"units"`
+	Revenue  int64  `json:"revenue"`
+}
+
+type TopProductsReport struct {
+	From     time.Time      `json:"from"`
+	To       time.Time      `json:"to"`
+	By       string         `json:"by"`
+	Metric   string         `json:"metric"`
+	Products []ProductSales `json:"products"`
+}
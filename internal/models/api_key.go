@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// APIKey is a static credential for the HTTP and gRPC APIs. Only the
+// SHA-256 hash of the key is ever stored.
+type APIKey struct {
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	KeyHash   string    `json:"-"`
+	Scopes    []string  `json:"scopes"`
+	CreatedAt time.Time `json:"created_at"`
+}
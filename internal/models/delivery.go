@@ -1,11 +1,14 @@
 package models
 
+// Delivery is the recipient of an order. Fields tagged `pii` hold
+// customer personal data; the tag names the masking rule used in logs and
+// for callers not allowed to see it (see pii.Mask).
 type Delivery struct {
-	Name    string `json:"name" fake:"{firstname} {lastname}" validate:"required,min=2,max=100"`
-	Phone   string `json:"phone" fake:"{phone}" validate:"required,e164"`
-	Zip     string `json:"zip" fake:"{zip}" validate:"required,min=5,max=10"`
+	Name    string `json:"name" fake:"{firstname} {lastname}" validate:"required,min=2,max=100" pii:"name"`
+	Phone   string `json:"phone" fake:"{phone}" validate:"required,e164" pii:"phone"`
+	Zip     string `json:"zip" fake:"{zip}" validate:"required,min=5,max=10" pii:"redact"`
 	City    string `json:"city" fake:"{city}" validate:"required,min=2,max=50"`
-	Address string `json:"address" fake:"{streetaddress}" validate:"required,min=5,max=200"`
+	Address string `json:"address" fake:"{streetaddress}" validate:"required,min=5,max=200" pii:"redact"`
 	Region  string `json:"region" fake:"{state}" validate:"required,min=2,max=50"`
-	Email   string `json:"email" fake:"{email}" validate:"required,email"`
+	Email   string `json:"email" fake:"{email}" validate:"required,email" pii:"email"`
 }
@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+// ErasedValue replaces customer personal data removed on request.
+const ErasedValue = "[erased]"
+
+// ErasedFields lists the order fields anonymised by a customer data
+// erasure. Financial amounts are kept for accounting.
+var ErasedFields = []string{
+	"delivery.name",
+	"delivery.phone",
+	"delivery.zip",
+	"delivery.city",
+	"delivery.address",
+	"delivery.region",
+	"delivery.email",
+	"payment.transaction",
+}
+
+// ErasedDelivery is the delivery of an order whose customer data was erased.
+var ErasedDelivery = Delivery{
+	Name:    ErasedValue,
+	Phone:   ErasedValue,
+	Zip:     ErasedValue,
+	City:    ErasedValue,
+	Address: ErasedValue,
+	Region:  ErasedValue,
+	Email:   ErasedValue,
+}
+
+// ErasePersonalData anonymises the fields listed in ErasedFields.
+func (o *Order) ErasePersonalData() {
+	o.Delivery = ErasedDelivery
+	o.Payment.Transaction = ErasedValue
+}
+
+// ErasureReport describes a customer data erasure. For a dry run nothing
+// is changed and the report tells what would be.
+type ErasureReport struct {
+	CustomerID   string   `json:"customer_id"`
+	DryRun       bool     `json:"dry_run"`
+	OrderUIDs    []string `json:"order_uids"`
+	ErasedFields []string `json:"erased_fields"`
+	// Pending outbox events and webhook deliveries of the affected orders
+	// carry copies of the data and are deleted as well.
+	OutboxEvents      int64     `json:"outbox_events"`
+	WebhookDeliveries int64     `json:"webhook_deliveries"`
+	RequestedBy       string    `json:"requested_by"`
+	Reason            string    `json:"reason,omitempty"`
+	AuditID           uint      `json:"audit_id,omitempty"`
+	ErasedAt          time.Time `json:"erased_at,omitzero"`
+}
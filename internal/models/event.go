@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Order event types published to downstream consumers.
+const (
+	EventOrderStored        = "OrderStored"
+	EventOrderUpdated       = "OrderUpdated"
+	EventOrderStatusChanged = "OrderStatusChanged"
+)
+
+// EventTypes lists every event type known to the service.
+var EventTypes = []string{EventOrderStored, EventOrderUpdated, EventOrderStatusChanged}
+
+// OrderEvent is an integration event describing a change of a stored order.
+type OrderEvent struct {
+	EventID    string    `json:"event_id"`
+	EventType  string    `json:"event_type"`
+	OrderUID   string    `json:"order_uid"`
+	OccurredAt time.Time `json:"occurred_at"`
+	Order      *Order    `json:"order"`
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"log/slog"
+
+	"wb-tech-l0/internal/pii"
+)
+
+// Models that carry customer personal data implement slog.LogValuer, so
+// passing them to a logger never prints names, contacts, addresses or
+// payment transaction IDs in clear text. Values are masked by the rules in
+// their `pii` struct tags.
+
+func (d Delivery) LogValue() slog.Value {
+	m := pii.Mask(d)
+	return slog.GroupValue(
+		slog.String("name", m.Name),
+		slog.String("phone", m.Phone),
+		slog.String("email", m.Email),
+		slog.String("address", m.Address),
+		slog.String("zip", m.Zip),
+		slog.String("city", d.City),
+		slog.String("region", d.Region),
+	)
+}
+
+func (p Payment) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("transaction", pii.Mask(p).Transaction),
+		slog.String("provider", p.Provider),
+		slog.String("currency", p.Currency),
+		slog.Int("amount", p.Amount),
+	)
+}
+
+// LogValue logs an order as a short summary with masked delivery and
+// payment details; items are reduced to their count.
+func (o Order) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("order_uid", o.OrderUID),
+		slog.String("track_number", o.TrackNumber),
+		slog.String("entry", o.Entry),
+		slog.String("delivery_service", o.DeliveryService),
+		slog.Int("items", len(o.Items)),
+		slog.Any("delivery", o.Delivery),
+		slog.Any("payment", o.Payment),
+	)
+}
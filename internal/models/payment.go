@@ -1,7 +1,7 @@
 package models
 
 type Payment struct {
-	Transaction  string `json:"transaction" fake:"{uuid}" validate:"required,uuid"`
+	Transaction  string `json:"transaction" fake:"{uuid}" validate:"required,uuid" pii:"last4"`
 	RequestID    string `json:"request_id" fake:"{uuid}" validate:"omitempty,uuid"`
 	Currency     string `json:"currency" fake:"{currencyshort}" validate:"required,len=3"`
 	Provider     string `json:"provider" fake:"{randomstring:[wbpay,paypal,stripe]}" validate:"required,oneof=wbpay paypal stripe"`
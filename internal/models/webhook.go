@@ -0,0 +1,45 @@
+package models
+
+import (
+	"slices"
+	"time"
+)
+
+// Webhook delivery states. Delivered deliveries are removed, so
+// WebhookDeliveryDelivered only marks rows left by earlier versions.
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	WebhookDeliveryDead      = "dead"
+)
+
+// WebhookSubscription is an external endpoint receiving order events.
+// An empty EventTypes list subscribes to every event type.
+type WebhookSubscription struct {
+	ID         uint      `json:"id"`
+	URL        string    `json:"url"`
+	Secret     string    `json:"secret,omitempty"`
+	EventTypes []string  `json:"event_types"`
+	Active     bool      `json:"active"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Accepts reports whether the subscription wants events of the given type.
+func (s WebhookSubscription) Accepts(eventType string) bool {
+	return len(s.EventTypes) == 0 || slices.Contains(s.EventTypes, eventType)
+}
+
+// WebhookDelivery is a single attempt series of sending an event to a subscriber.
+type WebhookDelivery struct {
+	ID             uint      `json:"id"`
+	SubscriptionID uint      `json:"subscription_id"`
+	EventID        string    `json:"event_id"`
+	EventType      string    `json:"event_type"`
+	OrderUID       string    `json:"order_uid"`
+	Payload        []byte    `json:"-"`
+	Status         string    `json:"status"`
+	Attempts       int       `json:"attempts"`
+	NextAttemptAt  time.Time `json:"next_attempt_at"`
+	LastError      string    `json:"last_error,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+}
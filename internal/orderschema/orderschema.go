@@ -0,0 +1,270 @@
+// Package orderschema derives a JSON Schema of the Kafka order message from
+// models.Order. The committed order.json is served at /schema/order.json;
+// regenerate it with `go generate ./internal/orderschema` after changing
+// the models or their validate tags.
+package orderschema
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"wb-tech-l0/internal/models"
+)
+
+//go:generate go run ../../tools/orderschema_gen -o order.json
+
+// Order is the committed schema of models.Order.
+//
+//go:embed order.json
+var Order []byte
+
+// Draft is the JSON Schema dialect of the generated schema.
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+// e164Pattern is the expression the e164 validator checks phones against.
+const e164Pattern = `^\+[1-9]?[0-9]{7,14}$`
+
+// Schema is the subset of JSON Schema the models map to. Fields are
+// declared in the order they are written out.
+type Schema struct {
+	Schema      string             `json:"$schema,omitempty"`
+	Ref         string             `json:"$ref,omitempty"`
+	Title       string             `json:"title,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Type        string             `json:"type,omitempty"`
+	Format      string             `json:"format,omitempty"`
+	Pattern     string             `json:"pattern,omitempty"`
+	Enum        []any              `json:"enum,omitempty"`
+	AnyOf       []*Schema          `json:"anyOf,omitempty"`
+	Not         *Schema            `json:"not,omitempty"`
+	Const       any                `json:"const,omitempty"`
+	MinLength   *int               `json:"minLength,omitempty"`
+	MaxLength   *int               `json:"maxLength,omitempty"`
+	Minimum     *int               `json:"minimum,omitempty"`
+	Maximum     *int               `json:"maximum,omitempty"`
+	MinItems    *int               `json:"minItems,omitempty"`
+	MaxItems    *int               `json:"maxItems,omitempty"`
+	Items       *Schema            `json:"items,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	Defs        map[string]*Schema `json:"$defs,omitempty"`
+}
+
+// Generate builds the schema of models.Order and returns it formatted the
+// way order.json is committed. Every validate rule must have a mapping:
+// an unknown rule is an error, so a new constraint cannot be left out of
+// the schema unnoticed.
+func Generate() ([]byte, error) {
+	g := &generator{defs: make(map[string]*Schema)}
+	root, err := g.object(reflect.TypeFor[models.Order]())
+	if err != nil {
+		return nil, err
+	}
+	root.Schema = Draft
+	root.Title = "Order"
+	root.Description = "Order message consumed from Kafka. Generated from internal/models by `go generate ./internal/orderschema`; do not edit."
+	root.Defs = g.defs
+
+	data, err := json.MarshalIndent(root, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+type generator struct {
+	defs map[string]*Schema
+}
+
+// object describes a struct. Nested structs other than the root are put
+// in $defs and referenced.
+func (g *generator) object(t reflect.Type) (*Schema, error) {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for i := range t.NumField() {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if !f.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		prop, required, err := g.field(f.Type, f.Tag.Get("validate"))
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", t.Name(), f.Name, err)
+		}
+		s.Properties[name] = prop
+		if required {
+			s.Required = append(s.Required, name)
+		}
+	}
+	return s, nil
+}
+
+// field describes a field of type t constrained by a validate tag and
+// reports whether the field is required.
+func (g *generator) field(t reflect.Type, tag string) (*Schema, bool, error) {
+	s, err := g.typeSchema(t)
+	if err != nil {
+		return nil, false, err
+	}
+
+	rules := strings.Split(tag, ",")
+	if tag == "" {
+		rules = nil
+	}
+
+	required, omitEmpty := false, false
+	for i, rule := range rules {
+		name, _, _ := strings.Cut(rule, "=")
+		switch name {
+		case "omitempty":
+			omitEmpty = true
+		case "required":
+			required = true
+		case "dive":
+			// The remaining rules apply to the elements.
+			if s.Items == nil {
+				return nil, false, fmt.Errorf("dive on a non-slice field")
+			}
+			if err := applyRules(s.Items, rules[i+1:]); err != nil {
+				return nil, false, err
+			}
+			return s, required, nil
+		default:
+			if err := applyRules(s, []string{rule}); err != nil {
+				return nil, false, err
+			}
+		}
+	}
+
+	// The rules of an omitempty field are skipped for its zero value.
+	if omitEmpty {
+		switch s.Type {
+		case "string":
+			return &Schema{AnyOf: []*Schema{{Type: "string", Const: ""}, s}}, required, nil
+		case "integer":
+			return &Schema{AnyOf: []*Schema{{Type: "integer", Const: 0}, s}}, required, nil
+		}
+	}
+
+	// A required value must not be the zero value of its type.
+	if required {
+		switch s.Type {
+		case "string":
+			if s.MinLength == nil && s.Format == "" && s.Pattern == "" && s.Enum == nil {
+				s.MinLength = ptr(1)
+			}
+		case "integer":
+			if s.Minimum == nil && s.Enum == nil {
+				s.Not = &Schema{Const: 0}
+			}
+		}
+	}
+	return s, required, nil
+}
+
+func (g *generator) typeSchema(t reflect.Type) (*Schema, error) {
+	if t == reflect.TypeFor[time.Time]() {
+		return &Schema{Type: "string", Format: "date-time"}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}, nil
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}, nil
+	case reflect.Slice:
+		items, err := g.typeSchema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: "array", Items: items}, nil
+	case reflect.Struct:
+		if _, ok := g.defs[t.Name()]; !ok {
+			// Reserve the name first, in case the struct refers to itself.
+			g.defs[t.Name()] = nil
+			def, err := g.object(t)
+			if err != nil {
+				return nil, err
+			}
+			g.defs[t.Name()] = def
+		}
+		return &Schema{Ref: "#/$defs/" + t.Name()}, nil
+	}
+	return nil, fmt.Errorf("unsupported type %s", t)
+}
+
+// applyRules maps validate rules onto s.
+func applyRules(s *Schema, rules []string) error {
+	for _, rule := range rules {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "omitempty", "required":
+			// Presence of an element is implied by the array.
+		case "uuid":
+			s.Format = "uuid"
+		case "email":
+			s.Format = "email"
+		case "e164":
+			s.Pattern = e164Pattern
+		case "oneof":
+			for _, v := range strings.Fields(param) {
+				if s.Type == "integer" {
+					n, err := strconv.Atoi(v)
+					if err != nil {
+						return fmt.Errorf("oneof value %q: %w", v, err)
+					}
+					s.Enum = append(s.Enum, n)
+					continue
+				}
+				s.Enum = append(s.Enum, v)
+			}
+		case "min", "max", "len":
+			n, err := strconv.Atoi(param)
+			if err != nil {
+				return fmt.Errorf("%s value %q: %w", name, param, err)
+			}
+			if err := applyBound(s, name, n); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("validate rule %q has no JSON Schema mapping", rule)
+		}
+	}
+	return nil
+}
+
+// applyBound maps min, max and len to the keyword matching the type: a
+// length for strings, a value for numbers and a size for arrays.
+func applyBound(s *Schema, name string, n int) error {
+	var lower, upper **int
+	switch s.Type {
+	case "string":
+		lower, upper = &s.MinLength, &s.MaxLength
+	case "integer":
+		lower, upper = &s.Minimum, &s.Maximum
+	case "array":
+		lower, upper = &s.MinItems, &s.MaxItems
+	default:
+		return fmt.Errorf("%s on a field of type %s", name, s.Type)
+	}
+	if name != "max" {
+		*lower = ptr(n)
+	}
+	if name != "min" {
+		*upper = ptr(n)
+	}
+	return nil
+}
+
+func ptr(n int) *int { return &n }
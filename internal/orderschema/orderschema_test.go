@@ -0,0 +1,146 @@
+package orderschema_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"wb-tech-l0/internal/models"
+	"wb-tech-l0/internal/orderschema"
+	vpkg "wb-tech-l0/internal/validator"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrderSchemaIsUpToDate(t *testing.T) {
+	generated, err := orderschema.Generate()
+	require.NoError(t, err)
+	assert.Equal(t, string(generated), string(orderschema.Order),
+		"order.json is stale, run `go generate ./internal/orderschema`")
+}
+
+func validOrder() models.Order {
+	return models.Order{
+		OrderUID:    "b563feb7-b2b8-4b6a-9f3e-1c2d3e4f5a6b",
+		TrackNumber: "WBILMTESTTRACK",
+		Entry:       "WBIL",
+		Delivery: models.Delivery{
+			Name:    "Test Testov",
+			Phone:   "+9720000000",
+			Zip:     "2639809",
+			City:    "Kiryat Mozkin",
+			Address: "Ploshad Mira 15",
+			Region:  "Kraiot",
+			Email:   "test@gmail.com",
+		},
+		Payment: models.Payment{
+			Transaction:  "5c1d4e7a-8f2b-4c3d-9e0f-a1b2c3d4e5f6",
+			Currency:     "USD",
+			Provider:     "wbpay",
+			Amount:       1817,
+			PaymentDt:    1637907727,
+			Bank:         "alpha",
+			DeliveryCost: 1500,
+			GoodsTotal:   317,
+		},
+		Items: []models.Item{{
+			ChrtID:      9934930,
+			TrackNumber: "WBILMTESTTRACK",
+			Price:       453,
+			RID:         "ab421908-7a76-4ae0-b000-000000000001",
+			Name:        "Mascaras",
+			Sale:        30,
+			Size:        "0",
+			TotalPrice:  317,
+			NmID:        2389212,
+			Brand:       "Vivienne Sabo",
+			Status:      202,
+		}},
+		Locale:          "en",
+		CustomerID:      "0f8e7d6c-5b4a-4392-8170-6f5e4d3c2b1a",
+		DeliveryService: "meest",
+		Shardkey:        "9",
+		SmID:            99,
+		DateCreated:     time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+		OofShard:        "1",
+	}
+}
+
+// The schema must accept exactly the orders the consumer's validator
+// accepts, at least for the constraints it can express.
+func TestOrderSchema_AgreesWithValidator(t *testing.T) {
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(orderschema.Order))
+	require.NoError(t, err)
+	c := jsonschema.NewCompiler()
+	c.AssertFormat()
+	require.NoError(t, c.AddResource("order.json", doc))
+	schema, err := c.Compile("order.json")
+	require.NoError(t, err)
+
+	v := vpkg.NewValidator()
+
+	tests := []struct {
+		name   string
+		mutate func(o *models.Order)
+		valid  bool
+	}{
+		{"valid", func(o *models.Order) {}, true},
+		{"empty optional request_id", func(o *models.Order) { o.Payment.RequestID = "" }, true},
+		{"uuid request_id", func(o *models.Order) { o.Payment.RequestID = "8e2c1a4b-3d5f-4e6a-9b7c-0d1e2f3a4b5c" }, true},
+		{"invalid request_id", func(o *models.Order) { o.Payment.RequestID = "req-1" }, false},
+		{"order_uid not a uuid", func(o *models.Order) { o.OrderUID = "b563feb7b2b84b6test" }, false},
+		{"unknown locale", func(o *models.Order) { o.Locale = "de" }, false},
+		{"unknown delivery service", func(o *models.Order) { o.DeliveryService = "post" }, false},
+		{"short track number", func(o *models.Order) { o.TrackNumber = "WB" }, false},
+		{"long internal signature", func(o *models.Order) { o.InternalSignature = string(make([]byte, 51)) }, false},
+		{"sm_id below minimum", func(o *models.Order) { o.SmID = 0 }, false},
+		{"empty shardkey", func(o *models.Order) { o.Shardkey = "" }, false},
+		{"phone not e164", func(o *models.Order) { o.Delivery.Phone = "89001234567" }, false},
+		{"invalid email", func(o *models.Order) { o.Delivery.Email = "test" }, false},
+		{"currency length", func(o *models.Order) { o.Payment.Currency = "US" }, false},
+		{"unknown provider", func(o *models.Order) { o.Payment.Provider = "cash" }, false},
+		{"zero payment_dt", func(o *models.Order) { o.Payment.PaymentDt = 0 }, false},
+		{"negative custom fee", func(o *models.Order) { o.Payment.CustomFee = -1 }, false},
+		{"no items", func(o *models.Order) { o.Items = []models.Item{} }, false},
+		{"item status", func(o *models.Order) { o.Items[0].Status = 203 }, false},
+		{"item sale above maximum", func(o *models.Order) { o.Items[0].Sale = 101 }, false},
+		{"item rid not a uuid", func(o *models.Order) { o.Items[0].RID = "ab4219087a764ae0btest" }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := validOrder()
+			tt.mutate(&order)
+
+			data, err := json.Marshal(order)
+			require.NoError(t, err)
+			instance, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+			require.NoError(t, err)
+
+			schemaErr := schema.Validate(instance)
+			validatorErr := v.Validate(order)
+			assert.Equal(t, tt.valid, validatorErr == nil, "validator: %v", validatorErr)
+			assert.Equal(t, tt.valid, schemaErr == nil, "schema: %v", schemaErr)
+		})
+	}
+}
+
+func TestOrderSchema_RequiresFields(t *testing.T) {
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(orderschema.Order))
+	require.NoError(t, err)
+	c := jsonschema.NewCompiler()
+	require.NoError(t, c.AddResource("order.json", doc))
+	schema, err := c.Compile("order.json")
+	require.NoError(t, err)
+
+	var order map[string]any
+	data, err := json.Marshal(validOrder())
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &order))
+	require.NoError(t, schema.Validate(order))
+	delete(order, "payment")
+
+	assert.Error(t, schema.Validate(order))
+}
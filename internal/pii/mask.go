@@ -0,0 +1,66 @@
+// Package pii contains helpers that mask customer personal data before it
+// leaves the service through logs or restricted API responses.
+package pii
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+const maskRune = '*'
+
+// MaskPhone keeps the first two and the last four characters of a phone
+// number: "+79161234567" -> "+7******4567". Short values are fully masked.
+func MaskPhone(phone string) string {
+	return maskMiddle(phone, 2, 4)
+}
+
+// MaskEmail keeps the first character of the local part and the domain:
+// "john@example.com" -> "j***@example.com".
+func MaskEmail(email string) string {
+	local, domain, ok := strings.Cut(email, "@")
+	if !ok {
+		return MaskAll(email)
+	}
+	if local == "" {
+		return "***@" + domain
+	}
+	r, _ := utf8.DecodeRuneInString(local)
+	return string(r) + "***@" + domain
+}
+
+// MaskName keeps the initial of every word: "John Smith" -> "J*** S***".
+func MaskName(name string) string {
+	words := strings.Fields(name)
+	for i, w := range words {
+		r, _ := utf8.DecodeRuneInString(w)
+		words[i] = string(r) + "***"
+	}
+	return strings.Join(words, " ")
+}
+
+// MaskTail keeps only the last n characters: MaskTail("b563feb7b2b84b6", 4)
+// -> "***********84b6". Values not longer than n are fully masked.
+func MaskTail(s string, n int) string {
+	return maskMiddle(s, 0, n)
+}
+
+// MaskAll replaces a non-empty value with a fixed placeholder, hiding its
+// length as well as its content.
+func MaskAll(s string) string {
+	if s == "" {
+		return ""
+	}
+	return "***"
+}
+
+func maskMiddle(s string, head, tail int) string {
+	runes := []rune(s)
+	if len(runes) <= head+tail {
+		return strings.Repeat(string(maskRune), len(runes))
+	}
+	for i := head; i < len(runes)-tail; i++ {
+		runes[i] = maskRune
+	}
+	return string(runes)
+}
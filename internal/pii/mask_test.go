@@ -0,0 +1,72 @@
+package pii
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMasking(t *testing.T) {
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"phone", MaskPhone("+79161234567"), "+7******4567"},
+		{"short phone", MaskPhone("12345"), "*****"},
+		{"email", MaskEmail("john@example.com"), "j***@example.com"},
+		{"email without local part", MaskEmail("@example.com"), "***@example.com"},
+		{"not an email", MaskEmail("john"), "***"},
+		{"name", MaskName("John  Smith"), "J*** S***"},
+		{"cyrillic name", MaskName("Иван Петров"), "И*** П***"},
+		{"tail", MaskTail("b563feb7b2b84b6test", 4), "***************test"},
+		{"short tail", MaskTail("abc", 4), "***"},
+		{"all", MaskAll("Ploshad Mira 15"), "***"},
+		{"empty", MaskAll(""), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.got)
+		})
+	}
+}
+
+type testContact struct {
+	Name  string `pii:"name"`
+	Phone string `pii:"phone"`
+	Note  string `pii:"unknown-rule"`
+	City  string
+}
+
+type testRecord struct {
+	ID       string
+	Contact  testContact
+	Previous *testContact
+	History  []testContact
+	Card     string `pii:"last4"`
+}
+
+func TestMask(t *testing.T) {
+	rec := testRecord{
+		ID:       "rec-1",
+		Contact:  testContact{Name: "John Smith", Phone: "+79161234567", Note: "call after 6", City: "Moscow"},
+		Previous: &testContact{Name: "Jane"},
+		History:  []testContact{{Phone: "+79160000000"}},
+		Card:     "4111111111111111",
+	}
+
+	got := Mask(rec)
+
+	assert.Equal(t, testRecord{
+		ID:       "rec-1",
+		Contact:  testContact{Name: "J*** S***", Phone: "+7******4567", Note: "***", City: "Moscow"},
+		Previous: &testContact{Name: "J***"},
+		History:  []testContact{{Phone: "+7******0000"}},
+		Card:     "************1111",
+	}, got)
+
+	// The original, including values behind pointers and slices, is untouched.
+	assert.Equal(t, "John Smith", rec.Contact.Name)
+	assert.Equal(t, "Jane", rec.Previous.Name)
+	assert.Equal(t, "+79160000000", rec.History[0].Phone)
+}
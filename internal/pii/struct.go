@@ -0,0 +1,79 @@
+package pii
+
+import "reflect"
+
+// Tag is the struct tag that declares how a string field is masked:
+//
+//	Phone string `json:"phone" pii:"phone"`
+//
+// Rules: name, phone, email, last4 (keep the last four characters) and
+// redact (hide the value completely).
+const Tag = "pii"
+
+var rules = map[string]func(string) string{
+	"name":   MaskName,
+	"phone":  MaskPhone,
+	"email":  MaskEmail,
+	"last4":  func(s string) string { return MaskTail(s, 4) },
+	"redact": MaskAll,
+}
+
+// Mask returns a deep copy of v with every string field tagged `pii`
+// masked by its rule. Nested structs, pointers and slices are followed;
+// v itself is never modified. A field with an unknown rule is redacted,
+// so a typo in a tag cannot leak data.
+func Mask[T any](v T) T {
+	maskValue(reflect.ValueOf(&v).Elem())
+	return v
+}
+
+func maskValue(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return
+		}
+		cp := reflect.New(v.Type().Elem())
+		cp.Elem().Set(v.Elem())
+		maskValue(cp.Elem())
+		v.Set(cp)
+
+	case reflect.Slice:
+		if v.IsNil() {
+			return
+		}
+		cp := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		reflect.Copy(cp, v)
+		for i := range cp.Len() {
+			maskValue(cp.Index(i))
+		}
+		v.Set(cp)
+
+	case reflect.Array:
+		for i := range v.Len() {
+			maskValue(v.Index(i))
+		}
+
+	case reflect.Struct:
+		t := v.Type()
+		for i := range t.NumField() {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			fv := v.Field(i)
+			if rule, ok := f.Tag.Lookup(Tag); ok && fv.Kind() == reflect.String {
+				fv.SetString(apply(rule, fv.String()))
+				continue
+			}
+			maskValue(fv)
+		}
+	}
+}
+
+func apply(rule, s string) string {
+	if mask, ok := rules[rule]; ok {
+		return mask(s)
+	}
+	return MaskAll(s)
+}
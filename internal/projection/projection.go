@@ -0,0 +1,41 @@
+// Package projection shapes orders for the caller before they leave the
+// service. Callers with the pii:read scope see orders as stored; everyone
+// else, including anonymous visitors of public pages, gets personal data
+// masked by the rules declared in the models' `pii` struct tags.
+package projection
+
+import (
+	"context"
+
+	"wb-tech-l0/internal/auth"
+	"wb-tech-l0/internal/models"
+	"wb-tech-l0/internal/pii"
+)
+
+// CanViewPII reports whether the principal in ctx may see personal data.
+func CanViewPII(ctx context.Context) bool {
+	p := auth.PrincipalFromContext(ctx)
+	return p != nil && p.HasScope(auth.ScopePIIRead)
+}
+
+// Order returns the order as the caller in ctx may see it. The original
+// is never modified, so it is safe to pass cached values.
+func Order(ctx context.Context, o *models.Order) *models.Order {
+	if o == nil || CanViewPII(ctx) {
+		return o
+	}
+	masked := pii.Mask(*o)
+	return &masked
+}
+
+// Orders applies Order to every element, returning a new slice.
+func Orders(ctx context.Context, orders []*models.Order) []*models.Order {
+	if CanViewPII(ctx) {
+		return orders
+	}
+	out := make([]*models.Order, len(orders))
+	for i, o := range orders {
+		out[i] = Order(ctx, o)
+	}
+	return out
+}
@@ -0,0 +1,79 @@
+package projection
+
+import (
+	"context"
+	"testing"
+
+	"wb-tech-l0/internal/auth"
+	"wb-tech-l0/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testOrder() *models.Order {
+	return &models.Order{
+		OrderUID: "uid-1",
+		Delivery: models.Delivery{
+			Name:    "John Smith",
+			Phone:   "+79161234567",
+			Zip:     "125009",
+			City:    "Moscow",
+			Address: "Tverskaya 1",
+			Region:  "Moscow",
+			Email:   "john@example.com",
+		},
+		Payment: models.Payment{Transaction: "b563feb7b2b84b6test", Currency: "RUB", Amount: 1817},
+		Items:   []models.Item{{ChrtID: 9934930, Name: "Mascaras", Status: 202}},
+	}
+}
+
+func withScopes(scopes ...string) context.Context {
+	return auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "test", Scopes: scopes})
+}
+
+func TestOrder_MasksPersonalData(t *testing.T) {
+	for name, ctx := range map[string]context.Context{
+		"anonymous":     context.Background(),
+		"support agent": withScopes(auth.ScopeOrdersRead),
+	} {
+		t.Run(name, func(t *testing.T) {
+			original := testOrder()
+			got := Order(ctx, original)
+
+			assert.Equal(t, models.Delivery{
+				Name:    "J*** S***",
+				Phone:   "+7******4567",
+				Zip:     "***",
+				City:    "Moscow",
+				Address: "***",
+				Region:  "Moscow",
+				Email:   "j***@example.com",
+			}, got.Delivery)
+			assert.Equal(t, "***************test", got.Payment.Transaction)
+			assert.Equal(t, 1817, got.Payment.Amount)
+			assert.Equal(t, original.Items, got.Items)
+
+			assert.Equal(t, testOrder(), original, "the original order must not change")
+		})
+	}
+}
+
+func TestOrder_FullAccess(t *testing.T) {
+	for name, ctx := range map[string]context.Context{
+		"pii reader": withScopes(auth.ScopeOrdersRead, auth.ScopePIIRead),
+		"admin":      withScopes(auth.ScopeAdmin),
+	} {
+		t.Run(name, func(t *testing.T) {
+			o := testOrder()
+			assert.Same(t, o, Order(ctx, o))
+		})
+	}
+}
+
+func TestOrders(t *testing.T) {
+	got := Orders(withScopes(auth.ScopeOrdersRead), []*models.Order{testOrder(), testOrder()})
+	for _, o := range got {
+		assert.Equal(t, "+7******4567", o.Delivery.Phone)
+	}
+	assert.Nil(t, Order(context.Background(), nil))
+}
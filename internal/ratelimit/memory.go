@@ -0,0 +1,103 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often Memory drops the buckets of idle clients.
+const sweepInterval = time.Minute
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Memory is a Limiter keeping the buckets in process memory. Each replica
+// enforces its own limit, so use Redis when running more than one.
+type Memory struct {
+	limits Limits
+	now    func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+var _ Limiter = (*Memory)(nil)
+
+// NewMemory creates an in-memory limiter; the limits must be valid.
+func NewMemory(limits Limits) *Memory {
+	return &Memory{
+		limits:    limits,
+		now:       time.Now,
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow takes a token from the bucket of key. It never fails.
+func (m *Memory) Allow(_ context.Context, key string) (Result, error) {
+	now := m.now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sweep(now)
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(m.limits.Burst), last: now}
+		m.buckets[key] = b
+	}
+
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = min(float64(m.limits.Burst), b.tokens+elapsed.Seconds()*m.limits.Rate)
+		b.last = now
+	}
+
+	var res Result
+	b.tokens, res = take(m.limits, b.tokens)
+	return res, nil
+}
+
+// Peek reports the state of the bucket of key. It never fails.
+func (m *Memory) Peek(_ context.Context, key string) (Result, error) {
+	now := m.now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tokens := float64(m.limits.Burst)
+	if b, ok := m.buckets[key]; ok {
+		tokens = b.tokens
+		if elapsed := now.Sub(b.last); elapsed > 0 {
+			tokens = min(float64(m.limits.Burst), tokens+elapsed.Seconds()*m.limits.Rate)
+		}
+	}
+	return peek(m.limits, tokens), nil
+}
+
+// sweep forgets buckets that have refilled completely: a new bucket for
+// the same key would start full anyway. Called with mu held.
+func (m *Memory) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < sweepInterval {
+		return
+	}
+	m.lastSweep = now
+
+	idle := m.limits.refillTime()
+	for key, b := range m.buckets {
+		if now.Sub(b.last) >= idle {
+			delete(m.buckets, key)
+		}
+	}
+}
+
+// Len returns the number of tracked clients.
+func (m *Memory) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.buckets)
+}
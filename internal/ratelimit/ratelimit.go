@@ -0,0 +1,92 @@
+// Package ratelimit throttles clients with a token bucket.
+//
+// Every client key owns a bucket holding up to Burst tokens that refills
+// at Rate tokens per second; a request takes one token and is rejected
+// when the bucket is empty. Memory keeps the buckets in the process, Redis
+// keeps them in Redis so that replicas share one limit per client.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+)
+
+// Limits configures the token bucket.
+type Limits struct {
+	// Rate is the number of tokens added per second.
+	Rate float64
+	// Burst is the bucket capacity: how many requests a client may send at
+	// once after being idle.
+	Burst int
+}
+
+// Validate reports whether the limits describe a usable bucket.
+func (l Limits) Validate() error {
+	if !(l.Rate > 0) || math.IsInf(l.Rate, 0) {
+		return errors.New("ratelimit: rate must be a positive number")
+	}
+	if l.Burst < 1 {
+		return errors.New("ratelimit: burst must be at least 1")
+	}
+	return nil
+}
+
+// refillTime is how long an empty bucket takes to become full again.
+func (l Limits) refillTime() time.Duration {
+	return time.Duration(float64(l.Burst) / l.Rate * float64(time.Second))
+}
+
+// Result describes the outcome of one Allow call.
+type Result struct {
+	Allowed bool
+	// Limit is the bucket capacity.
+	Limit int
+	// Remaining is the number of whole tokens left after this request.
+	Remaining int
+	// RetryAfter is how long a rejected client has to wait for a token;
+	// zero when the request was allowed.
+	RetryAfter time.Duration
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+}
+
+// Limiter decides whether the client identified by key may make another
+// request.
+type Limiter interface {
+	Allow(ctx context.Context, key string) (Result, error)
+	// Peek reports whether Allow would let the client through, without
+	// taking a token.
+	Peek(ctx context.Context, key string) (Result, error)
+}
+
+// take applies one request to a bucket holding tokens and returns the
+// new token count along with the result.
+func take(l Limits, tokens float64) (float64, Result) {
+	res := Result{Limit: l.Burst}
+	if tokens >= 1 {
+		tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = seconds((1 - tokens) / l.Rate)
+	}
+	res.Remaining = int(math.Floor(tokens))
+	res.Reset = seconds((float64(l.Burst) - tokens) / l.Rate)
+	return tokens, res
+}
+
+// peek describes a bucket holding tokens without taking from it.
+func peek(l Limits, tokens float64) Result {
+	res := Result{Limit: l.Burst, Allowed: tokens >= 1}
+	if !res.Allowed {
+		res.RetryAfter = seconds((1 - tokens) / l.Rate)
+	}
+	res.Remaining = int(math.Floor(tokens))
+	res.Reset = seconds((float64(l.Burst) - tokens) / l.Rate)
+	return res
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Ceil(s * float64(time.Second)))
+}
@@ -0,0 +1,150 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock is a settable time source.
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newLimiters(t *testing.T, limits Limits, clock *fakeClock) map[string]Limiter {
+	t.Helper()
+
+	mem := NewMemory(limits)
+	mem.now = clock.now
+
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	rl := NewRedis(client, limits)
+	rl.now = clock.now
+
+	return map[string]Limiter{"memory": mem, "redis": rl}
+}
+
+func TestLimiter_TokenBucket(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1_700_000_000, 0)}
+
+	for name, l := range newLimiters(t, Limits{Rate: 2, Burst: 3}, clock) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			// The burst is available at once.
+			for want := 2; want >= 0; want-- {
+				res, err := l.Allow(ctx, "client-a")
+				require.NoError(t, err)
+				assert.True(t, res.Allowed)
+				assert.Equal(t, 3, res.Limit)
+				assert.Equal(t, want, res.Remaining)
+				assert.Zero(t, res.RetryAfter)
+			}
+
+			res, err := l.Allow(ctx, "client-a")
+			require.NoError(t, err)
+			assert.False(t, res.Allowed)
+			assert.Equal(t, 0, res.Remaining)
+			assert.Equal(t, 500*time.Millisecond, res.RetryAfter)
+			assert.Equal(t, 1500*time.Millisecond, res.Reset)
+
+			// Other clients have their own bucket.
+			res, err = l.Allow(ctx, "client-b")
+			require.NoError(t, err)
+			assert.True(t, res.Allowed)
+
+			// One token is back after 1/rate.
+			clock.advance(500 * time.Millisecond)
+			res, err = l.Allow(ctx, "client-a")
+			require.NoError(t, err)
+			assert.True(t, res.Allowed)
+			assert.Equal(t, 0, res.Remaining)
+
+			// The bucket never holds more than the burst.
+			clock.advance(time.Hour)
+			res, err = l.Allow(ctx, "client-a")
+			require.NoError(t, err)
+			assert.True(t, res.Allowed)
+			assert.Equal(t, 2, res.Remaining)
+		})
+	}
+}
+
+func TestLimiter_Peek(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1_700_000_000, 0)}
+
+	for name, l := range newLimiters(t, Limits{Rate: 2, Burst: 2}, clock) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			res, err := l.Peek(ctx, "client-a")
+			require.NoError(t, err)
+			assert.True(t, res.Allowed, "an unknown bucket is full")
+			assert.Equal(t, 2, res.Remaining)
+
+			for range 2 {
+				_, err := l.Allow(ctx, "client-a")
+				require.NoError(t, err)
+			}
+			for range 2 {
+				res, err = l.Peek(ctx, "client-a")
+				require.NoError(t, err)
+				assert.False(t, res.Allowed)
+				assert.Equal(t, 500*time.Millisecond, res.RetryAfter)
+			}
+
+			// Peeking takes nothing: the refilled token is still there.
+			clock.advance(500 * time.Millisecond)
+			res, err = l.Peek(ctx, "client-a")
+			require.NoError(t, err)
+			assert.True(t, res.Allowed)
+			res, err = l.Allow(ctx, "client-a")
+			require.NoError(t, err)
+			assert.True(t, res.Allowed)
+			assert.Equal(t, 0, res.Remaining)
+		})
+	}
+}
+
+func TestMemory_SweepsIdleBuckets(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1_700_000_000, 0)}
+	m := NewMemory(Limits{Rate: 1, Burst: 5})
+	m.now = clock.now
+	m.lastSweep = clock.t
+	ctx := context.Background()
+
+	_, _ = m.Allow(ctx, "idle")
+	clock.advance(sweepInterval)
+	_, _ = m.Allow(ctx, "active")
+
+	assert.Equal(t, 1, m.Len())
+}
+
+func TestRedis_BucketsExpire(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+
+	_, err := NewRedis(client, Limits{Rate: 10, Burst: 5}).Allow(context.Background(), "client")
+	require.NoError(t, err)
+
+	key := DefaultRedisPrefix + "client"
+	require.True(t, mr.Exists(key))
+	assert.Positive(t, mr.TTL(key))
+	mr.FastForward(2 * time.Second)
+	assert.False(t, mr.Exists(key))
+}
+
+func TestLimits_Validate(t *testing.T) {
+	assert.NoError(t, Limits{Rate: 0.5, Burst: 1}.Validate())
+	assert.Error(t, Limits{Rate: 0, Burst: 1}.Validate())
+	assert.Error(t, Limits{Rate: 1, Burst: 0}.Validate())
+}
@@ -0,0 +1,131 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// DefaultRedisPrefix namespaces the bucket keys in Redis.
+const DefaultRedisPrefix = "ratelimit:"
+
+// tokenBucket refills and takes from the bucket in one atomic step. The
+// bucket is a hash of the token count and the time of the last update
+// (ms), and expires once it would be full again.
+//
+// KEYS[1] bucket key; ARGV: rate (tokens/ms), burst, now (ms).
+// Returns the token count after the request as a string, to keep the
+// fraction, and 1 if the request was allowed.
+var tokenBucket = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local state = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+	tokens = burst
+	ts = now
+end
+if now > ts then
+	tokens = math.min(burst, tokens + (now - ts) * rate)
+	ts = now
+end
+
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "ts", ts)
+redis.call("PEXPIRE", KEYS[1], math.ceil((burst - tokens) / rate) + 1000)
+return {tostring(tokens), allowed}
+`)
+
+// Redis is a Limiter keeping the buckets in Redis, so that every replica
+// using the same Redis enforces one shared limit per client.
+//
+// The replicas' clocks are used for refilling, so they should be kept in
+// sync; skew only shifts refills, it never grants extra tokens.
+type Redis struct {
+	client *redis.Client
+	limits Limits
+	prefix string
+	now    func() time.Time
+}
+
+var _ Limiter = (*Redis)(nil)
+
+// NewRedis creates a Redis backed limiter; the limits must be valid.
+func NewRedis(client *redis.Client, limits Limits) *Redis {
+	return &Redis{
+		client: client,
+		limits: limits,
+		prefix: DefaultRedisPrefix,
+		now:    time.Now,
+	}
+}
+
+// Allow takes a token from the bucket of key.
+func (r *Redis) Allow(ctx context.Context, key string) (Result, error) {
+	args := []any{
+		strconv.FormatFloat(r.limits.Rate/1000, 'g', -1, 64),
+		r.limits.Burst,
+		r.now().UnixMilli(),
+	}
+	reply, err := tokenBucket.Run(ctx, r.client, []string{r.prefix + key}, args...).Slice()
+	if err != nil {
+		return Result{}, fmt.Errorf("ratelimit: redis: %w", err)
+	}
+	if len(reply) != 2 {
+		return Result{}, fmt.Errorf("ratelimit: unexpected script reply %v", reply)
+	}
+
+	raw, _ := reply[0].(string)
+	tokens, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return Result{}, fmt.Errorf("ratelimit: unexpected token count %q", raw)
+	}
+	allowed, _ := reply[1].(int64)
+
+	// The script already took the token, so only the result is computed
+	// here: give the token back for take to remove it again.
+	if allowed == 1 {
+		tokens++
+	}
+	_, res := take(r.limits, tokens)
+	return res, nil
+}
+
+// Peek reads the bucket of key and refills it locally, without writing
+// it back: a single HMGET.
+func (r *Redis) Peek(ctx context.Context, key string) (Result, error) {
+	state, err := r.client.HMGet(ctx, r.prefix+key, "tokens", "ts").Result()
+	if err != nil {
+		return Result{}, fmt.Errorf("ratelimit: redis: %w", err)
+	}
+
+	tokens := float64(r.limits.Burst)
+	raw, _ := state[0].(string)
+	rawTS, _ := state[1].(string)
+	if raw != "" && rawTS != "" {
+		stored, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return Result{}, fmt.Errorf("ratelimit: unexpected token count %q", raw)
+		}
+		ts, err := strconv.ParseFloat(rawTS, 64)
+		if err != nil {
+			return Result{}, fmt.Errorf("ratelimit: unexpected bucket time %q", rawTS)
+		}
+		tokens = stored
+		if now := float64(r.now().UnixMilli()); now > ts {
+			tokens = min(float64(r.limits.Burst), tokens+float64(now-ts)*r.limits.Rate/1000)
+		}
+	}
+	return peek(r.limits, tokens), nil
+}
@@ -2,25 +2,50 @@ package cache
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
-	"log"
+	"log/slog"
 	"time"
 
+	"wb-tech-l0/internal/fieldcrypt"
+	"wb-tech-l0/internal/logging"
+	"wb-tech-l0/internal/metrics"
 	"wb-tech-l0/internal/models"
+	"wb-tech-l0/internal/telemetry"
 
 	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = telemetry.Tracer("wb-tech-l0/internal/repository/cache")
+
+// startSpan opens a client span for a single Redis operation.
+func startSpan(ctx context.Context, operation string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append(attrs,
+		attribute.String("db.system", "redis"),
+		attribute.String("db.operation.name", operation),
+	)
+	return tracer.Start(ctx, "cache."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+}
+
 type OrderCache struct {
 	client *redis.Client
 	ttl    time.Duration
+	codec  codec
+	logger *slog.Logger
 }
 
-func NewOrderCache(client *redis.Client, ttl time.Duration) *OrderCache {
+// NewOrderCache creates the cache. With a non-nil keyring cached orders are
+// encrypted.
+func NewOrderCache(client *redis.Client, ttl time.Duration, keys *fieldcrypt.Keyring, logger *slog.Logger) *OrderCache {
 	return &OrderCache{
 		client: client,
 		ttl:    ttl,
+		codec:  codec{keys: keys},
+		logger: logger.With("component", "order_cache"),
 	}
 }
 
@@ -28,49 +53,162 @@ func (c *OrderCache) key(orderUID string) string {
 	return "order:" + orderUID
 }
 
-func (c *OrderCache) Set(orderUID string, order *models.Order) {
-	ctx := context.Background()
+func (c *OrderCache) Set(ctx context.Context, orderUID string, order *models.Order) {
+	ctx, span := startSpan(ctx, "set", attribute.String("order.uid", orderUID))
+	defer span.End()
 
-	data, err := json.Marshal(order)
+	data, err := c.codec.encode(order)
 	if err != nil {
-		log.Printf("OrderCache: failed to marshal order %s: %v", orderUID, err)
+		metrics.CacheErrors.WithLabelValues("set").Inc()
+		c.logger.ErrorContext(ctx, "failed to encode order", logging.KeyOrderUID, orderUID, logging.Err(err))
+		telemetry.RecordError(span, err)
 		return
 	}
+	metrics.CachePayloadBytes.WithLabelValues("set").Observe(float64(len(data)))
 
 	if err := c.client.Set(ctx, c.key(orderUID), data, c.ttl).Err(); err != nil {
-		log.Printf("OrderCache: failed to set order %s in Redis: %v", orderUID, err)
+		metrics.CacheErrors.WithLabelValues("set").Inc()
+		c.logger.WarnContext(ctx, "failed to set order in Redis", logging.KeyOrderUID, orderUID, logging.Err(err))
+		telemetry.RecordError(span, err)
 	}
 }
 
-func (c *OrderCache) Get(orderUID string) (*models.Order, bool) {
-	ctx := context.Background()
+func (c *OrderCache) Get(ctx context.Context, orderUID string) (*models.Order, bool) {
+	ctx, span := startSpan(ctx, "get", attribute.String("order.uid", orderUID))
+	defer span.End()
 
 	val, err := c.client.Get(ctx, c.key(orderUID)).Result()
 	if errors.Is(err, redis.Nil) {
+		metrics.CacheMisses.Inc()
+		span.SetAttributes(attribute.Bool("cache.hit", false))
 		return nil, false
 	}
 	if err != nil {
-		log.Printf("OrderCache: failed to get order %s from Redis: %v", orderUID, err)
+		metrics.CacheErrors.WithLabelValues("get").Inc()
+		c.logger.WarnContext(ctx, "failed to get order from Redis", logging.KeyOrderUID, orderUID, logging.Err(err))
+		telemetry.RecordError(span, err)
 		return nil, false
 	}
+	metrics.CachePayloadBytes.WithLabelValues("get").Observe(float64(len(val)))
 
-	var order models.Order
-	if err := json.Unmarshal([]byte(val), &order); err != nil {
-		log.Printf("OrderCache: failed to unmarshal order %s from Redis: %v", orderUID, err)
+	order, err := c.codec.decode(val)
+	if err != nil {
+		metrics.CacheErrors.WithLabelValues("get").Inc()
+		c.logger.WarnContext(ctx, "failed to decode cached order", logging.KeyOrderUID, orderUID, logging.Err(err))
+		telemetry.RecordError(span, err)
 		return nil, false
 	}
 
-	return &order, true
+	metrics.CacheHits.Inc()
+	span.SetAttributes(attribute.Bool("cache.hit", true))
+	return order, true
+}
+
+// GetMany looks up several orders with a single MGET and returns the ones
+// found, keyed by order UID.
+func (c *OrderCache) GetMany(ctx context.Context, orderUIDs []string) map[string]*models.Order {
+	found := make(map[string]*models.Order, len(orderUIDs))
+	if len(orderUIDs) == 0 {
+		return found
+	}
+
+	ctx, span := startSpan(ctx, "mget", attribute.Int("cache.keys", len(orderUIDs)))
+	defer span.End()
+
+	keys := make([]string, len(orderUIDs))
+	for i, uid := range orderUIDs {
+		keys[i] = c.key(uid)
+	}
+
+	vals, err := c.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		metrics.CacheErrors.WithLabelValues("mget").Inc()
+		c.logger.WarnContext(ctx, "failed to get orders from Redis", "count", len(keys), logging.Err(err))
+		telemetry.RecordError(span, err)
+		return found
+	}
+
+	for i, val := range vals {
+		s, ok := val.(string)
+		if !ok {
+			metrics.CacheMisses.Inc()
+			continue
+		}
+		metrics.CachePayloadBytes.WithLabelValues("mget").Observe(float64(len(s)))
+
+		order, err := c.codec.decode(s)
+		if err != nil {
+			metrics.CacheErrors.WithLabelValues("mget").Inc()
+			c.logger.WarnContext(ctx, "failed to decode cached order", logging.KeyOrderUID, orderUIDs[i], logging.Err(err))
+			continue
+		}
+		metrics.CacheHits.Inc()
+		found[orderUIDs[i]] = order
+	}
+
+	span.SetAttributes(attribute.Int("cache.hits", len(found)))
+	return found
+}
+
+// SetMany stores several orders in one pipelined round trip.
+func (c *OrderCache) SetMany(ctx context.Context, orders []*models.Order) {
+	if len(orders) == 0 {
+		return
+	}
+
+	ctx, span := startSpan(ctx, "mset", attribute.Int("cache.keys", len(orders)))
+	defer span.End()
+
+	pipe := c.client.Pipeline()
+	for _, order := range orders {
+		data, err := c.codec.encode(order)
+		if err != nil {
+			metrics.CacheErrors.WithLabelValues("mset").Inc()
+			c.logger.ErrorContext(ctx, "failed to encode order", logging.KeyOrderUID, order.OrderUID, logging.Err(err))
+			continue
+		}
+		metrics.CachePayloadBytes.WithLabelValues("mset").Observe(float64(len(data)))
+		pipe.Set(ctx, c.key(order.OrderUID), data, c.ttl)
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		metrics.CacheErrors.WithLabelValues("mset").Inc()
+		c.logger.WarnContext(ctx, "failed to set orders in Redis", "count", len(orders), logging.Err(err))
+		telemetry.RecordError(span, err)
+	}
+}
+
+func (c *OrderCache) Delete(ctx context.Context, orderUID string) error {
+	ctx, span := startSpan(ctx, "del", attribute.String("order.uid", orderUID))
+	defer span.End()
+
+	if err := c.client.Del(ctx, c.key(orderUID)).Err(); err != nil {
+		metrics.CacheErrors.WithLabelValues("delete").Inc()
+		telemetry.RecordError(span, err)
+		return err
+	}
+	return nil
 }
 
+// Size counts the cached orders. The Redis DB is shared with other keys
+// (the rate limiter's buckets), so order keys are counted with SCAN
+// rather than DBSIZE.
 func (c *OrderCache) Size() int {
 	ctx := context.Background()
 
-	n, err := c.client.DBSize(ctx).Result()
-	if err != nil {
-		log.Printf("OrderCache: failed to get DB size from Redis: %v", err)
+	n := 0
+	iter := c.client.Scan(ctx, 0, c.key("*"), sizeScanCount).Iterator()
+	for iter.Next(ctx) {
+		n++
+	}
+	if err := iter.Err(); err != nil {
+		metrics.CacheErrors.WithLabelValues("size").Inc()
+		c.logger.WarnContext(ctx, "failed to count cached orders", logging.Err(err))
 		return 0
 	}
 
-	return int(n)
+	return n
 }
+
+// sizeScanCount is the COUNT hint of the SCAN calls made by Size.
+const sizeScanCount = 1000
@@ -0,0 +1,39 @@
+package cache
+
+import (
+	"encoding/json"
+
+	"wb-tech-l0/internal/fieldcrypt"
+	"wb-tech-l0/internal/models"
+)
+
+// codecAAD binds encrypted cache entries to their purpose.
+const codecAAD = "cache.order"
+
+// codec converts orders to Redis values and back. With a keyring the whole
+// JSON document is encrypted, so customer data never reaches Redis in clear
+// text; entries written without encryption are still read.
+type codec struct {
+	keys *fieldcrypt.Keyring
+}
+
+func (c codec) encode(order *models.Order) (string, error) {
+	data, err := json.Marshal(order)
+	if err != nil {
+		return "", err
+	}
+	return c.keys.Encrypt(string(data), codecAAD)
+}
+
+func (c codec) decode(val string) (*models.Order, error) {
+	data, err := c.keys.Decrypt(val, codecAAD)
+	if err != nil {
+		return nil, err
+	}
+
+	var order models.Order
+	if err := json.Unmarshal([]byte(data), &order); err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
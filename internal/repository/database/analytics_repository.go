@@ -0,0 +1,212 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"wb-tech-l0/internal/application/ports"
+	"wb-tech-l0/internal/metrics"
+	"wb-tech-l0/internal/models"
+
+	"gorm.io/gorm"
+)
+
+var _ ports.AnalyticsRepository = (*DB)(nil)
+
+// periodStart truncates a column of unix seconds (UTC) to the start of its
+// day or week. 1970-01-01 was a Thursday, so weeks are shifted by four days
+// to start on Monday.
+func periodStart(interval, column string) string {
+	if interval == ports.IntervalWeek {
+		return column + " - (" + column + " + 259200) % 604800"
+	}
+	return column + " - " + column + " % 86400"
+}
+
+// breakdownColumns maps ports.BreakdownDimensions to their columns.
+var breakdownColumns = map[string]string{
+	"delivery_service": "o.delivery_service",
+	"provider":         "p.provider",
+	"bank":             "p.bank",
+	"locale":           "o.locale",
+	"entry":            "o.entry",
+}
+
+// rollupBreakdownColumns are the breakdown dimensions kept in
+// order_rollup_dbs.
+var rollupBreakdownColumns = map[string]string{
+	"delivery_service": "r.delivery_service",
+	"provider":         "r.provider",
+}
+
+// productColumns maps ports.ProductKeys to their item columns.
+var productColumns = map[string]string{
+	"brand": "i.brand",
+	"nm_id": "i.nm_id",
+}
+
+// liveOrders selects the orders that are not deleted, joined with their
+// payments as o and p. Item statuses are not filtered on, matching the
+// rollup tables.
+func liveOrders(conn *gorm.DB) *gorm.DB {
+	return conn.Table("order_dbs AS o").
+		Joins("JOIN payment_dbs AS p ON p.id = o.payment_id").
+		Where("o.deleted_at IS NULL")
+}
+
+// ordersInRange selects the live orders created in r. The range is served
+// by the date_created index.
+func ordersInRange(conn *gorm.DB, r ports.AnalyticsRange) *gorm.DB {
+	return liveOrders(conn).Where("o.date_created >= ? AND o.date_created < ?", r.From.Unix(), r.To.Unix())
+}
+
+// wholeDays reports whether r starts and ends at UTC midnight, so that it
+// can be answered from the daily rollup tables.
+func wholeDays(r ports.AnalyticsRange) bool {
+	return r.From.Unix()%86400 == 0 && r.To.Unix()%86400 == 0
+}
+
+// rollupsInRange selects the rows of a rollup table, as r, for the days in
+// rng.
+func rollupsInRange(conn *gorm.DB, table string, rng ports.AnalyticsRange) *gorm.DB {
+	return conn.Table(table+" AS r").Where("r.day >= ? AND r.day < ?", rng.From.Unix(), rng.To.Unix())
+}
+
+func (db *DB) Revenue(ctx context.Context, q ports.RevenueQuery) ([]models.PeriodRevenue, error) {
+	defer metrics.ObserveDB("analytics_revenue", time.Now())
+
+	var rows []struct {
+		Period   int64
+		Currency string
+		Orders   int64
+		Revenue  int64
+	}
+	conn := db.Conn.WithContext(ctx)
+	query := ordersInRange(conn, q.AnalyticsRange).
+		Select(periodStart(q.Interval, "o.date_created") + " AS period, p.currency AS currency, COUNT(*) AS orders, CAST(SUM(p.amount) AS BIGINT) AS revenue").
+		Group("period, p.currency")
+	if wholeDays(q.AnalyticsRange) {
+		query = rollupsInRange(conn, "order_rollup_dbs", q.AnalyticsRange).
+			Select(periodStart(q.Interval, "r.day") + " AS period, r.currency AS currency, CAST(SUM(r.orders) AS BIGINT) AS orders, CAST(SUM(r.revenue) AS BIGINT) AS revenue").
+			Group("period, r.currency")
+	}
+	err := query.Order("period, currency").Scan(&rows).Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	points := make([]models.PeriodRevenue, len(rows))
+	for i, r := range rows {
+		points[i] = models.PeriodRevenue{
+			Period:   time.Unix(r.Period, 0).UTC(),
+			Currency: r.Currency,
+			Orders:   r.Orders,
+			Revenue:  r.Revenue,
+		}
+	}
+	return points, nil
+}
+
+func (db *DB) Breakdown(ctx context.Context, q ports.BreakdownQuery) ([]models.BreakdownRow, error) {
+	defer metrics.ObserveDB("analytics_breakdown", time.Now())
+
+	conn := db.Conn.WithContext(ctx)
+	column := breakdownColumns[q.By]
+	query := ordersInRange(conn, q.AnalyticsRange).
+		Select(column + " AS value, p.currency AS currency, COUNT(*) AS orders, CAST(SUM(p.amount) AS BIGINT) AS revenue").
+		Group(column + ", p.currency")
+	if column, ok := rollupBreakdownColumns[q.By]; ok && wholeDays(q.AnalyticsRange) {
+		query = rollupsInRange(conn, "order_rollup_dbs", q.AnalyticsRange).
+			Select(column + " AS value, r.currency AS currency, CAST(SUM(r.orders) AS BIGINT) AS orders, CAST(SUM(r.revenue) AS BIGINT) AS revenue").
+			Group(column + ", r.currency")
+	}
+
+	rows := []models.BreakdownRow{}
+	err := query.Order("orders DESC, value, currency").Scan(&rows).Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return rows, nil
+}
+
+func (db *DB) Basket(ctx context.Context, r ports.AnalyticsRange) ([]models.BasketStats, error) {
+	defer metrics.ObserveDB("analytics_basket", time.Now())
+
+	conn := db.Conn.WithContext(ctx)
+	stats := []models.BasketStats{}
+	if wholeDays(r) {
+		err := rollupsInRange(conn, "order_rollup_dbs", r).
+			Select("r.currency AS currency, CAST(SUM(r.orders) AS BIGINT) AS orders, CAST(SUM(r.items) AS BIGINT) AS items, CAST(SUM(r.goods_total) AS BIGINT) AS goods_total").
+			Group("r.currency").
+			Order("currency").
+			Scan(&stats).Error
+		if err != nil {
+			return nil, translateError(err)
+		}
+		return stats, nil
+	}
+
+	err := ordersInRange(conn, r).
+		Select("p.currency AS currency, COUNT(*) AS orders, CAST(SUM(p.goods_total) AS BIGINT) AS goods_total").
+		Group("p.currency").
+		Order("currency").
+		Scan(&stats).Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	// Items are counted separately: joining them above would repeat the
+	// goods total of an order once per item.
+	var items []struct {
+		Currency string
+		Items    int64
+	}
+	err = ordersInRange(conn, r).
+		Joins("JOIN item_dbs AS i ON i.order_uid = o.order_uid AND i.deleted_at IS NULL").
+		Select("p.currency AS currency, COUNT(*) AS items").
+		Group("p.currency").
+		Scan(&items).Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	for _, it := range items {
+		for i := range stats {
+			if stats[i].Currency == it.Currency {
+				stats[i].Items = it.Items
+			}
+		}
+	}
+	return stats, nil
+}
+
+func (db *DB) TopProducts(ctx context.Context, q ports.TopProductsQuery) ([]models.ProductSales, error) {
+	defer metrics.ObserveDB("analytics_top_products", time.Now())
+
+	column := productColumns[q.By]
+	order := "revenue DESC, units DESC"
+	if q.Metric == "units" {
+		order = "units DESC, revenue DESC"
+	}
+
+	conn := db.Conn.WithContext(ctx)
+	query := ordersInRange(conn, q.AnalyticsRange).
+		Joins("JOIN item_dbs AS i ON i.order_uid = o.order_uid AND i.deleted_at IS NULL").
+		Select(column + " AS " + q.By + ", p.currency AS currency, COUNT(*) AS units, CAST(SUM(i.total_price) AS BIGINT) AS revenue").
+		Group(column + ", p.currency")
+	if q.By == "brand" && wholeDays(q.AnalyticsRange) {
+		query = rollupsInRange(conn, "brand_rollup_dbs", q.AnalyticsRange).
+			Select("r.brand AS brand, r.currency AS currency, CAST(SUM(r.units) AS BIGINT) AS units, CAST(SUM(r.revenue) AS BIGINT) AS revenue").
+			Group("r.brand, r.currency")
+	}
+
+	products := []models.ProductSales{}
+	err := query.
+		Order(order + ", " + q.By + ", currency").
+		Limit(q.Limit).
+		Scan(&products).Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return products, nil
+}
@@ -0,0 +1,104 @@
+package database_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"wb-tech-l0/internal/application/ports"
+	"wb-tech-l0/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyticsRepository(t *testing.T) {
+	db, cleanup := newTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	day := func(d, h int) time.Time { return time.Date(2025, 3, d, h, 0, 0, 0, time.UTC) }
+	item := func(brand string, nmID, price int) models.Item {
+		return models.Item{ChrtID: 1, TrackNumber: "ABCDEFGHJK", Price: price, RID: "rid", Name: "Item", Size: "M", TotalPrice: price, NmID: nmID, Brand: brand, Status: 200}
+	}
+	save := func(uid string, created time.Time, service, currency string, amount int, items ...models.Item) {
+		o := newTestOrder(uid)
+		o.DateCreated = created
+		o.DeliveryService = service
+		o.Payment.Currency = currency
+		o.Payment.Amount = amount
+		o.Payment.GoodsTotal = amount
+		o.Items = items
+		saveOrder(t, db, o)
+	}
+	// 2025-03-03 is a Monday.
+	save("a", day(3, 10), "dhl", "USD", 100, item("acme", 1, 60), item("acme", 1, 40))
+	save("b", day(3, 23), "ups", "USD", 50, item("zeta", 2, 50))
+	save("c", day(5, 1), "dhl", "RUB", 900, item("acme", 1, 900))
+	save("d", day(10, 1), "dhl", "USD", 70, item("zeta", 2, 70))
+	save("outside", day(20, 1), "dhl", "USD", 1000, item("acme", 1, 1000))
+
+	// Item statuses are not interpreted by either path: an order whose
+	// items change status, a cancellation included, still counts in full.
+	cancelled := item("zeta", 2, 50)
+	cancelled.Status = 202
+	save("b", day(3, 23), "ups", "USD", 50, cancelled)
+
+	for _, tt := range []struct {
+		name string
+		r    ports.AnalyticsRange
+	}{
+		// Whole days are read from the rollup tables, other ranges from
+		// the order tables.
+		{"rollups", ports.AnalyticsRange{From: day(1, 0), To: day(17, 0)}},
+		{"order tables", ports.AnalyticsRange{From: day(1, 0).Add(-time.Hour), To: day(17, 0).Add(time.Hour)}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := tt.r
+
+			points, err := db.Revenue(ctx, ports.RevenueQuery{AnalyticsRange: r, Interval: ports.IntervalDay})
+			require.NoError(t, err)
+			assert.Equal(t, []models.PeriodRevenue{
+				{Period: day(3, 0), Currency: "USD", Orders: 2, Revenue: 150},
+				{Period: day(5, 0), Currency: "RUB", Orders: 1, Revenue: 900},
+				{Period: day(10, 0), Currency: "USD", Orders: 1, Revenue: 70},
+			}, points)
+
+			points, err = db.Revenue(ctx, ports.RevenueQuery{AnalyticsRange: r, Interval: ports.IntervalWeek})
+			require.NoError(t, err)
+			assert.Equal(t, []models.PeriodRevenue{
+				{Period: day(3, 0), Currency: "RUB", Orders: 1, Revenue: 900},
+				{Period: day(3, 0), Currency: "USD", Orders: 2, Revenue: 150},
+				{Period: day(10, 0), Currency: "USD", Orders: 1, Revenue: 70},
+			}, points)
+
+			rows, err := db.Breakdown(ctx, ports.BreakdownQuery{AnalyticsRange: r, By: "delivery_service"})
+			require.NoError(t, err)
+			assert.Equal(t, []models.BreakdownRow{
+				{Value: "dhl", Currency: "USD", Orders: 2, Revenue: 170},
+				{Value: "dhl", Currency: "RUB", Orders: 1, Revenue: 900},
+				{Value: "ups", Currency: "USD", Orders: 1, Revenue: 50},
+			}, rows)
+
+			basket, err := db.Basket(ctx, r)
+			require.NoError(t, err)
+			assert.Equal(t, []models.BasketStats{
+				{Currency: "RUB", Orders: 1, Items: 1, GoodsTotal: 900},
+				{Currency: "USD", Orders: 3, Items: 4, GoodsTotal: 220},
+			}, basket)
+
+			products, err := db.TopProducts(ctx, ports.TopProductsQuery{AnalyticsRange: r, By: "brand", Metric: "units", Limit: 2})
+			require.NoError(t, err)
+			// Equal units are ranked by revenue.
+			assert.Equal(t, []models.ProductSales{
+				{Brand: "zeta", Currency: "USD", Units: 2, Revenue: 120},
+				{Brand: "acme", Currency: "USD", Units: 2, Revenue: 100},
+			}, products)
+
+			products, err = db.TopProducts(ctx, ports.TopProductsQuery{AnalyticsRange: r, By: "nm_id", Metric: "revenue", Limit: 10})
+			require.NoError(t, err)
+			require.Len(t, products, 3)
+			assert.Equal(t, models.ProductSales{NmID: 1, Currency: "RUB", Units: 1, Revenue: 900}, products[0])
+		})
+	}
+}
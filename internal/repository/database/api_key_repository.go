@@ -0,0 +1,33 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+	"wb-tech-l0/internal/application/ports"
+	"wb-tech-l0/internal/metrics"
+	"wb-tech-l0/internal/models"
+	"wb-tech-l0/internal/repository/database/db_models"
+
+	"gorm.io/gorm"
+)
+
+var _ ports.APIKeyRepository = (*DB)(nil)
+
+func (db *DB) FindAPIKeyByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	defer metrics.ObserveDB("find_api_key", time.Now())
+
+	var row db_models.APIKeyDB
+	err := db.Conn.WithContext(ctx).
+		Where("key_hash = ? AND revoked_at IS NULL", keyHash).
+		First(&row).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ports.ErrAPIKeyNotFound
+		}
+		return nil, err
+	}
+
+	key := db_models.ToDomainAPIKey(row)
+	return &key, nil
+}
@@ -0,0 +1,34 @@
+package database_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"wb-tech-l0/internal/application/ports"
+	"wb-tech-l0/internal/repository/database/db_models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKeyRepository_FindAPIKeyByHash(t *testing.T) {
+	db, cleanup := newTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	revokedAt := time.Now()
+	require.NoError(t, db.Conn.Create(&db_models.APIKeyDB{Name: "support", KeyHash: "active", Scopes: "orders:read admin"}).Error)
+	require.NoError(t, db.Conn.Create(&db_models.APIKeyDB{Name: "old", KeyHash: "revoked", RevokedAt: &revokedAt}).Error)
+
+	key, err := db.FindAPIKeyByHash(ctx, "active")
+	require.NoError(t, err)
+	assert.Equal(t, "support", key.Name)
+	assert.Equal(t, []string{"orders:read", "admin"}, key.Scopes)
+
+	_, err = db.FindAPIKeyByHash(ctx, "revoked")
+	assert.ErrorIs(t, err, ports.ErrAPIKeyNotFound)
+
+	_, err = db.FindAPIKeyByHash(ctx, "unknown")
+	assert.ErrorIs(t, err, ports.ErrAPIKeyNotFound)
+}
@@ -1,35 +1,89 @@
 package database
 
 import (
+	"context"
+	"log/slog"
+	"time"
+
+	"wb-tech-l0/internal/fieldcrypt"
+	"wb-tech-l0/internal/models"
 	"wb-tech-l0/internal/repository/cache"
 	"wb-tech-l0/internal/repository/database/db_models"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
 )
 
 type DB struct {
 	Conn  *gorm.DB
 	Cache *cache.OrderCache
+	// Keys encrypts customer personal data columns; nil stores them in
+	// clear text.
+	Keys   *fieldcrypt.Keyring
+	Logger *slog.Logger
 }
 
-func NewDB(dsn string, c *cache.OrderCache) (*DB, error) {
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+func NewDB(dsn string, c *cache.OrderCache, keys *fieldcrypt.Keyring, logger *slog.Logger) (*DB, error) {
+	logger = logger.With("component", "database")
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+		// Queries are logged without bound values: they carry customer data.
+		Logger: gormlogger.NewSlogLogger(logger, gormlogger.Config{
+			SlowThreshold:             200 * time.Millisecond,
+			LogLevel:                  gormlogger.Warn,
+			IgnoreRecordNotFoundError: true,
+			ParameterizedQueries:      true,
+		}),
+	})
 	if err != nil {
 		return nil, err
 	}
 
 	return &DB{
-		Conn:  db,
-		Cache: c,
+		Conn:   db,
+		Cache:  c,
+		Keys:   keys,
+		Logger: logger,
 	}, nil
 }
 
+// Migrate creates or updates the tables. Rollup tables created by it are
+// filled from the orders already stored.
 func (db *DB) Migrate() error {
-	return db.Conn.AutoMigrate(
+	newRollups := !db.Conn.Migrator().HasTable(&db_models.OrderRollupDB{}) ||
+		!db.Conn.Migrator().HasTable(&db_models.BrandRollupDB{})
+
+	err := db.Conn.AutoMigrate(
 		&db_models.DeliveryDB{},
 		&db_models.PaymentDB{},
 		&db_models.OrderDB{},
 		&db_models.ItemDB{},
+		&db_models.OutboxEventDB{},
+		&db_models.WebhookSubscriptionDB{},
+		&db_models.WebhookDeliveryDB{},
+		&db_models.APIKeyDB{},
+		&db_models.ErasureAuditDB{},
+		&db_models.OrderRollupDB{},
+		&db_models.BrandRollupDB{},
 	)
+	if err != nil {
+		return err
+	}
+	if err := db.purgeDeliveredWebhooks(); err != nil {
+		return err
+	}
+	if !newRollups {
+		return nil
+	}
+	_, err = db.RebuildRollups(context.Background())
+	return err
+}
+
+// purgeDeliveredWebhooks drops delivered webhook rows kept by earlier
+// versions together with their plaintext payloads.
+func (db *DB) purgeDeliveredWebhooks() error {
+	return db.Conn.Unscoped().
+		Where("status = ?", models.WebhookDeliveryDelivered).
+		Delete(&db_models.WebhookDeliveryDB{}).Error
 }
@@ -0,0 +1,27 @@
+package db_models
+
+import (
+	"strings"
+	"time"
+	"wb-tech-l0/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type APIKeyDB struct {
+	gorm.Model
+	Name      string `gorm:"not null"`
+	KeyHash   string `gorm:"not null;uniqueIndex"`
+	Scopes    string // space-separated
+	RevokedAt *time.Time
+}
+
+func ToDomainAPIKey(k APIKeyDB) models.APIKey {
+	return models.APIKey{
+		ID:        k.ID,
+		Name:      k.Name,
+		KeyHash:   k.KeyHash,
+		Scopes:    strings.Fields(k.Scopes),
+		CreatedAt: k.CreatedAt,
+	}
+}
@@ -1,6 +1,9 @@
 package db_models
 
 import (
+	"fmt"
+
+	"wb-tech-l0/internal/fieldcrypt"
 	"wb-tech-l0/internal/models"
 
 	"gorm.io/gorm"
@@ -17,8 +20,22 @@ type DeliveryDB struct {
 	Email   string
 }
 
-func ToDeliveryDB(d models.Delivery) DeliveryDB {
-	return DeliveryDB{
+// EncryptedFields lists the columns holding customer personal data, which
+// are stored encrypted when a keyring is configured. The key is passed to
+// the cipher as additional data, so a value cannot be moved to another
+// column.
+func (d *DeliveryDB) EncryptedFields() map[string]*string {
+	return map[string]*string{
+		"delivery.name":    &d.Name,
+		"delivery.phone":   &d.Phone,
+		"delivery.zip":     &d.Zip,
+		"delivery.address": &d.Address,
+		"delivery.email":   &d.Email,
+	}
+}
+
+func ToDeliveryDB(d models.Delivery, keys *fieldcrypt.Keyring) (DeliveryDB, error) {
+	row := DeliveryDB{
 		Name:    d.Name,
 		Phone:   d.Phone,
 		Zip:     d.Zip,
@@ -27,4 +44,31 @@ func ToDeliveryDB(d models.Delivery) DeliveryDB {
 		Region:  d.Region,
 		Email:   d.Email,
 	}
+	for field, v := range row.EncryptedFields() {
+		enc, err := keys.Encrypt(*v, field)
+		if err != nil {
+			return DeliveryDB{}, fmt.Errorf("encrypt %s: %w", field, err)
+		}
+		*v = enc
+	}
+	return row, nil
+}
+
+func ToDomainDelivery(row DeliveryDB, keys *fieldcrypt.Keyring) (models.Delivery, error) {
+	for field, v := range row.EncryptedFields() {
+		dec, err := keys.Decrypt(*v, field)
+		if err != nil {
+			return models.Delivery{}, fmt.Errorf("decrypt %s: %w", field, err)
+		}
+		*v = dec
+	}
+	return models.Delivery{
+		Name:    row.Name,
+		Phone:   row.Phone,
+		Zip:     row.Zip,
+		City:    row.City,
+		Address: row.Address,
+		Region:  row.Region,
+		Email:   row.Email,
+	}, nil
 }
@@ -0,0 +1,16 @@
+package db_models
+
+import (
+	"gorm.io/gorm"
+)
+
+// ErasureAuditDB records every executed customer data erasure. It holds
+// identifiers only, never the erased data.
+type ErasureAuditDB struct {
+	gorm.Model
+	CustomerID  string `gorm:"not null;index"`
+	OrderUIDs   string // comma-separated
+	OrderCount  int
+	RequestedBy string `gorm:"not null"`
+	Reason      string
+}
@@ -2,6 +2,7 @@ package db_models
 
 import (
 	"time"
+	"wb-tech-l0/internal/fieldcrypt"
 	"wb-tech-l0/internal/models"
 
 	"gorm.io/gorm"
@@ -18,7 +19,7 @@ type OrderDB struct {
 	DeliveryService   string
 	Shardkey          string
 	SmID              int
-	DateCreated       int64
+	DateCreated       int64 `gorm:"index"`
 	OofShard          string
 
 	DeliveryID uint `gorm:"not null"`
@@ -48,7 +49,13 @@ func ToDomainOrder(
 	deliveryDB DeliveryDB,
 	paymentDB PaymentDB,
 	itemsDB []ItemDB,
-) *models.Order {
+	keys *fieldcrypt.Keyring,
+) (*models.Order, error) {
+	delivery, err := ToDomainDelivery(deliveryDB, keys)
+	if err != nil {
+		return nil, err
+	}
+
 	items := make([]models.Item, len(itemsDB))
 	for i, it := range itemsDB {
 		items[i] = models.Item{
@@ -70,15 +77,7 @@ func ToDomainOrder(
 		OrderUID:    orderDB.OrderUID,
 		TrackNumber: orderDB.TrackNumber,
 		Entry:       orderDB.Entry,
-		Delivery: models.Delivery{
-			Name:    deliveryDB.Name,
-			Phone:   deliveryDB.Phone,
-			Zip:     deliveryDB.Zip,
-			City:    deliveryDB.City,
-			Address: deliveryDB.Address,
-			Region:  deliveryDB.Region,
-			Email:   deliveryDB.Email,
-		},
+		Delivery:    delivery,
 		Payment: models.Payment{
 			Transaction:  paymentDB.Transaction,
 			RequestID:    paymentDB.RequestID,
@@ -100,5 +99,5 @@ func ToDomainOrder(
 		SmID:              orderDB.SmID,
 		DateCreated:       time.Unix(orderDB.DateCreated, 0),
 		OofShard:          orderDB.OofShard,
-	}
+	}, nil
 }
@@ -0,0 +1,56 @@
+package db_models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+	"wb-tech-l0/internal/fieldcrypt"
+	"wb-tech-l0/internal/models"
+)
+
+// EventPayloadAAD binds sealed event payloads to their column. The outbox
+// row and the webhook deliveries of an event share the same ciphertext, so
+// both tables use it.
+const EventPayloadAAD = "order_event.payload"
+
+// OutboxEventDB is a row of the transactional outbox. Rows are written in the
+// same transaction as the order itself and removed once published.
+type OutboxEventDB struct {
+	ID        uint64 `gorm:"primaryKey;autoIncrement"`
+	EventID   string `gorm:"uniqueIndex;not null"`
+	EventType string `gorm:"not null"`
+	OrderUID  string `gorm:"index;not null"`
+	Payload   []byte `gorm:"not null"`
+	CreatedAt time.Time
+}
+
+// ToOutboxEventDB serialises the event and encrypts the payload, which
+// carries the customer's delivery details.
+func ToOutboxEventDB(e models.OrderEvent, keys *fieldcrypt.Keyring) (OutboxEventDB, error) {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return OutboxEventDB{}, err
+	}
+	sealed, err := keys.Encrypt(string(payload), EventPayloadAAD)
+	if err != nil {
+		return OutboxEventDB{}, fmt.Errorf("encrypt event payload: %w", err)
+	}
+
+	return OutboxEventDB{
+		EventID:   e.EventID,
+		EventType: e.EventType,
+		OrderUID:  e.OrderUID,
+		Payload:   []byte(sealed),
+		CreatedAt: e.OccurredAt,
+	}, nil
+}
+
+// DecryptEventPayload opens a payload written by ToOutboxEventDB. Payloads
+// stored before encryption was enabled are returned as they are.
+func DecryptEventPayload(payload []byte, keys *fieldcrypt.Keyring) ([]byte, error) {
+	plain, err := keys.Decrypt(string(payload), EventPayloadAAD)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt event payload: %w", err)
+	}
+	return []byte(plain), nil
+}
@@ -0,0 +1,27 @@
+package db_models
+
+// OrderRollupDB holds the orders created on one UTC day (Day is its start
+// in unix seconds) per delivery service, payment provider and currency.
+// Rows are updated in the transaction that writes the orders and can be
+// recomputed from the order tables.
+type OrderRollupDB struct {
+	Day             int64  `gorm:"primaryKey;autoIncrement:false"`
+	DeliveryService string `gorm:"primaryKey"`
+	Provider        string `gorm:"primaryKey"`
+	Currency        string `gorm:"primaryKey"`
+	Orders          int64  `gorm:"not null"`
+	Items           int64  `gorm:"not null"`
+	Revenue         int64  `gorm:"not null"`
+	GoodsTotal      int64  `gorm:"not null"`
+}
+
+// BrandRollupDB holds the items of the orders created on one UTC day per
+// brand and currency: Units counts item rows, Revenue sums their
+// total_price.
+type BrandRollupDB struct {
+	Day      int64  `gorm:"primaryKey;autoIncrement:false"`
+	Brand    string `gorm:"primaryKey"`
+	Currency string `gorm:"primaryKey"`
+	Units    int64  `gorm:"not null"`
+	Revenue  int64  `gorm:"not null"`
+}
@@ -0,0 +1,108 @@
+package db_models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"wb-tech-l0/internal/fieldcrypt"
+	"wb-tech-l0/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// WebhookSecretAAD binds sealed subscription secrets to their column.
+const WebhookSecretAAD = "webhook_subscription.secret"
+
+type WebhookSubscriptionDB struct {
+	gorm.Model
+	URL        string `gorm:"not null"`
+	Secret     string `gorm:"not null"`
+	EventTypes string // comma-separated, empty means all
+	Active     bool   `gorm:"not null;default:true"`
+}
+
+type WebhookDeliveryDB struct {
+	gorm.Model
+	SubscriptionID uint   `gorm:"not null;index"`
+	EventID        string `gorm:"not null"`
+	EventType      string `gorm:"not null"`
+	OrderUID       string `gorm:"not null;index"`
+	Payload        []byte `gorm:"not null"`
+	Status         string `gorm:"not null;index"`
+	Attempts       int
+	NextAttemptAt  time.Time `gorm:"index"`
+	// ClaimedUntil is set while a dispatcher is sending the delivery;
+	// other dispatchers leave it alone until then.
+	ClaimedUntil *time.Time
+	LastError    string
+}
+
+// Accepts reports whether the subscription wants events of the given
+// type, without decrypting its secret.
+func (s WebhookSubscriptionDB) Accepts(eventType string) bool {
+	return models.WebhookSubscription{EventTypes: splitEventTypes(s.EventTypes)}.Accepts(eventType)
+}
+
+func splitEventTypes(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+// ToWebhookSubscriptionDB maps the subscription and encrypts its secret:
+// anyone holding it can sign requests to the subscriber.
+func ToWebhookSubscriptionDB(s models.WebhookSubscription, keys *fieldcrypt.Keyring) (WebhookSubscriptionDB, error) {
+	secret, err := keys.Encrypt(s.Secret, WebhookSecretAAD)
+	if err != nil {
+		return WebhookSubscriptionDB{}, fmt.Errorf("encrypt webhook secret: %w", err)
+	}
+
+	return WebhookSubscriptionDB{
+		URL:        s.URL,
+		Secret:     secret,
+		EventTypes: strings.Join(s.EventTypes, ","),
+		Active:     s.Active,
+	}, nil
+}
+
+// ToDomainWebhookSubscription maps the row and decrypts its secret.
+// Secrets stored before encryption was enabled are read as they are.
+func ToDomainWebhookSubscription(s WebhookSubscriptionDB, keys *fieldcrypt.Keyring) (models.WebhookSubscription, error) {
+	secret, err := keys.Decrypt(s.Secret, WebhookSecretAAD)
+	if err != nil {
+		return models.WebhookSubscription{}, fmt.Errorf("decrypt webhook secret: %w", err)
+	}
+
+	return models.WebhookSubscription{
+		ID:         s.ID,
+		URL:        s.URL,
+		Secret:     secret,
+		EventTypes: splitEventTypes(s.EventTypes),
+		Active:     s.Active,
+		CreatedAt:  s.CreatedAt,
+	}, nil
+}
+
+// ToDomainWebhookDelivery maps the row and decrypts its payload, which is
+// the outbox payload of the event and sealed the same way.
+func ToDomainWebhookDelivery(d WebhookDeliveryDB, keys *fieldcrypt.Keyring) (models.WebhookDelivery, error) {
+	payload, err := DecryptEventPayload(d.Payload, keys)
+	if err != nil {
+		return models.WebhookDelivery{}, err
+	}
+
+	return models.WebhookDelivery{
+		ID:             d.ID,
+		SubscriptionID: d.SubscriptionID,
+		EventID:        d.EventID,
+		EventType:      d.EventType,
+		OrderUID:       d.OrderUID,
+		Payload:        payload,
+		Status:         d.Status,
+		Attempts:       d.Attempts,
+		NextAttemptAt:  d.NextAttemptAt,
+		LastError:      d.LastError,
+		CreatedAt:      d.CreatedAt,
+	}, nil
+}
@@ -0,0 +1,199 @@
+package database
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+	"wb-tech-l0/internal/application/ports"
+	"wb-tech-l0/internal/fieldcrypt"
+	"wb-tech-l0/internal/logging"
+	"wb-tech-l0/internal/metrics"
+	"wb-tech-l0/internal/models"
+	"wb-tech-l0/internal/repository/database/db_models"
+	"wb-tech-l0/internal/telemetry"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var _ ports.ErasureRepository = (*DB)(nil)
+
+func (db *DB) EraseCustomerData(ctx context.Context, req ports.ErasureRequest) (report models.ErasureReport, err error) {
+	defer metrics.ObserveDB("erase_customer", time.Now())
+
+	ctx, span := tracer.Start(ctx, "db.EraseCustomerData")
+	defer func() { telemetry.End(span, err) }()
+
+	report = models.ErasureReport{
+		CustomerID:   req.CustomerID,
+		DryRun:       req.DryRun,
+		OrderUIDs:    []string{},
+		ErasedFields: models.ErasedFields,
+		RequestedBy:  req.RequestedBy,
+		Reason:       req.Reason,
+	}
+
+	err = db.Conn.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// The customer lock and the order row locks make a concurrent save
+		// of the customer's orders wait for the erasure, and then find the
+		// audit row written by it.
+		query := tx
+		if !req.DryRun {
+			if err := lockCustomers(tx, []string{req.CustomerID}); err != nil {
+				return err
+			}
+			query = tx.Clauses(clause.Locking{Strength: "UPDATE"})
+		}
+		var orders []db_models.OrderDB
+		if err := query.Where("customer_id = ?", req.CustomerID).Order("order_uid").Find(&orders).Error; err != nil {
+			return err
+		}
+
+		deliveryIDs := make([]uint, len(orders))
+		for i, o := range orders {
+			report.OrderUIDs = append(report.OrderUIDs, o.OrderUID)
+			deliveryIDs[i] = o.DeliveryID
+		}
+
+		if len(orders) > 0 {
+			if err := tx.Model(&db_models.OutboxEventDB{}).Where("order_uid IN ?", report.OrderUIDs).Count(&report.OutboxEvents).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&db_models.WebhookDeliveryDB{}).Where("order_uid IN ?", report.OrderUIDs).Count(&report.WebhookDeliveries).Error; err != nil {
+				return err
+			}
+		}
+
+		if req.DryRun {
+			return nil
+		}
+
+		if len(orders) > 0 {
+			if err := anonymiseOrders(tx, deliveryIDs, report.OrderUIDs, db.Keys); err != nil {
+				return err
+			}
+		}
+
+		audit := db_models.ErasureAuditDB{
+			CustomerID:  req.CustomerID,
+			OrderUIDs:   strings.Join(report.OrderUIDs, ","),
+			OrderCount:  len(report.OrderUIDs),
+			RequestedBy: req.RequestedBy,
+			Reason:      req.Reason,
+		}
+		if err := tx.Create(&audit).Error; err != nil {
+			return err
+		}
+		report.AuditID = audit.ID
+		report.ErasedAt = audit.CreatedAt
+		return nil
+	})
+	if err != nil {
+		metrics.DBTransactionFailures.WithLabelValues("erase_customer").Inc()
+		return models.ErasureReport{}, err
+	}
+
+	if !req.DryRun {
+		for _, uid := range report.OrderUIDs {
+			if err := db.Cache.Delete(ctx, uid); err != nil {
+				db.Logger.WarnContext(ctx, "failed to evict erased order from cache", logging.KeyOrderUID, uid, logging.Err(err))
+			}
+		}
+	}
+	return report, nil
+}
+
+// anonymiseOrders overwrites the delivery rows and payment transactions of
+// the orders and deletes pending copies of them.
+func anonymiseOrders(tx *gorm.DB, deliveryIDs []uint, orderUIDs []string, keys *fieldcrypt.Keyring) error {
+	erased, err := db_models.ToDeliveryDB(models.ErasedDelivery, keys)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Model(&db_models.DeliveryDB{}).Unscoped().
+		Where("id IN ?", deliveryIDs).
+		Select("Name", "Phone", "Zip", "City", "Address", "Region", "Email").
+		Updates(&erased).Error
+	if err != nil {
+		return err
+	}
+
+	err = tx.Model(&db_models.PaymentDB{}).Unscoped().
+		Where("order_uid IN ?", orderUIDs).
+		Update("transaction", models.ErasedValue).Error
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Where("order_uid IN ?", orderUIDs).Delete(&db_models.OutboxEventDB{}).Error; err != nil {
+		return err
+	}
+	return tx.Unscoped().Where("order_uid IN ?", orderUIDs).Delete(&db_models.WebhookDeliveryDB{}).Error
+}
+
+// lockCustomers takes a transaction-scoped advisory lock per customer, in
+// customer ID order so that overlapping transactions do not deadlock. It
+// serialises erasures with saves of the customer's orders, including
+// first saves, which have no row to lock yet. SQLite runs one writing
+// transaction at a time and needs no lock.
+func lockCustomers(tx *gorm.DB, customerIDs []string) error {
+	if tx.Dialector.Name() != "postgres" {
+		return nil
+	}
+	for _, id := range customerIDs {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", "customer:"+id).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyErasures anonymises, in place, the orders of customers found in
+// the erasure audit log, so re-ingesting an original message (replay,
+// ingest) does not bring the erased data back. It runs in the saving
+// transaction, before the orders reach the tables, the outbox or the cache,
+// and holds the customer locks until it commits: an erasure running
+// concurrently either finishes first and is seen here, or waits and
+// anonymises the orders saved.
+func applyErasures(tx *gorm.DB, orders []*models.Order) error {
+	seen := make(map[string]bool, len(orders))
+	var customerIDs []string
+	for _, o := range orders {
+		if o.CustomerID != "" && !seen[o.CustomerID] {
+			seen[o.CustomerID] = true
+			customerIDs = append(customerIDs, o.CustomerID)
+		}
+	}
+	if len(customerIDs) == 0 {
+		return nil
+	}
+	slices.Sort(customerIDs)
+	if err := lockCustomers(tx, customerIDs); err != nil {
+		return err
+	}
+
+	var erased []string
+	err := tx.Model(&db_models.ErasureAuditDB{}).
+		Where("customer_id IN ?", customerIDs).
+		Distinct().
+		Pluck("customer_id", &erased).Error
+	if err != nil {
+		return err
+	}
+	if len(erased) == 0 {
+		return nil
+	}
+
+	isErased := make(map[string]bool, len(erased))
+	for _, id := range erased {
+		isErased[id] = true
+	}
+	for _, o := range orders {
+		if isErased[o.CustomerID] {
+			o.ErasePersonalData()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package database_test
+
+import (
+	"context"
+	"testing"
+
+	"wb-tech-l0/internal/application/ports"
+	"wb-tech-l0/internal/models"
+	"wb-tech-l0/internal/repository/database/db_models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErasureRepository_EraseCustomerData(t *testing.T) {
+	for name, encrypted := range map[string]bool{"clear text": false, "encrypted": true} {
+		t.Run(name, func(t *testing.T) {
+			db, mr := newEncryptedTestDB(t, nil)
+			if encrypted {
+				db.Keys = newKeyring(t, "k1", "k1")
+			}
+			ctx := context.Background()
+
+			mine1, mine2, other := newTestOrder("erase-1"), newTestOrder("erase-2"), newTestOrder("keep-1")
+			mine1.CustomerID, mine2.CustomerID, other.CustomerID = "cust-1", "cust-1", "cust-2"
+			for _, o := range []*models.Order{mine1, mine2, other} {
+				saveOrder(t, db, o)
+			}
+			require.True(t, mr.Exists("order:erase-1"))
+
+			req := ports.ErasureRequest{CustomerID: "cust-1", RequestedBy: "apikey:dpo", Reason: "ticket 42", DryRun: true}
+			dry, err := db.EraseCustomerData(ctx, req)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"erase-1", "erase-2"}, dry.OrderUIDs)
+			assert.EqualValues(t, 2, dry.OutboxEvents)
+			assert.Zero(t, dry.AuditID)
+
+			got, err := db.GetOrder(ctx, "erase-1")
+			require.NoError(t, err)
+			assert.Equal(t, mine1.Delivery, got.Delivery, "dry run changes nothing")
+
+			req.DryRun = false
+			report, err := db.EraseCustomerData(ctx, req)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"erase-1", "erase-2"}, report.OrderUIDs)
+			assert.NotZero(t, report.AuditID)
+			assert.False(t, report.ErasedAt.IsZero())
+
+			assert.False(t, mr.Exists("order:erase-1"), "erased orders are evicted from the cache")
+			assert.True(t, mr.Exists("order:keep-1"))
+
+			got, err = db.GetOrder(ctx, "erase-2")
+			require.NoError(t, err)
+			assert.Equal(t, models.ErasedValue, got.Delivery.Name)
+			assert.Equal(t, models.ErasedValue, got.Delivery.Email)
+			assert.Equal(t, models.ErasedValue, got.Payment.Transaction)
+			assert.Equal(t, mine2.Payment.Amount, got.Payment.Amount)
+			assert.Equal(t, mine2.Payment.GoodsTotal, got.Payment.GoodsTotal)
+			assert.Equal(t, mine2.Items, got.Items)
+
+			kept, err := db.GetOrder(ctx, "keep-1")
+			require.NoError(t, err)
+			assert.Equal(t, other.Delivery, kept.Delivery)
+
+			var outbox int64
+			require.NoError(t, db.Conn.Model(&db_models.OutboxEventDB{}).Where("order_uid IN ?", report.OrderUIDs).Count(&outbox).Error)
+			assert.Zero(t, outbox)
+
+			var audit db_models.ErasureAuditDB
+			require.NoError(t, db.Conn.First(&audit, report.AuditID).Error)
+			assert.Equal(t, "cust-1", audit.CustomerID)
+			assert.Equal(t, "erase-1,erase-2", audit.OrderUIDs)
+			assert.Equal(t, "apikey:dpo", audit.RequestedBy)
+		})
+	}
+}
+
+func TestErasureRepository_ErasureSurvivesReingest(t *testing.T) {
+	db, mr := newEncryptedTestDB(t, newKeyring(t, "k1", "k1"))
+	ctx := context.Background()
+
+	single, batched := newTestOrder("erase-1"), newTestOrder("erase-2")
+	single.CustomerID, batched.CustomerID = "cust-1", "cust-1"
+	saveOrders(t, db, []*models.Order{single, batched})
+	_, err := db.EraseCustomerData(ctx, ports.ErasureRequest{CustomerID: "cust-1", RequestedBy: "apikey:dpo"})
+	require.NoError(t, err)
+
+	// The original messages come in again, e.g. through replay or ingest,
+	// along with a new order of the same customer.
+	fresh := newTestOrder("erase-3")
+	fresh.CustomerID = "cust-1"
+	again := newTestOrder("erase-1")
+	again.CustomerID = "cust-1"
+	assert.False(t, saveOrder(t, db, again))
+	reingested := newTestOrder("erase-2")
+	reingested.CustomerID = "cust-1"
+	assert.Equal(t, []bool{false, true}, saveOrders(t, db, []*models.Order{reingested, fresh}))
+
+	var events int64
+	require.NoError(t, db.Conn.Model(&db_models.OutboxEventDB{}).Where("order_uid IN ?", []string{"erase-1", "erase-2"}).Count(&events).Error)
+	assert.Zero(t, events, "re-saving an erased order changes nothing")
+
+	mr.FlushAll()
+	for _, uid := range []string{"erase-1", "erase-2", "erase-3"} {
+		got, err := db.GetOrder(ctx, uid)
+		require.NoError(t, err)
+		assert.Equal(t, models.ErasedValue, got.Delivery.Name, uid)
+		assert.Equal(t, models.ErasedValue, got.Delivery.Email, uid)
+		assert.Equal(t, models.ErasedValue, got.Payment.Transaction, uid)
+		assert.Equal(t, newTestOrder(uid).Payment.Amount, got.Payment.Amount, uid)
+	}
+}
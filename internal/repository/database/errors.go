@@ -0,0 +1,80 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"wb-tech-l0/internal/application/ports"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// translateError maps storage errors to the domain errors of ports, keeping
+// the original error in the chain. Errors without a domain meaning, and
+// errors that already carry one, are returned unchanged.
+func translateError(err error) error {
+	switch {
+	case err == nil,
+		errors.Is(err, ports.ErrOrderNotFound),
+		errors.Is(err, ports.ErrInvalidOrder),
+		errors.Is(err, ports.ErrConflict),
+		errors.Is(err, ports.ErrUnavailable):
+		return err
+	case isConflict(err):
+		return fmt.Errorf("%w: %w", ports.ErrConflict, err)
+	case isUnavailable(err):
+		return fmt.Errorf("%w: %w", ports.ErrUnavailable, err)
+	}
+	return err
+}
+
+// isConflict reports transactions aborted because of concurrent ones:
+// serialization failures, deadlocks and two first saves of the same order,
+// the second failing on the unique order_uid. Other unique violations are
+// not conflicts: running the transaction again would fail the same way.
+func isConflict(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	switch pgErr.Code {
+	case "40001", "40P01":
+		return true
+	case "23505":
+		// order_uid is the only unique column of order_dbs.
+		return pgErr.TableName == "order_dbs"
+	}
+	return false
+}
+
+// isUnavailable reports errors caused by the database not being reachable
+// or not accepting work, as opposed to errors in the query itself.
+func isUnavailable(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, sql.ErrConnDone) {
+		return true
+	}
+
+	var connectErr *pgconn.ConnectError
+	if errors.As(err, &connectErr) || pgconn.Timeout(err) {
+		return true
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		// Class 08: connection exception, 53: insufficient resources,
+		// 57P01-57P03: the server is shutting down or starting up.
+		return strings.HasPrefix(pgErr.Code, "08") ||
+			strings.HasPrefix(pgErr.Code, "53") ||
+			pgErr.Code == "57P01" || pgErr.Code == "57P02" || pgErr.Code == "57P03"
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
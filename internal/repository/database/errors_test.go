@@ -0,0 +1,47 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"wb-tech-l0/internal/application/ports"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestTranslateError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"order uid taken", &pgconn.PgError{Code: "23505", TableName: "order_dbs"}, ports.ErrConflict},
+		{"serialization failure", fmt.Errorf("commit: %w", &pgconn.PgError{Code: "40001"}), ports.ErrConflict},
+		{"deadlock", &pgconn.PgError{Code: "40P01"}, ports.ErrConflict},
+		{"connection failure", &pgconn.PgError{Code: "08006"}, ports.ErrUnavailable},
+		{"too many connections", &pgconn.PgError{Code: "53300"}, ports.ErrUnavailable},
+		{"admin shutdown", &pgconn.PgError{Code: "57P01"}, ports.ErrUnavailable},
+		{"deadline", context.DeadlineExceeded, ports.ErrUnavailable},
+		{"already translated", ports.ErrOrderNotFound, ports.ErrOrderNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := translateError(tt.err)
+			assert.ErrorIs(t, got, tt.want)
+			assert.ErrorIs(t, got, tt.err)
+		})
+	}
+
+	syntax := &pgconn.PgError{Code: "42601"}
+	assert.Same(t, syntax, translateError(syntax))
+	// A unique violation elsewhere fails again on every retry.
+	duplicate := &pgconn.PgError{Code: "23505", TableName: "api_key_dbs"}
+	assert.Same(t, duplicate, translateError(duplicate))
+	assert.Same(t, gorm.ErrDuplicatedKey, translateError(gorm.ErrDuplicatedKey))
+	assert.NoError(t, translateError(nil))
+	assert.False(t, errors.Is(translateError(context.Canceled), ports.ErrUnavailable))
+}
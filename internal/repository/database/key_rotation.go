@@ -0,0 +1,234 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"wb-tech-l0/internal/repository/database/db_models"
+
+	"gorm.io/gorm"
+)
+
+// KeyRotationResult reports what RotateEncryptionKeys did.
+type KeyRotationResult struct {
+	Scanned int
+	Updated int
+}
+
+// RotateEncryptionKeys brings every delivery row, every pending event
+// payload (outbox rows and webhook deliveries) and every webhook secret
+// under the primary key:
+// values encrypted with an older key get their data key re-wrapped and
+// clear text values are encrypted. Rows are processed in id order,
+// batchSize per transaction, so the command can be interrupted and rerun.
+// A row changed by a save or an erasure after it was read is skipped: its
+// writer already encrypted it with the primary key.
+func (db *DB) RotateEncryptionKeys(ctx context.Context, batchSize int) (KeyRotationResult, error) {
+	var result KeyRotationResult
+	if db.Keys == nil {
+		return result, errors.New("no encryption keys configured")
+	}
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+
+	if err := db.rotateDeliveries(ctx, batchSize, &result); err != nil {
+		return result, err
+	}
+	for _, model := range []any{&db_models.OutboxEventDB{}, &db_models.WebhookDeliveryDB{}} {
+		if err := db.rotatePayloads(ctx, model, batchSize, &result); err != nil {
+			return result, err
+		}
+	}
+	if err := db.rotateSecrets(ctx, &result); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+func (db *DB) rotateDeliveries(ctx context.Context, batchSize int, result *KeyRotationResult) error {
+	var lastID uint
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var rows []db_models.DeliveryDB
+		err := db.Conn.WithContext(ctx).Unscoped().
+			Where("id > ?", lastID).
+			Order("id ASC").
+			Limit(batchSize).
+			Find(&rows).Error
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		lastID = rows[len(rows)-1].ID
+
+		updated, err := db.rotateDeliveryBatch(ctx, rows)
+		if err != nil {
+			return err
+		}
+		result.Scanned += len(rows)
+		result.Updated += updated
+
+		db.Logger.InfoContext(ctx, "key rotation batch done",
+			"table", "delivery_dbs", "scanned", result.Scanned, "updated", result.Updated, "last_id", lastID)
+	}
+}
+
+func (db *DB) rotateDeliveryBatch(ctx context.Context, rows []db_models.DeliveryDB) (int, error) {
+	updated := 0
+	err := db.Conn.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for i := range rows {
+			row := &rows[i]
+
+			// The update only applies if the rotated columns still hold the
+			// values read, so a concurrent save or erasure is never undone.
+			q := tx.Model(&db_models.DeliveryDB{}).Unscoped().Where("id = ?", row.ID)
+			columns := make(map[string]any)
+			for field, v := range row.EncryptedFields() {
+				if !db.Keys.NeedsRotation(*v) {
+					continue
+				}
+				rotated, err := db.Keys.Rotate(*v, field)
+				if err != nil {
+					return fmt.Errorf("delivery %d: %s: %w", row.ID, field, err)
+				}
+				column := strings.TrimPrefix(field, "delivery.")
+				columns[column] = rotated
+				q = q.Where(column+" = ?", *v)
+			}
+			if len(columns) == 0 {
+				continue
+			}
+
+			// UpdateColumns keeps updated_at: the data itself did not change.
+			res := q.UpdateColumns(columns)
+			if res.Error != nil {
+				return res.Error
+			}
+			updated += int(res.RowsAffected)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return updated, nil
+}
+
+// payloadRow is the part of an outbox or webhook delivery row that
+// rotatePayloads reads.
+type payloadRow struct {
+	ID      uint64
+	Payload []byte
+}
+
+// rotatePayloads re-encrypts the event payloads of model's table. Dead
+// webhook deliveries can stay for a long time, so they would otherwise
+// keep a retired key in use.
+func (db *DB) rotatePayloads(ctx context.Context, model any, batchSize int, result *KeyRotationResult) error {
+	stmt := &gorm.Statement{DB: db.Conn}
+	if err := stmt.Parse(model); err != nil {
+		return err
+	}
+	table := stmt.Schema.Table
+
+	var lastID uint64
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var rows []payloadRow
+		err := db.Conn.WithContext(ctx).Table(table).
+			Select("id", "payload").
+			Where("id > ?", lastID).
+			Order("id ASC").
+			Limit(batchSize).
+			Find(&rows).Error
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		lastID = rows[len(rows)-1].ID
+
+		updated := 0
+		err = db.Conn.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			for _, row := range rows {
+				if !db.Keys.NeedsRotation(string(row.Payload)) {
+					continue
+				}
+				rotated, err := db.Keys.Rotate(string(row.Payload), db_models.EventPayloadAAD)
+				if err != nil {
+					return fmt.Errorf("%s %d: %w", table, row.ID, err)
+				}
+				// As for deliveries, a row deleted or changed since it was
+				// read is left alone.
+				res := tx.Table(table).
+					Where("id = ? AND payload = ?", row.ID, row.Payload).
+					UpdateColumn("payload", []byte(rotated))
+				if res.Error != nil {
+					return res.Error
+				}
+				updated += int(res.RowsAffected)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		result.Scanned += len(rows)
+		result.Updated += updated
+
+		db.Logger.InfoContext(ctx, "key rotation batch done",
+			"table", table, "scanned", result.Scanned, "updated", result.Updated, "last_id", lastID)
+	}
+}
+
+// rotateSecrets re-encrypts the webhook subscription secrets, deleted
+// subscriptions included. There are few subscriptions, so they are done
+// in one transaction.
+func (db *DB) rotateSecrets(ctx context.Context, result *KeyRotationResult) error {
+	var rows []db_models.WebhookSubscriptionDB
+	if err := db.Conn.WithContext(ctx).Unscoped().Select("id", "secret").Order("id ASC").Find(&rows).Error; err != nil {
+		return err
+	}
+
+	updated := 0
+	err := db.Conn.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, row := range rows {
+			if !db.Keys.NeedsRotation(row.Secret) {
+				continue
+			}
+			rotated, err := db.Keys.Rotate(row.Secret, db_models.WebhookSecretAAD)
+			if err != nil {
+				return fmt.Errorf("webhook subscription %d: %w", row.ID, err)
+			}
+			res := tx.Model(&db_models.WebhookSubscriptionDB{}).Unscoped().
+				Where("id = ? AND secret = ?", row.ID, row.Secret).
+				UpdateColumn("secret", rotated)
+			if res.Error != nil {
+				return res.Error
+			}
+			updated += int(res.RowsAffected)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	result.Scanned += len(rows)
+	result.Updated += updated
+
+	db.Logger.InfoContext(ctx, "key rotation batch done",
+		"table", "webhook_subscription_dbs", "scanned", result.Scanned, "updated", result.Updated)
+	return nil
+}
@@ -0,0 +1,246 @@
+package database_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"wb-tech-l0/internal/application/ports"
+	"wb-tech-l0/internal/fieldcrypt"
+	"wb-tech-l0/internal/logging"
+	"wb-tech-l0/internal/models"
+	"wb-tech-l0/internal/repository/cache"
+	dbpkg "wb-tech-l0/internal/repository/database"
+	"wb-tech-l0/internal/repository/database/db_models"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newKeyring(t *testing.T, primary string, ids ...string) *fieldcrypt.Keyring {
+	t.Helper()
+	keys := make(map[string][]byte, len(ids))
+	for i, id := range ids {
+		keys[id] = bytes.Repeat([]byte{byte(i + 1)}, 32)
+	}
+	k, err := fieldcrypt.NewKeyring(primary, keys)
+	require.NoError(t, err)
+	return k
+}
+
+func newEncryptedTestDB(t *testing.T, keys *fieldcrypt.Keyring) (*dbpkg.DB, *miniredis.Miniredis) {
+	t.Helper()
+
+	dsn := fmt.Sprintf("file:encrypted_%d?mode=memory&cache=shared", time.Now().UnixNano())
+	gdb, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+
+	db := &dbpkg.DB{
+		Conn:   gdb,
+		Cache:  cache.NewOrderCache(rdb, time.Hour, keys, logging.Discard()),
+		Keys:   keys,
+		Logger: logging.Discard(),
+	}
+	require.NoError(t, db.Migrate())
+	return db, mr
+}
+
+func TestOrderRepository_EncryptsPersonalData(t *testing.T) {
+	keys := newKeyring(t, "k1", "k1")
+	db, mr := newEncryptedTestDB(t, keys)
+	ctx := context.Background()
+
+	order := newTestOrder("enc-1")
+	saveOrder(t, db, order)
+
+	var row db_models.DeliveryDB
+	require.NoError(t, db.Conn.First(&row).Error)
+	for field, v := range row.EncryptedFields() {
+		assert.True(t, fieldcrypt.IsEncrypted(*v), field)
+	}
+	assert.Equal(t, order.Delivery.City, row.City, "non-personal columns stay readable")
+
+	cached, err := mr.Get("order:enc-1")
+	require.NoError(t, err)
+	assert.True(t, fieldcrypt.IsEncrypted(cached))
+	assert.NotContains(t, cached, order.Delivery.Email)
+
+	// Read through the cache and straight from the database.
+	got, err := db.GetOrder(ctx, "enc-1")
+	require.NoError(t, err)
+	assert.Equal(t, order.Delivery, got.Delivery)
+
+	mr.FlushAll()
+	got, err = db.GetOrder(ctx, "enc-1")
+	require.NoError(t, err)
+	assert.Equal(t, order.Delivery, got.Delivery)
+}
+
+func TestOrderRepository_EncryptsEventPayloads(t *testing.T) {
+	db, _ := newEncryptedTestDB(t, newKeyring(t, "k1", "k1"))
+	ctx := context.Background()
+
+	require.NoError(t, db.CreateSubscription(&models.WebhookSubscription{URL: "http://example.com/hook", Secret: "s", Active: true}))
+	order := newTestOrder("enc-1")
+	saveOrder(t, db, order)
+
+	var outbox db_models.OutboxEventDB
+	require.NoError(t, db.Conn.First(&outbox).Error)
+	assert.True(t, fieldcrypt.IsEncrypted(string(outbox.Payload)))
+	assert.NotContains(t, string(outbox.Payload), order.Delivery.Email)
+
+	var published []ports.OutboxEvent
+	_, err := db.RelayOutboxEvents(ctx, 10, func(events []ports.OutboxEvent) []uint64 {
+		published = events
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, published, 1)
+	var event models.OrderEvent
+	require.NoError(t, json.Unmarshal(published[0].Payload, &event))
+	assert.Equal(t, order.Delivery, event.Order.Delivery)
+
+	deliveries, err := db.ClaimDueDeliveries(time.Now().Add(time.Minute), 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.JSONEq(t, string(published[0].Payload), string(deliveries[0].Payload))
+
+	// Once delivered the row, and the payload with it, is gone.
+	require.NoError(t, db.MarkDelivered(deliveries[0].ID))
+	var left int64
+	require.NoError(t, db.Conn.Unscoped().Model(&db_models.WebhookDeliveryDB{}).Count(&left).Error)
+	assert.Zero(t, left)
+}
+
+func TestWebhookRepository_EncryptsSecrets(t *testing.T) {
+	db, _ := newEncryptedTestDB(t, newKeyring(t, "k1", "k1"))
+
+	sub := &models.WebhookSubscription{URL: "http://example.com/hook", Secret: "s3cret", Active: true}
+	require.NoError(t, db.CreateSubscription(sub))
+
+	var row db_models.WebhookSubscriptionDB
+	require.NoError(t, db.Conn.First(&row, sub.ID).Error)
+	assert.True(t, fieldcrypt.IsEncrypted(row.Secret))
+	assert.NotContains(t, row.Secret, "s3cret")
+
+	got, err := db.GetSubscription(sub.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", got.Secret)
+	all, err := db.ListSubscriptions()
+	require.NoError(t, err)
+	require.Len(t, all, 1)
+	assert.Equal(t, "s3cret", all[0].Secret)
+}
+
+func TestDB_RotateEncryptionKeys(t *testing.T) {
+	db, mr := newEncryptedTestDB(t, nil)
+	ctx := context.Background()
+
+	// Two orders written before encryption was enabled, one under the
+	// old key.
+	saveOrder(t, db, newTestOrder("plain-1"))
+	saveOrder(t, db, newTestOrder("plain-2"))
+	db.Keys = newKeyring(t, "old", "old")
+	saveOrder(t, db, newTestOrder("old-1"))
+	sub := &models.WebhookSubscription{URL: "http://example.com/hook", Secret: "s3cret", Active: true}
+	require.NoError(t, db.CreateSubscription(sub))
+
+	db.Keys = newKeyring(t, "new", "old", "new")
+	// Three delivery rows, the three outbox events of the orders and the
+	// webhook secret.
+	result, err := db.RotateEncryptionKeys(ctx, 2)
+	require.NoError(t, err)
+	assert.Equal(t, dbpkg.KeyRotationResult{Scanned: 7, Updated: 7}, result)
+
+	result, err = db.RotateEncryptionKeys(ctx, 2)
+	require.NoError(t, err)
+	assert.Equal(t, dbpkg.KeyRotationResult{Scanned: 7, Updated: 0}, result, "a second run has nothing to do")
+
+	var subRow db_models.WebhookSubscriptionDB
+	require.NoError(t, db.Conn.First(&subRow, sub.ID).Error)
+	assert.True(t, strings.HasPrefix(subRow.Secret, "enc:v1:new:"))
+	got, err := db.GetSubscription(sub.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", got.Secret)
+
+	var events []db_models.OutboxEventDB
+	require.NoError(t, db.Conn.Find(&events).Error)
+	require.Len(t, events, 3)
+	for _, e := range events {
+		assert.True(t, strings.HasPrefix(string(e.Payload), "enc:v1:new:"), e.EventID)
+	}
+
+	var rows []db_models.DeliveryDB
+	require.NoError(t, db.Conn.Find(&rows).Error)
+	require.Len(t, rows, 3)
+	for _, row := range rows {
+		for field, v := range row.EncryptedFields() {
+			assert.True(t, strings.HasPrefix(*v, "enc:v1:new:"), field)
+		}
+	}
+
+	mr.FlushAll()
+	for _, uid := range []string{"plain-1", "plain-2", "old-1"} {
+		got, err := db.GetOrder(ctx, uid)
+		require.NoError(t, err)
+		assert.Equal(t, newTestOrder(uid).Delivery, got.Delivery)
+	}
+}
+
+func TestDB_RotateEncryptionKeysSkipsConcurrentWrites(t *testing.T) {
+	db, mr := newEncryptedTestDB(t, newKeyring(t, "old", "old"))
+	ctx := context.Background()
+
+	erased, kept := newTestOrder("erase-1"), newTestOrder("keep-1")
+	erased.CustomerID, kept.CustomerID = "cust-1", "cust-2"
+	saveOrder(t, db, erased)
+	saveOrder(t, db, kept)
+
+	// The customer is erased right after rotation has read the delivery
+	// rows and before it writes them back.
+	armed := true
+	err := db.Conn.Callback().Query().After("gorm:query").Register("test:erase", func(tx *gorm.DB) {
+		if !armed || tx.Statement.Table != "delivery_dbs" {
+			return
+		}
+		armed = false
+		_, err := db.EraseCustomerData(ctx, ports.ErasureRequest{CustomerID: "cust-1", RequestedBy: "apikey:dpo"})
+		require.NoError(t, err)
+	})
+	require.NoError(t, err)
+
+	db.Keys = newKeyring(t, "new", "old", "new")
+	result, err := db.RotateEncryptionKeys(ctx, 10)
+	require.NoError(t, err)
+	assert.False(t, armed)
+	// Two delivery rows and the event of the kept order, whose own event
+	// the erasure deleted; the erased delivery row is left alone.
+	assert.Equal(t, dbpkg.KeyRotationResult{Scanned: 3, Updated: 2}, result)
+
+	mr.FlushAll()
+	got, err := db.GetOrder(ctx, "erase-1")
+	require.NoError(t, err)
+	assert.Equal(t, models.ErasedValue, got.Delivery.Name, "rotation must not write erased data back")
+	got, err = db.GetOrder(ctx, "keep-1")
+	require.NoError(t, err)
+	assert.Equal(t, kept.Delivery, got.Delivery)
+}
+
+func TestDB_RotateEncryptionKeysWithoutKeys(t *testing.T) {
+	db, _ := newEncryptedTestDB(t, nil)
+
+	_, err := db.RotateEncryptionKeys(context.Background(), 10)
+	assert.Error(t, err)
+}
@@ -1,88 +1,683 @@
 package database
 
 import (
-	"log"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
 	"wb-tech-l0/internal/application/ports"
+	"wb-tech-l0/internal/fieldcrypt"
+	"wb-tech-l0/internal/logging"
+	"wb-tech-l0/internal/metrics"
 	"wb-tech-l0/internal/models"
 	"wb-tech-l0/internal/repository/database/db_models"
+	"wb-tech-l0/internal/telemetry"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 var _ ports.OrderRepository = (*DB)(nil)
 
-func (db *DB) SaveOrder(order *models.Order) error {
-	err := db.Conn.Transaction(func(tx *gorm.DB) error {
-		deliveryDB := db_models.ToDeliveryDB(order.Delivery)
-		if err := tx.Create(&deliveryDB).Error; err != nil {
+var tracer = telemetry.Tracer("wb-tech-l0/internal/repository/database")
+
+// SaveOrder stores a new order or replaces a previously stored one with the
+// same UID. An OrderStored/OrderUpdated event is written to the outbox in the
+// same transaction, followed by OrderStatusChanged when the status of any item
+// changed. The daily rollups are updated in the same transaction. Saving an
+// unchanged order is a no-op, reported by changed being false. Orders of a
+// customer whose data was erased are anonymised in place before they are
+// stored.
+func (db *DB) SaveOrder(ctx context.Context, order *models.Order) (changed bool, err error) {
+	defer metrics.ObserveDB("save_order", time.Now())
+
+	ctx, span := tracer.Start(ctx, "db.SaveOrder",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "postgresql"),
+			attribute.String("order.uid", order.OrderUID),
+			attribute.Int("order.items", len(order.Items)),
+		),
+	)
+	defer func() { telemetry.End(span, err) }()
+
+	err = db.saveTransaction(ctx, func(tx *gorm.DB) error {
+		if err := applyErasures(tx, []*models.Order{order}); err != nil {
 			return err
 		}
 
-		paymentDB := db_models.ToPaymentDB(order)
-		if err := tx.Create(&paymentDB).Error; err != nil {
+		rollups := newRollupDelta()
+		var err error
+		if changed, err = saveOrder(tx, order, db.Keys, rollups); err != nil {
 			return err
 		}
+		return rollups.apply(tx)
+	})
+
+	if err != nil {
+		metrics.DBTransactionFailures.WithLabelValues("save_order").Inc()
+		return false, translateError(err)
+	}
 
-		orderDB := db_models.ToOrderDB(order, deliveryDB.ID, paymentDB.ID)
-		if err := tx.Create(&orderDB).Error; err != nil {
+	db.Cache.Set(ctx, order.OrderUID, order)
+	return changed, nil
+}
+
+// saveConflictRetries bounds the retries of a save that conflicted with a
+// concurrent transaction.
+const saveConflictRetries = 3
+
+// saveTransaction runs fn in a transaction, retrying it when it conflicts
+// with a concurrent one: typically two first saves of the same order, the
+// second failing on the unique order_uid. The retry finds the stored row
+// and replaces it like any later save, so the outcome is the same as if
+// the saves had not overlapped. fn must be safe to run again.
+func (db *DB) saveTransaction(ctx context.Context, fn func(tx *gorm.DB) error) error {
+	for attempt := 0; ; attempt++ {
+		err := db.Conn.WithContext(ctx).Transaction(fn)
+		if err == nil || attempt == saveConflictRetries || !isConflict(err) || ctx.Err() != nil {
 			return err
 		}
+		db.Logger.DebugContext(ctx, "save conflicted with a concurrent one, retrying", "attempt", attempt+1, logging.Err(err))
+	}
+}
 
-		for _, item := range order.Items {
-			itemDB := db_models.ToItemDB(item, order.OrderUID)
-			if err := tx.Create(&itemDB).Error; err != nil {
-				return err
+// saveOrder inserts or replaces one order. The stored row is read with
+// SELECT ... FOR UPDATE: a concurrent save of the same order waits for
+// this transaction and then diffs the rollups against the version stored
+// here, not the one both started from. It reports whether anything was
+// written.
+func saveOrder(tx *gorm.DB, order *models.Order, keys *fieldcrypt.Keyring, rollups *rollupDelta) (bool, error) {
+	var existing db_models.OrderDB
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("order_uid = ?", order.OrderUID).
+		Limit(1).
+		Find(&existing).Error
+	if err != nil {
+		return false, err
+	}
+
+	if existing.ID == 0 {
+		if err := insertOrder(tx, order, keys); err != nil {
+			return false, err
+		}
+		rollups.add(order, 1)
+		return true, recordEvents(tx, []models.OrderEvent{newOrderEvent(models.EventOrderStored, order)}, keys)
+	}
+	return replaceOrder(tx, existing, order, keys, rollups)
+}
+
+// replaceOrder updates a stored order unless it is unchanged, recording
+// OrderUpdated and, if an item status changed, OrderStatusChanged. The
+// stored version is taken out of the rollups and the new one counted in.
+// It reports whether the order was changed.
+func replaceOrder(tx *gorm.DB, existing db_models.OrderDB, order *models.Order, keys *fieldcrypt.Keyring, rollups *rollupDelta) (bool, error) {
+	stored, err := loadOrder(tx, existing, keys)
+	if err != nil {
+		return false, err
+	}
+	if sameOrder(stored, order) {
+		return false, nil
+	}
+	if err := updateOrder(tx, existing, order, keys); err != nil {
+		return false, err
+	}
+	rollups.add(stored, -1)
+	rollups.add(order, 1)
+
+	events := []models.OrderEvent{newOrderEvent(models.EventOrderUpdated, order)}
+	if itemStatusChanged(stored, order) {
+		events = append(events, newOrderEvent(models.EventOrderStatusChanged, order))
+	}
+	return true, recordEvents(tx, events, keys)
+}
+
+// insertBatchSize bounds the rows of one multi-row INSERT, keeping it
+// well under the PostgreSQL limit of 65535 bind parameters.
+const insertBatchSize = 500
+
+// SaveOrders stores the orders in one transaction, with the same outcome
+// and events as saving them one by one in order. New orders are written
+// with one multi-row INSERT per table; orders already stored, or present
+// more than once in the batch, are saved one at a time. The rollup changes
+// of the whole batch are applied at the end. If any order fails nothing is
+// stored. Erased customers' orders are anonymised as in SaveOrder.
+// changed[i] reports whether orders[i] was written.
+func (db *DB) SaveOrders(ctx context.Context, orders []*models.Order) (changed []bool, err error) {
+	if len(orders) == 0 {
+		return nil, nil
+	}
+
+	defer metrics.ObserveDB("save_orders", time.Now())
+
+	ctx, span := tracer.Start(ctx, "db.SaveOrders",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "postgresql"),
+			attribute.Int("orders", len(orders)),
+		),
+	)
+	defer func() { telemetry.End(span, err) }()
+
+	err = db.saveTransaction(ctx, func(tx *gorm.DB) error {
+		changed = make([]bool, len(orders))
+		if err := applyErasures(tx, orders); err != nil {
+			return err
+		}
+
+		count := make(map[string]int, len(orders))
+		uids := make([]string, 0, len(orders))
+		for _, o := range orders {
+			if count[o.OrderUID] == 0 {
+				uids = append(uids, o.OrderUID)
 			}
+			count[o.OrderUID]++
 		}
 
-		return nil
+		// Locked as in saveOrder, in UID order so that overlapping batches
+		// do not deadlock.
+		var existing []db_models.OrderDB
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("order_uid IN ?", uids).
+			Order("order_uid").
+			Find(&existing).Error
+		if err != nil {
+			return err
+		}
+		stored := make(map[string]db_models.OrderDB, len(existing))
+		for _, o := range existing {
+			stored[o.OrderUID] = o
+		}
+
+		rollups := newRollupDelta()
+		var fresh []*models.Order
+		for i, o := range orders {
+			if _, ok := stored[o.OrderUID]; !ok && count[o.OrderUID] == 1 {
+				fresh = append(fresh, o)
+				changed[i] = true
+			}
+		}
+		if err := insertOrders(tx, fresh, db.Keys, rollups); err != nil {
+			return err
+		}
+
+		for i, o := range orders {
+			var err error
+			if _, ok := stored[o.OrderUID]; ok {
+				changed[i], err = replaceOrder(tx, stored[o.OrderUID], o, db.Keys, rollups)
+			} else if count[o.OrderUID] > 1 {
+				changed[i], err = saveOrder(tx, o, db.Keys, rollups)
+			}
+			if err != nil {
+				return fmt.Errorf("order %s: %w", o.OrderUID, err)
+			}
+		}
+		return rollups.apply(tx)
 	})
 
+	if err != nil {
+		metrics.DBTransactionFailures.WithLabelValues("save_orders").Inc()
+		return nil, translateError(err)
+	}
+
+	db.Cache.SetMany(ctx, orders)
+	return changed, nil
+}
+
+// insertOrders writes new orders with multi-row INSERTs and records an
+// OrderStored event for each.
+func insertOrders(tx *gorm.DB, orders []*models.Order, keys *fieldcrypt.Keyring, rollups *rollupDelta) error {
+	if len(orders) == 0 {
+		return nil
+	}
+
+	deliveries := make([]db_models.DeliveryDB, len(orders))
+	payments := make([]db_models.PaymentDB, len(orders))
+	for i, o := range orders {
+		d, err := db_models.ToDeliveryDB(o.Delivery, keys)
+		if err != nil {
+			return err
+		}
+		deliveries[i] = d
+		payments[i] = db_models.ToPaymentDB(o)
+	}
+	if err := tx.CreateInBatches(&deliveries, insertBatchSize).Error; err != nil {
+		return err
+	}
+	if err := tx.CreateInBatches(&payments, insertBatchSize).Error; err != nil {
+		return err
+	}
+
+	orderDBs := make([]db_models.OrderDB, len(orders))
+	var items []db_models.ItemDB
+	events := make([]models.OrderEvent, len(orders))
+	for i, o := range orders {
+		orderDBs[i] = db_models.ToOrderDB(o, deliveries[i].ID, payments[i].ID)
+		for _, item := range o.Items {
+			items = append(items, db_models.ToItemDB(item, o.OrderUID))
+		}
+		events[i] = newOrderEvent(models.EventOrderStored, o)
+		rollups.add(o, 1)
+	}
+	if err := tx.CreateInBatches(&orderDBs, insertBatchSize).Error; err != nil {
+		return err
+	}
+	if len(items) > 0 {
+		if err := tx.CreateInBatches(&items, insertBatchSize).Error; err != nil {
+			return err
+		}
+	}
+	return recordEvents(tx, events, keys)
+}
+
+func insertOrder(tx *gorm.DB, order *models.Order, keys *fieldcrypt.Keyring) error {
+	deliveryDB, err := db_models.ToDeliveryDB(order.Delivery, keys)
 	if err != nil {
 		return err
 	}
+	if err := tx.Create(&deliveryDB).Error; err != nil {
+		return err
+	}
 
-	db.Cache.Set(order.OrderUID, order)
+	paymentDB := db_models.ToPaymentDB(order)
+	if err := tx.Create(&paymentDB).Error; err != nil {
+		return err
+	}
+
+	orderDB := db_models.ToOrderDB(order, deliveryDB.ID, paymentDB.ID)
+	if err := tx.Create(&orderDB).Error; err != nil {
+		return err
+	}
+
+	return insertItems(tx, order)
+}
+
+func insertItems(tx *gorm.DB, order *models.Order) error {
+	for _, item := range order.Items {
+		itemDB := db_models.ToItemDB(item, order.OrderUID)
+		if err := tx.Create(&itemDB).Error; err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func (db *DB) GetOrder(orderUID string) (*models.Order, error) {
-	if order, ok := db.Cache.Get(orderUID); ok {
-		log.Printf("Order %s found in cache", orderUID)
+// updateOrder overwrites the rows of an already stored order in place,
+// keeping their primary keys and creation timestamps.
+func updateOrder(tx *gorm.DB, existing db_models.OrderDB, order *models.Order, keys *fieldcrypt.Keyring) error {
+	deliveryDB, err := db_models.ToDeliveryDB(order.Delivery, keys)
+	if err != nil {
+		return err
+	}
+	if err := overwrite(tx, &db_models.DeliveryDB{}, existing.DeliveryID, &deliveryDB); err != nil {
+		return err
+	}
+
+	paymentDB := db_models.ToPaymentDB(order)
+	if err := overwrite(tx, &db_models.PaymentDB{}, existing.PaymentID, &paymentDB); err != nil {
+		return err
+	}
+
+	orderDB := db_models.ToOrderDB(order, existing.DeliveryID, existing.PaymentID)
+	if err := overwrite(tx, &db_models.OrderDB{}, existing.ID, &orderDB); err != nil {
+		return err
+	}
+
+	if err := tx.Unscoped().Where("order_uid = ?", order.OrderUID).Delete(&db_models.ItemDB{}).Error; err != nil {
+		return err
+	}
+	return insertItems(tx, order)
+}
+
+func overwrite(tx *gorm.DB, model interface{}, id uint, values interface{}) error {
+	return tx.Model(model).
+		Where("id = ?", id).
+		Select("*").
+		Omit("id", "created_at", "deleted_at").
+		Updates(values).Error
+}
+
+// sameOrder reports whether the stored order already matches the incoming
+// one. Timestamps are compared at the precision they are stored with.
+func sameOrder(stored, incoming *models.Order) bool {
+	normalized := *incoming
+	normalized.DateCreated = time.Unix(incoming.DateCreated.Unix(), 0)
+
+	a, err := json.Marshal(stored)
+	if err != nil {
+		return false
+	}
+	b, err := json.Marshal(&normalized)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(a, b)
+}
+
+// itemStatusChanged reports whether any item present in both versions of the
+// order (matched by rid) has a different status.
+func itemStatusChanged(stored, incoming *models.Order) bool {
+	statuses := make(map[string]int, len(stored.Items))
+	for _, item := range stored.Items {
+		statuses[item.RID] = item.Status
+	}
+	for _, item := range incoming.Items {
+		if status, ok := statuses[item.RID]; ok && status != item.Status {
+			return true
+		}
+	}
+	return false
+}
+
+func (db *DB) GetOrder(ctx context.Context, orderUID string) (*models.Order, error) {
+	if order, ok := db.Cache.Get(ctx, orderUID); ok {
+		db.Logger.DebugContext(ctx, "order found in cache", logging.KeyOrderUID, orderUID)
 		return order, nil
 	}
 
-	order, err := db.loadOrderFromDB(orderUID)
+	order, err := db.loadOrderFromDB(ctx, orderUID)
 	if err != nil {
-		return nil, err
+		return nil, translateError(err)
 	}
 
-	db.Cache.Set(orderUID, order)
+	db.Cache.Set(ctx, orderUID, order)
 	return order, nil
 }
 
-func (db *DB) loadOrderFromDB(orderUID string) (*models.Order, error) {
+func (db *DB) loadOrderFromDB(ctx context.Context, orderUID string) (order *models.Order, err error) {
+	defer metrics.ObserveDB("get_order", time.Now())
+
+	ctx, span := tracer.Start(ctx, "db.GetOrder",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "postgresql"),
+			attribute.String("order.uid", orderUID),
+		),
+	)
+	defer func() { telemetry.End(span, err) }()
+
+	conn := db.Conn.WithContext(ctx)
+
 	var orderDB db_models.OrderDB
-	if err := db.Conn.Where("order_uid = ?", orderUID).First(&orderDB).Error; err != nil {
+	if err := conn.Where("order_uid = ?", orderUID).First(&orderDB).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: %s", ports.ErrOrderNotFound, orderUID)
+		}
 		return nil, err
 	}
 
+	return loadOrder(conn, orderDB, db.Keys)
+}
+
+func loadOrder(conn *gorm.DB, orderDB db_models.OrderDB, keys *fieldcrypt.Keyring) (*models.Order, error) {
 	var deliveryDB db_models.DeliveryDB
-	if err := db.Conn.First(&deliveryDB, orderDB.DeliveryID).Error; err != nil {
+	if err := conn.First(&deliveryDB, orderDB.DeliveryID).Error; err != nil {
 		return nil, err
 	}
 
 	var paymentDB db_models.PaymentDB
-	if err := db.Conn.Where("order_uid = ?", orderUID).First(&paymentDB).Error; err != nil {
+	if err := conn.Where("order_uid = ?", orderDB.OrderUID).First(&paymentDB).Error; err != nil {
 		return nil, err
 	}
 
 	var itemsDB []db_models.ItemDB
-	if err := db.Conn.Where("order_uid = ?", orderUID).Find(&itemsDB).Error; err != nil {
+	if err := conn.Where("order_uid = ?", orderDB.OrderUID).Order("id ASC").Find(&itemsDB).Error; err != nil {
 		return nil, err
 	}
 
-	return db_models.ToDomainOrder(orderDB, deliveryDB, paymentDB, itemsDB), nil
+	return db_models.ToDomainOrder(orderDB, deliveryDB, paymentDB, itemsDB, keys)
+}
+
+// GetOrders serves cache hits with one MGET, loads the misses from the
+// database in one batch and writes them back to the cache.
+func (db *DB) GetOrders(ctx context.Context, orderUIDs []string) (map[string]*models.Order, error) {
+	found := db.Cache.GetMany(ctx, orderUIDs)
+
+	misses := make([]string, 0, len(orderUIDs)-len(found))
+	for _, uid := range orderUIDs {
+		if _, ok := found[uid]; !ok {
+			misses = append(misses, uid)
+		}
+	}
+	if len(misses) == 0 {
+		return found, nil
+	}
+
+	defer metrics.ObserveDB("get_orders", time.Now())
+
+	var orderDBs []db_models.OrderDB
+	if err := db.Conn.WithContext(ctx).Where("order_uid IN ?", misses).Find(&orderDBs).Error; err != nil {
+		return nil, translateError(err)
+	}
+
+	loaded, err := loadOrders(db.Conn.WithContext(ctx), orderDBs, db.Keys)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	for _, order := range loaded {
+		found[order.OrderUID] = order
+	}
+
+	db.Cache.SetMany(ctx, loaded)
+	return found, nil
+}
+
+func (db *DB) ListOrders(ctx context.Context, query ports.ListOrdersQuery) (page ports.OrderPage, err error) {
+	defer metrics.ObserveDB("list_orders", time.Now())
+
+	ctx, span := tracer.Start(ctx, "db.ListOrders",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "postgresql"),
+			attribute.Int("limit", query.Limit),
+		),
+	)
+	defer func() { telemetry.End(span, err) }()
+
+	conn := db.Conn.WithContext(ctx)
+	tx := applyOrderFilter(conn.Model(&db_models.OrderDB{}), query.Filter)
+	if query.Cursor != "" {
+		lastID, err := strconv.ParseUint(query.Cursor, 10, 64)
+		if err != nil {
+			return ports.OrderPage{}, ports.ErrInvalidCursor
+		}
+		tx = tx.Where("id < ?", lastID)
+	}
+
+	// Fetch one extra row to find out whether another page exists.
+	var orderDBs []db_models.OrderDB
+	if err := tx.Order("id DESC").Limit(query.Limit + 1).Find(&orderDBs).Error; err != nil {
+		return ports.OrderPage{}, translateError(err)
+	}
+
+	if len(orderDBs) > query.Limit {
+		orderDBs = orderDBs[:query.Limit]
+		page.NextCursor = strconv.FormatUint(uint64(orderDBs[len(orderDBs)-1].ID), 10)
+	}
+
+	orders, err := loadOrders(conn, orderDBs, db.Keys)
+	if err != nil {
+		return ports.OrderPage{}, translateError(err)
+	}
+	page.Orders = orders
+	return page, nil
+}
+
+// exportBatchSize is how many orders ExportOrders holds in memory at once.
+const exportBatchSize = 1000
+
+// ExportOrders streams the orders matching filter to fn in id order, one
+// batch at a time, inside a read-only transaction so the export sees one
+// snapshot. On Postgres the rows come from a server-side cursor; other
+// databases are read in keyset pages. fn returning an error stops the
+// export with that error.
+func (db *DB) ExportOrders(ctx context.Context, filter ports.OrderFilter, fn func(*models.Order) error) error {
+	defer metrics.ObserveDB("export_orders", time.Now())
+
+	postgres := db.Conn.Dialector.Name() == "postgres"
+	opts := &sql.TxOptions{ReadOnly: true}
+	if postgres {
+		opts.Isolation = sql.LevelRepeatableRead
+	}
+
+	err := db.Conn.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		next := keysetBatches(tx, filter)
+		if postgres {
+			var err error
+			if next, err = cursorBatches(ctx, tx, filter); err != nil {
+				return err
+			}
+		}
+
+		for {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			orderDBs, err := next()
+			if err != nil {
+				return err
+			}
+			if len(orderDBs) == 0 {
+				return nil
+			}
+
+			orders, err := loadOrders(tx, orderDBs, db.Keys)
+			if err != nil {
+				return err
+			}
+			for _, order := range orders {
+				if err := fn(order); err != nil {
+					return exportAborted{err}
+				}
+			}
+		}
+	}, opts)
+
+	var aborted exportAborted
+	if errors.As(err, &aborted) {
+		return aborted.err
+	}
+	return translateError(err)
+}
+
+// exportAborted carries an error of the ExportOrders callback past
+// translateError.
+type exportAborted struct{ err error }
+
+func (e exportAborted) Error() string { return e.err.Error() }
+
+// cursorBatches declares a cursor over the filtered orders in tx and
+// returns a function fetching its next batch.
+func cursorBatches(ctx context.Context, tx *gorm.DB, filter ports.OrderFilter) (func() ([]db_models.OrderDB, error), error) {
+	stmt := applyOrderFilter(tx.Session(&gorm.Session{DryRun: true}).Model(&db_models.OrderDB{}), filter).
+		Order("id ASC").
+		Find(&[]db_models.OrderDB{}).Statement
+	declare := "DECLARE order_export NO SCROLL CURSOR FOR " + stmt.SQL.String()
+	if _, err := tx.Statement.ConnPool.ExecContext(ctx, declare, stmt.Vars...); err != nil {
+		return nil, err
+	}
+
+	fetch := fmt.Sprintf("FETCH FORWARD %d FROM order_export", exportBatchSize)
+	return func() ([]db_models.OrderDB, error) {
+		var orderDBs []db_models.OrderDB
+		err := tx.Raw(fetch).Scan(&orderDBs).Error
+		return orderDBs, err
+	}, nil
+}
+
+// keysetBatches returns a function reading the filtered orders in tx page
+// by page, in id order.
+func keysetBatches(tx *gorm.DB, filter ports.OrderFilter) func() ([]db_models.OrderDB, error) {
+	var lastID uint
+	return func() ([]db_models.OrderDB, error) {
+		var orderDBs []db_models.OrderDB
+		err := applyOrderFilter(tx.Model(&db_models.OrderDB{}), filter).
+			Where("id > ?", lastID).
+			Order("id ASC").
+			Limit(exportBatchSize).
+			Find(&orderDBs).Error
+		if len(orderDBs) > 0 {
+			lastID = orderDBs[len(orderDBs)-1].ID
+		}
+		return orderDBs, err
+	}
+}
+
+func applyOrderFilter(tx *gorm.DB, f ports.OrderFilter) *gorm.DB {
+	if f.DeliveryService != "" {
+		tx = tx.Where("delivery_service = ?", f.DeliveryService)
+	}
+	if f.Entry != "" {
+		tx = tx.Where("entry = ?", f.Entry)
+	}
+	if f.CustomerID != "" {
+		tx = tx.Where("customer_id = ?", f.CustomerID)
+	}
+	if !f.CreatedFrom.IsZero() {
+		tx = tx.Where("date_created >= ?", f.CreatedFrom.Unix())
+	}
+	if !f.CreatedTo.IsZero() {
+		tx = tx.Where("date_created < ?", f.CreatedTo.Unix())
+	}
+	return tx
+}
+
+// loadOrders assembles domain orders for the given rows with one query per
+// related table, preserving the order of orderDBs.
+func loadOrders(conn *gorm.DB, orderDBs []db_models.OrderDB, keys *fieldcrypt.Keyring) ([]*models.Order, error) {
+	if len(orderDBs) == 0 {
+		return []*models.Order{}, nil
+	}
+
+	uids := make([]string, len(orderDBs))
+	deliveryIDs := make([]uint, len(orderDBs))
+	for i, o := range orderDBs {
+		uids[i] = o.OrderUID
+		deliveryIDs[i] = o.DeliveryID
+	}
+
+	var deliveryDBs []db_models.DeliveryDB
+	if err := conn.Where("id IN ?", deliveryIDs).Find(&deliveryDBs).Error; err != nil {
+		return nil, err
+	}
+	deliveries := make(map[uint]db_models.DeliveryDB, len(deliveryDBs))
+	for _, d := range deliveryDBs {
+		deliveries[d.ID] = d
+	}
+
+	var paymentDBs []db_models.PaymentDB
+	if err := conn.Where("order_uid IN ?", uids).Find(&paymentDBs).Error; err != nil {
+		return nil, err
+	}
+	payments := make(map[string]db_models.PaymentDB, len(paymentDBs))
+	for _, p := range paymentDBs {
+		payments[p.OrderUID] = p
+	}
+
+	var itemDBs []db_models.ItemDB
+	if err := conn.Where("order_uid IN ?", uids).Order("id ASC").Find(&itemDBs).Error; err != nil {
+		return nil, err
+	}
+	items := make(map[string][]db_models.ItemDB, len(orderDBs))
+	for _, it := range itemDBs {
+		items[it.OrderUID] = append(items[it.OrderUID], it)
+	}
+
+	orders := make([]*models.Order, len(orderDBs))
+	for i, o := range orderDBs {
+		order, err := db_models.ToDomainOrder(o, deliveries[o.DeliveryID], payments[o.OrderUID], items[o.OrderUID], keys)
+		if err != nil {
+			return nil, fmt.Errorf("order %s: %w", o.OrderUID, err)
+		}
+		orders[i] = order
+	}
+	return orders, nil
 }
 
 func (db *DB) LoadOrdersToCache(maxOrdersCount int) error {
@@ -92,22 +687,21 @@ func (db *DB) LoadOrdersToCache(maxOrdersCount int) error {
 	}
 
 	for _, odb := range orderDBs {
-		order, err := db.GetOrder(odb.OrderUID)
-		if err != nil {
-			log.Printf("Failed to load order %s: %v", odb.OrderUID, err)
-			continue
+		if _, err := db.GetOrder(context.Background(), odb.OrderUID); err != nil {
+			db.Logger.Warn("failed to load order to cache", logging.KeyOrderUID, odb.OrderUID, logging.Err(err))
 		}
-		_ = order
 	}
 
-	log.Printf("Loaded %d orders to cache", db.Cache.Size())
+	db.Logger.Info("orders loaded to cache", "cache_size", db.Cache.Size())
 	return nil
 }
 
 func (db *DB) GetOrderCount() (int64, error) {
+	defer metrics.ObserveDB("count_orders", time.Now())
+
 	var count int64
 	if err := db.Conn.Model(&db_models.OrderDB{}).Count(&count).Error; err != nil {
-		return 0, err
+		return 0, translateError(err)
 	}
 	return count, nil
 }
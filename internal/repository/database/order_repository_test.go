@@ -1,16 +1,21 @@
 package database_test
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"testing"
 	"time"
 
+	"wb-tech-l0/internal/application/ports"
+	"wb-tech-l0/internal/logging"
 	"wb-tech-l0/internal/models"
 	"wb-tech-l0/internal/repository/cache"
 	dbpkg "wb-tech-l0/internal/repository/database"
 	"wb-tech-l0/internal/repository/database/db_models"
 
 	"github.com/alicebob/miniredis/v2"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -48,18 +53,17 @@ func newTestDB(t *testing.T) (*dbpkg.DB, func()) {
 	gdb, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
 	require.NoError(t, err)
 
-	// Run migrations for required tables
-	err = gdb.AutoMigrate(&db_models.DeliveryDB{}, &db_models.PaymentDB{}, &db_models.OrderDB{}, &db_models.ItemDB{})
-	require.NoError(t, err)
-
 	// Miniredis for cache
 	mr, err := miniredis.Run()
 	require.NoError(t, err)
 
 	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
-	oc := cache.NewOrderCache(rdb, time.Hour)
+	oc := cache.NewOrderCache(rdb, time.Hour, nil, logging.Discard())
 
-	db := &dbpkg.DB{Conn: gdb, Cache: oc}
+	db := &dbpkg.DB{Conn: gdb, Cache: oc, Logger: logging.Discard()}
+
+	// Run migrations for required tables
+	require.NoError(t, db.Migrate())
 	cleanup := func() {
 		rdb.Close()
 		mr.Close()
@@ -67,16 +71,31 @@ func newTestDB(t *testing.T) (*dbpkg.DB, func()) {
 	return db, cleanup
 }
 
+// saveOrder saves the order and reports whether it was written.
+func saveOrder(t *testing.T, db *dbpkg.DB, order *models.Order) bool {
+	t.Helper()
+	changed, err := db.SaveOrder(context.Background(), order)
+	require.NoError(t, err)
+	return changed
+}
+
+// saveOrders saves the orders in one batch and reports which were written.
+func saveOrders(t *testing.T, db *dbpkg.DB, orders []*models.Order) []bool {
+	t.Helper()
+	changed, err := db.SaveOrders(context.Background(), orders)
+	require.NoError(t, err)
+	return changed
+}
+
 func TestOrderRepository_SaveAndGetOrder(t *testing.T) {
 	db, cleanup := newTestDB(t)
 	defer cleanup()
 
 	order := newTestOrder("uid-save-1")
-	err := db.SaveOrder(order)
-	require.NoError(t, err)
+	assert.True(t, saveOrder(t, db, order))
 
 	// Fetch back
-	got, err := db.GetOrder(order.OrderUID)
+	got, err := db.GetOrder(context.Background(), order.OrderUID)
 	require.NoError(t, err)
 	require.NotNil(t, got)
 
@@ -86,6 +105,21 @@ func TestOrderRepository_SaveAndGetOrder(t *testing.T) {
 	assert.Equal(t, order.Items[0].RID, got.Items[0].RID)
 }
 
+func TestOrderRepository_GetOrderErrors(t *testing.T) {
+	db, cleanup := newTestDB(t)
+	defer cleanup()
+
+	_, err := db.GetOrder(context.Background(), "unknown")
+	assert.ErrorIs(t, err, ports.ErrOrderNotFound)
+
+	// A database that does not answer in time is reported as unavailable.
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	_, err = db.GetOrder(ctx, "unknown")
+	assert.ErrorIs(t, err, ports.ErrUnavailable)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
 func TestOrderRepository_GetOrderCount(t *testing.T) {
 	db, cleanup := newTestDB(t)
 	defer cleanup()
@@ -96,7 +130,7 @@ func TestOrderRepository_GetOrderCount(t *testing.T) {
 	assert.Equal(t, int64(0), cnt)
 
 	// add one
-	require.NoError(t, db.SaveOrder(newTestOrder("uid-count-1")))
+	saveOrder(t, db, newTestOrder("uid-count-1"))
 	cnt, err = db.GetOrderCount()
 	require.NoError(t, err)
 	assert.Equal(t, int64(1), cnt)
@@ -107,14 +141,413 @@ func TestOrderRepository_LoadAllOrdersToCache(t *testing.T) {
 	defer cleanup()
 
 	// populate two orders
-	require.NoError(t, db.SaveOrder(newTestOrder("uid-cache-1")))
-	require.NoError(t, db.SaveOrder(newTestOrder("uid-cache-2")))
+	saveOrder(t, db, newTestOrder("uid-cache-1"))
+	saveOrder(t, db, newTestOrder("uid-cache-2"))
 
 	// Clear redis by recreating client through the same addr is complex; rely on method behavior filling cache
-	err := db.LoadAllOrdersToCache()
+	err := db.LoadOrdersToCache(10)
 	require.NoError(t, err)
 
 	// We expect cache to have at least 2 keys
 	size := db.CacheSize()
 	assert.GreaterOrEqual(t, size, 2)
 }
+
+func TestOrderRepository_CacheSizeCountsOrdersOnly(t *testing.T) {
+	db, mr := newEncryptedTestDB(t, nil)
+
+	saveOrder(t, db, newTestOrder("uid-size-1"))
+	// The rate limiter keeps its buckets in the same Redis DB.
+	require.NoError(t, mr.Set("ratelimit:key:reader", "1"))
+
+	assert.Equal(t, 1, db.CacheSize())
+}
+
+// pendingEvents returns the outbox events without publishing any.
+func pendingEvents(t *testing.T, db *dbpkg.DB, limit int) []ports.OutboxEvent {
+	t.Helper()
+
+	var pending []ports.OutboxEvent
+	_, err := db.RelayOutboxEvents(context.Background(), limit, func(events []ports.OutboxEvent) []uint64 {
+		pending = events
+		return nil
+	})
+	require.NoError(t, err)
+	return pending
+}
+
+func TestOrderRepository_RetriesSaveAfterConflict(t *testing.T) {
+	db, cleanup := newTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	// Inserts of an order fail as if a concurrent save of the same order
+	// had just stored it, conflicts[uid] times.
+	conflicts := map[string]int{"uid-race": 1, "uid-hot": 100}
+	attempts := map[string]int{}
+	err := db.Conn.Callback().Create().Before("gorm:create").Register("test:conflict", func(tx *gorm.DB) {
+		var uid string
+		switch dest := tx.Statement.Dest.(type) {
+		case *db_models.OrderDB:
+			uid = dest.OrderUID
+		case []db_models.OrderDB:
+			uid = dest[0].OrderUID
+		default:
+			return
+		}
+		attempts[uid]++
+		if attempts[uid] <= conflicts[uid] {
+			_ = tx.AddError(&pgconn.PgError{Code: "23505", TableName: "order_dbs"})
+		}
+	})
+	require.NoError(t, err)
+
+	saveOrder(t, db, newTestOrder("uid-race"))
+	assert.Equal(t, 2, attempts["uid-race"])
+	events := pendingEvents(t, db, 10)
+	require.Len(t, events, 1, "the failed attempt left nothing behind")
+	assert.Equal(t, models.EventOrderStored, events[0].EventType)
+	check, err := db.CheckRollups(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, check.Mismatches)
+
+	// Retries are bounded.
+	_, err = db.SaveOrders(ctx, []*models.Order{newTestOrder("uid-hot")})
+	assert.ErrorIs(t, err, ports.ErrConflict)
+	assert.Equal(t, 4, attempts["uid-hot"])
+}
+
+func TestOrderRepository_SaveOrderWritesOutboxEvent(t *testing.T) {
+	db, cleanup := newTestDB(t)
+	defer cleanup()
+
+	order := newTestOrder("uid-outbox-1")
+	saveOrder(t, db, order)
+
+	events := pendingEvents(t, db, 10)
+	require.Len(t, events, 1)
+	assert.Equal(t, models.EventOrderStored, events[0].EventType)
+	assert.Equal(t, order.OrderUID, events[0].OrderUID)
+
+	var payload models.OrderEvent
+	require.NoError(t, json.Unmarshal(events[0].Payload, &payload))
+	assert.Equal(t, events[0].EventID, payload.EventID)
+	require.NotNil(t, payload.Order)
+	assert.Equal(t, order.OrderUID, payload.Order.OrderUID)
+
+	n, err := db.RelayOutboxEvents(context.Background(), 10, func(events []ports.OutboxEvent) []uint64 {
+		return []uint64{events[0].ID}
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Empty(t, pendingEvents(t, db, 10))
+}
+
+func TestOrderRepository_SaveOrderUpdatesExistingOrder(t *testing.T) {
+	db, cleanup := newTestDB(t)
+	defer cleanup()
+
+	order := newTestOrder("uid-update-1")
+	saveOrder(t, db, order)
+
+	// Saving the same order again must not produce a new event.
+	assert.False(t, saveOrder(t, db, order), "unchanged order is not written")
+
+	updated := newTestOrder("uid-update-1")
+	updated.DateCreated = order.DateCreated
+	updated.Delivery.City = "Other City"
+	updated.Items = append(updated.Items, models.Item{ChrtID: 2, TrackNumber: "ABCDEFGHJK", Price: 50, RID: "rid-2", Name: "Second", Size: "L", TotalPrice: 50, NmID: 2, Brand: "brand", Status: 201})
+	assert.True(t, saveOrder(t, db, updated))
+
+	cnt, err := db.GetOrderCount()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), cnt)
+
+	var stored db_models.OrderDB
+	require.NoError(t, db.Conn.Where("order_uid = ?", updated.OrderUID).First(&stored).Error)
+	var delivery db_models.DeliveryDB
+	require.NoError(t, db.Conn.First(&delivery, stored.DeliveryID).Error)
+	assert.Equal(t, "Other City", delivery.City)
+	var items []db_models.ItemDB
+	require.NoError(t, db.Conn.Where("order_uid = ?", updated.OrderUID).Find(&items).Error)
+	assert.Len(t, items, 2)
+
+	events := pendingEvents(t, db, 10)
+	require.Len(t, events, 2)
+	assert.Equal(t, models.EventOrderStored, events[0].EventType)
+	assert.Equal(t, models.EventOrderUpdated, events[1].EventType)
+}
+
+func TestOrderRepository_SaveOrders(t *testing.T) {
+	db, cleanup := newTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	all := &models.WebhookSubscription{URL: "http://all.example", Secret: "a", Active: true}
+	require.NoError(t, db.CreateSubscription(all))
+
+	stored := newTestOrder("uid-batch-stored")
+	saveOrder(t, db, stored)
+
+	changed := newTestOrder("uid-batch-stored")
+	changed.DateCreated = stored.DateCreated
+	changed.Items[0].Status = 202
+	first := newTestOrder("uid-batch-1")
+	second := newTestOrder("uid-batch-2")
+	second.Items = append(second.Items, models.Item{ChrtID: 2, TrackNumber: "ABCDEFGHJK", Price: 50, RID: "rid-2", Name: "Second", Size: "L", TotalPrice: 50, NmID: 2, Brand: "brand", Status: 201})
+	// The same order twice in one batch: the later version wins.
+	twice := newTestOrder("uid-batch-twice")
+	twiceUpdated := newTestOrder("uid-batch-twice")
+	twiceUpdated.DateCreated = twice.DateCreated
+	twiceUpdated.Delivery.City = "Other City"
+
+	unchanged := newTestOrder("uid-batch-stored")
+	unchanged.DateCreated = stored.DateCreated
+	unchanged.Items[0].Status = 202
+
+	assert.Equal(t, []bool{true, true, true, true, true, false},
+		saveOrders(t, db, []*models.Order{first, changed, twice, second, twiceUpdated, unchanged}))
+
+	cnt, err := db.GetOrderCount()
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), cnt)
+
+	for _, want := range []*models.Order{first, second, changed, twiceUpdated} {
+		require.NoError(t, db.Cache.Delete(ctx, want.OrderUID))
+		got, err := db.GetOrder(ctx, want.OrderUID)
+		require.NoError(t, err)
+		assert.Equal(t, want.Delivery.City, got.Delivery.City)
+		assert.Len(t, got.Items, len(want.Items))
+		assert.Equal(t, want.Items[0].Status, got.Items[0].Status)
+	}
+
+	events := pendingEvents(t, db, 20)
+	var got []string
+	for _, e := range events {
+		got = append(got, e.OrderUID+" "+e.EventType)
+	}
+	assert.ElementsMatch(t, []string{
+		"uid-batch-stored " + models.EventOrderStored,
+		"uid-batch-1 " + models.EventOrderStored,
+		"uid-batch-2 " + models.EventOrderStored,
+		"uid-batch-stored " + models.EventOrderUpdated,
+		"uid-batch-stored " + models.EventOrderStatusChanged,
+		"uid-batch-twice " + models.EventOrderStored,
+		"uid-batch-twice " + models.EventOrderUpdated,
+	}, got)
+
+	due, err := db.ClaimDueDeliveries(time.Now().Add(time.Minute), 20, time.Minute)
+	require.NoError(t, err)
+	assert.Len(t, due, len(events))
+}
+
+func TestOrderRepository_SaveOrdersIsAtomic(t *testing.T) {
+	db, cleanup := newTestDB(t)
+	defer cleanup()
+
+	// Without the items table the last insert of the batch fails.
+	require.NoError(t, db.Conn.Migrator().DropTable(&db_models.ItemDB{}))
+
+	_, err := db.SaveOrders(context.Background(), []*models.Order{newTestOrder("uid-atomic-1"), newTestOrder("uid-atomic-2")})
+	require.Error(t, err)
+
+	cnt, err := db.GetOrderCount()
+	require.NoError(t, err)
+	assert.Zero(t, cnt)
+	events := pendingEvents(t, db, 10)
+	assert.Empty(t, events)
+}
+
+func TestOrderRepository_SaveOrderEnqueuesWebhookDeliveries(t *testing.T) {
+	db, cleanup := newTestDB(t)
+	defer cleanup()
+
+	all := &models.WebhookSubscription{URL: "http://all.example", Secret: "a", Active: true}
+	statusOnly := &models.WebhookSubscription{URL: "http://status.example", Secret: "b", Active: true, EventTypes: []string{models.EventOrderStatusChanged}}
+	require.NoError(t, db.CreateSubscription(all))
+	require.NoError(t, db.CreateSubscription(statusOnly))
+
+	order := newTestOrder("uid-webhook-1")
+	saveOrder(t, db, order)
+
+	changed := newTestOrder("uid-webhook-1")
+	changed.DateCreated = order.DateCreated
+	changed.Items[0].Status = 202
+	saveOrder(t, db, changed)
+
+	due, err := db.ClaimDueDeliveries(time.Now().Add(time.Minute), 10, time.Minute)
+	require.NoError(t, err)
+
+	types := map[uint][]string{}
+	for _, d := range due {
+		types[d.SubscriptionID] = append(types[d.SubscriptionID], d.EventType)
+	}
+	assert.Equal(t, []string{models.EventOrderStored, models.EventOrderUpdated, models.EventOrderStatusChanged}, types[all.ID])
+	assert.Equal(t, []string{models.EventOrderStatusChanged}, types[statusOnly.ID])
+}
+
+func TestOrderRepository_ReplayDeadDeliveries(t *testing.T) {
+	db, cleanup := newTestDB(t)
+	defer cleanup()
+
+	sub := &models.WebhookSubscription{URL: "http://sub.example", Secret: "s", Active: true}
+	require.NoError(t, db.CreateSubscription(sub))
+	saveOrder(t, db, newTestOrder("uid-replay-1"))
+
+	due, err := db.ClaimDueDeliveries(time.Now().Add(time.Minute), 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	require.NoError(t, db.MarkFailed(due[0].ID, 5, time.Now(), true, "boom"))
+
+	dead, err := db.ListDeadLetters(sub.ID)
+	require.NoError(t, err)
+	require.Len(t, dead, 1)
+	assert.Equal(t, "boom", dead[0].LastError)
+
+	replayed, err := db.ReplayDeliveries(sub.ID, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), replayed)
+
+	dead, err = db.ListDeadLetters(sub.ID)
+	require.NoError(t, err)
+	assert.Empty(t, dead)
+
+	due, err = db.ClaimDueDeliveries(time.Now().Add(time.Minute), 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	assert.Equal(t, 0, due[0].Attempts)
+}
+
+func TestOrderRepository_ClaimDueDeliveries(t *testing.T) {
+	db, cleanup := newTestDB(t)
+	defer cleanup()
+
+	a := &models.WebhookSubscription{URL: "http://a.example", Secret: "a", Active: true}
+	b := &models.WebhookSubscription{URL: "http://b.example", Secret: "b", Active: true}
+	require.NoError(t, db.CreateSubscription(a))
+	require.NoError(t, db.CreateSubscription(b))
+	saveOrder(t, db, newTestOrder("uid-claim-1"))
+	saveOrder(t, db, newTestOrder("uid-claim-2"))
+
+	claim := func(now time.Time) map[uint][]string {
+		t.Helper()
+		due, err := db.ClaimDueDeliveries(now, 10, time.Minute)
+		require.NoError(t, err)
+		got := map[uint][]string{}
+		for _, d := range due {
+			got[d.SubscriptionID] = append(got[d.SubscriptionID], d.OrderUID)
+		}
+		return got
+	}
+	ids := func(subID uint) []uint {
+		t.Helper()
+		var ids []uint
+		require.NoError(t, db.Conn.Model(&db_models.WebhookDeliveryDB{}).
+			Where("subscription_id = ?", subID).Order("id").Pluck("id", &ids).Error)
+		return ids
+	}
+
+	now := time.Now().Add(time.Minute)
+	assert.Equal(t, map[uint][]string{
+		a.ID: {"uid-claim-1", "uid-claim-2"},
+		b.ID: {"uid-claim-1", "uid-claim-2"},
+	}, claim(now))
+	assert.Empty(t, claim(now), "claimed deliveries are not handed out twice")
+
+	// The first event of a fails and is retried in an hour; its second
+	// is released unsent, and b gets its first one.
+	aIDs, bIDs := ids(a.ID), ids(b.ID)
+	require.NoError(t, db.MarkFailed(aIDs[0], 1, now.Add(time.Hour), false, "boom"))
+	require.NoError(t, db.ReleaseDeliveries(aIDs[1:]))
+	require.NoError(t, db.MarkDelivered(bIDs[0]))
+	assert.Empty(t, claim(now), "a's second event waits for its first")
+
+	// An expired claim is taken over.
+	assert.Equal(t, map[uint][]string{b.ID: {"uid-claim-2"}}, claim(now.Add(2*time.Minute)))
+	require.NoError(t, db.MarkDelivered(bIDs[1]))
+
+	assert.Equal(t, map[uint][]string{a.ID: {"uid-claim-1", "uid-claim-2"}}, claim(now.Add(2*time.Hour)))
+}
+
+func TestOrderRepository_ListOrders(t *testing.T) {
+	db, cleanup := newTestDB(t)
+	defer cleanup()
+
+	for i, service := range []string{"meest", "dhl", "meest", "meest"} {
+		o := newTestOrder(fmt.Sprintf("uid-list-%d", i))
+		o.DeliveryService = service
+		saveOrder(t, db, o)
+	}
+
+	filter := ports.OrderFilter{DeliveryService: "meest"}
+	page, err := db.ListOrders(context.Background(), ports.ListOrdersQuery{Filter: filter, Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, page.Orders, 2)
+	assert.Equal(t, "uid-list-3", page.Orders[0].OrderUID)
+	assert.Equal(t, "uid-list-2", page.Orders[1].OrderUID)
+	require.Len(t, page.Orders[0].Items, 1)
+	assert.Equal(t, "john@example.com", page.Orders[0].Delivery.Email)
+	require.NotEmpty(t, page.NextCursor)
+
+	page, err = db.ListOrders(context.Background(), ports.ListOrdersQuery{Filter: filter, Limit: 2, Cursor: page.NextCursor})
+	require.NoError(t, err)
+	require.Len(t, page.Orders, 1)
+	assert.Equal(t, "uid-list-0", page.Orders[0].OrderUID)
+	assert.Empty(t, page.NextCursor)
+
+	_, err = db.ListOrders(context.Background(), ports.ListOrdersQuery{Limit: 2, Cursor: "not-a-cursor"})
+	assert.ErrorIs(t, err, ports.ErrInvalidCursor)
+}
+
+func TestOrderRepository_ExportOrders(t *testing.T) {
+	db, cleanup := newTestDB(t)
+	defer cleanup()
+
+	for i, service := range []string{"meest", "dhl", "meest"} {
+		o := newTestOrder(fmt.Sprintf("uid-export-%d", i))
+		o.DeliveryService = service
+		saveOrder(t, db, o)
+	}
+
+	var uids []string
+	err := db.ExportOrders(context.Background(), ports.OrderFilter{DeliveryService: "meest"}, func(o *models.Order) error {
+		require.Len(t, o.Items, 1)
+		uids = append(uids, o.OrderUID)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"uid-export-0", "uid-export-2"}, uids)
+
+	// An error of the callback stops the export and is returned as is.
+	calls := 0
+	err = db.ExportOrders(context.Background(), ports.OrderFilter{}, func(*models.Order) error {
+		calls++
+		return assert.AnError
+	})
+	assert.Same(t, assert.AnError, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestOrderRepository_GetOrdersMixesCacheAndDB(t *testing.T) {
+	db, cleanup := newTestDB(t)
+	defer cleanup()
+
+	saveOrder(t, db, newTestOrder("uid-batch-1"))
+	saveOrder(t, db, newTestOrder("uid-batch-2"))
+
+	// Evict one order so that it has to come from the database.
+	require.NoError(t, db.Cache.Delete(context.Background(), "uid-batch-2"))
+	_, cached := db.Cache.Get(context.Background(), "uid-batch-2")
+	require.False(t, cached)
+
+	found, err := db.GetOrders(context.Background(), []string{"uid-batch-1", "uid-batch-2", "uid-missing"})
+	require.NoError(t, err)
+	require.Len(t, found, 2)
+	assert.Equal(t, "uid-batch-1", found["uid-batch-1"].OrderUID)
+	require.Len(t, found["uid-batch-2"].Items, 1)
+	assert.Equal(t, "john@example.com", found["uid-batch-2"].Delivery.Email)
+
+	// The miss has been written back to the cache.
+	_, cached = db.Cache.Get(context.Background(), "uid-batch-2")
+	assert.True(t, cached)
+}
@@ -0,0 +1,100 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"wb-tech-l0/internal/application/ports"
+	"wb-tech-l0/internal/fieldcrypt"
+	"wb-tech-l0/internal/metrics"
+	"wb-tech-l0/internal/models"
+	"wb-tech-l0/internal/repository/database/db_models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var _ ports.OutboxRepository = (*DB)(nil)
+
+func newOrderEvent(eventType string, order *models.Order) models.OrderEvent {
+	return models.OrderEvent{
+		EventID:    uuid.NewString(),
+		EventType:  eventType,
+		OrderUID:   order.OrderUID,
+		OccurredAt: time.Now().UTC(),
+		Order:      order,
+	}
+}
+
+// recordEvents stores the events in the outbox and schedules webhook
+// deliveries for them, inside the caller's transaction. Rows are written
+// with multi-row INSERTs in event order; payloads are encrypted with keys.
+func recordEvents(tx *gorm.DB, events []models.OrderEvent, keys *fieldcrypt.Keyring) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	rows := make([]db_models.OutboxEventDB, len(events))
+	for i, e := range events {
+		row, err := db_models.ToOutboxEventDB(e, keys)
+		if err != nil {
+			return err
+		}
+		rows[i] = row
+	}
+	if err := tx.CreateInBatches(&rows, insertBatchSize).Error; err != nil {
+		return err
+	}
+	return enqueueWebhookDeliveries(tx, events, rows)
+}
+
+// RelayOutboxEvents reads the batch with SELECT ... FOR UPDATE and keeps
+// the rows locked while publish runs. SKIP LOCKED is deliberately not
+// used: a second relay would then move on to later events, possibly of
+// the same orders, while the first is still publishing. Instead it waits
+// on the first locked row and continues once the batch is settled.
+func (db *DB) RelayOutboxEvents(ctx context.Context, limit int, publish func([]ports.OutboxEvent) []uint64) (int, error) {
+	defer metrics.ObserveDB("relay_outbox_events", time.Now())
+
+	var published []uint64
+	err := db.Conn.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var rows []db_models.OutboxEventDB
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Order("id ASC").
+			Limit(limit).
+			Find(&rows).Error
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+
+		events := make([]ports.OutboxEvent, len(rows))
+		for i, row := range rows {
+			payload, err := db_models.DecryptEventPayload(row.Payload, db.Keys)
+			if err != nil {
+				return fmt.Errorf("outbox event %d: %w", row.ID, err)
+			}
+			events[i] = ports.OutboxEvent{
+				ID:        row.ID,
+				EventID:   row.EventID,
+				EventType: row.EventType,
+				OrderUID:  row.OrderUID,
+				Payload:   payload,
+				CreatedAt: row.CreatedAt,
+			}
+		}
+
+		published = publish(events)
+		if len(published) == 0 {
+			return nil
+		}
+		return tx.Where("id IN ?", published).Delete(&db_models.OutboxEventDB{}).Error
+	})
+	if err != nil {
+		return 0, translateError(err)
+	}
+	return len(published), nil
+}
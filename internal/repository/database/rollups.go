@@ -0,0 +1,339 @@
+package database
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"wb-tech-l0/internal/application/ports"
+	"wb-tech-l0/internal/metrics"
+	"wb-tech-l0/internal/models"
+	"wb-tech-l0/internal/repository/database/db_models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// rollupDay is the start of the UTC day of t in unix seconds, the bucket
+// of the rollup tables.
+func rollupDay(t time.Time) int64 {
+	s := t.Unix()
+	return s - s%86400
+}
+
+type orderRollupKey struct {
+	day                                 int64
+	deliveryService, provider, currency string
+}
+
+type brandRollupKey struct {
+	day             int64
+	brand, currency string
+}
+
+// rollupDelta collects the changes the orders written in a transaction
+// make to the rollup tables.
+type rollupDelta struct {
+	orders map[orderRollupKey]db_models.OrderRollupDB
+	brands map[brandRollupKey]db_models.BrandRollupDB
+}
+
+func newRollupDelta() *rollupDelta {
+	return &rollupDelta{
+		orders: make(map[orderRollupKey]db_models.OrderRollupDB),
+		brands: make(map[brandRollupKey]db_models.BrandRollupDB),
+	}
+}
+
+// add counts the order in with sign 1, or takes a previously stored
+// version of it out with sign -1. Like the reports computed from the order
+// tables, it counts every order and item whatever the item status: the
+// statuses are not interpreted, so a cancelled item is not told apart.
+func (d *rollupDelta) add(o *models.Order, sign int64) {
+	day := rollupDay(o.DateCreated)
+
+	key := orderRollupKey{day, o.DeliveryService, o.Payment.Provider, o.Payment.Currency}
+	row := d.orders[key]
+	row.Day, row.DeliveryService, row.Provider, row.Currency = key.day, key.deliveryService, key.provider, key.currency
+	row.Orders += sign
+	row.Items += sign * int64(len(o.Items))
+	row.Revenue += sign * int64(o.Payment.Amount)
+	row.GoodsTotal += sign * int64(o.Payment.GoodsTotal)
+	d.orders[key] = row
+
+	for _, item := range o.Items {
+		key := brandRollupKey{day, item.Brand, o.Payment.Currency}
+		row := d.brands[key]
+		row.Day, row.Brand, row.Currency = key.day, key.brand, key.currency
+		row.Units += sign
+		row.Revenue += sign * int64(item.TotalPrice)
+		d.brands[key] = row
+	}
+}
+
+// apply adds the collected changes to the rollup rows with upserts. Rows
+// are written in key order, so concurrent transactions lock them in the
+// same order and cannot deadlock each other. Rows an update left without
+// orders or units are removed.
+func (d *rollupDelta) apply(tx *gorm.DB) error {
+	var orders []db_models.OrderRollupDB
+	var emptiedDays []int64
+	for _, row := range d.orders {
+		if row.Orders == 0 && row.Items == 0 && row.Revenue == 0 && row.GoodsTotal == 0 {
+			continue
+		}
+		if row.Orders < 0 {
+			emptiedDays = append(emptiedDays, row.Day)
+		}
+		orders = append(orders, row)
+	}
+	slices.SortFunc(orders, func(a, b db_models.OrderRollupDB) int {
+		return cmp.Or(
+			cmp.Compare(a.Day, b.Day),
+			strings.Compare(a.DeliveryService, b.DeliveryService),
+			strings.Compare(a.Provider, b.Provider),
+			strings.Compare(a.Currency, b.Currency),
+		)
+	})
+
+	var brands []db_models.BrandRollupDB
+	for _, row := range d.brands {
+		if row.Units == 0 && row.Revenue == 0 {
+			continue
+		}
+		if row.Units < 0 {
+			emptiedDays = append(emptiedDays, row.Day)
+		}
+		brands = append(brands, row)
+	}
+	slices.SortFunc(brands, func(a, b db_models.BrandRollupDB) int {
+		return cmp.Or(
+			cmp.Compare(a.Day, b.Day),
+			strings.Compare(a.Brand, b.Brand),
+			strings.Compare(a.Currency, b.Currency),
+		)
+	})
+
+	if len(orders) > 0 {
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "day"}, {Name: "delivery_service"}, {Name: "provider"}, {Name: "currency"}},
+			DoUpdates: addExcluded("order_rollup_dbs", "orders", "items", "revenue", "goods_total"),
+		}).CreateInBatches(&orders, insertBatchSize).Error
+		if err != nil {
+			return err
+		}
+	}
+	if len(brands) > 0 {
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "day"}, {Name: "brand"}, {Name: "currency"}},
+			DoUpdates: addExcluded("brand_rollup_dbs", "units", "revenue"),
+		}).CreateInBatches(&brands, insertBatchSize).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(emptiedDays) == 0 {
+		return nil
+	}
+	if err := tx.Where("day IN ? AND orders = 0", emptiedDays).Delete(&db_models.OrderRollupDB{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("day IN ? AND units = 0", emptiedDays).Delete(&db_models.BrandRollupDB{}).Error
+}
+
+// addExcluded sets each column to its stored value plus the inserted one.
+func addExcluded(table string, columns ...string) clause.Set {
+	set := make(clause.Set, len(columns))
+	for i, c := range columns {
+		set[i] = clause.Assignment{
+			Column: clause.Column{Name: c},
+			Value:  gorm.Expr(table + "." + c + " + excluded." + c),
+		}
+	}
+	return set
+}
+
+// aggregateRollups computes the rollup rows from the order tables.
+func aggregateRollups(conn *gorm.DB) ([]db_models.OrderRollupDB, []db_models.BrandRollupDB, error) {
+	day := periodStart(ports.IntervalDay, "o.date_created")
+
+	itemCounts := conn.Table("item_dbs").
+		Select("order_uid, COUNT(*) AS items").
+		Where("deleted_at IS NULL").
+		Group("order_uid")
+	var orders []db_models.OrderRollupDB
+	err := liveOrders(conn).
+		Joins("LEFT JOIN (?) AS ic ON ic.order_uid = o.order_uid", itemCounts).
+		Select(day + " AS day, o.delivery_service AS delivery_service, p.provider AS provider, p.currency AS currency, " +
+			"COUNT(*) AS orders, CAST(COALESCE(SUM(ic.items), 0) AS BIGINT) AS items, " +
+			"CAST(SUM(p.amount) AS BIGINT) AS revenue, CAST(SUM(p.goods_total) AS BIGINT) AS goods_total").
+		Group("day, o.delivery_service, p.provider, p.currency").
+		Scan(&orders).Error
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var brands []db_models.BrandRollupDB
+	err = liveOrders(conn).
+		Joins("JOIN item_dbs AS i ON i.order_uid = o.order_uid AND i.deleted_at IS NULL").
+		Select(day + " AS day, i.brand AS brand, p.currency AS currency, COUNT(*) AS units, CAST(SUM(i.total_price) AS BIGINT) AS revenue").
+		Group("day, i.brand, p.currency").
+		Scan(&brands).Error
+	if err != nil {
+		return nil, nil, err
+	}
+	return orders, brands, nil
+}
+
+// RollupCheck compares the rollup tables with the order tables.
+type RollupCheck struct {
+	// OrderRows and BrandRows are the numbers of rows computed from the
+	// order tables.
+	OrderRows  int              `json:"order_rows"`
+	BrandRows  int              `json:"brand_rows"`
+	Mismatches []RollupMismatch `json:"mismatches"`
+}
+
+// RollupMismatch is a rollup row that differs from the order tables. Want
+// or Got is empty when the row is missing on that side.
+type RollupMismatch struct {
+	Table string `json:"table"`
+	Key   string `json:"key"`
+	Want  string `json:"want"`
+	Got   string `json:"got"`
+}
+
+// CheckRollups compares the rollup tables with aggregates of the order
+// tables, reading both in one read-only snapshot.
+func (db *DB) CheckRollups(ctx context.Context) (RollupCheck, error) {
+	defer metrics.ObserveDB("check_rollups", time.Now())
+
+	opts := &sql.TxOptions{ReadOnly: true}
+	if db.Conn.Dialector.Name() == "postgres" {
+		opts.Isolation = sql.LevelRepeatableRead
+	}
+
+	var check RollupCheck
+	err := db.Conn.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		orders, brands, err := aggregateRollups(tx)
+		if err != nil {
+			return err
+		}
+		check, err = compareRollups(tx, orders, brands)
+		return err
+	}, opts)
+	if err != nil {
+		return RollupCheck{}, translateError(err)
+	}
+	return check, nil
+}
+
+// RebuildRollups recomputes the rollup tables from the order tables in one
+// transaction and reports how the replaced rows differed from them. On
+// PostgreSQL the rollup tables are locked first: order writes wait for the
+// rebuild instead of having their changes overwritten by it.
+func (db *DB) RebuildRollups(ctx context.Context) (RollupCheck, error) {
+	defer metrics.ObserveDB("rebuild_rollups", time.Now())
+
+	var check RollupCheck
+	err := db.Conn.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if tx.Dialector.Name() == "postgres" {
+			if err := tx.Exec("LOCK TABLE order_rollup_dbs, brand_rollup_dbs IN EXCLUSIVE MODE").Error; err != nil {
+				return err
+			}
+		}
+
+		orders, brands, err := aggregateRollups(tx)
+		if err != nil {
+			return err
+		}
+		if check, err = compareRollups(tx, orders, brands); err != nil {
+			return err
+		}
+
+		all := tx.Session(&gorm.Session{AllowGlobalUpdate: true})
+		if err := all.Delete(&db_models.OrderRollupDB{}).Error; err != nil {
+			return err
+		}
+		if err := all.Delete(&db_models.BrandRollupDB{}).Error; err != nil {
+			return err
+		}
+		if len(orders) > 0 {
+			if err := tx.CreateInBatches(&orders, insertBatchSize).Error; err != nil {
+				return err
+			}
+		}
+		if len(brands) > 0 {
+			if err := tx.CreateInBatches(&brands, insertBatchSize).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return RollupCheck{}, translateError(err)
+	}
+	return check, nil
+}
+
+// compareRollups compares the stored rollup rows with the expected ones.
+func compareRollups(tx *gorm.DB, orders []db_models.OrderRollupDB, brands []db_models.BrandRollupDB) (RollupCheck, error) {
+	var storedOrders []db_models.OrderRollupDB
+	if err := tx.Find(&storedOrders).Error; err != nil {
+		return RollupCheck{}, err
+	}
+	var storedBrands []db_models.BrandRollupDB
+	if err := tx.Find(&storedBrands).Error; err != nil {
+		return RollupCheck{}, err
+	}
+
+	check := RollupCheck{OrderRows: len(orders), BrandRows: len(brands), Mismatches: []RollupMismatch{}}
+	check.Mismatches = append(check.Mismatches, diffRollups("order_rollup_dbs", orders, storedOrders,
+		func(r db_models.OrderRollupDB) string {
+			return fmt.Sprintf("%s %q %q %q", formatDay(r.Day), r.DeliveryService, r.Provider, r.Currency)
+		},
+		func(r db_models.OrderRollupDB) string {
+			return fmt.Sprintf("orders=%d items=%d revenue=%d goods_total=%d", r.Orders, r.Items, r.Revenue, r.GoodsTotal)
+		},
+	)...)
+	check.Mismatches = append(check.Mismatches, diffRollups("brand_rollup_dbs", brands, storedBrands,
+		func(r db_models.BrandRollupDB) string {
+			return fmt.Sprintf("%s %q %q", formatDay(r.Day), r.Brand, r.Currency)
+		},
+		func(r db_models.BrandRollupDB) string {
+			return fmt.Sprintf("units=%d revenue=%d", r.Units, r.Revenue)
+		},
+	)...)
+	return check, nil
+}
+
+// diffRollups lists the rows of want and got that differ, matched by key.
+func diffRollups[T any](table string, want, got []T, key, values func(T) string) []RollupMismatch {
+	stored := make(map[string]string, len(got))
+	for _, row := range got {
+		stored[key(row)] = values(row)
+	}
+
+	var diffs []RollupMismatch
+	for _, row := range want {
+		k, v := key(row), values(row)
+		if g, ok := stored[k]; !ok || g != v {
+			diffs = append(diffs, RollupMismatch{Table: table, Key: k, Want: v, Got: g})
+		}
+		delete(stored, k)
+	}
+	for k, g := range stored {
+		diffs = append(diffs, RollupMismatch{Table: table, Key: k, Got: g})
+	}
+	slices.SortFunc(diffs, func(a, b RollupMismatch) int { return strings.Compare(a.Key, b.Key) })
+	return diffs
+}
+
+func formatDay(day int64) string {
+	return time.Unix(day, 0).UTC().Format(time.DateOnly)
+}
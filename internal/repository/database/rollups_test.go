@@ -0,0 +1,191 @@
+package database_test
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"wb-tech-l0/internal/logging"
+	"wb-tech-l0/internal/models"
+	"wb-tech-l0/internal/repository/cache"
+	dbpkg "wb-tech-l0/internal/repository/database"
+	"wb-tech-l0/internal/repository/database/db_models"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestRollups_FollowOrderWrites(t *testing.T) {
+	db, cleanup := newTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	created := time.Date(2025, 3, 3, 10, 0, 0, 0, time.UTC)
+	order := func(uid, service string, amount int, brands ...string) *models.Order {
+		o := newTestOrder(uid)
+		o.DateCreated = created
+		o.DeliveryService = service
+		o.Payment.Amount = amount
+		o.Items = nil
+		for _, brand := range brands {
+			o.Items = append(o.Items, models.Item{ChrtID: 1, TrackNumber: "ABCDEFGHJK", Price: 10, RID: "rid-" + brand, Name: "Item", Size: "M", TotalPrice: 10, NmID: 1, Brand: brand, Status: 200})
+		}
+		return o
+	}
+
+	saveOrder(t, db, order("a", "dhl", 100, "acme", "zeta"))
+	saveOrders(t, db, []*models.Order{
+		order("b", "dhl", 50, "acme"),
+		order("c", "ups", 70, "zeta"),
+		order("c", "ups", 80, "zeta", "zeta"),
+	})
+	// Moving an order to another delivery service takes it out of its old
+	// row, which is removed once empty.
+	saveOrder(t, db, order("b", "cdek", 60))
+	saveOrders(t, db, []*models.Order{order("a", "dhl", 90, "acme")})
+
+	day := created.Truncate(24 * time.Hour).Unix()
+	var orders []db_models.OrderRollupDB
+	require.NoError(t, db.Conn.Order("delivery_service").Find(&orders).Error)
+	assert.Equal(t, []db_models.OrderRollupDB{
+		{Day: day, DeliveryService: "cdek", Provider: "wbpay", Currency: "USD", Orders: 1, Items: 0, Revenue: 60, GoodsTotal: 90},
+		{Day: day, DeliveryService: "dhl", Provider: "wbpay", Currency: "USD", Orders: 1, Items: 1, Revenue: 90, GoodsTotal: 90},
+		{Day: day, DeliveryService: "ups", Provider: "wbpay", Currency: "USD", Orders: 1, Items: 2, Revenue: 80, GoodsTotal: 90},
+	}, orders)
+
+	var brands []db_models.BrandRollupDB
+	require.NoError(t, db.Conn.Order("brand").Find(&brands).Error)
+	assert.Equal(t, []db_models.BrandRollupDB{
+		{Day: day, Brand: "acme", Currency: "USD", Units: 1, Revenue: 10},
+		{Day: day, Brand: "zeta", Currency: "USD", Units: 2, Revenue: 20},
+	}, brands)
+
+	check, err := db.CheckRollups(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, check.OrderRows)
+	assert.Equal(t, 2, check.BrandRows)
+	assert.Empty(t, check.Mismatches)
+}
+
+func TestRollups_RebuildRepairsDrift(t *testing.T) {
+	db, cleanup := newTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	o := newTestOrder("a")
+	o.DateCreated = time.Date(2025, 3, 3, 10, 0, 0, 0, time.UTC)
+	saveOrder(t, db, o)
+
+	require.NoError(t, db.Conn.Model(&db_models.OrderRollupDB{}).Where("delivery_service = ?", "meest").Update("orders", 5).Error)
+	require.NoError(t, db.Conn.Create(&db_models.BrandRollupDB{Day: 0, Brand: "ghost", Currency: "USD", Units: 1, Revenue: 1}).Error)
+
+	check, err := db.CheckRollups(ctx)
+	require.NoError(t, err)
+	require.Len(t, check.Mismatches, 2)
+	assert.Equal(t, dbpkg.RollupMismatch{
+		Table: "order_rollup_dbs",
+		Key:   `2025-03-03 "meest" "wbpay" "USD"`,
+		Want:  "orders=1 items=1 revenue=100 goods_total=90",
+		Got:   "orders=5 items=1 revenue=100 goods_total=90",
+	}, check.Mismatches[0])
+	assert.Equal(t, dbpkg.RollupMismatch{
+		Table: "brand_rollup_dbs",
+		Key:   `1970-01-01 "ghost" "USD"`,
+		Got:   "units=1 revenue=1",
+	}, check.Mismatches[1])
+
+	rebuilt, err := db.RebuildRollups(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, check, rebuilt)
+
+	check, err = db.CheckRollups(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, check.Mismatches)
+}
+
+func TestRollups_MigrateFillsNewTables(t *testing.T) {
+	db, cleanup := newTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	saveOrder(t, db, newTestOrder("a"))
+	// As before an upgrade: orders are stored, the rollup tables do not exist.
+	require.NoError(t, db.Conn.Migrator().DropTable(&db_models.OrderRollupDB{}, &db_models.BrandRollupDB{}))
+
+	require.NoError(t, db.Migrate())
+
+	check, err := db.CheckRollups(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, check.OrderRows)
+	assert.Empty(t, check.Mismatches)
+	var count int64
+	require.NoError(t, db.Conn.Model(&db_models.OrderRollupDB{}).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestRollups_ConcurrentUpdatesDoNotDrift(t *testing.T) {
+	// A file database with BEGIN IMMEDIATE: SQLite has no row locks, so
+	// writers take the database lock up front, which is what FOR UPDATE
+	// gives on PostgreSQL for the rows concerned.
+	dsn := "file:" + filepath.Join(t.TempDir(), "orders.db") + "?_txlock=immediate&_busy_timeout=10000"
+	gdb, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	rdb := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	db := &dbpkg.DB{Conn: gdb, Cache: cache.NewOrderCache(rdb, time.Hour, nil, logging.Discard()), Logger: logging.Discard()}
+	require.NoError(t, db.Migrate())
+	ctx := context.Background()
+
+	// Reads of stored orders must ask for the row lock.
+	var unlocked atomic.Int32
+	err = db.Conn.Callback().Query().Before("gorm:query").Register("test:order_locks", func(tx *gorm.DB) {
+		if _, locked := tx.Statement.Clauses["FOR"]; tx.Statement.Table == "order_dbs" && !locked {
+			unlocked.Add(1)
+		}
+	})
+	require.NoError(t, err)
+
+	version := func(i int) *models.Order {
+		o := newTestOrder("a")
+		o.DateCreated = time.Date(2025, 3, 3, 10, 0, 0, 0, time.UTC)
+		o.DeliveryService = []string{"dhl", "ups", "cdek"}[i%3]
+		o.Payment.Amount = 100 + i
+		o.Items[0].Brand = fmt.Sprintf("brand-%d", i%2)
+		return o
+	}
+	saveOrder(t, db, version(0))
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 1; i <= 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if i%2 == 0 {
+				_, err := db.SaveOrder(ctx, version(i))
+				errs <- err
+			} else {
+				_, err := db.SaveOrders(ctx, []*models.Order{version(i), newTestOrder(fmt.Sprintf("other-%d", i))})
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+	assert.Zero(t, unlocked.Load())
+
+	check, err := db.CheckRollups(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, check.Mismatches)
+}
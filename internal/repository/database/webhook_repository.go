@@ -0,0 +1,233 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"wb-tech-l0/internal/application/ports"
+	"wb-tech-l0/internal/fieldcrypt"
+	"wb-tech-l0/internal/metrics"
+	"wb-tech-l0/internal/models"
+	"wb-tech-l0/internal/repository/database/db_models"
+
+	"gorm.io/gorm"
+)
+
+var _ ports.WebhookRepository = (*DB)(nil)
+
+// enqueueWebhookDeliveries schedules each event for every active
+// subscription interested in it, inside the caller's transaction. rows are
+// the outbox rows of the events, whose payload is delivered.
+func enqueueWebhookDeliveries(tx *gorm.DB, events []models.OrderEvent, rows []db_models.OutboxEventDB) error {
+	var subs []db_models.WebhookSubscriptionDB
+	if err := tx.Where("active = ?", true).Find(&subs).Error; err != nil {
+		return err
+	}
+
+	var deliveries []db_models.WebhookDeliveryDB
+	for i, event := range events {
+		for _, s := range subs {
+			if !s.Accepts(event.EventType) {
+				continue
+			}
+
+			deliveries = append(deliveries, db_models.WebhookDeliveryDB{
+				SubscriptionID: s.ID,
+				EventID:        event.EventID,
+				EventType:      event.EventType,
+				OrderUID:       event.OrderUID,
+				Payload:        rows[i].Payload,
+				Status:         models.WebhookDeliveryPending,
+				NextAttemptAt:  event.OccurredAt,
+			})
+		}
+	}
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return tx.CreateInBatches(&deliveries, insertBatchSize).Error
+}
+
+func (db *DB) CreateSubscription(sub *models.WebhookSubscription) error {
+	row, err := db_models.ToWebhookSubscriptionDB(*sub, db.Keys)
+	if err != nil {
+		return err
+	}
+	if err := db.Conn.Create(&row).Error; err != nil {
+		return err
+	}
+
+	sub.ID = row.ID
+	sub.CreatedAt = row.CreatedAt
+	return nil
+}
+
+func (db *DB) GetSubscription(id uint) (*models.WebhookSubscription, error) {
+	var row db_models.WebhookSubscriptionDB
+	if err := db.Conn.First(&row, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ports.ErrSubscriptionNotFound
+		}
+		return nil, err
+	}
+
+	sub, err := db_models.ToDomainWebhookSubscription(row, db.Keys)
+	if err != nil {
+		return nil, fmt.Errorf("webhook subscription %d: %w", row.ID, err)
+	}
+	return &sub, nil
+}
+
+func (db *DB) ListSubscriptions() ([]models.WebhookSubscription, error) {
+	var rows []db_models.WebhookSubscriptionDB
+	if err := db.Conn.Order("id ASC").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	subs := make([]models.WebhookSubscription, len(rows))
+	for i, row := range rows {
+		sub, err := db_models.ToDomainWebhookSubscription(row, db.Keys)
+		if err != nil {
+			return nil, fmt.Errorf("webhook subscription %d: %w", row.ID, err)
+		}
+		subs[i] = sub
+	}
+	return subs, nil
+}
+
+func (db *DB) DeleteSubscription(id uint) error {
+	err := db.Conn.Transaction(func(tx *gorm.DB) error {
+		res := tx.Delete(&db_models.WebhookSubscriptionDB{}, id)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ports.ErrSubscriptionNotFound
+		}
+		return tx.Unscoped().Where("subscription_id = ?", id).Delete(&db_models.WebhookDeliveryDB{}).Error
+	})
+	if err != nil && !errors.Is(err, ports.ErrSubscriptionNotFound) {
+		metrics.DBTransactionFailures.WithLabelValues("delete_subscription").Inc()
+	}
+	return err
+}
+
+// ClaimDueDeliveries selects and claims the deliveries in one transaction.
+// On PostgreSQL claims are taken one transaction at a time, under a
+// transaction-level advisory lock: a dispatcher then always sees the
+// claims of the others, which the hold-back check depends on.
+func (db *DB) ClaimDueDeliveries(now time.Time, limit int, ttl time.Duration) ([]models.WebhookDelivery, error) {
+	defer metrics.ObserveDB("claim_webhook_deliveries", time.Now())
+
+	var rows []db_models.WebhookDeliveryDB
+	err := db.Conn.Transaction(func(tx *gorm.DB) error {
+		if tx.Dialector.Name() == "postgres" {
+			if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext('webhook_delivery_claims'))").Error; err != nil {
+				return err
+			}
+		}
+
+		err := tx.
+			Where("status = ? AND next_attempt_at <= ?", models.WebhookDeliveryPending, now).
+			Where("claimed_until IS NULL OR claimed_until <= ?", now).
+			Where(`NOT EXISTS (
+				SELECT 1 FROM webhook_delivery_dbs earlier
+				WHERE earlier.subscription_id = webhook_delivery_dbs.subscription_id
+				AND earlier.id < webhook_delivery_dbs.id
+				AND earlier.deleted_at IS NULL
+				AND earlier.status = ?
+				AND (earlier.next_attempt_at > ? OR earlier.claimed_until > ?))`,
+				models.WebhookDeliveryPending, now, now).
+			Order("id ASC").
+			Limit(limit).
+			Find(&rows).Error
+		if err != nil || len(rows) == 0 {
+			return err
+		}
+
+		ids := make([]uint, len(rows))
+		for i, row := range rows {
+			ids[i] = row.ID
+		}
+		return tx.Model(&db_models.WebhookDeliveryDB{}).
+			Where("id IN ?", ids).
+			UpdateColumn("claimed_until", now.Add(ttl)).Error
+	})
+	if err != nil {
+		metrics.DBTransactionFailures.WithLabelValues("claim_webhook_deliveries").Inc()
+		return nil, err
+	}
+	return toDomainWebhookDeliveries(rows, db.Keys)
+}
+
+func (db *DB) ReleaseDeliveries(ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return db.Conn.Model(&db_models.WebhookDeliveryDB{}).
+		Where("id IN ?", ids).
+		UpdateColumn("claimed_until", nil).Error
+}
+
+// MarkDelivered removes the delivery: its payload carries the customer's
+// details and is of no use once the subscriber has it.
+func (db *DB) MarkDelivered(id uint) error {
+	return db.Conn.Unscoped().Delete(&db_models.WebhookDeliveryDB{}, id).Error
+}
+
+func (db *DB) MarkFailed(id uint, attempts int, nextAttemptAt time.Time, dead bool, lastErr string) error {
+	status := models.WebhookDeliveryPending
+	if dead {
+		status = models.WebhookDeliveryDead
+	}
+
+	return db.Conn.Model(&db_models.WebhookDeliveryDB{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status":          status,
+			"attempts":        attempts,
+			"next_attempt_at": nextAttemptAt,
+			"claimed_until":   nil,
+			"last_error":      lastErr,
+		}).Error
+}
+
+func (db *DB) ListDeadLetters(subscriptionID uint) ([]models.WebhookDelivery, error) {
+	var rows []db_models.WebhookDeliveryDB
+	err := db.Conn.
+		Where("subscription_id = ? AND status = ?", subscriptionID, models.WebhookDeliveryDead).
+		Order("id ASC").
+		Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	return toDomainWebhookDeliveries(rows, db.Keys)
+}
+
+func (db *DB) ReplayDeliveries(subscriptionID uint, deliveryIDs []uint) (int64, error) {
+	q := db.Conn.Model(&db_models.WebhookDeliveryDB{}).
+		Where("subscription_id = ? AND status = ?", subscriptionID, models.WebhookDeliveryDead)
+	if len(deliveryIDs) > 0 {
+		q = q.Where("id IN ?", deliveryIDs)
+	}
+
+	res := q.Updates(map[string]interface{}{
+		"status":          models.WebhookDeliveryPending,
+		"attempts":        0,
+		"next_attempt_at": time.Now().UTC(),
+		"claimed_until":   nil,
+	})
+	return res.RowsAffected, res.Error
+}
+
+func toDomainWebhookDeliveries(rows []db_models.WebhookDeliveryDB, keys *fieldcrypt.Keyring) ([]models.WebhookDelivery, error) {
+	deliveries := make([]models.WebhookDelivery, len(rows))
+	for i, row := range rows {
+		d, err := db_models.ToDomainWebhookDelivery(row, keys)
+		if err != nil {
+			return nil, fmt.Errorf("webhook delivery %d: %w", row.ID, err)
+		}
+		deliveries[i] = d
+	}
+	return deliveries, nil
+}
@@ -0,0 +1,213 @@
+// Package schemaregistry is a client of the Confluent Schema Registry REST
+// API, limited to what consumers need: looking schemas up by ID and by
+// subject version. Registered schemas never change, so every lookup is
+// cached for the life of the process.
+package schemaregistry
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"wb-tech-l0/internal/application/ports"
+)
+
+// Schema types as reported by the registry. An empty type means Avro.
+const (
+	TypeAvro     = "AVRO"
+	TypeProtobuf = "PROTOBUF"
+	TypeJSON     = "JSON"
+)
+
+// ErrSchemaNotFound is returned for IDs and subject versions the registry
+// does not know.
+var ErrSchemaNotFound = errors.New("schema not found")
+
+// ErrNotWireFormat is returned by ParseWireFormat for data without the
+// Confluent framing.
+var ErrNotWireFormat = errors.New("not in the schema registry wire format")
+
+// magicByte starts every message framed for the schema registry.
+const magicByte = 0
+
+// Reference points at another schema a schema depends on, e.g. an
+// imported .proto file.
+type Reference struct {
+	Name    string `json:"name"`
+	Subject string `json:"subject"`
+	Version int    `json:"version"`
+}
+
+type Schema struct {
+	ID         int
+	Type       string
+	Schema     string
+	References []Reference
+}
+
+type Config struct {
+	URL      string
+	Username string
+	Password string
+}
+
+// Client looks schemas up in the registry. It is safe for concurrent use.
+type Client struct {
+	http *http.Client
+	cfg  Config
+
+	mu        sync.RWMutex
+	byID      map[int]Schema
+	byVersion map[Reference]Schema
+}
+
+func NewClient(httpClient *http.Client, cfg Config) *Client {
+	return &Client{
+		http:      httpClient,
+		cfg:       Config{URL: strings.TrimRight(cfg.URL, "/"), Username: cfg.Username, Password: cfg.Password},
+		byID:      make(map[int]Schema),
+		byVersion: make(map[Reference]Schema),
+	}
+}
+
+// schemaResponse is the body of both lookups; the registry leaves
+// schemaType out for Avro schemas.
+type schemaResponse struct {
+	ID         int         `json:"id"`
+	Schema     string      `json:"schema"`
+	SchemaType string      `json:"schemaType"`
+	References []Reference `json:"references"`
+}
+
+// SchemaByID returns the schema registered under id.
+func (c *Client) SchemaByID(ctx context.Context, id int) (Schema, error) {
+	c.mu.RLock()
+	s, ok := c.byID[id]
+	c.mu.RUnlock()
+	if ok {
+		return s, nil
+	}
+
+	var resp schemaResponse
+	if err := c.get(ctx, "/schemas/ids/"+strconv.Itoa(id), &resp); err != nil {
+		return Schema{}, fmt.Errorf("schema %d: %w", id, err)
+	}
+	resp.ID = id
+	s = resp.schema()
+
+	c.mu.Lock()
+	c.byID[id] = s
+	c.mu.Unlock()
+	return s, nil
+}
+
+// SchemaByReference returns the schema version a reference points at.
+func (c *Client) SchemaByReference(ctx context.Context, ref Reference) (Schema, error) {
+	key := Reference{Subject: ref.Subject, Version: ref.Version}
+	c.mu.RLock()
+	s, ok := c.byVersion[key]
+	c.mu.RUnlock()
+	if ok {
+		return s, nil
+	}
+
+	var resp schemaResponse
+	path := "/subjects/" + url.PathEscape(ref.Subject) + "/versions/" + strconv.Itoa(ref.Version)
+	if err := c.get(ctx, path, &resp); err != nil {
+		return Schema{}, fmt.Errorf("subject %s version %d: %w", ref.Subject, ref.Version, err)
+	}
+	s = resp.schema()
+
+	c.mu.Lock()
+	c.byVersion[key] = s
+	c.byID[s.ID] = s
+	c.mu.Unlock()
+	return s, nil
+}
+
+func (r schemaResponse) schema() Schema {
+	typ := r.SchemaType
+	if typ == "" {
+		typ = TypeAvro
+	}
+	return Schema{ID: r.ID, Type: typ, Schema: r.Schema, References: r.References}
+}
+
+// get fetches path into v. Failures to reach the registry and server
+// errors are reported as ports.ErrUnavailable: they say nothing about the
+// message being decoded.
+func (c *Client) get(ctx context.Context, path string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.cfg.URL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.schemaregistry.v1+json, application/json")
+	if c.cfg.Username != "" {
+		req.SetBasicAuth(c.cfg.Username, c.cfg.Password)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: schema registry: %w", ports.ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return fmt.Errorf("%w: schema registry: %w", ports.ErrUnavailable, err)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		if err := json.Unmarshal(body, v); err != nil {
+			return fmt.Errorf("decode schema registry response: %w", err)
+		}
+		return nil
+	case resp.StatusCode == http.StatusNotFound:
+		return ErrSchemaNotFound
+	case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests:
+		return fmt.Errorf("%w: schema registry: status %d: %s", ports.ErrUnavailable, resp.StatusCode, errorMessage(body))
+	default:
+		return fmt.Errorf("schema registry: status %d: %s", resp.StatusCode, errorMessage(body))
+	}
+}
+
+func errorMessage(body []byte) string {
+	var e struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &e) == nil && e.Message != "" {
+		return e.Message
+	}
+	return strings.TrimSpace(string(body))
+}
+
+// IsWireFormat reports whether data looks framed for the registry.
+func IsWireFormat(data []byte) bool {
+	return len(data) >= 5 && data[0] == magicByte
+}
+
+// ParseWireFormat splits a message in the Confluent wire format: a zero
+// magic byte, the big-endian schema ID and the encoded payload.
+func ParseWireFormat(data []byte) (id int, payload []byte, err error) {
+	if !IsWireFormat(data) {
+		return 0, nil, ErrNotWireFormat
+	}
+	return int(binary.BigEndian.Uint32(data[1:5])), data[5:], nil
+}
+
+// AppendWireFormat frames payload for the registry; producers and tests
+// use it to build messages.
+func AppendWireFormat(dst []byte, id int, payload []byte) []byte {
+	dst = append(dst, magicByte)
+	dst = binary.BigEndian.AppendUint32(dst, uint32(id))
+	return append(dst, payload...)
+}
@@ -0,0 +1,95 @@
+package schemaregistry_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"wb-tech-l0/internal/application/ports"
+	"wb-tech-l0/internal/schemaregistry"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_CachesSchemas(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		user, pass, _ := r.BasicAuth()
+		assert.Equal(t, "reader", user)
+		assert.Equal(t, "secret", pass)
+
+		w.Header().Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+		switch r.URL.Path {
+		case "/schemas/ids/7":
+			_ = json.NewEncoder(w).Encode(map[string]any{"schema": `{"type":"string"}`})
+		case "/subjects/common.proto/versions/2":
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"subject": "common.proto", "id": 8, "version": 2,
+				"schemaType": "PROTOBUF", "schema": `syntax = "proto3";`,
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error_code":40403,"message":"Schema not found"}`))
+		}
+	}))
+	defer srv.Close()
+
+	c := schemaregistry.NewClient(srv.Client(), schemaregistry.Config{URL: srv.URL + "/", Username: "reader", Password: "secret"})
+	ctx := context.Background()
+
+	for range 2 {
+		s, err := c.SchemaByID(ctx, 7)
+		require.NoError(t, err)
+		assert.Equal(t, schemaregistry.Schema{ID: 7, Type: schemaregistry.TypeAvro, Schema: `{"type":"string"}`}, s)
+	}
+	assert.EqualValues(t, 1, requests.Load())
+
+	ref := schemaregistry.Reference{Name: "common.proto", Subject: "common.proto", Version: 2}
+	s, err := c.SchemaByReference(ctx, ref)
+	require.NoError(t, err)
+	assert.Equal(t, schemaregistry.TypeProtobuf, s.Type)
+	assert.Equal(t, 8, s.ID)
+	// The version is cached under its ID as well.
+	_, err = c.SchemaByID(ctx, 8)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, requests.Load())
+
+	_, err = c.SchemaByID(ctx, 9)
+	assert.ErrorIs(t, err, schemaregistry.ErrSchemaNotFound)
+}
+
+func TestClient_UnavailableRegistry(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte(`{"error_code":50003,"message":"Error while forwarding the request to the leader"}`))
+	}))
+	c := schemaregistry.NewClient(srv.Client(), schemaregistry.Config{URL: srv.URL})
+
+	_, err := c.SchemaByID(context.Background(), 1)
+	assert.ErrorIs(t, err, ports.ErrUnavailable)
+	assert.ErrorContains(t, err, "forwarding the request")
+
+	srv.Close()
+	_, err = c.SchemaByID(context.Background(), 1)
+	assert.ErrorIs(t, err, ports.ErrUnavailable)
+}
+
+func TestWireFormat(t *testing.T) {
+	data := schemaregistry.AppendWireFormat(nil, 258, []byte("payload"))
+	assert.Equal(t, []byte{0, 0, 0, 1, 2}, data[:5])
+
+	id, payload, err := schemaregistry.ParseWireFormat(data)
+	require.NoError(t, err)
+	assert.Equal(t, 258, id)
+	assert.Equal(t, []byte("payload"), payload)
+
+	_, _, err = schemaregistry.ParseWireFormat([]byte(`{"order_uid":"x"}`))
+	assert.ErrorIs(t, err, schemaregistry.ErrNotWireFormat)
+	_, _, err = schemaregistry.ParseWireFormat([]byte{0, 0, 1})
+	assert.ErrorIs(t, err, schemaregistry.ErrNotWireFormat)
+}
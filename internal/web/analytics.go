@@ -0,0 +1,126 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"html/template"
+	"net/http"
+	"time"
+
+	"wb-tech-l0/internal/application/ports"
+	"wb-tech-l0/internal/models"
+)
+
+// AnalyticsHandler renders the analytics dashboard from the use case
+// reports.
+type AnalyticsHandler struct {
+	analyticsUseCase ports.AnalyticsUseCase
+}
+
+func NewAnalyticsHandler(analyticsUseCase ports.AnalyticsUseCase) *AnalyticsHandler {
+	return &AnalyticsHandler{analyticsUseCase: analyticsUseCase}
+}
+
+type productRanking struct {
+	Title  string
+	Report models.TopProductsReport
+}
+
+type dashboardPage struct {
+	// From and To fill the date inputs of the filter form; To is the last
+	// day included.
+	From, To   string
+	Interval   string
+	Revenue    models.RevenueReport
+	Breakdowns []models.BreakdownReport
+	Basket     models.BasketReport
+	Rankings   []productRanking
+	Error      string
+}
+
+// DashboardHandler shows revenue, breakdowns, baskets and top products for
+// the from/to date range of the query, the last 30 days by default.
+func (h *AnalyticsHandler) DashboardHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	page := dashboardPage{Interval: query.Get("interval")}
+
+	status, err := h.fill(r.Context(), &page, query.Get("from"), query.Get("to"))
+	if err != nil {
+		page.Error = dashboardError(err)
+	}
+
+	tmpl := template.Must(template.ParseFiles("templates/analytics.html"))
+	w.Header().Set("Content-Type", contentTypeHTML)
+	w.WriteHeader(status)
+	_ = tmpl.Execute(w, page)
+}
+
+func (h *AnalyticsHandler) fill(ctx context.Context, page *dashboardPage, from, to string) (int, error) {
+	var rng ports.AnalyticsRange
+	var err error
+	if rng.From, err = ports.ParseAnalyticsTime(from); err != nil {
+		return http.StatusBadRequest, ports.ErrInvalidAnalyticsQuery
+	}
+	if rng.To, err = ports.ParseAnalyticsTime(to); err != nil {
+		return http.StatusBadRequest, ports.ErrInvalidAnalyticsQuery
+	}
+	// The form asks for the last day included.
+	if !rng.To.IsZero() {
+		rng.To = rng.To.AddDate(0, 0, 1)
+	}
+
+	if page.Revenue, err = h.analyticsUseCase.Revenue(ctx, ports.RevenueQuery{AnalyticsRange: rng, Interval: page.Interval}); err != nil {
+		return dashboardStatus(err), err
+	}
+	rng = ports.AnalyticsRange{From: page.Revenue.From, To: page.Revenue.To}
+	page.From = rng.From.Format(time.DateOnly)
+	page.To = rng.To.AddDate(0, 0, -1).Format(time.DateOnly)
+	page.Interval = page.Revenue.Interval
+
+	for _, by := range ports.BreakdownDimensions {
+		report, err := h.analyticsUseCase.Breakdown(ctx, ports.BreakdownQuery{AnalyticsRange: rng, By: by})
+		if err != nil {
+			return dashboardStatus(err), err
+		}
+		page.Breakdowns = append(page.Breakdowns, report)
+	}
+
+	if page.Basket, err = h.analyticsUseCase.Basket(ctx, rng); err != nil {
+		return dashboardStatus(err), err
+	}
+
+	for _, ranking := range []struct{ title, by, metric string }{
+		{"Бренды по выручке", "brand", "revenue"},
+		{"Бренды по количеству", "brand", "units"},
+		{"Артикулы (nm_id) по выручке", "nm_id", "revenue"},
+		{"Артикулы (nm_id) по количеству", "nm_id", "units"},
+	} {
+		report, err := h.analyticsUseCase.TopProducts(ctx, ports.TopProductsQuery{AnalyticsRange: rng, By: ranking.by, Metric: ranking.metric})
+		if err != nil {
+			return dashboardStatus(err), err
+		}
+		page.Rankings = append(page.Rankings, productRanking{Title: ranking.title, Report: report})
+	}
+	return http.StatusOK, nil
+}
+
+func dashboardStatus(err error) int {
+	status, _ := errorPage(err)
+	if errors.Is(err, ports.ErrInvalidAnalyticsQuery) {
+		status = http.StatusBadRequest
+	}
+	return status
+}
+
+// dashboardError is the message shown for a failed dashboard; internal
+// error messages are not shown to visitors.
+func dashboardError(err error) string {
+	switch {
+	case errors.Is(err, ports.ErrInvalidAnalyticsQuery):
+		return "Некорректные параметры: даты в формате ГГГГ-ММ-ДД, начало не позже конца, интервал — день или неделя"
+	case errors.Is(err, ports.ErrUnavailable), errors.Is(err, context.DeadlineExceeded):
+		return "Сервис временно недоступен, попробуйте позже"
+	default:
+		return "Не удалось построить отчёт"
+	}
+}
@@ -1,12 +1,19 @@
 package web
 
 import (
+	"context"
+	"errors"
 	"html/template"
 	"net/http"
 
 	"wb-tech-l0/internal/application/ports"
+	"wb-tech-l0/internal/projection"
 )
 
+// contentTypeHTML is set explicitly so the media type of the pages does
+// not depend on content sniffing.
+const contentTypeHTML = "text/html; charset=utf-8"
+
 // WebHandler is an HTTP adapter that talks only to the use case layer.
 type WebHandler struct {
 	orderUseCase ports.OrderUseCase
@@ -23,6 +30,7 @@ func (h *WebHandler) IndexHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	tmpl := template.Must(template.ParseFiles("templates/index.html"))
+	w.Header().Set("Content-Type", contentTypeHTML)
 	_ = tmpl.Execute(w, nil)
 }
 
@@ -33,15 +41,34 @@ func (h *WebHandler) OrderPageHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	order, err := h.orderUseCase.GetOrder(orderUID)
+	order, err := h.orderUseCase.GetOrder(r.Context(), orderUID)
 	if err != nil {
+		status, message := errorPage(err)
 		tmpl := template.Must(template.ParseFiles("templates/index.html"))
+		w.Header().Set("Content-Type", contentTypeHTML)
+		w.WriteHeader(status)
 		_ = tmpl.Execute(w, map[string]string{
-			"Error": "Заказ не найден: " + err.Error(),
+			"Error": message,
 		})
 		return
 	}
 
 	tmpl := template.Must(template.ParseFiles("templates/order.html"))
-	_ = tmpl.Execute(w, order)
+	w.Header().Set("Content-Type", contentTypeHTML)
+	_ = tmpl.Execute(w, projection.Order(r.Context(), order))
+}
+
+// errorPage picks the status and the message shown for a failed lookup.
+// Internal error messages are not shown to visitors.
+func errorPage(err error) (int, string) {
+	switch {
+	case errors.Is(err, ports.ErrOrderNotFound):
+		return http.StatusNotFound, "Заказ не найден"
+	case errors.Is(err, ports.ErrInvalidOrder):
+		return http.StatusBadRequest, "Некорректный UID заказа"
+	case errors.Is(err, ports.ErrUnavailable), errors.Is(err, context.DeadlineExceeded):
+		return http.StatusServiceUnavailable, "Сервис временно недоступен, попробуйте позже"
+	default:
+		return http.StatusInternalServerError, "Не удалось загрузить заказ"
+	}
 }
@@ -0,0 +1,6 @@
+// Package api holds Go code generated from the protobuf definitions in
+// api/proto. Regenerate it with `go generate ./pkg/api` (requires buf,
+// protoc-gen-go and protoc-gen-go-grpc in PATH).
+package api
+
+//go:generate sh -c "cd ../.. && buf generate"
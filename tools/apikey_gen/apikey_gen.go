@@ -0,0 +1,36 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+
+	"wb-tech-l0/internal/auth"
+)
+
+// apikey_gen prints a new API key together with the hash the service
+// stores, as an AUTH_API_KEYS entry and as a row for the api_key_dbs table.
+// The key itself is shown only once and never stored.
+func main() {
+	name := flag.String("name", "", "key name, e.g. the client or team using it")
+	scopes := flag.String("scopes", auth.ScopeOrdersRead, "space-separated scopes")
+	flag.Parse()
+
+	if *name == "" {
+		log.Fatal("-name is required")
+	}
+
+	key, hash, err := auth.GenerateAPIKey()
+	if err != nil {
+		log.Fatalf("generate key: %v", err)
+	}
+	scopeList := strings.Fields(*scopes)
+
+	fmt.Printf("API key (give it to the client, it is not stored):\n  %s\n\n", key)
+	fmt.Printf("SHA-256:\n  %s\n\n", hash)
+	fmt.Printf("AUTH_API_KEYS entry:\n  {\"name\":%q,\"sha256\":%q,\"scopes\":[\"%s\"]}\n\n",
+		*name, hash, strings.Join(scopeList, `","`))
+	fmt.Printf("SQL:\n  INSERT INTO api_key_dbs (created_at, updated_at, name, key_hash, scopes)\n  VALUES (now(), now(), '%s', '%s', '%s');\n",
+		strings.ReplaceAll(*name, "'", "''"), hash, strings.Join(scopeList, " "))
+}
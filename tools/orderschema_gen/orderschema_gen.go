@@ -0,0 +1,29 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"wb-tech-l0/internal/orderschema"
+)
+
+// orderschema_gen writes the JSON Schema of the order message. It is run
+// by `go generate ./internal/orderschema`; commit the result.
+func main() {
+	out := flag.String("o", "", "output file, stdout when empty")
+	flag.Parse()
+
+	schema, err := orderschema.Generate()
+	if err != nil {
+		log.Fatalf("generate schema: %v", err)
+	}
+
+	if *out == "" {
+		_, _ = os.Stdout.Write(schema)
+		return
+	}
+	if err := os.WriteFile(*out, schema, 0o644); err != nil {
+		log.Fatalf("write schema: %v", err)
+	}
+}